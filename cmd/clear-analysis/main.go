@@ -17,9 +17,8 @@ func main() {
 	log.Println()
 
 	database, err := db.New(db.Config{
-		DataDir:        *dataDir,
-		DBFile:         "vault.db",
-		MigrationsPath: "migrations",
+		DataDir: *dataDir,
+		DBFile:  "vault.db",
 	})
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
@@ -20,9 +20,8 @@ func main() {
 
 	// Initialize database
 	database, err := db.New(db.Config{
-		DataDir:        *dataDir,
-		DBFile:         "vault.db",
-		MigrationsPath: "migrations",
+		DataDir: *dataDir,
+		DBFile:  "vault.db",
 	})
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"ramiro-uziel/vault/internal/db"
+	"ramiro-uziel/vault/internal/logger"
+	"ramiro-uziel/vault/internal/service"
+	"ramiro-uziel/vault/internal/transcoding"
+)
+
+// runFsck checks that every file referenced by the database exists in storage
+// and that storage holds nothing the database does not know about. It only
+// needs storage settings, so it runs without the auth secrets the server wants.
+func runFsck(args []string) int {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	verifyHashes := flags.Bool("verify-hashes", false, "re-hash source files and compare against recorded checksums")
+	retranscode := flags.Bool("retranscode", false, "re-queue transcoding for missing or damaged derived files")
+	deleteOrphans := flags.Bool("delete-orphans", false, "delete files in storage that no record references")
+	markBroken := flags.Bool("mark-broken", false, "flag unrecoverable files so they are no longer served")
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Keep stdout clean for the report.
+	slog.SetDefault(slog.New(logger.NewPrettyHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+	})))
+
+	config := loadStorageConfig()
+
+	database, err := db.New(db.Config{
+		DataDir: config.DataDir,
+		DBFile:  "vault.db",
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fsck: failed to open database: %v\n", err)
+		return 1
+	}
+	defer database.Close()
+
+	keyring, err := newKeyring(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fsck: %v\n", err)
+		return 1
+	}
+	storageAdapter, err := newStorage(config, keyring)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fsck: failed to initialize storage: %v\n", err)
+		return 1
+	}
+
+	transcoder := transcoding.NewTranscoder(database, storageAdapter, 2)
+	transcoder.Start()
+
+	fsck := service.NewFsckService(database, storageAdapter, transcoder)
+	report, err := fsck.Check(context.Background(), service.FsckOptions{
+		VerifyHashes:  *verifyHashes,
+		Retranscode:   *retranscode,
+		DeleteOrphans: *deleteOrphans,
+		MarkBroken:    *markBroken,
+	})
+	// Let queued retranscodes finish before the database is closed.
+	transcoder.Drain()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fsck: %v\n", err)
+		return 1
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return 1
+		}
+	} else {
+		printFsckReport(report)
+	}
+
+	if len(report.Issues) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func printFsckReport(report *service.FsckReport) {
+	for _, issue := range report.Issues {
+		line := fmt.Sprintf("%-14s %s", issue.Kind, issue.Path)
+		if issue.Kind == service.FsckSizeMismatch {
+			line += fmt.Sprintf(" (expected %d bytes, found %d)", issue.ExpectedSize, issue.ActualSize)
+		}
+		if issue.Repair != "" {
+			line += " -> " + issue.Repair
+		}
+		if issue.RepairError != "" {
+			line += " failed: " + issue.RepairError
+		}
+		fmt.Println(line)
+	}
+	for _, publicID := range report.OrphanedProjects {
+		fmt.Printf("%-14s projects/%s\n", "orphaned_dir", publicID)
+	}
+
+	fmt.Printf("\nchecked %d files and %d stored objects: %d missing, %d size mismatches, %d hash mismatches, %d orphaned\n",
+		report.CheckedFiles,
+		report.CheckedObjects,
+		report.Count(service.FsckMissing),
+		report.Count(service.FsckSizeMismatch),
+		report.Count(service.FsckHashMismatch),
+		report.Count(service.FsckOrphaned),
+	)
+}
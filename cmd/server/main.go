@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,7 +21,9 @@ import (
 	"ramiro-uziel/vault/internal/handlers/tracks"
 	"ramiro-uziel/vault/internal/httputil"
 	"ramiro-uziel/vault/internal/logger"
+	"ramiro-uziel/vault/internal/mail"
 	"ramiro-uziel/vault/internal/middleware"
+	"ramiro-uziel/vault/internal/oidc"
 	"ramiro-uziel/vault/internal/service"
 	"ramiro-uziel/vault/internal/storage"
 	"ramiro-uziel/vault/internal/transcoding"
@@ -33,6 +38,33 @@ type Config struct {
 	DataDir            string
 	AuthConfig         auth.Config
 	CORSAllowedOrigins []string
+	TrustedProxies     []*net.IPNet
+	TrustedProxyHeader string
+	StorageBackend     string
+	S3Config           storage.S3Config
+	EncryptionKey      string
+	PreviousKeys       []string
+	TrashRetention     time.Duration
+	CompactionInterval time.Duration
+	AuditRetention     time.Duration
+	TakeoutTTL         time.Duration
+	BackupDestination  string
+	BackupDir          string
+	BackupS3Config     storage.S3Config
+	BackupSFTPConfig   storage.SFTPConfig
+	BackupPolicy       service.BackupPolicy
+	OIDC               service.OIDCConfig
+	ForwardAuth        ForwardAuthConfig
+	Email              service.EmailConfig
+}
+
+// ForwardAuthConfig turns on trusting an authenticating reverse proxy's user
+// header. It is off without trusted proxies.
+type ForwardAuthConfig struct {
+	TrustedProxies []*net.IPNet
+	UserHeader     string
+	EmailHeader    string
+	AutoCreate     bool
 }
 
 func loadConfig() Config {
@@ -43,10 +75,7 @@ func loadConfig() Config {
 		port = "8080"
 	}
 
-	dataDir := os.Getenv("DATA_DIR")
-	if dataDir == "" {
-		dataDir = "./data"
-	}
+	dataDir := dataDirFromEnv()
 
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" || jwtSecret == "change-this-secret-key" {
@@ -71,6 +100,12 @@ func loadConfig() Config {
 		cookieSameSite = "Lax"
 	}
 
+	webAuthnRPID := os.Getenv("WEBAUTHN_RP_ID")
+	webAuthnOrigins := parseCommaEnv("WEBAUTHN_ORIGINS")
+	if webAuthnRPID != "" && len(webAuthnOrigins) == 0 {
+		webAuthnOrigins = []string{"https://" + webAuthnRPID}
+	}
+
 	tokenPepper := os.Getenv("TOKEN_PEPPER")
 	if tokenPepper == "" {
 		slog.Warn("TOKEN_PEPPER is not set; refresh/reset tokens are hashed without a pepper")
@@ -89,8 +124,279 @@ func loadConfig() Config {
 			CookieDomain:        cookieDomain,
 			CookieSecure:        cookieSecure,
 			CookieSameSite:      cookieSameSite,
+			WebAuthnRPID:        webAuthnRPID,
+			WebAuthnOrigins:     webAuthnOrigins,
 		},
 		CORSAllowedOrigins: parseCommaEnv("CORS_ALLOWED_ORIGINS"),
+		TrustedProxies:     trustedProxiesFromEnv(),
+		TrustedProxyHeader: trustedProxyHeaderFromEnv(),
+		StorageBackend:     storageBackendFromEnv(),
+		S3Config:           s3ConfigFromEnv(signedURLTTL),
+		EncryptionKey:      os.Getenv("ENCRYPTION_KEY"),
+		PreviousKeys:       parseCommaEnv("ENCRYPTION_PREVIOUS_KEYS"),
+		TrashRetention:     getDurationEnv("TRASH_RETENTION", 30*24*time.Hour),
+		CompactionInterval: getDurationEnv("COMPACTION_INTERVAL", time.Hour),
+		AuditRetention:     getDurationEnv("AUDIT_RETENTION", 365*24*time.Hour),
+		TakeoutTTL:         getDurationEnv("TAKEOUT_TTL", 48*time.Hour),
+		BackupDestination:  backupDestinationFromEnv(),
+		BackupDir:          backupDirFromEnv(dataDir),
+		BackupS3Config:     backupS3ConfigFromEnv(),
+		BackupSFTPConfig:   backupSFTPConfigFromEnv(),
+		BackupPolicy: service.BackupPolicy{
+			Interval:   getDurationEnv("BACKUP_INTERVAL", 0),
+			KeepDaily:  getIntEnv("BACKUP_KEEP_DAILY", 7),
+			KeepWeekly: getIntEnv("BACKUP_KEEP_WEEKLY", 4),
+			FullEvery:  getIntEnv("BACKUP_FULL_EVERY", 7),
+		},
+		OIDC:        oidcConfigFromEnv(),
+		ForwardAuth: forwardAuthConfigFromEnv(),
+		Email:       emailConfigFromEnv(),
+	}
+}
+
+// loadStorageConfig reads only the settings needed to reach stored files and
+// backups, for maintenance commands that run without the server's auth
+// secrets.
+func loadStorageConfig() Config {
+	_ = godotenv.Load()
+	dataDir := dataDirFromEnv()
+	return Config{
+		DataDir:           dataDir,
+		StorageBackend:    storageBackendFromEnv(),
+		S3Config:          s3ConfigFromEnv(getDurationEnv("SIGNED_URL_TTL", 5*time.Minute)),
+		EncryptionKey:     os.Getenv("ENCRYPTION_KEY"),
+		PreviousKeys:      parseCommaEnv("ENCRYPTION_PREVIOUS_KEYS"),
+		BackupDestination: backupDestinationFromEnv(),
+		BackupDir:         backupDirFromEnv(dataDir),
+		BackupS3Config:    backupS3ConfigFromEnv(),
+		BackupSFTPConfig:  backupSFTPConfigFromEnv(),
+	}
+}
+
+func oidcConfigFromEnv() service.OIDCConfig {
+	scopes := strings.Fields(os.Getenv("OIDC_SCOPES"))
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	return service.OIDCConfig{
+		Config: oidc.Config{
+			Issuer:       os.Getenv("OIDC_ISSUER"),
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Scopes:       scopes,
+		},
+		ProviderName:   os.Getenv("OIDC_PROVIDER_NAME"),
+		AllowedDomains: parseCommaEnv("OIDC_ALLOWED_DOMAINS"),
+		AdminClaim:     os.Getenv("OIDC_ADMIN_CLAIM"),
+		AdminValues:    parseCommaEnv("OIDC_ADMIN_VALUES"),
+	}
+}
+
+func trustedProxiesFromEnv() []*net.IPNet {
+	trustedProxies, err := middleware.ParseCIDRs(parseCommaEnv("TRUSTED_PROXIES"))
+	if err != nil {
+		slog.Error("TRUSTED_PROXIES is invalid", "error", err)
+		os.Exit(1)
+	}
+	return trustedProxies
+}
+
+func trustedProxyHeaderFromEnv() string {
+	header, err := middleware.ParseProxyHeader(os.Getenv("TRUSTED_PROXY_HEADER"))
+	if err != nil {
+		slog.Error("TRUSTED_PROXY_HEADER is invalid", "error", err)
+		os.Exit(1)
+	}
+	return header
+}
+
+func forwardAuthConfigFromEnv() ForwardAuthConfig {
+	trustedProxies, err := middleware.ParseCIDRs(parseCommaEnv("FORWARD_AUTH_TRUSTED_PROXIES"))
+	if err != nil {
+		slog.Error("FORWARD_AUTH_TRUSTED_PROXIES is invalid", "error", err)
+		os.Exit(1)
+	}
+
+	userHeader := os.Getenv("FORWARD_AUTH_USER_HEADER")
+	if userHeader == "" {
+		userHeader = "Remote-User"
+	}
+	emailHeader := os.Getenv("FORWARD_AUTH_EMAIL_HEADER")
+	if emailHeader == "" {
+		emailHeader = "Remote-Email"
+	}
+
+	return ForwardAuthConfig{
+		TrustedProxies: trustedProxies,
+		UserHeader:     userHeader,
+		EmailHeader:    emailHeader,
+		AutoCreate:     getBoolEnv("FORWARD_AUTH_AUTO_CREATE", false),
+	}
+}
+
+// emailConfigFromEnv leaves email off without SMTP_HOST. With it, the rest
+// of the settings must be usable, since invites would otherwise queue up
+// undeliverable.
+func emailConfigFromEnv() service.EmailConfig {
+	security := strings.ToLower(strings.TrimSpace(os.Getenv("SMTP_SECURITY")))
+	if security == "" {
+		security = mail.SecurityStartTLS
+	}
+	defaultPort := 587
+	if security == mail.SecurityTLS {
+		defaultPort = 465
+	}
+
+	config := service.EmailConfig{
+		SMTP: mail.Config{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     getIntEnv("SMTP_PORT", defaultPort),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+			Security: security,
+		},
+		PublicURL: os.Getenv("PUBLIC_URL"),
+	}
+	if !config.SMTP.Enabled() {
+		return config
+	}
+
+	if err := config.SMTP.Validate(); err != nil {
+		slog.Error("SMTP configuration is invalid", "error", err)
+		os.Exit(1)
+	}
+	publicURL, err := url.Parse(config.PublicURL)
+	if err != nil || (publicURL.Scheme != "http" && publicURL.Scheme != "https") || publicURL.Host == "" {
+		slog.Error("PUBLIC_URL must be the app's http(s) URL to send email", "value", config.PublicURL)
+		os.Exit(1)
+	}
+	return config
+}
+
+func dataDirFromEnv() string {
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "./data"
+	}
+	return dataDir
+}
+
+func storageBackendFromEnv() string {
+	storageBackend := strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_BACKEND")))
+	if storageBackend == "" {
+		storageBackend = "filesystem"
+	}
+	return storageBackend
+}
+
+func s3ConfigFromEnv(presignTTL time.Duration) storage.S3Config {
+	return storage.S3Config{
+		Endpoint:         os.Getenv("S3_ENDPOINT"),
+		Region:           os.Getenv("S3_REGION"),
+		Bucket:           os.Getenv("S3_BUCKET"),
+		AccessKeyID:      os.Getenv("S3_ACCESS_KEY_ID"),
+		SecretAccessKey:  os.Getenv("S3_SECRET_ACCESS_KEY"),
+		Prefix:           os.Getenv("S3_PREFIX"),
+		PathStyle:        getBoolEnv("S3_PATH_STYLE", true),
+		PresignRedirects: getBoolEnv("S3_PRESIGN_REDIRECTS", false),
+		PresignTTL:       getDurationEnv("S3_PRESIGN_TTL", presignTTL),
+	}
+}
+
+func backupDestinationFromEnv() string {
+	destination := strings.ToLower(strings.TrimSpace(os.Getenv("BACKUP_DESTINATION")))
+	if destination == "" {
+		destination = "local"
+	}
+	return destination
+}
+
+func backupDirFromEnv(dataDir string) string {
+	if dir := os.Getenv("BACKUP_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(dataDir, "backups")
+}
+
+// backupS3ConfigFromEnv falls back to the storage bucket's settings, so
+// backups can share it under their own prefix.
+func backupS3ConfigFromEnv() storage.S3Config {
+	prefix := os.Getenv("BACKUP_S3_PREFIX")
+	if prefix == "" {
+		prefix = "backups"
+	}
+	return storage.S3Config{
+		Endpoint:        getEnvFallback("BACKUP_S3_ENDPOINT", "S3_ENDPOINT"),
+		Region:          getEnvFallback("BACKUP_S3_REGION", "S3_REGION"),
+		Bucket:          getEnvFallback("BACKUP_S3_BUCKET", "S3_BUCKET"),
+		AccessKeyID:     getEnvFallback("BACKUP_S3_ACCESS_KEY_ID", "S3_ACCESS_KEY_ID"),
+		SecretAccessKey: getEnvFallback("BACKUP_S3_SECRET_ACCESS_KEY", "S3_SECRET_ACCESS_KEY"),
+		Prefix:          prefix,
+		PathStyle:       getBoolEnv("BACKUP_S3_PATH_STYLE", getBoolEnv("S3_PATH_STYLE", true)),
+	}
+}
+
+func backupSFTPConfigFromEnv() storage.SFTPConfig {
+	return storage.SFTPConfig{
+		Addr:           os.Getenv("BACKUP_SFTP_ADDR"),
+		User:           os.Getenv("BACKUP_SFTP_USER"),
+		Password:       os.Getenv("BACKUP_SFTP_PASSWORD"),
+		KeyFile:        os.Getenv("BACKUP_SFTP_KEY_FILE"),
+		KnownHostsFile: os.Getenv("BACKUP_SFTP_KNOWN_HOSTS"),
+		Dir:            os.Getenv("BACKUP_SFTP_DIR"),
+	}
+}
+
+func newBackupDestination(config Config) (storage.BackupDestination, error) {
+	switch config.BackupDestination {
+	case "local":
+		return storage.NewLocalBackups(config.BackupDir)
+	case "s3":
+		return storage.NewS3Backups(config.BackupS3Config)
+	case "sftp":
+		return storage.NewSFTPBackups(config.BackupSFTPConfig)
+	default:
+		return nil, fmt.Errorf("unknown backup destination %q", config.BackupDestination)
+	}
+}
+
+// newKeyring returns nil when encryption at rest is not configured.
+func newKeyring(config Config) (*storage.Keyring, error) {
+	if config.EncryptionKey == "" {
+		return nil, nil
+	}
+	current, err := storage.ParseMasterKey(config.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("ENCRYPTION_KEY: %w", err)
+	}
+	previous := make([][]byte, 0, len(config.PreviousKeys))
+	for _, value := range config.PreviousKeys {
+		key, err := storage.ParseMasterKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("ENCRYPTION_PREVIOUS_KEYS: %w", err)
+		}
+		previous = append(previous, key)
+	}
+	return storage.NewKeyring(current, previous...)
+}
+
+func newStorage(config Config, keyring *storage.Keyring) (storage.Storage, error) {
+	switch config.StorageBackend {
+	case "filesystem":
+		fs := storage.NewFilesystemStorage(config.DataDir)
+		if keyring != nil {
+			fs.SetKeyring(keyring)
+		}
+		return fs, nil
+	case "s3":
+		if keyring != nil {
+			return nil, fmt.Errorf("encryption at rest is only supported by the filesystem backend")
+		}
+		return storage.NewS3Storage(config.S3Config)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", config.StorageBackend)
 	}
 }
 
@@ -123,6 +429,27 @@ func getDurationEnv(key string, fallback time.Duration) time.Duration {
 	return parsed
 }
 
+func getIntEnv(key string, fallback int) int {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Invalid integer env, using fallback", "key", key, "value", value)
+		return fallback
+	}
+	return parsed
+}
+
+// getEnvFallback reads key, or fallbackKey when key is unset.
+func getEnvFallback(key, fallbackKey string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return os.Getenv(fallbackKey)
+}
+
 func getBoolEnv(key string, fallback bool) bool {
 	value := strings.TrimSpace(strings.ToLower(os.Getenv(key)))
 	if value == "" {
@@ -184,18 +511,32 @@ func main() {
 	slog.SetDefault(slog.New(logger.NewPrettyHandler(os.Stdout, &slog.HandlerOptions{
 		Level: logLevel,
 	})))
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fsck":
+			os.Exit(runFsck(os.Args[2:]))
+		case "rotate-key":
+			os.Exit(runRotateKey(os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "restore":
+			os.Exit(runRestore(os.Args[2:]))
+		}
+	}
+
 	slog.Info("Starting Vault server")
 
 	config := loadConfig()
 	slog.Info("Configuration loaded",
 		"port", config.Port,
 		"data_dir", config.DataDir,
+		"storage", config.StorageBackend,
 	)
 
 	database, err := db.New(db.Config{
-		DataDir:        config.DataDir,
-		DBFile:         "vault.db",
-		MigrationsPath: "migrations",
+		DataDir: config.DataDir,
+		DBFile:  "vault.db",
 	})
 	if err != nil {
 		slog.Error("Failed to initialize database", "error", err)
@@ -207,14 +548,24 @@ func main() {
 	wsHub := handlers.NewWSHub()
 	wsHandler := handlers.NewWebSocketHandler(wsHub)
 
+	keyring, err := newKeyring(config)
+	if err != nil {
+		slog.Error("Failed to initialize encryption", "error", err)
+		os.Exit(1)
+	}
+	storageAdapter, err := newStorage(config, keyring)
+	if err != nil {
+		slog.Error("Failed to initialize storage", "error", err)
+		os.Exit(1)
+	}
+
 	// WORKERS
-	transcoder := transcoding.NewTranscoder(database, 2)
+	transcoder := transcoding.NewTranscoder(database, storageAdapter, 2)
 	transcoder.SetNotifier(wsHub)
 	transcoder.Start()
 	defer transcoder.Stop()
 	slog.Info("Transcoding system initialized", "workers", 2)
 
-	storageAdapter := storage.NewFilesystemStorage(config.DataDir)
 	svc := service.NewService(database, storageAdapter)
 
 	go func() {
@@ -226,24 +577,68 @@ func main() {
 		}
 	}()
 
+	auditService := service.NewAuditService(database, config.AuditRetention)
+	go service.RunAuditPurge(context.Background(), auditService, time.Hour)
+
+	trashService := service.NewTrashService(database, storageAdapter, config.TrashRetention, auditService)
+	go service.RunTrashPurge(context.Background(), trashService, time.Hour)
+
+	backupDestination, err := newBackupDestination(config)
+	if err != nil {
+		slog.Error("Failed to initialize backup destination", "error", err)
+		os.Exit(1)
+	}
+	backupService := service.NewBackupService(database, config.DataDir, storageAdapter, keyring, backupDestination, config.BackupPolicy)
+	go service.RunBackups(context.Background(), backupService)
+
+	takeoutService := service.NewTakeoutService(database, storageAdapter, config.DataDir, config.TakeoutTTL)
+	go service.RunTakeoutPurge(context.Background(), takeoutService, time.Hour)
+
+	compactionService := service.NewCompactionService(database, storageAdapter)
+	go service.RunCompaction(context.Background(), compactionService, config.CompactionInterval)
+
 	authService := service.NewAuthService(database, config.AuthConfig)
 
-	authHandler := handlers.NewAuthHandler(authService, config.AuthConfig)
-	adminHandler := handlers.NewAdminHandler(database, config.AuthConfig)
+	lockoutService := service.NewLockoutService(database)
+	go service.RunLockoutPurge(context.Background(), lockoutService, time.Hour)
+
+	emailService := service.NewEmailService(database, config.Email)
+	if emailService.Enabled() {
+		slog.Info("Email delivery enabled", "smtp_host", config.Email.SMTP.Host, "smtp_port", config.Email.SMTP.Port)
+	}
+	go service.RunEmailQueue(context.Background(), emailService, time.Minute)
+
+	twoFactorService := service.NewTwoFactorService(database, config.AuthConfig)
+	apiTokenService := service.NewAPITokenService(database, config.AuthConfig)
+	sessionService := service.NewSessionService(database)
+	oidcService := service.NewOIDCService(database, config.AuthConfig, config.OIDC)
+	if oidcService.Enabled() {
+		slog.Info("Single sign-on enabled", "issuer", config.OIDC.Issuer)
+	}
+
+	authHandler := handlers.NewAuthHandler(authService, twoFactorService, oidcService, emailService, lockoutService, config.AuthConfig, auditService)
+	adminHandler := handlers.NewAdminHandler(database, twoFactorService, emailService, lockoutService, config.AuthConfig, auditService)
 	prefsHandler := handlers.NewPreferencesHandler(database)
-	statsHandler := handlers.NewStatsHandler(database, CommitSHA)
-	instanceHandler := handlers.NewInstanceHandler(database, config.DataDir, wsHub)
+	statsHandler := handlers.NewStatsHandler(database, CommitSHA, auditService)
+	instanceHandler := handlers.NewInstanceHandler(database, config.DataDir, storageAdapter, keyring, wsHub, auditService)
 	mediaHandler := handlers.NewMediaHandler(config.AuthConfig)
-	projectsHandler := projects.NewProjectsHandler(svc.Projects, database, config.DataDir)
-	foldersHandler := handlers.NewFoldersHandler(database)
-	tracksHandler := tracks.NewTracksHandler(database, storageAdapter, transcoder)
-	versionsHandler := handlers.NewVersionsHandler(database, storageAdapter, transcoder)
-	streamingHandler := handlers.NewStreamingHandler(database)
-	sharingHandler := sharing.NewSharingHandler(database, storageAdapter)
+	projectsHandler := projects.NewProjectsHandler(svc.Projects, database, storageAdapter, transcoder, auditService)
+	foldersHandler := handlers.NewFoldersHandler(database, trashService, auditService)
+	tracksHandler := tracks.NewTracksHandler(database, storageAdapter, transcoder, auditService)
+	versionsHandler := handlers.NewVersionsHandler(database, storageAdapter, transcoder, auditService)
+	streamingHandler := handlers.NewStreamingHandler(database, storageAdapter)
+	sharingHandler := sharing.NewSharingHandler(database, storageAdapter, emailService, lockoutService, auditService)
 	collaborationHub := handlers.NewCollaborationHub()
 	collaborationHandler := handlers.NewCollaborationWebSocketHandler(collaborationHub)
 	notesHandler := handlers.NewNotesHandler(database)
 	organizationHandler := handlers.NewOrganizationHandler(database)
+	trashHandler := handlers.NewTrashHandler(trashService, auditService)
+	auditHandler := handlers.NewAuditHandler(database, auditService)
+	backupsHandler := handlers.NewBackupsHandler(database, backupService, auditService)
+	takeoutHandler := handlers.NewTakeoutHandler(takeoutService, wsHub, config.AuthConfig, auditService)
+	sessionHandler := handlers.NewSessionHandler(database, sessionService, config.AuthConfig, auditService)
+	apiTokenHandler := handlers.NewAPITokenHandler(apiTokenService, auditService)
+	storageHandler := handlers.NewStorageHandler(database, service.NewFsckService(database, storageAdapter, transcoder), compactionService)
 
 	mux := http.NewServeMux()
 
@@ -264,6 +659,16 @@ func main() {
 	mux.HandleFunc("POST /api/auth/reset-password", authRL.RateLimit(httputil.Wrap(authHandler.ResetPassword)))
 	mux.HandleFunc("GET /api/auth/validate-reset-token", tokenRL.RateLimit(httputil.Wrap(authHandler.ValidateResetToken)))
 	mux.HandleFunc("POST /api/auth/login", authRL.RateLimit(httputil.Wrap(authHandler.Login)))
+	mux.HandleFunc("POST /api/auth/login/2fa", authRL.RateLimit(httputil.Wrap(authHandler.LoginTwoFactor)))
+	mux.HandleFunc("POST /api/auth/login/2fa/setup", authRL.RateLimit(httputil.Wrap(authHandler.LoginTwoFactorSetup)))
+	mux.HandleFunc("POST /api/auth/login/2fa/passkey/begin", authRL.RateLimit(httputil.Wrap(authHandler.LoginTwoFactorPasskeyBegin)))
+	mux.HandleFunc("POST /api/auth/login/2fa/passkey", authRL.RateLimit(httputil.Wrap(authHandler.LoginTwoFactorPasskey)))
+	mux.HandleFunc("POST /api/auth/passkeys/login/begin", authRL.RateLimit(httputil.Wrap(authHandler.BeginPasskeyLogin)))
+	mux.HandleFunc("POST /api/auth/passkeys/login/finish", authRL.RateLimit(httputil.Wrap(authHandler.FinishPasskeyLogin)))
+	mux.HandleFunc("POST /api/auth/email/confirm", tokenRL.RateLimit(httputil.Wrap(authHandler.ConfirmEmailChange)))
+	mux.HandleFunc("GET /api/auth/oidc", publicRL.RateLimit(httputil.Wrap(authHandler.GetOIDC)))
+	mux.HandleFunc("GET /api/auth/oidc/login", authRL.RateLimit(httputil.Wrap(authHandler.BeginOIDCLogin)))
+	mux.HandleFunc("GET /api/auth/oidc/callback", authRL.RateLimit(httputil.Wrap(authHandler.OIDCCallback)))
 	mux.HandleFunc("POST /api/auth/refresh", refreshRL.RateLimit(httputil.Wrap(authHandler.Refresh)))
 	mux.HandleFunc("GET /api/share/{token}", shareRL.RateLimit(httputil.Wrap(sharingHandler.ValidateShareToken)))
 	mux.HandleFunc("GET /api/share/{token}/stream", shareRL.RateLimit(httputil.Wrap(sharingHandler.StreamSharedTrack)))
@@ -279,8 +684,17 @@ func main() {
 		w.Write([]byte("OK"))
 	})
 
-	sessionValidator := func(userID int, issuedAt time.Time) bool {
+	sessionValidator := func(userID int, sessionID int64, issuedAt time.Time) bool {
 		ctx := context.Background()
+
+		// A revoked session takes its access tokens with it.
+		if sessionID != 0 {
+			session, err := database.Queries.GetRefreshTokenByID(ctx, sessionID)
+			if err != nil || session.RevokedAt.Valid || session.UserID != int64(userID) {
+				return false
+			}
+		}
+
 		result, err := database.Queries.GetSessionInvalidatedAt(ctx)
 		if err != nil || !result.Valid {
 			// continue
@@ -295,14 +709,82 @@ func main() {
 		return issuedAt.After(userInvalidated.Time)
 	}
 
-	authMW := middleware.AuthMiddleware(config.AuthConfig.JWTSecret, sessionValidator)
-	optionalAuthMW := middleware.OptionalAuthMiddleware(config.AuthConfig.JWTSecret, sessionValidator)
+	// API tokens created before a user's sessions were invalidated (by a
+	// password reset, say) stop working along with the sessions.
+	apiTokenValidator := func(ctx context.Context, token string, r *http.Request) (*middleware.APITokenIdentity, error) {
+		owner, err := apiTokenService.Authenticate(ctx, token, httputil.ClientIP(r))
+		if err != nil {
+			return nil, err
+		}
+		if !sessionValidator(int(owner.UserID), 0, owner.CreatedAt) {
+			return nil, service.ErrInvalidToken
+		}
+		return &middleware.APITokenIdentity{
+			TokenID:  owner.TokenID,
+			UserID:   int(owner.UserID),
+			Username: owner.Username,
+			Scopes:   owner.Scopes,
+		}, nil
+	}
+
+	sessionAuthMW := middleware.AuthMiddleware(config.AuthConfig.JWTSecret, sessionValidator)
+	optionalSessionAuthMW := middleware.OptionalAuthMiddleware(config.AuthConfig.JWTSecret, sessionValidator)
+	if len(config.ForwardAuth.TrustedProxies) > 0 {
+		forwardAuthService := service.NewForwardAuthService(database, config.ForwardAuth.AutoCreate)
+		forwardAuthConfig := middleware.ForwardAuthConfig{
+			TrustedProxies: config.ForwardAuth.TrustedProxies,
+			UserHeader:     config.ForwardAuth.UserHeader,
+			EmailHeader:    config.ForwardAuth.EmailHeader,
+			IssueCSRF: func(w http.ResponseWriter) {
+				if csrfToken, err := auth.GenerateSecureToken(16); err == nil {
+					httputil.SetCSRFCookie(w, csrfToken, config.AuthConfig)
+				}
+			},
+		}
+		forwardAuthResolver := func(ctx context.Context, username, email string) (*middleware.ForwardAuthIdentity, error) {
+			user, err := forwardAuthService.Resolve(ctx, username, email)
+			if err != nil {
+				return nil, err
+			}
+			return &middleware.ForwardAuthIdentity{UserID: int(user.ID), Username: user.Username}, nil
+		}
+		sessionAuthMW = middleware.ForwardAuth(forwardAuthConfig, forwardAuthResolver, sessionAuthMW)
+		optionalSessionAuthMW = middleware.ForwardAuth(forwardAuthConfig, forwardAuthResolver, optionalSessionAuthMW)
+		slog.Info("Forward authentication enabled",
+			"user_header", config.ForwardAuth.UserHeader,
+			"trusted_proxies", len(config.ForwardAuth.TrustedProxies),
+		)
+	}
+
+	authMW := middleware.APITokenAuth(apiTokenValidator, sessionAuthMW)
+	optionalAuthMW := middleware.APITokenAuth(apiTokenValidator, optionalSessionAuthMW)
 	signedURLMW := middleware.SignedURLMiddleware(config.AuthConfig.SignedURLSecret, 30*time.Second)
 
 	mux.Handle("GET /api/auth/me", authMW(httputil.Wrap(authHandler.Me)))
 	mux.Handle("PUT /api/auth/username", authMW(httputil.Wrap(authHandler.UpdateUsername)))
+	mux.Handle("PUT /api/auth/password", authMW(authRL.RateLimit(httputil.Wrap(authHandler.ChangePassword))))
+	mux.Handle("PUT /api/auth/email", authMW(authRL.RateLimit(httputil.Wrap(authHandler.RequestEmailChange))))
 	mux.Handle("DELETE /api/auth/me", authMW(httputil.Wrap(authHandler.DeleteSelf)))
 	mux.Handle("POST /api/auth/logout", authMW(httputil.Wrap(authHandler.Logout)))
+	mux.Handle("GET /api/auth/2fa", authMW(httputil.Wrap(authHandler.GetTwoFactor)))
+	mux.Handle("POST /api/auth/2fa/setup", authMW(httputil.Wrap(authHandler.SetupTwoFactor)))
+	mux.Handle("POST /api/auth/2fa/confirm", authMW(httputil.Wrap(authHandler.ConfirmTwoFactor)))
+	mux.Handle("POST /api/auth/2fa/recovery-codes", authMW(httputil.Wrap(authHandler.RegenerateRecoveryCodes)))
+	mux.Handle("DELETE /api/auth/2fa", authMW(httputil.Wrap(authHandler.DisableTwoFactor)))
+	mux.Handle("GET /api/auth/passkeys", authMW(httputil.Wrap(authHandler.ListPasskeys)))
+	mux.Handle("POST /api/auth/passkeys/register/begin", authMW(httputil.Wrap(authHandler.BeginPasskeyRegistration)))
+	mux.Handle("POST /api/auth/passkeys/register/finish", authMW(httputil.Wrap(authHandler.FinishPasskeyRegistration)))
+	mux.Handle("DELETE /api/auth/passkeys/{id}", authMW(httputil.Wrap(authHandler.DeletePasskey)))
+	mux.Handle("GET /api/auth/sessions", authMW(httputil.Wrap(sessionHandler.ListSessions)))
+	mux.Handle("DELETE /api/auth/sessions", authMW(httputil.Wrap(sessionHandler.RevokeOtherSessions)))
+	mux.Handle("DELETE /api/auth/sessions/{id}", authMW(httputil.Wrap(sessionHandler.RevokeSession)))
+	mux.Handle("POST /api/auth/oidc/link", authMW(httputil.Wrap(authHandler.BeginOIDCLink)))
+	mux.Handle("GET /api/auth/identities", authMW(httputil.Wrap(authHandler.ListIdentities)))
+	mux.Handle("DELETE /api/auth/identities/{id}", authMW(httputil.Wrap(authHandler.UnlinkIdentity)))
+
+	mux.Handle("GET /api/tokens", authMW(httputil.Wrap(apiTokenHandler.ListTokens)))
+	mux.Handle("POST /api/tokens", authMW(httputil.Wrap(apiTokenHandler.CreateToken)))
+	mux.Handle("DELETE /api/tokens/{id}", authMW(httputil.Wrap(apiTokenHandler.RevokeToken)))
 
 	mux.Handle("GET /api/users", authMW(httputil.Wrap(adminHandler.ListAllUsersPublic)))
 
@@ -312,15 +794,34 @@ func main() {
 	mux.Handle("PUT /api/admin/users/{id}/rename", authMW(httputil.Wrap(adminHandler.RenameUser)))
 	mux.Handle("DELETE /api/admin/users/{id}", authMW(httputil.Wrap(adminHandler.DeleteUser)))
 	mux.Handle("POST /api/admin/users/{id}/reset-link", authMW(httputil.Wrap(adminHandler.CreateResetLink)))
+	mux.Handle("DELETE /api/admin/users/{id}/2fa", authMW(httputil.Wrap(adminHandler.ResetTwoFactor)))
+	mux.Handle("GET /api/admin/users/{id}/sessions", authMW(httputil.Wrap(sessionHandler.AdminListSessions)))
+	mux.Handle("DELETE /api/admin/users/{id}/sessions", authMW(httputil.Wrap(sessionHandler.AdminRevokeAllSessions)))
+	mux.Handle("DELETE /api/admin/users/{id}/sessions/{sessionId}", authMW(httputil.Wrap(sessionHandler.AdminRevokeSession)))
+	mux.Handle("GET /api/admin/security", authMW(httputil.Wrap(adminHandler.GetSecuritySettings)))
+	mux.Handle("PUT /api/admin/security", authMW(httputil.Wrap(adminHandler.UpdateSecuritySettings)))
+	mux.Handle("GET /api/admin/lockouts", authMW(httputil.Wrap(adminHandler.ListLockouts)))
+	mux.Handle("DELETE /api/admin/lockouts/{id}", authMW(httputil.Wrap(adminHandler.Unlock)))
 
 	mux.Handle("GET /api/admin/instance/export/size", authMW(httputil.Wrap(instanceHandler.GetExportSize)))
 	mux.Handle("GET /api/admin/instance/export", authMW(httputil.Wrap(instanceHandler.ExportInstance)))
 	mux.Handle("POST /api/admin/instance/import", authMW(httputil.Wrap(instanceHandler.ImportInstance)))
 	mux.Handle("POST /api/admin/instance/reset", authMW(httputil.Wrap(instanceHandler.ResetInstance)))
+	mux.Handle("GET /api/admin/backups", authMW(httputil.Wrap(backupsHandler.GetBackups)))
+	mux.Handle("POST /api/admin/backups", authMW(httputil.Wrap(backupsHandler.RunBackup)))
+	mux.Handle("POST /api/admin/storage/fsck", authMW(httputil.Wrap(storageHandler.RunFsck)))
+	mux.Handle("GET /api/admin/storage/compaction", authMW(httputil.Wrap(storageHandler.GetCompaction)))
+	mux.Handle("PUT /api/admin/storage/compaction", authMW(httputil.Wrap(storageHandler.UpdateCompaction)))
+	mux.Handle("GET /api/admin/audit", authMW(httputil.Wrap(auditHandler.ListAuditEvents)))
+	mux.Handle("GET /api/admin/audit/export", authMW(httputil.Wrap(auditHandler.ExportAuditEvents)))
 
 	mux.Handle("GET /api/preferences", authMW(httputil.Wrap(prefsHandler.GetPreferences)))
 	mux.Handle("PUT /api/preferences", authMW(httputil.Wrap(prefsHandler.UpdatePreferences)))
 
+	mux.Handle("POST /api/takeout", authMW(httputil.Wrap(takeoutHandler.StartTakeout)))
+	mux.Handle("GET /api/takeout", authMW(httputil.Wrap(takeoutHandler.GetTakeout)))
+	mux.Handle("GET /api/takeout/{id}/download", optionalAuthMW(signedURLMW(httputil.Wrap(takeoutHandler.DownloadTakeout))))
+
 	mux.Handle("GET /api/stats/storage", authMW(httputil.Wrap(statsHandler.GetStorageStats)))
 	mux.Handle("GET /api/stats/storage/global", authMW(httputil.Wrap(statsHandler.GetGlobalStorageStats)))
 	mux.Handle("GET /api/instance", authMW(httputil.Wrap(statsHandler.GetInstanceInfo)))
@@ -331,6 +832,7 @@ func main() {
 	mux.Handle("GET /api/projects/{id}", authMW(httputil.Wrap(projectsHandler.GetProject)))
 	mux.Handle("PUT /api/projects/{id}", authMW(httputil.Wrap(projectsHandler.UpdateProject)))
 	mux.Handle("PUT /api/projects/{id}/folder", authMW(httputil.Wrap(projectsHandler.MoveProject)))
+	mux.Handle("POST /api/projects/import", authMW(httputil.Wrap(projectsHandler.ImportProject)))
 	mux.Handle("POST /api/projects/move-to-folder", authMW(httputil.Wrap(projectsHandler.MoveProjectsToFolder)))
 	mux.Handle("DELETE /api/projects/{id}", authMW(httputil.Wrap(projectsHandler.DeleteProject)))
 	mux.Handle("PUT /api/projects/{id}/cover", authMW(httputil.Wrap(projectsHandler.UploadProjectCover)))
@@ -348,6 +850,11 @@ func main() {
 	mux.Handle("POST /api/folders/{id}/empty", authMW(httputil.Wrap(foldersHandler.EmptyFolder)))
 	mux.Handle("DELETE /api/folders/{id}", authMW(httputil.Wrap(foldersHandler.DeleteFolder)))
 
+	mux.Handle("GET /api/trash", authMW(httputil.Wrap(trashHandler.ListTrash)))
+	mux.Handle("DELETE /api/trash", authMW(httputil.Wrap(trashHandler.EmptyTrash)))
+	mux.Handle("POST /api/trash/{type}/{id}/restore", authMW(httputil.Wrap(trashHandler.RestoreItem)))
+	mux.Handle("DELETE /api/trash/{type}/{id}", authMW(httputil.Wrap(trashHandler.PurgeItem)))
+
 	mux.Handle("POST /api/library/upload", authMW(httputil.Wrap(tracksHandler.UploadTrack)))
 	mux.Handle("POST /api/tracks/reorder", authMW(httputil.Wrap(tracksHandler.UpdateTracksOrder)))
 	mux.Handle("GET /api/tracks", authMW(httputil.Wrap(tracksHandler.ListTracks)))
@@ -418,20 +925,34 @@ func main() {
 	csrfMW := middleware.CSRFMiddleware(middleware.CSRFMiddlewareConfig{
 		ExemptPaths: []string{
 			"/api/auth/login",
+			"/api/auth/passkeys/login",
 			"/api/auth/register",
 			"/api/auth/refresh",
 			"/api/auth/reset-password",
 			"/api/auth/validate-invite-token",
 			"/api/auth/validate-reset-token",
 			"/api/auth/check-users",
+			"/api/auth/email/confirm",
 			"/api/share/",
 			"/api/health",
 		},
 	})
 
-	handler := middleware.CORS(middleware.CORSConfig{AllowedOrigins: config.CORSAllowedOrigins})(
-		csrfMW(middleware.SecurityHeaders(middleware.Logging(mux))),
+	clientIPResolver := middleware.ClientIPResolver{
+		TrustedProxies: config.TrustedProxies,
+		Header:         config.TrustedProxyHeader,
+	}
+	handler := middleware.RealIP(clientIPResolver)(
+		middleware.CORS(middleware.CORSConfig{AllowedOrigins: config.CORSAllowedOrigins})(
+			csrfMW(middleware.SecurityHeaders(middleware.Logging(mux))),
+		),
 	)
+	if len(config.TrustedProxies) > 0 {
+		slog.Info("Client addresses resolved through trusted proxies",
+			"trusted_proxies", len(config.TrustedProxies),
+			"header", config.TrustedProxyHeader,
+		)
+	}
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%s", config.Port),
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"ramiro-uziel/vault/internal/db"
+	"ramiro-uziel/vault/internal/logger"
+)
+
+const migrateUsage = `usage: vault-server migrate <command> [flags]
+
+commands:
+  status [-json]       list migrations and whether they are applied
+  up [-to VERSION]     apply pending migrations, optionally stopping at VERSION
+  down [-steps N]      revert the last N applied migrations (default 1)`
+
+// runMigrate inspects and moves the database schema. The server applies
+// pending migrations on its own at startup; this is for checking what is
+// applied and for stepping back after a bad upgrade. Both up and down take a
+// snapshot of the database first.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	command := args[0]
+	flags := flag.NewFlagSet("migrate "+command, flag.ContinueOnError)
+	var (
+		jsonOutput *bool
+		target     *string
+		steps      *int
+	)
+	switch command {
+	case "status":
+		jsonOutput = flags.Bool("json", false, "print the status as JSON")
+	case "up":
+		target = flags.String("to", "", "apply migrations up to and including this version")
+	case "down":
+		steps = flags.Int("steps", 1, "number of migrations to revert")
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	slog.SetDefault(slog.New(logger.NewPrettyHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	})))
+
+	config := loadStorageConfig()
+
+	database, err := db.New(db.Config{
+		DataDir:        config.DataDir,
+		DBFile:         "vault.db",
+		SkipMigrations: true,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: failed to open database: %v\n", err)
+		return 1
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+
+	switch command {
+	case "status":
+		states, err := database.MigrationStatus(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+			return 1
+		}
+		if *jsonOutput {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(states); err != nil {
+				return 1
+			}
+		} else {
+			printMigrationStatus(states)
+		}
+		for _, state := range states {
+			if state.Modified || state.Unknown {
+				return 1
+			}
+		}
+
+	case "up":
+		applied, err := database.MigrateUp(ctx, *target)
+		for _, version := range applied {
+			fmt.Printf("applied  %s\n", version)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+
+	case "down":
+		reverted, err := database.MigrateDown(ctx, *steps)
+		for _, version := range reverted {
+			fmt.Printf("reverted %s\n", version)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func printMigrationStatus(states []db.MigrationState) {
+	var pending int
+	for _, state := range states {
+		status := "pending"
+		switch {
+		case state.Unknown:
+			status = "unknown"
+		case state.Modified:
+			status = "modified"
+		case state.Applied:
+			status = "applied"
+		default:
+			pending++
+		}
+
+		line := fmt.Sprintf("%-9s %s", status, state.Version)
+		if state.AppliedAt != nil {
+			line += "  " + state.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+		}
+		if !state.Reversible && !state.Unknown {
+			line += "  (irreversible)"
+		}
+		fmt.Println(line)
+	}
+
+	fmt.Printf("\n%d migrations, %d pending\n", len(states), pending)
+}
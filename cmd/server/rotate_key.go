@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"ramiro-uziel/vault/internal/logger"
+	"ramiro-uziel/vault/internal/storage"
+)
+
+// runRotateKey moves every stored file onto ENCRYPTION_KEY. Files wrapped by a
+// key listed in ENCRYPTION_PREVIOUS_KEYS are rewrapped, and plaintext files
+// are encrypted. Once it succeeds the previous keys can be dropped.
+func runRotateKey(args []string) int {
+	flags := flag.NewFlagSet("rotate-key", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "print the result as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	slog.SetDefault(slog.New(logger.NewPrettyHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+	})))
+
+	config := loadStorageConfig()
+	if config.StorageBackend != "filesystem" {
+		fmt.Fprintln(os.Stderr, "rotate-key: encryption at rest is only supported by the filesystem backend")
+		return 1
+	}
+
+	keyring, err := newKeyring(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rotate-key: %v\n", err)
+		return 1
+	}
+	if keyring == nil {
+		fmt.Fprintln(os.Stderr, "rotate-key: ENCRYPTION_KEY is not set")
+		return 1
+	}
+
+	fs := storage.NewFilesystemStorage(config.DataDir)
+	fs.SetKeyring(keyring)
+
+	result, err := fs.RotateKeys(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rotate-key: %v\n", err)
+		return 1
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return 1
+		}
+	} else {
+		for _, path := range result.Failed {
+			fmt.Printf("failed  %s\n", path)
+		}
+		fmt.Printf("key %s: %d rewrapped, %d encrypted, %d already current, %d failed\n",
+			keyring.CurrentKeyID(), result.Rewrapped, result.Encrypted, result.Unchanged, len(result.Failed))
+	}
+
+	if len(result.Failed) > 0 {
+		return 1
+	}
+	return 0
+}
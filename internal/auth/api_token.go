@@ -0,0 +1,29 @@
+package auth
+
+import "strings"
+
+// APITokenPrefix starts every personal API token, so they can be told apart
+// from session JWTs in an Authorization header and spotted in leaked text.
+const APITokenPrefix = "vault_pat_"
+
+// API token scopes.
+const (
+	ScopeRead        = "read"
+	ScopeUpload      = "upload"
+	ScopeShareManage = "share-manage"
+	ScopeAdmin       = "admin"
+)
+
+var APITokenScopes = []string{ScopeRead, ScopeUpload, ScopeShareManage, ScopeAdmin}
+
+func GenerateAPIToken() (string, error) {
+	token, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	return APITokenPrefix + token, nil
+}
+
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
@@ -21,11 +21,18 @@ type Config struct {
 	CookieDomain        string
 	CookieSecure        bool
 	CookieSameSite      string
+	// WebAuthnRPID is the domain passkeys are bound to. Passkeys are
+	// disabled while it is empty.
+	WebAuthnRPID    string
+	WebAuthnOrigins []string
 }
 
 type Claims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
+	// SessionID is the refresh token the access token was issued with, so
+	// revoking that session also ends the access token.
+	SessionID int64 `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -41,10 +48,11 @@ func VerifyPassword(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-func GenerateToken(userID int, username string, config Config) (string, error) {
+func GenerateToken(userID int, username string, sessionID int64, config Config) (string, error) {
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
+		UserID:    userID,
+		Username:  username,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.JWTExpiration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
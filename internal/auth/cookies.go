@@ -4,4 +4,5 @@ const (
 	AccessTokenCookieName  = "access_token"
 	RefreshTokenCookieName = "refresh_token"
 	CSRFCookieName         = "csrf_token"
+	OIDCStateCookieName    = "oidc_state"
 )
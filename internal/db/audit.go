@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+)
+
+// AuditFilter narrows a listing of audit events, which come newest first.
+// Zero fields match everything.
+type AuditFilter struct {
+	ActorID *int64
+	// Actions matches any of the given actions. An entry ending in ".*", such
+	// as "share.*", matches every action with that prefix.
+	Actions    []string
+	TargetType string
+	TargetID   string
+	Since      *time.Time
+	Until      *time.Time
+	// BeforeID continues a listing after the event with this ID.
+	BeforeID int64
+	// Limit caps the number of events; 0 returns them all.
+	Limit int64
+}
+
+// ListAuditEvents returns the audit events matching filter.
+func (db *DB) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]sqlc.AuditEvent, error) {
+	events := []sqlc.AuditEvent{}
+	err := db.EachAuditEvent(ctx, filter, func(event sqlc.AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+// EachAuditEvent calls fn for every audit event matching filter without
+// holding them all in memory, stopping at the first error fn returns.
+func (db *DB) EachAuditEvent(ctx context.Context, filter AuditFilter, fn func(sqlc.AuditEvent) error) error {
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(name string, value any) string {
+		args = append(args, sql.Named(name, value))
+		return "@" + name
+	}
+
+	if filter.ActorID != nil {
+		where = append(where, "actor_id = "+arg("actor_id", *filter.ActorID))
+	}
+	if len(filter.Actions) > 0 {
+		var actions []string
+		for i, action := range filter.Actions {
+			name := fmt.Sprintf("action_%d", i)
+			if prefix, ok := strings.CutSuffix(action, ".*"); ok {
+				actions = append(actions, "action LIKE "+arg(name, escapeLike(prefix)+".%")+` ESCAPE '\'`)
+			} else {
+				actions = append(actions, "action = "+arg(name, action))
+			}
+		}
+		where = append(where, "("+strings.Join(actions, " OR ")+")")
+	}
+	if filter.TargetType != "" {
+		where = append(where, "target_type = "+arg("target_type", filter.TargetType))
+	}
+	if filter.TargetID != "" {
+		where = append(where, "target_id = "+arg("target_id", filter.TargetID))
+	}
+	if filter.Since != nil {
+		where = append(where, "created_at >= "+arg("since", filter.Since.UTC()))
+	}
+	if filter.Until != nil {
+		where = append(where, "created_at < "+arg("until", filter.Until.UTC()))
+	}
+	if filter.BeforeID > 0 {
+		where = append(where, "id < "+arg("before_id", filter.BeforeID))
+	}
+
+	query := `
+SELECT id, created_at, actor_id, actor_username, ip, user_agent, action, target_type, target_id, before_summary, after_summary
+FROM audit_events`
+	if len(where) > 0 {
+		query += "\nWHERE " + strings.Join(where, "\nAND ")
+	}
+	query += "\nORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += "\nLIMIT " + arg("limit", filter.Limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i sqlc.AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ActorID,
+			&i.ActorUsername,
+			&i.Ip,
+			&i.UserAgent,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.BeforeSummary,
+			&i.AfterSummary,
+		); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
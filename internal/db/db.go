@@ -1,12 +1,14 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	schema "ramiro-uziel/vault/migrations"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -14,13 +16,16 @@ import (
 type DB struct {
 	*sql.DB
 	*sqlc.Queries
-	config Config
+	config     Config
+	migrations []Migration
 }
 
 type Config struct {
-	DataDir        string
-	DBFile         string
-	MigrationsPath string
+	DataDir string
+	DBFile  string
+	// SkipMigrations opens the database without applying pending
+	// migrations, for tools that manage them explicitly.
+	SkipMigrations bool
 }
 
 func New(config Config) (*DB, error) {
@@ -41,84 +46,38 @@ func New(config Config) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-
-	wrapper := &DB{
-		DB:      db,
-		Queries: sqlc.New(db),
-		config:  config,
-	}
-
-	if err := wrapper.runMigrations(config.MigrationsPath); err != nil {
+	// The search index needs FTS5, which go-sqlite3 only compiles in with
+	// the sqlite_fts5 build tag.
+	var hasFTS5 bool
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&hasFTS5); err != nil || !hasFTS5 {
 		db.Close()
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+		return nil, fmt.Errorf("SQLite was built without FTS5; build with -tags sqlite_fts5")
 	}
 
-	return wrapper, nil
-}
-
-func (db *DB) runMigrations(migrationsPath string) error {
-	createTableSQL := `
-		CREATE TABLE IF NOT EXISTS schema_migrations (
-			version TEXT PRIMARY KEY,
-			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);
-	`
-	if _, err := db.Exec(createTableSQL); err != nil {
-		return fmt.Errorf("failed to create migrations table: %w", err)
-	}
+	db.SetMaxOpenConns(25)
+	db.SetMaxIdleConns(5)
 
-	entries, err := os.ReadDir(migrationsPath)
+	migrations, err := LoadMigrations(schema.FS)
 	if err != nil {
-		return fmt.Errorf("failed to read migrations directory: %w", err)
+		db.Close()
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() || filepath.Ext(entry.Name()) != ".sql" {
-			continue
-		}
-
-		version := entry.Name()
-
-		var exists bool
-		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = ?)", version).Scan(&exists)
-		if err != nil {
-			return fmt.Errorf("failed to check migration status: %w", err)
-		}
-
-		if exists {
-			continue
-		}
-
-		content, err := os.ReadFile(filepath.Join(migrationsPath, entry.Name()))
-		if err != nil {
-			return fmt.Errorf("failed to read migration %s: %w", version, err)
-		}
-
-		tx, err := db.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction: %w", err)
-		}
-
-		if _, err := tx.Exec(string(content)); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to execute migration %s: %w", version, err)
-		}
-
-		if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES (?)", version); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to record migration %s: %w", version, err)
-		}
+	wrapper := &DB{
+		DB:         db,
+		Queries:    sqlc.New(db),
+		config:     config,
+		migrations: migrations,
+	}
 
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit migration %s: %w", version, err)
+	if !config.SkipMigrations {
+		if _, err := wrapper.MigrateUp(context.Background(), ""); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to run migrations: %w", err)
 		}
-
-		fmt.Printf("Applied migration: %s\n", version)
 	}
 
-	return nil
+	return wrapper, nil
 }
 
 func (db *DB) Close() error {
@@ -156,8 +115,10 @@ func (db *DB) Reconnect() error {
 	db.DB = newDB
 	db.Queries = sqlc.New(newDB)
 
-	if err := db.runMigrations(db.config.MigrationsPath); err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
+	if !db.config.SkipMigrations {
+		if _, err := db.MigrateUp(context.Background(), ""); err != nil {
+			return fmt.Errorf("failed to run migrations: %w", err)
+		}
 	}
 
 	return nil
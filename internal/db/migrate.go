@@ -0,0 +1,408 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotsToKeep is how many pre-migration snapshots are kept in the
+// snapshots directory; older ones are deleted after a new one is taken.
+const snapshotsToKeep = 5
+
+// Migration is one schema change. Version is the file name of its up script,
+// which is also the key recorded in schema_migrations.
+type Migration struct {
+	Version  string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Reversible reports whether the migration has a down script.
+func (m Migration) Reversible() bool {
+	return m.Down != ""
+}
+
+// MigrationState describes one migration as seen by the database.
+type MigrationState struct {
+	Version    string     `json:"version"`
+	Applied    bool       `json:"applied"`
+	AppliedAt  *time.Time `json:"applied_at,omitempty"`
+	Reversible bool       `json:"reversible"`
+	// Modified is set when the applied script differs from the one embedded
+	// in this build.
+	Modified bool `json:"modified"`
+	// Unknown is set for migrations recorded in the database that this build
+	// does not have, usually because a newer version ran against it.
+	Unknown bool `json:"unknown"`
+}
+
+type appliedMigration struct {
+	version   string
+	appliedAt sql.NullTime
+	checksum  sql.NullString
+}
+
+// LoadMigrations reads NNN_name.sql up scripts and their optional
+// NNN_name.down.sql counterparts from fsys, ordered by version.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	ups := make(map[string]bool)
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".down.sql") {
+			ups[name] = true
+		}
+	}
+
+	var migrations []Migration
+	for _, name := range names {
+		if strings.HasSuffix(name, ".down.sql") {
+			if !ups[strings.TrimSuffix(name, ".down.sql")+".sql"] {
+				return nil, fmt.Errorf("down migration %s has no matching up migration", name)
+			}
+			continue
+		}
+
+		up, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+		down, err := fs.ReadFile(fsys, strings.TrimSuffix(name, ".sql")+".down.sql")
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read down migration for %s: %w", name, err)
+		}
+
+		sum := sha256.Sum256(up)
+		migrations = append(migrations, Migration{
+			Version:  name,
+			Up:       string(up),
+			Down:     string(down),
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func (db *DB) ensureMigrationsTable(ctx context.Context) error {
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			checksum TEXT
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	// Databases created before checksums were recorded lack the column.
+	var hasChecksum bool
+	if err := db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM pragma_table_info('schema_migrations') WHERE name = 'checksum')",
+	).Scan(&hasChecksum); err != nil {
+		return fmt.Errorf("failed to inspect migrations table: %w", err)
+	}
+	if !hasChecksum {
+		if _, err := db.ExecContext(ctx, "ALTER TABLE schema_migrations ADD COLUMN checksum TEXT"); err != nil {
+			return fmt.Errorf("failed to add checksum column: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) appliedMigrations(ctx context.Context) ([]appliedMigration, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at, checksum FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	var applied []appliedMigration
+	for rows.Next() {
+		var m appliedMigration
+		if err := rows.Scan(&m.version, &m.appliedAt, &m.checksum); err != nil {
+			return nil, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, rows.Err()
+}
+
+// verifyApplied checks that every applied migration is part of this build
+// and unchanged since it ran. Rows recorded before checksums existed are
+// filled in from the embedded scripts.
+func (db *DB) verifyApplied(ctx context.Context, applied []appliedMigration) error {
+	known := make(map[string]Migration, len(db.migrations))
+	for _, m := range db.migrations {
+		known[m.Version] = m
+	}
+
+	for _, a := range applied {
+		m, ok := known[a.version]
+		if !ok {
+			return fmt.Errorf("database has migration %s that this build does not know about; run a newer version or restore a snapshot", a.version)
+		}
+		if !a.checksum.Valid {
+			if _, err := db.ExecContext(ctx,
+				"UPDATE schema_migrations SET checksum = ? WHERE version = ?", m.Checksum, a.version,
+			); err != nil {
+				return fmt.Errorf("failed to record checksum for %s: %w", a.version, err)
+			}
+			continue
+		}
+		if a.checksum.String != m.Checksum {
+			return fmt.Errorf("migration %s was modified after it was applied", a.version)
+		}
+	}
+
+	return nil
+}
+
+// MigrationStatus lists every known migration along with any applied ones
+// this build does not have. Unlike MigrateUp it reports problems rather than
+// failing on them.
+func (db *DB) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	if err := db.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[string]appliedMigration, len(applied))
+	for _, a := range applied {
+		byVersion[a.version] = a
+	}
+
+	states := make([]MigrationState, 0, len(db.migrations))
+	for _, m := range db.migrations {
+		state := MigrationState{
+			Version:    m.Version,
+			Reversible: m.Reversible(),
+		}
+		if a, ok := byVersion[m.Version]; ok {
+			state.Applied = true
+			if a.appliedAt.Valid {
+				appliedAt := a.appliedAt.Time
+				state.AppliedAt = &appliedAt
+			}
+			state.Modified = a.checksum.Valid && a.checksum.String != m.Checksum
+			delete(byVersion, m.Version)
+		}
+		states = append(states, state)
+	}
+
+	for _, a := range applied {
+		if _, ok := byVersion[a.version]; !ok {
+			continue
+		}
+		state := MigrationState{Version: a.version, Applied: true, Unknown: true}
+		if a.appliedAt.Valid {
+			appliedAt := a.appliedAt.Time
+			state.AppliedAt = &appliedAt
+		}
+		states = append(states, state)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Version < states[j].Version
+	})
+	return states, nil
+}
+
+// MigrateUp applies pending migrations in order, up to and including target,
+// or all of them when target is empty. A snapshot of the database is taken
+// first unless it is brand new. It returns the versions it applied.
+func (db *DB) MigrateUp(ctx context.Context, target string) ([]string, error) {
+	if err := db.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.verifyApplied(ctx, applied); err != nil {
+		return nil, err
+	}
+
+	isApplied := make(map[string]bool, len(applied))
+	for _, a := range applied {
+		isApplied[a.version] = true
+	}
+
+	var pending []Migration
+	found := target == ""
+	for _, m := range db.migrations {
+		if target != "" && m.Version > target {
+			break
+		}
+		if m.Version == target {
+			found = true
+		}
+		if !isApplied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown migration %s", target)
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	if len(applied) > 0 {
+		if _, err := db.Snapshot("before-" + migrationLabel(pending[0].Version)); err != nil {
+			return nil, fmt.Errorf("failed to snapshot database before migrating: %w", err)
+		}
+	}
+
+	var done []string
+	for _, m := range pending {
+		if err := db.execMigration(ctx, m.Version, m.Up,
+			"INSERT INTO schema_migrations (version, checksum) VALUES (?, ?)", m.Version, m.Checksum,
+		); err != nil {
+			return done, err
+		}
+		slog.Info("Applied migration", "version", m.Version)
+		done = append(done, m.Version)
+	}
+
+	return done, nil
+}
+
+// MigrateDown reverts the most recent steps applied migrations, newest first,
+// after taking a snapshot. Nothing is reverted if any of them lacks a down
+// script; those can only be undone by restoring a snapshot.
+func (db *DB) MigrateDown(ctx context.Context, steps int) ([]string, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("steps must be at least 1")
+	}
+	if err := db.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.verifyApplied(ctx, applied); err != nil {
+		return nil, err
+	}
+	if steps > len(applied) {
+		return nil, fmt.Errorf("only %d migrations are applied", len(applied))
+	}
+
+	known := make(map[string]Migration, len(db.migrations))
+	for _, m := range db.migrations {
+		known[m.Version] = m
+	}
+
+	var reverting []Migration
+	for i := len(applied) - 1; i >= len(applied)-steps; i-- {
+		m := known[applied[i].version]
+		if !m.Reversible() {
+			return nil, fmt.Errorf("migration %s cannot be reverted; restore a snapshot instead", m.Version)
+		}
+		reverting = append(reverting, m)
+	}
+
+	if _, err := db.Snapshot("before-down-" + migrationLabel(reverting[0].Version)); err != nil {
+		return nil, fmt.Errorf("failed to snapshot database before reverting: %w", err)
+	}
+
+	var done []string
+	for _, m := range reverting {
+		if err := db.execMigration(ctx, m.Version, m.Down,
+			"DELETE FROM schema_migrations WHERE version = ?", m.Version,
+		); err != nil {
+			return done, err
+		}
+		slog.Info("Reverted migration", "version", m.Version)
+		done = append(done, m.Version)
+	}
+
+	return done, nil
+}
+
+// execMigration runs script and the bookkeeping statement in one transaction.
+func (db *DB) execMigration(ctx context.Context, version, script, record string, args ...any) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to execute migration %s: %w", version, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record migration %s: %w", version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", version, err)
+	}
+
+	return nil
+}
+
+// SnapshotDir is where pre-migration snapshots are written.
+func (db *DB) SnapshotDir() string {
+	return filepath.Join(db.config.DataDir, "snapshots")
+}
+
+// Snapshot writes a consistent copy of the database to the snapshot
+// directory and prunes old snapshots. It returns the new snapshot's path.
+// Restoring one means stopping the server and copying it over vault.db.
+func (db *DB) Snapshot(label string) (string, error) {
+	dir := db.SnapshotDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	name := fmt.Sprintf("vault-%s-%s.db", time.Now().UTC().Format("20060102T150405Z"), label)
+	path := filepath.Join(dir, name)
+	if _, err := db.Exec("VACUUM INTO ?", path); err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	slog.Info("Database snapshot written", "path", path)
+
+	snapshots, err := filepath.Glob(filepath.Join(dir, "vault-*.db"))
+	if err != nil {
+		return path, nil
+	}
+	sort.Strings(snapshots)
+	for len(snapshots) > snapshotsToKeep {
+		if err := os.Remove(snapshots[0]); err != nil {
+			slog.Warn("Failed to remove old snapshot", "path", snapshots[0], "error", err)
+		}
+		snapshots = snapshots[1:]
+	}
+
+	return path, nil
+}
+
+// migrationLabel shortens a migration file name for use in snapshot names.
+func migrationLabel(version string) string {
+	return strings.TrimSuffix(version, ".sql")
+}
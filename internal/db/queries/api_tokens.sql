@@ -0,0 +1,24 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetAPITokenByHash :one
+SELECT * FROM api_tokens
+WHERE token_hash = ?;
+
+-- name: ListAPITokensByUser :many
+SELECT * FROM api_tokens
+WHERE user_id = ?
+ORDER BY created_at DESC, id DESC;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = sqlc.arg(now),
+    last_used_ip = sqlc.arg(ip)
+WHERE id = sqlc.arg(id)
+  AND (last_used_at IS NULL OR last_used_at < sqlc.arg(since));
+
+-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens
+WHERE id = ? AND user_id = ?;
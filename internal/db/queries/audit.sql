@@ -0,0 +1,14 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (
+    created_at, actor_id, actor_username, ip, user_agent,
+    action, target_type, target_id, before_summary, after_summary
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: ListAllAuditEvents :many
+SELECT * FROM audit_events
+ORDER BY id ASC;
+
+-- name: DeleteAuditEventsBefore :execrows
+DELETE FROM audit_events
+WHERE created_at < ?;
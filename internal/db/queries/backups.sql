@@ -0,0 +1,40 @@
+-- name: CreateBackupRun :one
+INSERT INTO backup_runs (started_at, trigger, destination)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: FinishBackupRun :exec
+UPDATE backup_runs
+SET finished_at = ?,
+    status = ?,
+    name = ?,
+    size_bytes = ?,
+    error = ?
+WHERE id = ?;
+
+-- name: FailRunningBackupRuns :exec
+UPDATE backup_runs
+SET finished_at = ?,
+    status = 'failed',
+    error = ?
+WHERE status = 'running';
+
+-- name: GetLastBackupRun :one
+SELECT * FROM backup_runs
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: GetLastSuccessfulBackupRun :one
+SELECT * FROM backup_runs
+WHERE status = 'succeeded'
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: ListBackupRuns :many
+SELECT * FROM backup_runs
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: DeleteOldBackupRuns :exec
+DELETE FROM backup_runs
+WHERE id <= (SELECT id FROM backup_runs ORDER BY id DESC LIMIT 1 OFFSET ?);
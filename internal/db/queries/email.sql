@@ -0,0 +1,40 @@
+-- name: EnqueueEmail :one
+INSERT INTO email_queue (recipient, template, subject, text_body, html_body, next_attempt_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListDueEmails :many
+SELECT * FROM email_queue
+WHERE status = 'pending' AND next_attempt_at <= ?
+ORDER BY next_attempt_at, id
+LIMIT ?;
+
+-- name: MarkEmailSent :exec
+UPDATE email_queue
+SET status = 'sent',
+    attempts = attempts + 1,
+    sent_at = ?,
+    last_error = NULL,
+    text_body = '',
+    html_body = ''
+WHERE id = ?;
+
+-- name: RetryEmail :exec
+UPDATE email_queue
+SET attempts = attempts + 1,
+    last_error = ?,
+    next_attempt_at = ?
+WHERE id = ?;
+
+-- name: FailEmail :exec
+UPDATE email_queue
+SET status = 'failed',
+    attempts = attempts + 1,
+    last_error = ?,
+    text_body = '',
+    html_body = ''
+WHERE id = ?;
+
+-- name: DeleteOldEmails :exec
+DELETE FROM email_queue
+WHERE status != 'pending' AND created_at < ?;
@@ -1,29 +1,29 @@
 -- name: CreateFolder :one
-INSERT INTO folders (user_id, parent_id, name, folder_order)
-VALUES (?, ?, ?, ?)
+INSERT INTO folders (user_id, parent_id, name, folder_order, smart_query)
+VALUES (?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: GetFolder :one
 SELECT * FROM folders
-WHERE id = ? AND user_id = ?;
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL;
 
 -- name: GetFolderByID :one
 SELECT * FROM folders
-WHERE id = ?;
+WHERE id = ? AND deleted_at IS NULL;
 
 -- name: ListFoldersByUser :many
 SELECT * FROM folders
-WHERE user_id = ? AND parent_id IS NULL
+WHERE user_id = ? AND parent_id IS NULL AND deleted_at IS NULL
 ORDER BY folder_order ASC, created_at DESC;
 
 -- name: ListFoldersByParent :many
 SELECT * FROM folders
-WHERE user_id = ? AND parent_id = ?
+WHERE user_id = ? AND parent_id = ? AND deleted_at IS NULL
 ORDER BY folder_order ASC, created_at DESC;
 
 -- name: ListAllFoldersByUser :many
 SELECT * FROM folders
-WHERE user_id = ?
+WHERE user_id = ? AND deleted_at IS NULL
 ORDER BY folder_order ASC, created_at DESC;
 
 -- name: UpdateFolder :one
@@ -44,6 +44,12 @@ SET parent_id = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
 RETURNING *;
 
+-- name: UpdateFolderSmartQuery :one
+UPDATE folders
+SET smart_query = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND user_id = ? AND smart_query IS NOT NULL
+RETURNING *;
+
 -- name: UpdateFolderOrder :exec
 UPDATE folders
 SET folder_order = ?, updated_at = CURRENT_TIMESTAMP
@@ -58,16 +64,17 @@ DELETE FROM folders
 WHERE id = ?;
 
 -- name: CheckFolderExists :one
+-- Smart folders cannot hold projects, tracks or subfolders, so they do not count.
 SELECT COUNT(*) as count FROM folders
-WHERE id = ? AND user_id = ?;
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL AND smart_query IS NULL;
 
 -- name: CountProjectsInFolder :one
 SELECT COUNT(*) as count FROM projects
-WHERE folder_id = ?;
+WHERE folder_id = ? AND deleted_at IS NULL;
 
 -- name: CountSubfoldersInFolder :one
 SELECT COUNT(*) as count FROM folders
-WHERE parent_id = ?;
+WHERE parent_id = ? AND deleted_at IS NULL;
 
 -- name: ListProjectsInFolder :many
 SELECT
@@ -75,5 +82,5 @@ SELECT
     u.username as owner_username
 FROM projects p
 JOIN users u ON p.user_id = u.id
-WHERE p.folder_id = ? AND p.user_id = ?
+WHERE p.folder_id = ? AND p.user_id = ? AND p.deleted_at IS NULL
 ORDER BY p.folder_added_at ASC;
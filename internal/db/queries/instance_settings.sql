@@ -23,3 +23,11 @@ SELECT session_invalidated_at FROM instance_settings WHERE id = 1;
 UPDATE instance_settings
 SET session_invalidated_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
 WHERE id = 1;
+
+-- name: GetSourceCompaction :one
+SELECT source_compaction FROM instance_settings WHERE id = 1;
+
+-- name: SetSourceCompaction :exec
+UPDATE instance_settings
+SET source_compaction = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = 1;
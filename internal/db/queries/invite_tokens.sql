@@ -31,3 +31,7 @@ WHERE expires_at < CURRENT_TIMESTAMP AND used = 0;
 SELECT * FROM invite_tokens
 WHERE created_by = ? AND token_type = 'invite'
 ORDER BY created_at DESC;
+
+-- name: DeletePendingUserTokens :exec
+DELETE FROM invite_tokens
+WHERE user_id = ? AND token_type = ? AND used = 0;
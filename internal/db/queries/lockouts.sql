@@ -0,0 +1,44 @@
+-- name: GetAuthLockout :one
+SELECT * FROM auth_lockouts
+WHERE scope = ? AND subject = ?;
+
+-- name: InsertAuthAttempt :one
+-- Returns no row if another attempt created the subject's row first.
+INSERT INTO auth_lockouts (scope, subject, failures, last_failure_at)
+VALUES (sqlc.arg(scope), sqlc.arg(subject), 1, sqlc.arg(now))
+ON CONFLICT (scope, subject) DO NOTHING
+RETURNING *;
+
+-- name: UpdateAuthAttempt :one
+-- Returns no row if the subject is blocked, or another attempt has been
+-- counted since it was read.
+UPDATE auth_lockouts
+SET failures = sqlc.arg(failures),
+    last_failure_at = sqlc.arg(now),
+    blocked_until = sqlc.arg(blocked_until)
+WHERE id = sqlc.arg(id)
+  AND failures = sqlc.arg(read_failures)
+  AND (blocked_until IS NULL OR blocked_until <= sqlc.arg(now))
+RETURNING *;
+
+-- name: DeleteAuthLockout :exec
+DELETE FROM auth_lockouts
+WHERE scope = ? AND subject = ?;
+
+-- name: DeleteAuthLockoutByID :execrows
+DELETE FROM auth_lockouts
+WHERE id = ?;
+
+-- name: GetAuthLockoutByID :one
+SELECT * FROM auth_lockouts
+WHERE id = ?;
+
+-- name: ListAuthLockouts :many
+SELECT * FROM auth_lockouts
+WHERE last_failure_at >= ?
+ORDER BY last_failure_at DESC;
+
+-- name: DeleteStaleAuthLockouts :exec
+DELETE FROM auth_lockouts
+WHERE last_failure_at < sqlc.arg(forget_before)
+  AND (blocked_until IS NULL OR blocked_until < sqlc.arg(now));
@@ -0,0 +1,36 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (user_id, issuer, subject, email, last_login_at)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE issuer = ? AND subject = ?;
+
+-- name: ListUserIdentities :many
+SELECT * FROM user_identities
+WHERE user_id = ?
+ORDER BY created_at ASC, id ASC;
+
+-- name: UpdateUserIdentityLogin :exec
+UPDATE user_identities
+SET email = ?,
+    last_login_at = ?
+WHERE id = ?;
+
+-- name: DeleteUserIdentity :execrows
+DELETE FROM user_identities
+WHERE id = ? AND user_id = ?;
+
+-- name: CreateOIDCLogin :exec
+INSERT INTO oidc_logins (state_hash, nonce, code_verifier, invite_token_hash, link_user_id, expires_at)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: TakeOIDCLogin :one
+DELETE FROM oidc_logins
+WHERE state_hash = ?
+RETURNING *;
+
+-- name: DeleteExpiredOIDCLogins :exec
+DELETE FROM oidc_logins
+WHERE expires_at < ?;
@@ -0,0 +1,51 @@
+-- name: CreatePasskey :one
+INSERT INTO passkeys (
+    user_id, credential_id, public_key, sign_count, name, aaguid,
+    transports, backup_eligible, backup_state
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetPasskeyByCredentialID :one
+SELECT * FROM passkeys
+WHERE credential_id = ?;
+
+-- name: ListPasskeysByUser :many
+SELECT * FROM passkeys
+WHERE user_id = ?
+ORDER BY created_at ASC, id ASC;
+
+-- name: CountPasskeysByUser :one
+SELECT COUNT(*) FROM passkeys
+WHERE user_id = ?;
+
+-- name: ListPasskeyUserIDs :many
+SELECT DISTINCT user_id FROM passkeys;
+
+-- name: UpdatePasskeyUse :exec
+UPDATE passkeys
+SET sign_count = ?,
+    backup_state = ?,
+    last_used_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: DeletePasskey :execrows
+DELETE FROM passkeys
+WHERE id = ? AND user_id = ?;
+
+-- name: DeletePasskeysByUser :exec
+DELETE FROM passkeys
+WHERE user_id = ?;
+
+-- name: CreatePasskeyChallenge :exec
+INSERT INTO passkey_challenges (user_id, challenge, ceremony, expires_at)
+VALUES (?, ?, ?, ?);
+
+-- name: TakePasskeyChallenge :one
+DELETE FROM passkey_challenges
+WHERE challenge = ? AND ceremony = ?
+RETURNING *;
+
+-- name: DeleteExpiredPasskeyChallenges :exec
+DELETE FROM passkey_challenges
+WHERE expires_at < ?;
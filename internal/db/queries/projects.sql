@@ -5,11 +5,11 @@ RETURNING *;
 
 -- name: GetProject :one
 SELECT * FROM projects
-WHERE id = ? AND user_id = ?;
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL;
 
 -- name: GetProjectByID :one
 SELECT * FROM projects
-WHERE id = ?;
+WHERE id = ? AND deleted_at IS NULL;
 
 -- name: ListProjectsByUser :many
 SELECT
@@ -17,7 +17,7 @@ SELECT
     u.username as owner_username
 FROM projects p
 JOIN users u ON p.user_id = u.id
-WHERE p.user_id = ?
+WHERE p.user_id = ? AND p.deleted_at IS NULL
 ORDER BY p.created_at DESC;
 
 -- name: GetProjectByPublicID :one
@@ -28,7 +28,7 @@ SELECT
         WHERE ups.project_id = p.id
     ) THEN 1 ELSE 0 END as is_shared
 FROM projects p
-WHERE p.public_id = ? AND p.user_id = ?;
+WHERE p.public_id = ? AND p.user_id = ? AND p.deleted_at IS NULL;
 
 -- name: GetProjectByPublicIDNoFilter :one
 SELECT
@@ -38,7 +38,7 @@ SELECT
         WHERE ups.project_id = p.id
     ) THEN 1 ELSE 0 END as is_shared
 FROM projects p
-WHERE p.public_id = ?;
+WHERE p.public_id = ? AND p.deleted_at IS NULL;
 
 -- name: UpdateProject :one
 UPDATE projects
@@ -101,7 +101,7 @@ SELECT
     u.username as owner_username
 FROM projects p
 JOIN users u ON p.user_id = u.id
-WHERE p.user_id = ? AND p.folder_id IS NULL
+WHERE p.user_id = ? AND p.folder_id IS NULL AND p.deleted_at IS NULL
 ORDER BY p.created_at ASC;
 
 -- name: UpdateProjectFolder :one
@@ -129,5 +129,9 @@ RETURNING *;
 
 -- name: ListRootProjectsWithCustomOrder :many
 SELECT * FROM projects
-WHERE user_id = ? AND folder_id IS NULL
+WHERE user_id = ? AND folder_id IS NULL AND deleted_at IS NULL
 ORDER BY custom_order ASC, created_at DESC;
+
+-- name: ListProjectStorageRefs :many
+SELECT id, public_id, cover_art_path FROM projects
+ORDER BY id ASC;
@@ -25,3 +25,33 @@ WHERE id = ?;
 -- name: DeleteExpiredRefreshTokens :exec
 DELETE FROM refresh_tokens
 WHERE expires_at < CURRENT_TIMESTAMP;
+
+-- name: GetRefreshTokenByID :one
+SELECT * FROM refresh_tokens
+WHERE id = ?;
+
+-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET token_hash = sqlc.arg(token_hash),
+    expires_at = sqlc.arg(expires_at),
+    user_agent = sqlc.arg(user_agent),
+    ip = sqlc.arg(ip),
+    last_used_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND token_hash = sqlc.arg(old_token_hash) AND revoked_at IS NULL;
+
+-- name: ListActiveRefreshTokensByUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id = sqlc.arg(user_id)
+  AND revoked_at IS NULL
+  AND expires_at > sqlc.arg(now)
+ORDER BY COALESCE(last_used_at, created_at) DESC, id DESC;
+
+-- name: RevokeUserRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = ? AND user_id = ? AND revoked_at IS NULL;
+
+-- name: RevokeOtherRefreshTokensByUser :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = sqlc.arg(user_id) AND id != sqlc.arg(keep_id) AND revoked_at IS NULL;
@@ -188,13 +188,13 @@ RETURNING *;
 
 -- name: GetPublicTracks :many
 SELECT * FROM tracks
-WHERE visibility_status = 'public'
+WHERE visibility_status = 'public' AND deleted_at IS NULL
 ORDER BY created_at DESC
 LIMIT ? OFFSET ?;
 
 -- name: GetPublicProjects :many
 SELECT * FROM projects
-WHERE visibility_status = 'public'
+WHERE visibility_status = 'public' AND deleted_at IS NULL
 ORDER BY created_at DESC
 LIMIT ? OFFSET ?;
 
@@ -349,7 +349,7 @@ WHERE project_id = ? AND shared_to = ?;
 -- name: ListProjectsSharedWithUser :many
 SELECT DISTINCT p.* FROM projects p
 JOIN user_project_shares ups ON p.id = ups.project_id
-WHERE ups.shared_to = ?
+WHERE ups.shared_to = ? AND p.deleted_at IS NULL
 ORDER BY p.created_at DESC;
 
 -- name: ListProjectsSharedByUser :many
@@ -398,7 +398,7 @@ WHERE id = ?;
 -- name: ListTracksSharedWithUser :many
 SELECT DISTINCT t.* FROM tracks t
 JOIN user_track_shares uts ON t.id = uts.track_id
-WHERE uts.shared_to = ?
+WHERE uts.shared_to = ? AND t.deleted_at IS NULL
 ORDER BY t.created_at DESC;
 
 -- name: ListTracksSharedByUser :many
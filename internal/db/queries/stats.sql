@@ -24,3 +24,11 @@ SELECT
 FROM track_files tf
 INNER JOIN track_versions tv ON tf.version_id = tv.id
 INNER JOIN tracks t ON tv.track_id = t.id;
+
+-- name: GetInstanceSummary :one
+SELECT
+    (SELECT COUNT(*) FROM users) as user_count,
+    (SELECT COUNT(*) FROM projects) as project_count,
+    (SELECT COUNT(*) FROM tracks) as track_count,
+    (SELECT COUNT(*) FROM track_versions) as version_count,
+    (SELECT COUNT(*) FROM track_files) as file_count;
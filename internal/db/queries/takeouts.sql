@@ -0,0 +1,144 @@
+-- name: CreateTakeout :one
+INSERT INTO takeouts (user_id, created_at)
+VALUES (?, ?)
+RETURNING *;
+
+-- name: FinishTakeout :one
+UPDATE takeouts
+SET finished_at = ?,
+    status = ?,
+    expires_at = ?,
+    file_name = ?,
+    size_bytes = ?,
+    error = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: FailBuildingTakeouts :exec
+UPDATE takeouts
+SET finished_at = ?,
+    status = 'failed',
+    error = ?
+WHERE status = 'building';
+
+-- name: GetTakeout :one
+SELECT * FROM takeouts
+WHERE id = ? AND user_id = ?;
+
+-- name: GetLatestTakeout :one
+SELECT * FROM takeouts
+WHERE user_id = ?
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: ListKeptTakeoutFiles :many
+SELECT file_name FROM takeouts
+WHERE status = 'ready' AND expires_at > ? AND file_name IS NOT NULL;
+
+-- name: ExpireTakeouts :exec
+UPDATE takeouts
+SET status = 'expired'
+WHERE status = 'ready' AND expires_at <= ?;
+
+-- name: DeleteOldTakeouts :exec
+DELETE FROM takeouts
+WHERE status != 'building' AND created_at < ?;
+
+-- name: ListTakeoutTrackNotes :many
+SELECT
+    n.content,
+    n.author_name,
+    n.updated_at,
+    t.public_id AS track_public_id,
+    t.title AS track_title,
+    p.name AS project_name,
+    u.username AS owner_username
+FROM notes n
+JOIN tracks t ON n.track_id = t.id
+JOIN projects p ON p.id = t.project_id
+JOIN users u ON u.id = t.user_id
+WHERE n.user_id = ? AND t.user_id != n.user_id
+ORDER BY n.updated_at DESC;
+
+-- name: ListTakeoutProjectNotes :many
+SELECT
+    n.content,
+    n.author_name,
+    n.updated_at,
+    p.public_id AS project_public_id,
+    p.name AS project_name,
+    u.username AS owner_username
+FROM notes n
+JOIN projects p ON p.id = n.project_id
+JOIN users u ON u.id = p.user_id
+WHERE n.user_id = ? AND p.user_id != n.user_id
+ORDER BY n.updated_at DESC;
+
+-- name: ListTakeoutContributedTracks :many
+SELECT t.* FROM tracks t
+JOIN projects p ON p.id = t.project_id
+WHERE t.user_id = ? AND p.user_id != t.user_id
+  AND t.deleted_at IS NULL AND p.deleted_at IS NULL
+ORDER BY t.project_id, t.track_order;
+
+-- name: ListTakeoutProjectShares :many
+SELECT
+    p.public_id AS project_public_id,
+    p.name AS project_name,
+    u.username AS shared_to_username,
+    ups.can_edit,
+    ups.can_download,
+    ups.created_at
+FROM user_project_shares ups
+JOIN projects p ON p.id = ups.project_id
+JOIN users u ON u.id = ups.shared_to
+WHERE ups.shared_by = ?
+ORDER BY ups.created_at;
+
+-- name: ListTakeoutTrackShares :many
+SELECT
+    t.public_id AS track_public_id,
+    t.title AS track_title,
+    u.username AS shared_to_username,
+    uts.can_edit,
+    uts.can_download,
+    uts.created_at
+FROM user_track_shares uts
+JOIN tracks t ON t.id = uts.track_id
+JOIN users u ON u.id = uts.shared_to
+WHERE uts.shared_by = ?
+ORDER BY uts.created_at;
+
+-- name: ListTakeoutTrackShareLinks :many
+SELECT
+    t.public_id AS track_public_id,
+    t.title AS track_title,
+    st.visibility_type,
+    st.allow_editing,
+    st.allow_downloads,
+    st.password_hash IS NOT NULL AS password_protected,
+    st.expires_at,
+    st.max_access_count,
+    st.current_access_count,
+    st.created_at
+FROM share_tokens st
+JOIN tracks t ON t.id = st.track_id
+WHERE st.user_id = ?
+ORDER BY st.created_at;
+
+-- name: ListTakeoutProjectShareLinks :many
+SELECT
+    p.public_id AS project_public_id,
+    p.name AS project_name,
+    pst.visibility_type,
+    pst.allow_editing,
+    pst.allow_downloads,
+    pst.password_hash IS NOT NULL AS password_protected,
+    pst.expires_at,
+    pst.max_access_count,
+    pst.current_access_count,
+    pst.created_at
+FROM project_share_tokens pst
+JOIN projects p ON p.id = pst.project_id
+WHERE pst.user_id = ?
+ORDER BY pst.created_at;
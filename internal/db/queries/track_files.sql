@@ -46,3 +46,53 @@ LIMIT 1;
 -- name: ListAllTrackFiles :many
 SELECT * FROM track_files
 ORDER BY id ASC;
+
+-- name: ListCompactionCandidates :many
+SELECT * FROM track_files
+WHERE quality = 'source'
+  AND format IN ('wav', 'aiff', 'aif')
+  AND compaction_status IS NULL
+  AND transcoding_status = 'completed'
+ORDER BY id ASC
+LIMIT ?;
+
+-- name: CompactTrackFile :execrows
+UPDATE track_files
+SET file_path = ?,
+    file_size = ?,
+    content_hash = ?,
+    stored_format = ?,
+    original_codec = ?,
+    original_size = ?,
+    pcm_hash = ?,
+    compaction_status = 'compacted'
+WHERE id = ? AND file_path = ?;
+
+-- name: SetCompactionStatus :exec
+UPDATE track_files
+SET compaction_status = ?
+WHERE id = ?;
+
+-- name: CopyTrackFileCompaction :exec
+UPDATE track_files
+SET stored_format = ?,
+    original_codec = ?,
+    original_size = ?,
+    pcm_hash = ?,
+    compaction_status = ?
+WHERE id = ?;
+
+-- name: GetCompactionStats :one
+SELECT
+    COUNT(CASE WHEN compaction_status IS NULL THEN 1 END) as pending_files,
+    COUNT(CASE WHEN compaction_status = 'compacted' THEN 1 END) as compacted_files,
+    COUNT(CASE WHEN compaction_status = 'skipped' THEN 1 END) as skipped_files,
+    COUNT(CASE WHEN compaction_status = 'failed' THEN 1 END) as failed_files,
+    COALESCE(SUM(CASE WHEN compaction_status = 'compacted' THEN original_size - file_size ELSE 0 END), 0) as saved_bytes
+FROM track_files
+WHERE quality = 'source' AND format IN ('wav', 'aiff', 'aif');
+
+-- name: ResetFailedCompactions :exec
+UPDATE track_files
+SET compaction_status = NULL
+WHERE compaction_status = 'failed';
@@ -5,19 +5,19 @@ RETURNING *;
 
 -- name: GetTrack :one
 SELECT * FROM tracks
-WHERE id = ? AND user_id = ?;
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL;
 
 -- name: GetTrackByID :one
 SELECT * FROM tracks
-WHERE id = ?;
+WHERE id = ? AND deleted_at IS NULL;
 
 -- name: GetTrackByPublicID :one
 SELECT * FROM tracks
-WHERE public_id = ? AND user_id = ?;
+WHERE public_id = ? AND user_id = ? AND deleted_at IS NULL;
 
 -- name: GetTrackByPublicIDNoFilter :one
 SELECT * FROM tracks
-WHERE public_id = ?;
+WHERE public_id = ? AND deleted_at IS NULL;
 
 -- name: ListTracksByUser :many
 SELECT
@@ -31,7 +31,7 @@ FROM tracks t
 LEFT JOIN track_versions tv ON t.active_version_id = tv.id
 LEFT JOIN track_files tf ON tv.id = tf.version_id AND tf.quality = 'lossy'
 JOIN projects p ON t.project_id = p.id
-WHERE p.user_id = ?
+WHERE p.user_id = ? AND t.deleted_at IS NULL AND p.deleted_at IS NULL
 ORDER BY t.created_at DESC;
 
 -- name: ListTracksByProject :many
@@ -53,7 +53,7 @@ FROM tracks t
 LEFT JOIN track_versions tv ON t.active_version_id = tv.id
 LEFT JOIN track_files tf ON tv.id = tf.version_id AND tf.quality = 'lossy'
 JOIN projects p ON t.project_id = p.id
-WHERE t.user_id = ? AND t.project_id = ?
+WHERE t.user_id = ? AND t.project_id = ? AND t.deleted_at IS NULL
 ORDER BY t.track_order ASC;
 
 -- name: UpdateTrack :one
@@ -114,7 +114,7 @@ FROM tracks t
 LEFT JOIN track_versions tv ON t.active_version_id = tv.id
 LEFT JOIN track_files tf ON tv.id = tf.version_id AND tf.quality = 'lossy'
 JOIN projects p ON t.project_id = p.id
-WHERE t.id = ? AND t.user_id = ?;
+WHERE t.id = ? AND t.user_id = ? AND t.deleted_at IS NULL;
 
 -- name: UpdateTrackOrder :exec
 UPDATE tracks
@@ -152,12 +152,12 @@ SET bpm = NULL, key = NULL, updated_at = CURRENT_TIMESTAMP;
 
 -- name: ListPlainTracksByProject :many
 SELECT * FROM tracks
-WHERE user_id = ? AND project_id = ?
+WHERE user_id = ? AND project_id = ? AND deleted_at IS NULL
 ORDER BY track_order ASC;
 
 -- name: ListTracksByProjectID :many
 SELECT * FROM tracks
-WHERE project_id = ?
+WHERE project_id = ? AND deleted_at IS NULL
 ORDER BY track_order ASC;
 
 -- name: ListTracksWithDetailsByProjectID :many
@@ -179,7 +179,7 @@ FROM tracks t
 LEFT JOIN track_versions tv ON t.active_version_id = tv.id
 LEFT JOIN track_files tf ON tv.id = tf.version_id AND tf.quality = 'lossy'
 JOIN projects p ON t.project_id = p.id
-WHERE t.project_id = ?
+WHERE t.project_id = ? AND t.deleted_at IS NULL
 ORDER BY t.track_order ASC;
 
 -- name: GetMaxTrackOrderByProject :one
@@ -187,56 +187,3 @@ SELECT COALESCE(MAX(track_order), -1) as max_order
 FROM tracks
 WHERE project_id = ?;
 
--- name: SearchTracksAccessibleByUser :many
-SELECT DISTINCT
-    t.id,
-    t.user_id,
-    t.project_id,
-    t.public_id,
-    t.title,
-    t.artist,
-    t.album,
-    t.key,
-    t.bpm,
-    t.notes,
-    t.notes_author_name,
-    t.notes_updated_at,
-    t.active_version_id,
-    t.track_order,
-    t.visibility_status,
-    t.created_at,
-    t.updated_at,
-    COALESCE(tv.version_name, '') as active_version_name,
-    tv.duration_seconds as active_version_duration_seconds,
-    p.name as project_name,
-    tf.waveform as waveform,
-    tf.transcoding_status as lossy_transcoding_status,
-    CASE WHEN EXISTS (
-        SELECT 1 FROM user_project_shares ups
-        WHERE ups.project_id = t.project_id
-        AND ups.shared_to = sqlc.arg(user_id)
-    ) THEN 1 ELSE 0 END as is_shared
-FROM tracks t
-LEFT JOIN track_versions tv ON t.active_version_id = tv.id
-LEFT JOIN track_files tf ON tv.id = tf.version_id AND tf.quality = 'lossy'
-JOIN projects p ON t.project_id = p.id
-WHERE (
-    -- User's own projects
-    p.user_id = sqlc.arg(user_id)
-    OR
-    -- Projects shared with user
-    EXISTS (
-        SELECT 1 FROM user_project_shares ups
-        WHERE ups.project_id = p.id
-        AND ups.shared_to = sqlc.arg(user_id)
-    )
-)
-AND (
-    -- Search filter (case-insensitive)
-    sqlc.arg(search_query) = '' OR
-    LOWER(t.title) LIKE '%' || LOWER(sqlc.arg(search_query)) || '%' OR
-    LOWER(COALESCE(t.artist, '')) LIKE '%' || LOWER(sqlc.arg(search_query)) || '%' OR
-    LOWER(p.name) LIKE '%' || LOWER(sqlc.arg(search_query)) || '%'
-)
-ORDER BY t.updated_at DESC
-LIMIT sqlc.arg(limit_count);
@@ -0,0 +1,158 @@
+-- name: TrashProject :exec
+UPDATE projects
+SET deleted_at = ?
+WHERE id = ? AND user_id = ?;
+
+-- name: TrashProjectTracks :exec
+UPDATE tracks
+SET deleted_at = ?
+WHERE project_id = ? AND deleted_at IS NULL;
+
+-- name: TrashTrack :exec
+UPDATE tracks
+SET deleted_at = ?
+WHERE id = ?;
+
+-- name: TrashTrackVersion :exec
+UPDATE track_versions
+SET deleted_at = ?
+WHERE id = ?;
+
+-- name: TrashFolder :exec
+UPDATE folders
+SET deleted_at = ?
+WHERE id = ? AND user_id = ?;
+
+-- name: RestoreProjectTracks :exec
+UPDATE tracks
+SET deleted_at = NULL
+WHERE project_id = sqlc.arg(project_id)
+  AND deleted_at = (SELECT p.deleted_at FROM projects p WHERE p.id = sqlc.arg(project_id));
+
+-- name: RestoreProject :exec
+UPDATE projects
+SET deleted_at = NULL
+WHERE id = ?;
+
+-- name: RestoreTrack :exec
+UPDATE tracks
+SET deleted_at = NULL
+WHERE id = ?;
+
+-- name: RestoreTrackVersion :exec
+UPDATE track_versions
+SET deleted_at = NULL
+WHERE id = ?;
+
+-- name: RestoreFolder :exec
+UPDATE folders
+SET deleted_at = NULL,
+    parent_id = ?
+WHERE id = ?;
+
+-- name: GetTrashedProject :one
+SELECT * FROM projects
+WHERE public_id = ? AND user_id = ? AND deleted_at IS NOT NULL;
+
+-- name: GetTrashedTrack :one
+SELECT
+    t.*,
+    p.public_id as project_public_id,
+    p.deleted_at as project_deleted_at
+FROM tracks t
+JOIN projects p ON t.project_id = p.id
+WHERE t.public_id = ? AND p.user_id = ? AND t.deleted_at IS NOT NULL;
+
+-- name: GetTrashedTrackVersion :one
+SELECT
+    tv.*,
+    t.project_id,
+    t.deleted_at as track_deleted_at,
+    p.public_id as project_public_id,
+    p.deleted_at as project_deleted_at
+FROM track_versions tv
+JOIN tracks t ON tv.track_id = t.id
+JOIN projects p ON t.project_id = p.id
+WHERE tv.id = ? AND p.user_id = ? AND tv.deleted_at IS NOT NULL;
+
+-- name: GetTrashedFolder :one
+SELECT * FROM folders
+WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL;
+
+-- name: ListTrashedProjects :many
+SELECT * FROM projects
+WHERE user_id = ? AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
+
+-- name: ListTrashedTracks :many
+SELECT
+    t.*,
+    p.public_id as project_public_id,
+    p.name as project_name
+FROM tracks t
+JOIN projects p ON t.project_id = p.id
+WHERE p.user_id = ? AND t.deleted_at IS NOT NULL AND p.deleted_at IS NULL
+ORDER BY t.deleted_at DESC;
+
+-- name: ListTrashedTrackVersions :many
+SELECT
+    tv.*,
+    t.public_id as track_public_id,
+    t.title as track_title,
+    p.public_id as project_public_id
+FROM track_versions tv
+JOIN tracks t ON tv.track_id = t.id
+JOIN projects p ON t.project_id = p.id
+WHERE p.user_id = ? AND tv.deleted_at IS NOT NULL
+  AND t.deleted_at IS NULL AND p.deleted_at IS NULL
+ORDER BY tv.deleted_at DESC;
+
+-- name: ListTrashedFolders :many
+SELECT f.* FROM folders f
+LEFT JOIN folders parent ON f.parent_id = parent.id
+WHERE f.user_id = ? AND f.deleted_at IS NOT NULL
+  AND (parent.id IS NULL OR parent.deleted_at IS NULL OR parent.deleted_at != f.deleted_at)
+ORDER BY f.deleted_at DESC;
+
+-- name: ListFoldersTrashedWithParent :many
+SELECT * FROM folders
+WHERE parent_id = sqlc.arg(parent_id)
+  AND deleted_at = (SELECT f.deleted_at FROM folders f WHERE f.id = sqlc.arg(parent_id));
+
+-- name: ListExpiredTrashedProjects :many
+SELECT * FROM projects
+WHERE deleted_at IS NOT NULL AND deleted_at < ?;
+
+-- name: ListExpiredTrashedTracks :many
+SELECT
+    t.*,
+    p.public_id as project_public_id
+FROM tracks t
+JOIN projects p ON t.project_id = p.id
+WHERE t.deleted_at IS NOT NULL AND t.deleted_at < ?;
+
+-- name: ListExpiredTrashedTrackVersions :many
+SELECT
+    tv.*,
+    t.project_id,
+    p.public_id as project_public_id
+FROM track_versions tv
+JOIN tracks t ON tv.track_id = t.id
+JOIN projects p ON t.project_id = p.id
+WHERE tv.deleted_at IS NOT NULL AND tv.deleted_at < ?;
+
+-- name: ListExpiredTrashedFolders :many
+SELECT * FROM folders
+WHERE deleted_at IS NOT NULL AND deleted_at < ?;
+
+-- name: CreateTrashedFolderContent :exec
+INSERT INTO trashed_folder_contents (folder_id, user_id, item_type, item_id, custom_order)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: ListTrashedFolderContents :many
+SELECT * FROM trashed_folder_contents
+WHERE folder_id = ?;
+
+-- name: DeleteTrashedFolderContents :exec
+DELETE FROM trashed_folder_contents
+WHERE folder_id = ?;
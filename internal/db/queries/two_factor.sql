@@ -0,0 +1,79 @@
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+WHERE user_id = ?;
+
+-- name: SetPendingTOTPSecret :exec
+INSERT INTO user_totp (user_id, pending_secret)
+VALUES (?, ?)
+ON CONFLICT(user_id) DO UPDATE SET
+    pending_secret = excluded.pending_secret;
+
+-- name: ConfirmTOTPSecret :exec
+UPDATE user_totp
+SET secret = pending_secret,
+    pending_secret = NULL,
+    enabled_at = ?,
+    last_step = ?
+WHERE user_id = ? AND pending_secret IS NOT NULL;
+
+-- name: UpdateTOTPLastStep :execrows
+UPDATE user_totp
+SET last_step = sqlc.arg(step)
+WHERE user_id = sqlc.arg(user_id) AND last_step < sqlc.arg(step);
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE user_id = ?;
+
+-- name: ListTwoFactorUserIDs :many
+SELECT user_id FROM user_totp
+WHERE secret IS NOT NULL;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO user_recovery_codes (user_id, code_hash)
+VALUES (?, ?);
+
+-- name: UseRecoveryCode :execrows
+UPDATE user_recovery_codes
+SET used_at = CURRENT_TIMESTAMP
+WHERE user_id = ? AND code_hash = ? AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM user_recovery_codes
+WHERE user_id = ? AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM user_recovery_codes
+WHERE user_id = ?;
+
+-- name: CreateLoginChallenge :exec
+INSERT INTO login_challenges (user_id, token_hash, expires_at)
+VALUES (?, ?, ?);
+
+-- name: GetLoginChallenge :one
+SELECT * FROM login_challenges
+WHERE token_hash = ?;
+
+-- name: CountLoginChallengeAttempt :one
+-- Returns no row once the challenge is out of attempts.
+UPDATE login_challenges
+SET attempts = attempts + 1
+WHERE id = sqlc.arg(id)
+  AND attempts < sqlc.arg(max_attempts)
+RETURNING *;
+
+-- name: DeleteLoginChallenge :exec
+DELETE FROM login_challenges
+WHERE id = ?;
+
+-- name: DeleteExpiredLoginChallenges :exec
+DELETE FROM login_challenges
+WHERE expires_at < ?;
+
+-- name: GetRequireTwoFactor :one
+SELECT require_two_factor FROM instance_settings WHERE id = 1;
+
+-- name: SetRequireTwoFactor :exec
+UPDATE instance_settings
+SET require_two_factor = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = 1;
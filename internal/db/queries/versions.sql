@@ -5,11 +5,11 @@ RETURNING *;
 
 -- name: GetTrackVersion :one
 SELECT * FROM track_versions
-WHERE id = ?;
+WHERE id = ? AND deleted_at IS NULL;
 
 -- name: ListTrackVersions :many
 SELECT * FROM track_versions
-WHERE track_id = ?
+WHERE track_id = ? AND deleted_at IS NULL
 ORDER BY version_order ASC, created_at ASC;
 
 -- name: ListTrackVersionsWithMetadata :many
@@ -22,7 +22,7 @@ SELECT
 FROM track_versions tv
 LEFT JOIN track_files tf_source ON tv.id = tf_source.version_id AND tf_source.quality = 'source'
 LEFT JOIN track_files tf_lossy ON tv.id = tf_lossy.version_id AND tf_lossy.quality = 'lossy'
-WHERE tv.track_id = ?
+WHERE tv.track_id = ? AND tv.deleted_at IS NULL
 ORDER BY tv.version_order ASC, tv.created_at ASC;
 
 -- name: UpdateTrackVersion :one
@@ -45,13 +45,13 @@ WHERE id = ?;
 
 -- name: CountTrackVersions :one
 SELECT COUNT(*) FROM track_versions
-WHERE track_id = ?;
+WHERE track_id = ? AND deleted_at IS NULL;
 
 -- name: GetTrackVersionWithOwnership :one
 SELECT tv.*, t.user_id
 FROM track_versions tv
 JOIN tracks t ON tv.track_id = t.id
-WHERE tv.id = ?;
+WHERE tv.id = ? AND tv.deleted_at IS NULL;
 
 -- name: GetMaxVersionOrder :one
 SELECT COALESCE(MAX(version_order), -1) as max_order
@@ -0,0 +1,618 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Snippet highlight markers. They are swapped for <mark> tags once the rest
+// of the snippet has been HTML-escaped.
+const (
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+)
+
+// Values of TrackSearchRow.MatchedIn.
+const (
+	MatchTrack   = "track"
+	MatchProject = "project"
+	MatchVersion = "version"
+	MatchNote    = "note"
+)
+
+// Track search sort orders.
+const (
+	SortRelevance = "relevance"
+	SortUpdated   = "updated"
+	SortCreated   = "created"
+	SortTitle     = "title"
+	SortBPM       = "bpm"
+	SortDuration  = "duration"
+)
+
+// OwnerMe as TrackFilters.Owner selects the searching user's own projects.
+const OwnerMe = "me"
+
+// defaultSearchLimit applies when TrackSearch.Limit is not set.
+const defaultSearchLimit = 100
+
+// ErrInvalidSearch wraps errors caused by the search input rather than by
+// the database.
+var ErrInvalidSearch = errors.New("invalid search")
+
+// TrackFilters narrows a track search; unset fields match everything. Its
+// JSON form is what smart folders store.
+type TrackFilters struct {
+	// Text is free text; see FTSQuery.
+	Text   string `json:"q,omitempty"`
+	BPMMin *int64 `json:"bpm_min,omitempty"`
+	BPMMax *int64 `json:"bpm_max,omitempty"`
+	// Keys matches any of the given keys in either spelling, e.g. "A minor",
+	// "Am" or "8A".
+	Keys []string `json:"keys,omitempty"`
+	// CompatibleKey matches the key, its neighbours on the circle of fifths
+	// and its relative major or minor.
+	CompatibleKey string `json:"compatible_key,omitempty"`
+	// DurationMin and DurationMax are in seconds, measured on the active
+	// version.
+	DurationMin   *float64   `json:"duration_min,omitempty"`
+	DurationMax   *float64   `json:"duration_max,omitempty"`
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+	UpdatedAfter  *time.Time `json:"updated_after,omitempty"`
+	UpdatedBefore *time.Time `json:"updated_before,omitempty"`
+	// Owner is the username of the project owner, or OwnerMe.
+	Owner string `json:"owner,omitempty"`
+	// Shared limits results to projects shared with the user when true and
+	// to their own projects when false.
+	Shared *bool `json:"shared,omitempty"`
+	// Project is a project public ID.
+	Project string `json:"project,omitempty"`
+	// FolderID matches the user's own projects filed in the folder and the
+	// shared projects they placed there.
+	FolderID *int64 `json:"folder_id,omitempty"`
+}
+
+// Validate reports filters that cannot be parsed or can never match.
+func (f TrackFilters) Validate() error {
+	if f.BPMMin != nil && f.BPMMax != nil && *f.BPMMin > *f.BPMMax {
+		return fmt.Errorf("%w: bpm_min is greater than bpm_max", ErrInvalidSearch)
+	}
+	if f.DurationMin != nil && f.DurationMax != nil && *f.DurationMin > *f.DurationMax {
+		return fmt.Errorf("%w: duration_min is greater than duration_max", ErrInvalidSearch)
+	}
+	if f.CreatedAfter != nil && f.CreatedBefore != nil && !f.CreatedAfter.Before(*f.CreatedBefore) {
+		return fmt.Errorf("%w: created_after is not before created_before", ErrInvalidSearch)
+	}
+	if f.UpdatedAfter != nil && f.UpdatedBefore != nil && !f.UpdatedAfter.Before(*f.UpdatedBefore) {
+		return fmt.Errorf("%w: updated_after is not before updated_before", ErrInvalidSearch)
+	}
+	for _, key := range append(f.Keys, f.CompatibleKey) {
+		if key == "" {
+			continue
+		}
+		if _, err := parseKey(key); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSearch, err)
+		}
+	}
+	return nil
+}
+
+// TrackSearch describes a search over the tracks a user can see: tracks in
+// their own projects and in projects shared with them.
+type TrackSearch struct {
+	UserID int64
+	TrackFilters
+	// Sort is one of the Sort constants. It defaults to SortRelevance for
+	// text searches and SortUpdated otherwise.
+	Sort string
+	// Order is "asc" or "desc". By default the best match, the newest or the
+	// longest track comes first, and titles run A to Z.
+	Order string
+	// Cursor continues a previous search from where its page ended.
+	Cursor string
+	Limit  int64
+}
+
+type TrackSearchRow struct {
+	ID                           int64
+	UserID                       int64
+	ProjectID                    int64
+	PublicID                     string
+	Title                        string
+	Artist                       sql.NullString
+	Album                        sql.NullString
+	Key                          sql.NullString
+	Bpm                          sql.NullInt64
+	Notes                        sql.NullString
+	NotesAuthorName              sql.NullString
+	NotesUpdatedAt               sql.NullTime
+	ActiveVersionID              sql.NullInt64
+	TrackOrder                   int64
+	VisibilityStatus             string
+	CreatedAt                    sql.NullTime
+	UpdatedAt                    sql.NullTime
+	ActiveVersionName            string
+	ActiveVersionDurationSeconds sql.NullFloat64
+	ProjectName                  string
+	Waveform                     sql.NullString
+	LossyTranscodingStatus       sql.NullString
+	IsShared                     int64
+	// Rank is the bm25 score of the best match; lower is better.
+	Rank float64
+	// MatchedIn says where the best match was found, and Snippet holds the
+	// matching text as escaped HTML with the hits wrapped in <mark>.
+	MatchedIn string
+	Snippet   string
+}
+
+const trackSearchColumns = `
+	t.id,
+	t.user_id,
+	t.project_id,
+	t.public_id,
+	t.title,
+	t.artist,
+	t.album,
+	t.key,
+	t.bpm,
+	t.notes,
+	t.notes_author_name,
+	t.notes_updated_at,
+	t.active_version_id,
+	t.track_order,
+	t.visibility_status,
+	t.created_at,
+	t.updated_at,
+	COALESCE(tv.version_name, '') AS active_version_name,
+	tv.duration_seconds AS active_version_duration_seconds,
+	p.name AS project_name,
+	tf.waveform AS waveform,
+	tf.transcoding_status AS lossy_transcoding_status,
+	CASE WHEN EXISTS (
+		SELECT 1 FROM user_project_shares ups
+		WHERE ups.project_id = t.project_id
+		AND ups.shared_to = @user_id
+	) THEN 1 ELSE 0 END AS is_shared`
+
+const trackSearchJoins = `
+JOIN projects p ON t.project_id = p.id
+LEFT JOIN track_versions tv ON t.active_version_id = tv.id
+LEFT JOIN track_files tf ON tv.id = tf.version_id AND tf.quality = 'lossy'`
+
+const trackSearchAccess = `
+t.deleted_at IS NULL AND p.deleted_at IS NULL
+AND (
+	p.user_id = @user_id
+	OR EXISTS (
+		SELECT 1 FROM user_project_shares ups
+		WHERE ups.project_id = p.id
+		AND ups.shared_to = @user_id
+	)
+)`
+
+// trackSearchMatches finds tracks whose own text, project, versions or notes
+// match @query. A project or project note match counts for all of the
+// project's tracks. Only the best match per track is kept; SQLite returns
+// the bare columns of the row that produced MIN(rank).
+const trackSearchMatches = `
+WITH matches AS (
+	SELECT rowid AS track_id,
+		bm25(tracks_fts, 10.0, 5.0, 3.0) AS rank,
+		'track' AS matched_in,
+		snippet(tracks_fts, -1, char(2), char(3), '…', 12) AS snippet
+	FROM tracks_fts
+	WHERE tracks_fts MATCH @query
+	UNION ALL
+	SELECT t.id,
+		bm25(projects_fts, 4.0, 1.0),
+		'project',
+		snippet(projects_fts, -1, char(2), char(3), '…', 12)
+	FROM projects_fts
+	JOIN tracks t ON t.project_id = projects_fts.rowid
+	WHERE projects_fts MATCH @query
+	UNION ALL
+	SELECT tv.track_id,
+		bm25(track_versions_fts, 3.0, 1.0),
+		'version',
+		snippet(track_versions_fts, -1, char(2), char(3), '…', 12)
+	FROM track_versions_fts
+	JOIN track_versions tv ON tv.id = track_versions_fts.rowid
+	WHERE track_versions_fts MATCH @query AND tv.deleted_at IS NULL
+	UNION ALL
+	SELECT COALESCE(n.track_id, t.id),
+		bm25(notes_fts, 1.0, 0.5),
+		'note',
+		snippet(notes_fts, -1, char(2), char(3), '…', 12)
+	FROM notes_fts
+	JOIN notes n ON n.id = notes_fts.rowid
+	LEFT JOIN tracks t ON t.project_id = n.project_id
+	WHERE notes_fts MATCH @query AND COALESCE(n.track_id, t.id) IS NOT NULL
+),
+best AS (
+	SELECT track_id, MIN(rank) AS rank, matched_in, snippet
+	FROM matches
+	GROUP BY track_id
+)`
+
+// searchCursor marks the last row of a page: its sort key and ID.
+type searchCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d"`
+	Key  any    `json:"k"`
+	ID   int64  `json:"i"`
+}
+
+func (c searchCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSearchCursor(s string) (searchCursor, error) {
+	var c searchCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("%w: malformed cursor", ErrInvalidSearch)
+	}
+	return c, nil
+}
+
+// searchOrder resolves the sort of a search. orderExpr is what rows are
+// ordered and paged by; keyExpr selects the same value in a form that
+// round-trips through a cursor. Missing BPMs and durations sort last.
+func (s TrackSearch) searchOrder(hasText bool) (sort, orderExpr, keyExpr string, desc bool, err error) {
+	sort = s.Sort
+	if sort == "" || (sort == SortRelevance && !hasText) {
+		sort = SortUpdated
+		if hasText {
+			sort = SortRelevance
+		}
+	}
+	desc = sort != SortRelevance && sort != SortTitle
+	switch s.Order {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		return "", "", "", false, fmt.Errorf("%w: order must be asc or desc", ErrInvalidSearch)
+	}
+
+	missing := "1e12"
+	if desc {
+		missing = "-1"
+	}
+
+	switch sort {
+	case SortRelevance:
+		orderExpr, keyExpr = "best.rank", "best.rank"
+	case SortUpdated:
+		orderExpr, keyExpr = "t.updated_at", "CAST(t.updated_at AS TEXT)"
+	case SortCreated:
+		orderExpr, keyExpr = "t.created_at", "CAST(t.created_at AS TEXT)"
+	case SortTitle:
+		orderExpr, keyExpr = "t.title COLLATE NOCASE", "t.title"
+	case SortBPM:
+		orderExpr = "COALESCE(t.bpm, " + missing + ")"
+		keyExpr = orderExpr
+	case SortDuration:
+		orderExpr = "COALESCE(tv.duration_seconds, " + missing + ")"
+		keyExpr = orderExpr
+	default:
+		return "", "", "", false, fmt.Errorf("%w: unknown sort %q", ErrInvalidSearch, sort)
+	}
+	return sort, orderExpr, keyExpr, desc, nil
+}
+
+// trackQuery accumulates the conditions and named arguments of a search.
+type trackQuery struct {
+	match string
+	where []string
+	args  []any
+}
+
+// newTrackQuery starts a query over the tracks userID can see that match
+// filters. The filters must have been validated.
+func newTrackQuery(userID int64, f TrackFilters) *trackQuery {
+	q := &trackQuery{
+		match: FTSQuery(f.Text),
+		where: []string{trackSearchAccess},
+		args:  []any{sql.Named("user_id", userID)},
+	}
+
+	if f.BPMMin != nil {
+		q.where = append(q.where, "t.bpm >= "+q.arg("bpm_min", *f.BPMMin))
+	}
+	if f.BPMMax != nil {
+		q.where = append(q.where, "t.bpm <= "+q.arg("bpm_max", *f.BPMMax))
+	}
+	if len(f.Keys) > 0 {
+		var keys []musicKey
+		for _, k := range f.Keys {
+			key, _ := parseKey(k)
+			keys = append(keys, key)
+		}
+		q.where = append(q.where, q.keyCondition(keys, "key"))
+	}
+	if f.CompatibleKey != "" {
+		key, _ := parseKey(f.CompatibleKey)
+		q.where = append(q.where, q.keyCondition(key.compatible(), "compatible_key"))
+	}
+	if f.DurationMin != nil {
+		q.where = append(q.where, "tv.duration_seconds >= "+q.arg("duration_min", *f.DurationMin))
+	}
+	if f.DurationMax != nil {
+		q.where = append(q.where, "tv.duration_seconds <= "+q.arg("duration_max", *f.DurationMax))
+	}
+	if f.CreatedAfter != nil {
+		q.where = append(q.where, "t.created_at >= "+q.arg("created_after", sqliteTime(*f.CreatedAfter)))
+	}
+	if f.CreatedBefore != nil {
+		q.where = append(q.where, "t.created_at < "+q.arg("created_before", sqliteTime(*f.CreatedBefore)))
+	}
+	if f.UpdatedAfter != nil {
+		q.where = append(q.where, "t.updated_at >= "+q.arg("updated_after", sqliteTime(*f.UpdatedAfter)))
+	}
+	if f.UpdatedBefore != nil {
+		q.where = append(q.where, "t.updated_at < "+q.arg("updated_before", sqliteTime(*f.UpdatedBefore)))
+	}
+	if f.Owner == OwnerMe {
+		q.where = append(q.where, "p.user_id = @user_id")
+	} else if f.Owner != "" {
+		q.where = append(q.where, "p.user_id IN (SELECT id FROM users WHERE username = "+q.arg("owner", f.Owner)+" COLLATE NOCASE)")
+	}
+	if f.Shared != nil {
+		if *f.Shared {
+			q.where = append(q.where, "p.user_id != @user_id")
+		} else {
+			q.where = append(q.where, "p.user_id = @user_id")
+		}
+	}
+	if f.Project != "" {
+		q.where = append(q.where, "p.public_id = "+q.arg("project", f.Project))
+	}
+	if f.FolderID != nil {
+		folder := q.arg("folder_id", *f.FolderID)
+		q.where = append(q.where, `(
+	(p.user_id = @user_id AND p.folder_id = `+folder+`)
+	OR EXISTS (
+		SELECT 1 FROM user_shared_project_organization o
+		WHERE o.user_id = @user_id AND o.project_id = p.id AND o.folder_id = `+folder+`
+	)
+)`)
+	}
+	if q.match != "" {
+		q.args = append(q.args, sql.Named("query", q.match))
+	}
+
+	return q
+}
+
+// arg binds value to a named parameter and returns its placeholder.
+func (q *trackQuery) arg(name string, value any) string {
+	q.args = append(q.args, sql.Named(name, value))
+	return "@" + name
+}
+
+// keyCondition matches tracks stored in any spelling of the given keys.
+func (q *trackQuery) keyCondition(keys []musicKey, name string) string {
+	var placeholders []string
+	for _, key := range keys {
+		for _, spelling := range key.spellings() {
+			placeholders = append(placeholders, q.arg(fmt.Sprintf("%s_%d", name, len(placeholders)), spelling))
+		}
+	}
+	return "t.key COLLATE NOCASE IN (" + strings.Join(placeholders, ", ") + ")"
+}
+
+// sql builds the statement selecting columns, followed by tail. Text
+// searches read from the best match per track; the rest from tracks.
+func (q *trackQuery) sql(columns, tail string) string {
+	var query string
+	if q.match != "" {
+		query = trackSearchMatches + `
+SELECT ` + columns + `
+FROM best
+JOIN tracks t ON t.id = best.track_id`
+	} else {
+		query = `
+SELECT ` + columns + `
+FROM tracks t`
+	}
+	return query + trackSearchJoins + `
+WHERE ` + strings.Join(q.where, "\nAND ") + tail
+}
+
+// CountTracks returns how many tracks a user can see match filters.
+func (db *DB) CountTracks(ctx context.Context, userID int64, filters TrackFilters) (int64, error) {
+	if err := filters.Validate(); err != nil {
+		return 0, err
+	}
+	q := newTrackQuery(userID, filters)
+
+	var count int64
+	err := db.QueryRowContext(ctx, q.sql("COUNT(*)", ""), q.args...).Scan(&count)
+	return count, err
+}
+
+// SearchTracks runs a track search. It returns one page of results and the
+// cursor for the next page, which is empty on the last one.
+func (db *DB) SearchTracks(ctx context.Context, search TrackSearch) ([]TrackSearchRow, string, error) {
+	if err := search.Validate(); err != nil {
+		return nil, "", err
+	}
+	limit := search.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	q := newTrackQuery(search.UserID, search.TrackFilters)
+	sort, orderExpr, keyExpr, desc, err := search.searchOrder(q.match != "")
+	if err != nil {
+		return nil, "", err
+	}
+
+	direction, comparison := "ASC", ">"
+	if desc {
+		direction, comparison = "DESC", "<"
+	}
+	if search.Cursor != "" {
+		cursor, err := decodeSearchCursor(search.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if cursor.Sort != sort || cursor.Desc != desc {
+			return nil, "", fmt.Errorf("%w: cursor belongs to a different sort order", ErrInvalidSearch)
+		}
+		q.where = append(q.where, fmt.Sprintf("(%s, t.id) %s (%s, %s)",
+			orderExpr, comparison, q.arg("cursor_key", cursor.Key), q.arg("cursor_id", cursor.ID)))
+	}
+
+	matchColumns := "0.0, '', ''"
+	if q.match != "" {
+		matchColumns = "best.rank, best.matched_in, best.snippet"
+	}
+	query := q.sql(trackSearchColumns+`,
+	`+matchColumns+`,
+	`+keyExpr, `
+ORDER BY `+orderExpr+` `+direction+`, t.id `+direction+`
+LIMIT `+q.arg("limit", limit+1))
+
+	rows, err := db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var items []TrackSearchRow
+	var lastKey any
+	hasMore := false
+	for rows.Next() {
+		var i TrackSearchRow
+		var sortKey any
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ProjectID,
+			&i.PublicID,
+			&i.Title,
+			&i.Artist,
+			&i.Album,
+			&i.Key,
+			&i.Bpm,
+			&i.Notes,
+			&i.NotesAuthorName,
+			&i.NotesUpdatedAt,
+			&i.ActiveVersionID,
+			&i.TrackOrder,
+			&i.VisibilityStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ActiveVersionName,
+			&i.ActiveVersionDurationSeconds,
+			&i.ProjectName,
+			&i.Waveform,
+			&i.LossyTranscodingStatus,
+			&i.IsShared,
+			&i.Rank,
+			&i.MatchedIn,
+			&i.Snippet,
+			&sortKey,
+		); err != nil {
+			return nil, "", err
+		}
+		if int64(len(items)) == limit {
+			// The extra row only tells us there is another page.
+			hasMore = true
+			break
+		}
+		i.Snippet = highlightSnippet(i.Snippet)
+		items = append(items, i)
+		lastKey = sortKey
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if hasMore {
+		next = searchCursor{
+			Sort: sort,
+			Desc: desc,
+			Key:  lastKey,
+			ID:   items[len(items)-1].ID,
+		}.encode()
+	}
+	return items, next, nil
+}
+
+// sqliteTime formats t the way CURRENT_TIMESTAMP stores it, so range
+// filters compare like with like.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// FTSQuery turns user input into an FTS5 query. Words match as prefixes and
+// "quoted phrases" match exactly; all terms must match. Operators and other
+// FTS5 syntax are treated as plain text, so the result is always a valid
+// query. It returns "" when the input holds nothing searchable.
+func FTSQuery(input string) string {
+	var terms []string
+	for {
+		input = strings.TrimLeftFunc(input, unicode.IsSpace)
+		if input == "" {
+			break
+		}
+
+		if input[0] == '"' {
+			phrase := input[1:]
+			input = ""
+			if end := strings.IndexByte(phrase, '"'); end >= 0 {
+				phrase, input = phrase[:end], phrase[end+1:]
+			}
+			if hasSearchableText(phrase) {
+				terms = append(terms, quoteFTS(phrase))
+			}
+			continue
+		}
+
+		word := input
+		input = ""
+		if end := strings.IndexFunc(word, unicode.IsSpace); end >= 0 {
+			word, input = word[:end], word[end:]
+		}
+		word = strings.TrimRight(word, "*")
+		if hasSearchableText(word) {
+			terms = append(terms, quoteFTS(word)+"*")
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+func quoteFTS(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func hasSearchableText(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
+
+func highlightSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, snippetOpen, "<mark>")
+	return strings.ReplaceAll(snippet, snippetClose, "</mark>")
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Musical keys are stored as "<note> <mode>", e.g. "F# minor" or "Bb major",
+// with either sharp or flat spellings.
+
+var (
+	sharpNames = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+	flatNames  = [12]string{"C", "Db", "D", "Eb", "E", "F", "Gb", "G", "Ab", "A", "Bb", "B"}
+
+	notePitch = map[string]int{"c": 0, "d": 2, "e": 4, "f": 5, "g": 7, "a": 9, "b": 11}
+
+	keyPattern     = regexp.MustCompile(`^([A-Ga-g])([#♯b♭]?)\s*((?i:major|minor|maj|min)|m|M)?$`)
+	camelotPattern = regexp.MustCompile(`^(1[0-2]|[1-9])([ABab])$`)
+)
+
+type musicKey struct {
+	pitch int
+	minor bool
+}
+
+// parseKey accepts "A minor", "Am", "F#", "Bbm" or Camelot notation such as
+// "8A". A bare note is major.
+func parseKey(s string) (musicKey, error) {
+	s = strings.TrimSpace(s)
+
+	if m := camelotPattern.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		// 1A is Ab minor and 1B is B major; each step adds a fifth.
+		if strings.EqualFold(m[2], "a") {
+			return musicKey{pitch: (8 + 7*(n-1)) % 12, minor: true}, nil
+		}
+		return musicKey{pitch: (11 + 7*(n-1)) % 12}, nil
+	}
+
+	m := keyPattern.FindStringSubmatch(s)
+	if m == nil {
+		return musicKey{}, fmt.Errorf("unrecognized key %q", s)
+	}
+	pitch := notePitch[strings.ToLower(m[1])]
+	switch m[2] {
+	case "#", "♯":
+		pitch = (pitch + 1) % 12
+	case "b", "♭":
+		pitch = (pitch + 11) % 12
+	}
+	minor := m[3] == "m" || strings.HasPrefix(strings.ToLower(m[3]), "min")
+	return musicKey{pitch: pitch, minor: minor}, nil
+}
+
+// compatible returns the key itself and its neighbours on the circle of
+// fifths: a fifth up, a fifth down, and the relative major or minor.
+func (k musicKey) compatible() []musicKey {
+	relative := musicKey{pitch: (k.pitch + 3) % 12, minor: false}
+	if !k.minor {
+		relative = musicKey{pitch: (k.pitch + 9) % 12, minor: true}
+	}
+	return []musicKey{
+		k,
+		{pitch: (k.pitch + 7) % 12, minor: k.minor},
+		{pitch: (k.pitch + 5) % 12, minor: k.minor},
+		relative,
+	}
+}
+
+// spellings lists every way the key may be stored.
+func (k musicKey) spellings() []string {
+	mode := "major"
+	if k.minor {
+		mode = "minor"
+	}
+	names := []string{sharpNames[k.pitch] + " " + mode}
+	if flatNames[k.pitch] != sharpNames[k.pitch] {
+		names = append(names, flatNames[k.pitch]+" "+mode)
+	}
+	return names
+}
@@ -12,7 +12,7 @@ import (
 
 const checkFolderExists = `-- name: CheckFolderExists :one
 SELECT COUNT(*) as count FROM folders
-WHERE id = ? AND user_id = ?
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL AND smart_query IS NULL
 `
 
 type CheckFolderExistsParams struct {
@@ -20,6 +20,7 @@ type CheckFolderExistsParams struct {
 	UserID int64 `json:"user_id"`
 }
 
+// Smart folders cannot hold projects, tracks or subfolders, so they do not count.
 func (q *Queries) CheckFolderExists(ctx context.Context, arg CheckFolderExistsParams) (int64, error) {
 	row := q.db.QueryRowContext(ctx, checkFolderExists, arg.ID, arg.UserID)
 	var count int64
@@ -29,7 +30,7 @@ func (q *Queries) CheckFolderExists(ctx context.Context, arg CheckFolderExistsPa
 
 const countProjectsInFolder = `-- name: CountProjectsInFolder :one
 SELECT COUNT(*) as count FROM projects
-WHERE folder_id = ?
+WHERE folder_id = ? AND deleted_at IS NULL
 `
 
 func (q *Queries) CountProjectsInFolder(ctx context.Context, folderID sql.NullInt64) (int64, error) {
@@ -41,7 +42,7 @@ func (q *Queries) CountProjectsInFolder(ctx context.Context, folderID sql.NullIn
 
 const countSubfoldersInFolder = `-- name: CountSubfoldersInFolder :one
 SELECT COUNT(*) as count FROM folders
-WHERE parent_id = ?
+WHERE parent_id = ? AND deleted_at IS NULL
 `
 
 func (q *Queries) CountSubfoldersInFolder(ctx context.Context, parentID sql.NullInt64) (int64, error) {
@@ -52,16 +53,17 @@ func (q *Queries) CountSubfoldersInFolder(ctx context.Context, parentID sql.Null
 }
 
 const createFolder = `-- name: CreateFolder :one
-INSERT INTO folders (user_id, parent_id, name, folder_order)
-VALUES (?, ?, ?, ?)
-RETURNING id, user_id, parent_id, name, folder_order, created_at, updated_at
+INSERT INTO folders (user_id, parent_id, name, folder_order, smart_query)
+VALUES (?, ?, ?, ?, ?)
+RETURNING id, user_id, parent_id, name, folder_order, created_at, updated_at, deleted_at, smart_query
 `
 
 type CreateFolderParams struct {
-	UserID      int64         `json:"user_id"`
-	ParentID    sql.NullInt64 `json:"parent_id"`
-	Name        string        `json:"name"`
-	FolderOrder int64         `json:"folder_order"`
+	UserID      int64          `json:"user_id"`
+	ParentID    sql.NullInt64  `json:"parent_id"`
+	Name        string         `json:"name"`
+	FolderOrder int64          `json:"folder_order"`
+	SmartQuery  sql.NullString `json:"smart_query"`
 }
 
 func (q *Queries) CreateFolder(ctx context.Context, arg CreateFolderParams) (Folder, error) {
@@ -70,6 +72,7 @@ func (q *Queries) CreateFolder(ctx context.Context, arg CreateFolderParams) (Fol
 		arg.ParentID,
 		arg.Name,
 		arg.FolderOrder,
+		arg.SmartQuery,
 	)
 	var i Folder
 	err := row.Scan(
@@ -80,6 +83,8 @@ func (q *Queries) CreateFolder(ctx context.Context, arg CreateFolderParams) (Fol
 		&i.FolderOrder,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.SmartQuery,
 	)
 	return i, err
 }
@@ -110,8 +115,8 @@ func (q *Queries) DeleteFolderByID(ctx context.Context, id int64) error {
 }
 
 const getFolder = `-- name: GetFolder :one
-SELECT id, user_id, parent_id, name, folder_order, created_at, updated_at FROM folders
-WHERE id = ? AND user_id = ?
+SELECT id, user_id, parent_id, name, folder_order, created_at, updated_at, deleted_at, smart_query FROM folders
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL
 `
 
 type GetFolderParams struct {
@@ -130,13 +135,15 @@ func (q *Queries) GetFolder(ctx context.Context, arg GetFolderParams) (Folder, e
 		&i.FolderOrder,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.SmartQuery,
 	)
 	return i, err
 }
 
 const getFolderByID = `-- name: GetFolderByID :one
-SELECT id, user_id, parent_id, name, folder_order, created_at, updated_at FROM folders
-WHERE id = ?
+SELECT id, user_id, parent_id, name, folder_order, created_at, updated_at, deleted_at, smart_query FROM folders
+WHERE id = ? AND deleted_at IS NULL
 `
 
 func (q *Queries) GetFolderByID(ctx context.Context, id int64) (Folder, error) {
@@ -150,13 +157,15 @@ func (q *Queries) GetFolderByID(ctx context.Context, id int64) (Folder, error) {
 		&i.FolderOrder,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.SmartQuery,
 	)
 	return i, err
 }
 
 const listAllFoldersByUser = `-- name: ListAllFoldersByUser :many
-SELECT id, user_id, parent_id, name, folder_order, created_at, updated_at FROM folders
-WHERE user_id = ?
+SELECT id, user_id, parent_id, name, folder_order, created_at, updated_at, deleted_at, smart_query FROM folders
+WHERE user_id = ? AND deleted_at IS NULL
 ORDER BY folder_order ASC, created_at DESC
 `
 
@@ -177,6 +186,8 @@ func (q *Queries) ListAllFoldersByUser(ctx context.Context, userID int64) ([]Fol
 			&i.FolderOrder,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.SmartQuery,
 		); err != nil {
 			return nil, err
 		}
@@ -192,8 +203,8 @@ func (q *Queries) ListAllFoldersByUser(ctx context.Context, userID int64) ([]Fol
 }
 
 const listFoldersByParent = `-- name: ListFoldersByParent :many
-SELECT id, user_id, parent_id, name, folder_order, created_at, updated_at FROM folders
-WHERE user_id = ? AND parent_id = ?
+SELECT id, user_id, parent_id, name, folder_order, created_at, updated_at, deleted_at, smart_query FROM folders
+WHERE user_id = ? AND parent_id = ? AND deleted_at IS NULL
 ORDER BY folder_order ASC, created_at DESC
 `
 
@@ -219,6 +230,8 @@ func (q *Queries) ListFoldersByParent(ctx context.Context, arg ListFoldersByPare
 			&i.FolderOrder,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.SmartQuery,
 		); err != nil {
 			return nil, err
 		}
@@ -234,8 +247,8 @@ func (q *Queries) ListFoldersByParent(ctx context.Context, arg ListFoldersByPare
 }
 
 const listFoldersByUser = `-- name: ListFoldersByUser :many
-SELECT id, user_id, parent_id, name, folder_order, created_at, updated_at FROM folders
-WHERE user_id = ? AND parent_id IS NULL
+SELECT id, user_id, parent_id, name, folder_order, created_at, updated_at, deleted_at, smart_query FROM folders
+WHERE user_id = ? AND parent_id IS NULL AND deleted_at IS NULL
 ORDER BY folder_order ASC, created_at DESC
 `
 
@@ -256,6 +269,8 @@ func (q *Queries) ListFoldersByUser(ctx context.Context, userID int64) ([]Folder
 			&i.FolderOrder,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.SmartQuery,
 		); err != nil {
 			return nil, err
 		}
@@ -272,11 +287,11 @@ func (q *Queries) ListFoldersByUser(ctx context.Context, userID int64) ([]Folder
 
 const listProjectsInFolder = `-- name: ListProjectsInFolder :many
 SELECT
-    p.id, p.user_id, p.name, p.description, p.quality_override, p.created_at, p.updated_at, p.public_id, p.cover_art_path, p.cover_art_mime, p.cover_art_updated_at, p.author_override, p.folder_id, p.folder_added_at, p.notes, p.notes_author_name, p.notes_updated_at, p.visibility_status, p.allow_editing, p.allow_downloads, p.password_hash, p.origin_instance_url, p.shared_with_instance_users, p.custom_order, p.cover_processed,
+    p.id, p.user_id, p.name, p.description, p.quality_override, p.created_at, p.updated_at, p.public_id, p.cover_art_path, p.cover_art_mime, p.cover_art_updated_at, p.author_override, p.folder_id, p.folder_added_at, p.notes, p.notes_author_name, p.notes_updated_at, p.visibility_status, p.allow_editing, p.allow_downloads, p.password_hash, p.origin_instance_url, p.shared_with_instance_users, p.custom_order, p.cover_processed, p.deleted_at,
     u.username as owner_username
 FROM projects p
 JOIN users u ON p.user_id = u.id
-WHERE p.folder_id = ? AND p.user_id = ?
+WHERE p.folder_id = ? AND p.user_id = ? AND p.deleted_at IS NULL
 ORDER BY p.folder_added_at ASC
 `
 
@@ -311,6 +326,7 @@ type ListProjectsInFolderRow struct {
 	SharedWithInstanceUsers sql.NullBool   `json:"shared_with_instance_users"`
 	CustomOrder             int64          `json:"custom_order"`
 	CoverProcessed          sql.NullBool   `json:"cover_processed"`
+	DeletedAt               sql.NullTime   `json:"deleted_at"`
 	OwnerUsername           string         `json:"owner_username"`
 }
 
@@ -349,6 +365,7 @@ func (q *Queries) ListProjectsInFolder(ctx context.Context, arg ListProjectsInFo
 			&i.SharedWithInstanceUsers,
 			&i.CustomOrder,
 			&i.CoverProcessed,
+			&i.DeletedAt,
 			&i.OwnerUsername,
 		); err != nil {
 			return nil, err
@@ -368,7 +385,7 @@ const updateFolder = `-- name: UpdateFolder :one
 UPDATE folders
 SET name = ?, parent_id = ?, folder_order = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
-RETURNING id, user_id, parent_id, name, folder_order, created_at, updated_at
+RETURNING id, user_id, parent_id, name, folder_order, created_at, updated_at, deleted_at, smart_query
 `
 
 type UpdateFolderParams struct {
@@ -396,6 +413,8 @@ func (q *Queries) UpdateFolder(ctx context.Context, arg UpdateFolderParams) (Fol
 		&i.FolderOrder,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.SmartQuery,
 	)
 	return i, err
 }
@@ -404,7 +423,7 @@ const updateFolderName = `-- name: UpdateFolderName :one
 UPDATE folders
 SET name = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
-RETURNING id, user_id, parent_id, name, folder_order, created_at, updated_at
+RETURNING id, user_id, parent_id, name, folder_order, created_at, updated_at, deleted_at, smart_query
 `
 
 type UpdateFolderNameParams struct {
@@ -424,6 +443,8 @@ func (q *Queries) UpdateFolderName(ctx context.Context, arg UpdateFolderNamePara
 		&i.FolderOrder,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.SmartQuery,
 	)
 	return i, err
 }
@@ -449,7 +470,7 @@ const updateFolderParent = `-- name: UpdateFolderParent :one
 UPDATE folders
 SET parent_id = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
-RETURNING id, user_id, parent_id, name, folder_order, created_at, updated_at
+RETURNING id, user_id, parent_id, name, folder_order, created_at, updated_at, deleted_at, smart_query
 `
 
 type UpdateFolderParentParams struct {
@@ -469,6 +490,38 @@ func (q *Queries) UpdateFolderParent(ctx context.Context, arg UpdateFolderParent
 		&i.FolderOrder,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.SmartQuery,
+	)
+	return i, err
+}
+
+const updateFolderSmartQuery = `-- name: UpdateFolderSmartQuery :one
+UPDATE folders
+SET smart_query = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND user_id = ? AND smart_query IS NOT NULL
+RETURNING id, user_id, parent_id, name, folder_order, created_at, updated_at, deleted_at, smart_query
+`
+
+type UpdateFolderSmartQueryParams struct {
+	SmartQuery sql.NullString `json:"smart_query"`
+	ID         int64          `json:"id"`
+	UserID     int64          `json:"user_id"`
+}
+
+func (q *Queries) UpdateFolderSmartQuery(ctx context.Context, arg UpdateFolderSmartQueryParams) (Folder, error) {
+	row := q.db.QueryRowContext(ctx, updateFolderSmartQuery, arg.SmartQuery, arg.ID, arg.UserID)
+	var i Folder
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ParentID,
+		&i.Name,
+		&i.FolderOrder,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.SmartQuery,
 	)
 	return i, err
 }
@@ -11,7 +11,7 @@ import (
 )
 
 const getInstanceSettings = `-- name: GetInstanceSettings :one
-SELECT id, name, created_at, updated_at, session_invalidated_at FROM instance_settings
+SELECT id, name, created_at, updated_at, session_invalidated_at, source_compaction, require_two_factor FROM instance_settings
 WHERE id = 1
 `
 
@@ -24,6 +24,8 @@ func (q *Queries) GetInstanceSettings(ctx context.Context) (InstanceSetting, err
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.SessionInvalidatedAt,
+		&i.SourceCompaction,
+		&i.RequireTwoFactor,
 	)
 	return i, err
 }
@@ -39,6 +41,17 @@ func (q *Queries) GetSessionInvalidatedAt(ctx context.Context) (sql.NullTime, er
 	return session_invalidated_at, err
 }
 
+const getSourceCompaction = `-- name: GetSourceCompaction :one
+SELECT source_compaction FROM instance_settings WHERE id = 1
+`
+
+func (q *Queries) GetSourceCompaction(ctx context.Context) (bool, error) {
+	row := q.db.QueryRowContext(ctx, getSourceCompaction)
+	var source_compaction bool
+	err := row.Scan(&source_compaction)
+	return source_compaction, err
+}
+
 const invalidateSessions = `-- name: InvalidateSessions :exec
 UPDATE instance_settings
 SET session_invalidated_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
@@ -50,11 +63,22 @@ func (q *Queries) InvalidateSessions(ctx context.Context) error {
 	return err
 }
 
+const setSourceCompaction = `-- name: SetSourceCompaction :exec
+UPDATE instance_settings
+SET source_compaction = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = 1
+`
+
+func (q *Queries) SetSourceCompaction(ctx context.Context, sourceCompaction bool) error {
+	_, err := q.db.ExecContext(ctx, setSourceCompaction, sourceCompaction)
+	return err
+}
+
 const updateInstanceName = `-- name: UpdateInstanceName :one
 UPDATE instance_settings
 SET name = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = 1
-RETURNING id, name, created_at, updated_at, session_invalidated_at
+RETURNING id, name, created_at, updated_at, session_invalidated_at, source_compaction, require_two_factor
 `
 
 func (q *Queries) UpdateInstanceName(ctx context.Context, name string) (InstanceSetting, error) {
@@ -66,6 +90,8 @@ func (q *Queries) UpdateInstanceName(ctx context.Context, name string) (Instance
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.SessionInvalidatedAt,
+		&i.SourceCompaction,
+		&i.RequireTwoFactor,
 	)
 	return i, err
 }
@@ -99,6 +99,21 @@ func (q *Queries) DeleteExpiredTokens(ctx context.Context) error {
 	return err
 }
 
+const deletePendingUserTokens = `-- name: DeletePendingUserTokens :exec
+DELETE FROM invite_tokens
+WHERE user_id = ? AND token_type = ? AND used = 0
+`
+
+type DeletePendingUserTokensParams struct {
+	UserID    sql.NullInt64 `json:"user_id"`
+	TokenType string        `json:"token_type"`
+}
+
+func (q *Queries) DeletePendingUserTokens(ctx context.Context, arg DeletePendingUserTokensParams) error {
+	_, err := q.db.ExecContext(ctx, deletePendingUserTokens, arg.UserID, arg.TokenType)
+	return err
+}
+
 const getInviteTokenByToken = `-- name: GetInviteTokenByToken :one
 SELECT id, token_hash, token_type, user_id, created_by, email, used, used_at, created_at, expires_at FROM invite_tokens
 WHERE token_hash = ?
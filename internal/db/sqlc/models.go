@@ -9,6 +9,69 @@ import (
 	"time"
 )
 
+type ApiToken struct {
+	ID          int64          `json:"id"`
+	UserID      int64          `json:"user_id"`
+	Name        string         `json:"name"`
+	TokenHash   string         `json:"token_hash"`
+	TokenPrefix string         `json:"token_prefix"`
+	Scopes      string         `json:"scopes"`
+	ExpiresAt   sql.NullTime   `json:"expires_at"`
+	LastUsedAt  sql.NullTime   `json:"last_used_at"`
+	LastUsedIp  sql.NullString `json:"last_used_ip"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+type AuditEvent struct {
+	ID            int64          `json:"id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	ActorID       sql.NullInt64  `json:"actor_id"`
+	ActorUsername sql.NullString `json:"actor_username"`
+	Ip            sql.NullString `json:"ip"`
+	UserAgent     sql.NullString `json:"user_agent"`
+	Action        string         `json:"action"`
+	TargetType    sql.NullString `json:"target_type"`
+	TargetID      sql.NullString `json:"target_id"`
+	BeforeSummary sql.NullString `json:"before_summary"`
+	AfterSummary  sql.NullString `json:"after_summary"`
+}
+
+type AuthLockout struct {
+	ID            int64        `json:"id"`
+	Scope         string       `json:"scope"`
+	Subject       string       `json:"subject"`
+	Failures      int64        `json:"failures"`
+	LastFailureAt time.Time    `json:"last_failure_at"`
+	BlockedUntil  sql.NullTime `json:"blocked_until"`
+}
+
+type BackupRun struct {
+	ID          int64          `json:"id"`
+	StartedAt   time.Time      `json:"started_at"`
+	FinishedAt  sql.NullTime   `json:"finished_at"`
+	Trigger     string         `json:"trigger"`
+	Status      string         `json:"status"`
+	Destination string         `json:"destination"`
+	Name        sql.NullString `json:"name"`
+	SizeBytes   sql.NullInt64  `json:"size_bytes"`
+	Error       sql.NullString `json:"error"`
+}
+
+type EmailQueue struct {
+	ID            int64          `json:"id"`
+	Recipient     string         `json:"recipient"`
+	Template      string         `json:"template"`
+	Subject       string         `json:"subject"`
+	TextBody      string         `json:"text_body"`
+	HtmlBody      string         `json:"html_body"`
+	Status        string         `json:"status"`
+	Attempts      int64          `json:"attempts"`
+	LastError     sql.NullString `json:"last_error"`
+	NextAttemptAt time.Time      `json:"next_attempt_at"`
+	SentAt        sql.NullTime   `json:"sent_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
 type FederationToken struct {
 	ID                int64          `json:"id"`
 	Token             string         `json:"token"`
@@ -26,13 +89,15 @@ type FederationToken struct {
 }
 
 type Folder struct {
-	ID          int64         `json:"id"`
-	UserID      int64         `json:"user_id"`
-	ParentID    sql.NullInt64 `json:"parent_id"`
-	Name        string        `json:"name"`
-	FolderOrder int64         `json:"folder_order"`
-	CreatedAt   sql.NullTime  `json:"created_at"`
-	UpdatedAt   sql.NullTime  `json:"updated_at"`
+	ID          int64          `json:"id"`
+	UserID      int64          `json:"user_id"`
+	ParentID    sql.NullInt64  `json:"parent_id"`
+	Name        string         `json:"name"`
+	FolderOrder int64          `json:"folder_order"`
+	CreatedAt   sql.NullTime   `json:"created_at"`
+	UpdatedAt   sql.NullTime   `json:"updated_at"`
+	DeletedAt   sql.NullTime   `json:"deleted_at"`
+	SmartQuery  sql.NullString `json:"smart_query"`
 }
 
 type InstanceConfig struct {
@@ -51,6 +116,8 @@ type InstanceSetting struct {
 	CreatedAt            sql.NullTime `json:"created_at"`
 	UpdatedAt            sql.NullTime `json:"updated_at"`
 	SessionInvalidatedAt sql.NullTime `json:"session_invalidated_at"`
+	SourceCompaction     bool         `json:"source_compaction"`
+	RequireTwoFactor     bool         `json:"require_two_factor"`
 }
 
 type InviteToken struct {
@@ -66,6 +133,15 @@ type InviteToken struct {
 	ExpiresAt time.Time     `json:"expires_at"`
 }
 
+type LoginChallenge struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	TokenHash string       `json:"token_hash"`
+	Attempts  int64        `json:"attempts"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	CreatedAt sql.NullTime `json:"created_at"`
+}
+
 type Note struct {
 	ID         int64         `json:"id"`
 	UserID     int64         `json:"user_id"`
@@ -77,6 +153,46 @@ type Note struct {
 	UpdatedAt  sql.NullTime  `json:"updated_at"`
 }
 
+type NotesFt struct {
+	Content    string `json:"content"`
+	AuthorName string `json:"author_name"`
+}
+
+type OidcLogin struct {
+	ID              int64          `json:"id"`
+	StateHash       string         `json:"state_hash"`
+	Nonce           string         `json:"nonce"`
+	CodeVerifier    string         `json:"code_verifier"`
+	InviteTokenHash sql.NullString `json:"invite_token_hash"`
+	LinkUserID      sql.NullInt64  `json:"link_user_id"`
+	ExpiresAt       time.Time      `json:"expires_at"`
+	CreatedAt       sql.NullTime   `json:"created_at"`
+}
+
+type Passkey struct {
+	ID             int64        `json:"id"`
+	UserID         int64        `json:"user_id"`
+	CredentialID   string       `json:"credential_id"`
+	PublicKey      []byte       `json:"public_key"`
+	SignCount      int64        `json:"sign_count"`
+	Name           string       `json:"name"`
+	Aaguid         string       `json:"aaguid"`
+	Transports     string       `json:"transports"`
+	BackupEligible bool         `json:"backup_eligible"`
+	BackupState    bool         `json:"backup_state"`
+	CreatedAt      sql.NullTime `json:"created_at"`
+	LastUsedAt     sql.NullTime `json:"last_used_at"`
+}
+
+type PasskeyChallenge struct {
+	ID        int64         `json:"id"`
+	UserID    sql.NullInt64 `json:"user_id"`
+	Challenge string        `json:"challenge"`
+	Ceremony  string        `json:"ceremony"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	CreatedAt sql.NullTime  `json:"created_at"`
+}
+
 type Project struct {
 	ID                      int64          `json:"id"`
 	UserID                  int64          `json:"user_id"`
@@ -103,6 +219,7 @@ type Project struct {
 	SharedWithInstanceUsers sql.NullBool   `json:"shared_with_instance_users"`
 	CustomOrder             int64          `json:"custom_order"`
 	CoverProcessed          sql.NullBool   `json:"cover_processed"`
+	DeletedAt               sql.NullTime   `json:"deleted_at"`
 }
 
 type ProjectShareToken struct {
@@ -121,6 +238,11 @@ type ProjectShareToken struct {
 	UpdatedAt          sql.NullTime   `json:"updated_at"`
 }
 
+type ProjectsFt struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
 type RefreshToken struct {
 	ID         int64          `json:"id"`
 	UserID     int64          `json:"user_id"`
@@ -179,6 +301,18 @@ type ShareToken struct {
 	UpdatedAt          sql.NullTime   `json:"updated_at"`
 }
 
+type Takeout struct {
+	ID         int64          `json:"id"`
+	UserID     int64          `json:"user_id"`
+	Status     string         `json:"status"`
+	CreatedAt  time.Time      `json:"created_at"`
+	FinishedAt sql.NullTime   `json:"finished_at"`
+	ExpiresAt  sql.NullTime   `json:"expires_at"`
+	FileName   sql.NullString `json:"file_name"`
+	SizeBytes  sql.NullInt64  `json:"size_bytes"`
+	Error      sql.NullString `json:"error"`
+}
+
 type Track struct {
 	ID                      int64          `json:"id"`
 	UserID                  int64          `json:"user_id"`
@@ -202,6 +336,7 @@ type Track struct {
 	PasswordHash            sql.NullString `json:"password_hash"`
 	OriginInstanceUrl       sql.NullString `json:"origin_instance_url"`
 	SharedWithInstanceUsers sql.NullBool   `json:"shared_with_instance_users"`
+	DeletedAt               sql.NullTime   `json:"deleted_at"`
 }
 
 type TrackFile struct {
@@ -217,6 +352,11 @@ type TrackFile struct {
 	CreatedAt         sql.NullTime   `json:"created_at"`
 	Waveform          sql.NullString `json:"waveform"`
 	OriginalFilename  sql.NullString `json:"original_filename"`
+	StoredFormat      sql.NullString `json:"stored_format"`
+	OriginalCodec     sql.NullString `json:"original_codec"`
+	OriginalSize      sql.NullInt64  `json:"original_size"`
+	PcmHash           sql.NullString `json:"pcm_hash"`
+	CompactionStatus  sql.NullString `json:"compaction_status"`
 }
 
 type TrackVersion struct {
@@ -228,6 +368,26 @@ type TrackVersion struct {
 	VersionOrder    int64           `json:"version_order"`
 	CreatedAt       sql.NullTime    `json:"created_at"`
 	UpdatedAt       sql.NullTime    `json:"updated_at"`
+	DeletedAt       sql.NullTime    `json:"deleted_at"`
+}
+
+type TrackVersionsFt struct {
+	VersionName string `json:"version_name"`
+	Notes       string `json:"notes"`
+}
+
+type TracksFt struct {
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+}
+
+type TrashedFolderContent struct {
+	FolderID    int64  `json:"folder_id"`
+	UserID      int64  `json:"user_id"`
+	ItemType    string `json:"item_type"`
+	ItemID      int64  `json:"item_id"`
+	CustomOrder int64  `json:"custom_order"`
 }
 
 type User struct {
@@ -242,6 +402,16 @@ type User struct {
 	SessionInvalidatedAt sql.NullTime `json:"session_invalidated_at"`
 }
 
+type UserIdentity struct {
+	ID          int64        `json:"id"`
+	UserID      int64        `json:"user_id"`
+	Issuer      string       `json:"issuer"`
+	Subject     string       `json:"subject"`
+	Email       string       `json:"email"`
+	CreatedAt   time.Time    `json:"created_at"`
+	LastLoginAt sql.NullTime `json:"last_login_at"`
+}
+
 type UserPreference struct {
 	UserID             int64          `json:"user_id"`
 	DefaultQuality     string         `json:"default_quality"`
@@ -264,6 +434,14 @@ type UserProjectShare struct {
 	UpdatedAt   sql.NullTime `json:"updated_at"`
 }
 
+type UserRecoveryCode struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt sql.NullTime `json:"created_at"`
+}
+
 type UserSharedProjectOrganization struct {
 	ID          int64         `json:"id"`
 	UserID      int64         `json:"user_id"`
@@ -284,6 +462,15 @@ type UserSharedTrackOrganization struct {
 	UpdatedAt   sql.NullTime  `json:"updated_at"`
 }
 
+type UserTotp struct {
+	UserID        int64          `json:"user_id"`
+	Secret        sql.NullString `json:"secret"`
+	PendingSecret sql.NullString `json:"pending_secret"`
+	EnabledAt     sql.NullTime   `json:"enabled_at"`
+	LastStep      int64          `json:"last_step"`
+	CreatedAt     sql.NullTime   `json:"created_at"`
+}
+
 type UserTrackShare struct {
 	ID          int64        `json:"id"`
 	TrackID     int64        `json:"track_id"`
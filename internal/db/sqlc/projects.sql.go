@@ -18,7 +18,7 @@ SET cover_art_path = NULL,
     cover_processed = FALSE,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ?
-RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed
+RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at
 `
 
 func (q *Queries) ClearProjectCover(ctx context.Context, id int64) (Project, error) {
@@ -50,6 +50,7 @@ func (q *Queries) ClearProjectCover(ctx context.Context, id int64) (Project, err
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -57,7 +58,7 @@ func (q *Queries) ClearProjectCover(ctx context.Context, id int64) (Project, err
 const createProject = `-- name: CreateProject :one
 INSERT INTO projects (user_id, name, description, quality_override, public_id, author_override, folder_id)
 VALUES (?, ?, ?, ?, ?, ?, ?)
-RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed
+RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at
 `
 
 type CreateProjectParams struct {
@@ -107,6 +108,7 @@ func (q *Queries) CreateProject(ctx context.Context, arg CreateProjectParams) (P
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -127,8 +129,8 @@ func (q *Queries) DeleteProject(ctx context.Context, arg DeleteProjectParams) er
 }
 
 const getProject = `-- name: GetProject :one
-SELECT id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed FROM projects
-WHERE id = ? AND user_id = ?
+SELECT id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at FROM projects
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL
 `
 
 type GetProjectParams struct {
@@ -165,13 +167,14 @@ func (q *Queries) GetProject(ctx context.Context, arg GetProjectParams) (Project
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getProjectByID = `-- name: GetProjectByID :one
-SELECT id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed FROM projects
-WHERE id = ?
+SELECT id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at FROM projects
+WHERE id = ? AND deleted_at IS NULL
 `
 
 func (q *Queries) GetProjectByID(ctx context.Context, id int64) (Project, error) {
@@ -203,19 +206,20 @@ func (q *Queries) GetProjectByID(ctx context.Context, id int64) (Project, error)
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getProjectByPublicID = `-- name: GetProjectByPublicID :one
 SELECT
-    p.id, p.user_id, p.name, p.description, p.quality_override, p.created_at, p.updated_at, p.public_id, p.cover_art_path, p.cover_art_mime, p.cover_art_updated_at, p.author_override, p.folder_id, p.folder_added_at, p.notes, p.notes_author_name, p.notes_updated_at, p.visibility_status, p.allow_editing, p.allow_downloads, p.password_hash, p.origin_instance_url, p.shared_with_instance_users, p.custom_order, p.cover_processed,
+    p.id, p.user_id, p.name, p.description, p.quality_override, p.created_at, p.updated_at, p.public_id, p.cover_art_path, p.cover_art_mime, p.cover_art_updated_at, p.author_override, p.folder_id, p.folder_added_at, p.notes, p.notes_author_name, p.notes_updated_at, p.visibility_status, p.allow_editing, p.allow_downloads, p.password_hash, p.origin_instance_url, p.shared_with_instance_users, p.custom_order, p.cover_processed, p.deleted_at,
     CASE WHEN EXISTS (
         SELECT 1 FROM user_project_shares ups
         WHERE ups.project_id = p.id
     ) THEN 1 ELSE 0 END as is_shared
 FROM projects p
-WHERE p.public_id = ? AND p.user_id = ?
+WHERE p.public_id = ? AND p.user_id = ? AND p.deleted_at IS NULL
 `
 
 type GetProjectByPublicIDParams struct {
@@ -249,6 +253,7 @@ type GetProjectByPublicIDRow struct {
 	SharedWithInstanceUsers sql.NullBool   `json:"shared_with_instance_users"`
 	CustomOrder             int64          `json:"custom_order"`
 	CoverProcessed          sql.NullBool   `json:"cover_processed"`
+	DeletedAt               sql.NullTime   `json:"deleted_at"`
 	IsShared                int64          `json:"is_shared"`
 }
 
@@ -281,6 +286,7 @@ func (q *Queries) GetProjectByPublicID(ctx context.Context, arg GetProjectByPubl
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 		&i.IsShared,
 	)
 	return i, err
@@ -288,13 +294,13 @@ func (q *Queries) GetProjectByPublicID(ctx context.Context, arg GetProjectByPubl
 
 const getProjectByPublicIDNoFilter = `-- name: GetProjectByPublicIDNoFilter :one
 SELECT
-    p.id, p.user_id, p.name, p.description, p.quality_override, p.created_at, p.updated_at, p.public_id, p.cover_art_path, p.cover_art_mime, p.cover_art_updated_at, p.author_override, p.folder_id, p.folder_added_at, p.notes, p.notes_author_name, p.notes_updated_at, p.visibility_status, p.allow_editing, p.allow_downloads, p.password_hash, p.origin_instance_url, p.shared_with_instance_users, p.custom_order, p.cover_processed,
+    p.id, p.user_id, p.name, p.description, p.quality_override, p.created_at, p.updated_at, p.public_id, p.cover_art_path, p.cover_art_mime, p.cover_art_updated_at, p.author_override, p.folder_id, p.folder_added_at, p.notes, p.notes_author_name, p.notes_updated_at, p.visibility_status, p.allow_editing, p.allow_downloads, p.password_hash, p.origin_instance_url, p.shared_with_instance_users, p.custom_order, p.cover_processed, p.deleted_at,
     CASE WHEN EXISTS (
         SELECT 1 FROM user_project_shares ups
         WHERE ups.project_id = p.id
     ) THEN 1 ELSE 0 END as is_shared
 FROM projects p
-WHERE p.public_id = ?
+WHERE p.public_id = ? AND p.deleted_at IS NULL
 `
 
 type GetProjectByPublicIDNoFilterRow struct {
@@ -323,6 +329,7 @@ type GetProjectByPublicIDNoFilterRow struct {
 	SharedWithInstanceUsers sql.NullBool   `json:"shared_with_instance_users"`
 	CustomOrder             int64          `json:"custom_order"`
 	CoverProcessed          sql.NullBool   `json:"cover_processed"`
+	DeletedAt               sql.NullTime   `json:"deleted_at"`
 	IsShared                int64          `json:"is_shared"`
 }
 
@@ -355,18 +362,57 @@ func (q *Queries) GetProjectByPublicIDNoFilter(ctx context.Context, publicID str
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 		&i.IsShared,
 	)
 	return i, err
 }
 
+const listProjectStorageRefs = `-- name: ListProjectStorageRefs :many
+SELECT id, public_id, cover_art_path FROM projects
+ORDER BY id ASC
+`
+
+type ListProjectStorageRefsRow struct {
+	ID           int64          `json:"id"`
+	PublicID     string         `json:"public_id"`
+	CoverArtPath sql.NullString `json:"cover_art_path"`
+}
+
+func (q *Queries) ListProjectStorageRefs(ctx context.Context) ([]ListProjectStorageRefsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listProjectStorageRefs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListProjectStorageRefsRow{}
+	for rows.Next() {
+		var i ListProjectStorageRefsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PublicID,
+			&i.CoverArtPath,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listProjectsByUser = `-- name: ListProjectsByUser :many
 SELECT
-    p.id, p.user_id, p.name, p.description, p.quality_override, p.created_at, p.updated_at, p.public_id, p.cover_art_path, p.cover_art_mime, p.cover_art_updated_at, p.author_override, p.folder_id, p.folder_added_at, p.notes, p.notes_author_name, p.notes_updated_at, p.visibility_status, p.allow_editing, p.allow_downloads, p.password_hash, p.origin_instance_url, p.shared_with_instance_users, p.custom_order, p.cover_processed,
+    p.id, p.user_id, p.name, p.description, p.quality_override, p.created_at, p.updated_at, p.public_id, p.cover_art_path, p.cover_art_mime, p.cover_art_updated_at, p.author_override, p.folder_id, p.folder_added_at, p.notes, p.notes_author_name, p.notes_updated_at, p.visibility_status, p.allow_editing, p.allow_downloads, p.password_hash, p.origin_instance_url, p.shared_with_instance_users, p.custom_order, p.cover_processed, p.deleted_at,
     u.username as owner_username
 FROM projects p
 JOIN users u ON p.user_id = u.id
-WHERE p.user_id = ?
+WHERE p.user_id = ? AND p.deleted_at IS NULL
 ORDER BY p.created_at DESC
 `
 
@@ -396,6 +442,7 @@ type ListProjectsByUserRow struct {
 	SharedWithInstanceUsers sql.NullBool   `json:"shared_with_instance_users"`
 	CustomOrder             int64          `json:"custom_order"`
 	CoverProcessed          sql.NullBool   `json:"cover_processed"`
+	DeletedAt               sql.NullTime   `json:"deleted_at"`
 	OwnerUsername           string         `json:"owner_username"`
 }
 
@@ -434,6 +481,7 @@ func (q *Queries) ListProjectsByUser(ctx context.Context, userID int64) ([]ListP
 			&i.SharedWithInstanceUsers,
 			&i.CustomOrder,
 			&i.CoverProcessed,
+			&i.DeletedAt,
 			&i.OwnerUsername,
 		); err != nil {
 			return nil, err
@@ -451,11 +499,11 @@ func (q *Queries) ListProjectsByUser(ctx context.Context, userID int64) ([]ListP
 
 const listRootProjects = `-- name: ListRootProjects :many
 SELECT
-    p.id, p.user_id, p.name, p.description, p.quality_override, p.created_at, p.updated_at, p.public_id, p.cover_art_path, p.cover_art_mime, p.cover_art_updated_at, p.author_override, p.folder_id, p.folder_added_at, p.notes, p.notes_author_name, p.notes_updated_at, p.visibility_status, p.allow_editing, p.allow_downloads, p.password_hash, p.origin_instance_url, p.shared_with_instance_users, p.custom_order, p.cover_processed,
+    p.id, p.user_id, p.name, p.description, p.quality_override, p.created_at, p.updated_at, p.public_id, p.cover_art_path, p.cover_art_mime, p.cover_art_updated_at, p.author_override, p.folder_id, p.folder_added_at, p.notes, p.notes_author_name, p.notes_updated_at, p.visibility_status, p.allow_editing, p.allow_downloads, p.password_hash, p.origin_instance_url, p.shared_with_instance_users, p.custom_order, p.cover_processed, p.deleted_at,
     u.username as owner_username
 FROM projects p
 JOIN users u ON p.user_id = u.id
-WHERE p.user_id = ? AND p.folder_id IS NULL
+WHERE p.user_id = ? AND p.folder_id IS NULL AND p.deleted_at IS NULL
 ORDER BY p.created_at ASC
 `
 
@@ -485,6 +533,7 @@ type ListRootProjectsRow struct {
 	SharedWithInstanceUsers sql.NullBool   `json:"shared_with_instance_users"`
 	CustomOrder             int64          `json:"custom_order"`
 	CoverProcessed          sql.NullBool   `json:"cover_processed"`
+	DeletedAt               sql.NullTime   `json:"deleted_at"`
 	OwnerUsername           string         `json:"owner_username"`
 }
 
@@ -523,6 +572,7 @@ func (q *Queries) ListRootProjects(ctx context.Context, userID int64) ([]ListRoo
 			&i.SharedWithInstanceUsers,
 			&i.CustomOrder,
 			&i.CoverProcessed,
+			&i.DeletedAt,
 			&i.OwnerUsername,
 		); err != nil {
 			return nil, err
@@ -539,8 +589,8 @@ func (q *Queries) ListRootProjects(ctx context.Context, userID int64) ([]ListRoo
 }
 
 const listRootProjectsWithCustomOrder = `-- name: ListRootProjectsWithCustomOrder :many
-SELECT id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed FROM projects
-WHERE user_id = ? AND folder_id IS NULL
+SELECT id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at FROM projects
+WHERE user_id = ? AND folder_id IS NULL AND deleted_at IS NULL
 ORDER BY custom_order ASC, created_at DESC
 `
 
@@ -579,6 +629,7 @@ func (q *Queries) ListRootProjectsWithCustomOrder(ctx context.Context, userID in
 			&i.SharedWithInstanceUsers,
 			&i.CustomOrder,
 			&i.CoverProcessed,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -594,7 +645,7 @@ func (q *Queries) ListRootProjectsWithCustomOrder(ctx context.Context, userID in
 }
 
 const listUnprocessedCovers = `-- name: ListUnprocessedCovers :many
-SELECT id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed FROM projects
+SELECT id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at FROM projects
 WHERE cover_art_path IS NOT NULL AND cover_processed = FALSE
 `
 
@@ -633,6 +684,7 @@ func (q *Queries) ListUnprocessedCovers(ctx context.Context) ([]Project, error)
 			&i.SharedWithInstanceUsers,
 			&i.CustomOrder,
 			&i.CoverProcessed,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -669,7 +721,7 @@ SET name = COALESCE(?, name),
     notes_updated_at = CASE WHEN ? IS NOT NULL THEN CURRENT_TIMESTAMP ELSE notes_updated_at END,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
-RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed
+RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at
 `
 
 type UpdateProjectParams struct {
@@ -723,6 +775,7 @@ func (q *Queries) UpdateProject(ctx context.Context, arg UpdateProjectParams) (P
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -735,7 +788,7 @@ SET cover_art_path = ?,
     cover_processed = TRUE,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ?
-RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed
+RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at
 `
 
 type UpdateProjectCoverParams struct {
@@ -773,6 +826,7 @@ func (q *Queries) UpdateProjectCover(ctx context.Context, arg UpdateProjectCover
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -782,7 +836,7 @@ UPDATE projects
 SET custom_order = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
-RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed
+RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at
 `
 
 type UpdateProjectCustomOrderParams struct {
@@ -820,6 +874,7 @@ func (q *Queries) UpdateProjectCustomOrder(ctx context.Context, arg UpdateProjec
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -830,7 +885,7 @@ SET folder_id = ?,
     folder_added_at = CASE WHEN ? IS NOT NULL THEN CURRENT_TIMESTAMP ELSE NULL END,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
-RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed
+RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at
 `
 
 type UpdateProjectFolderParams struct {
@@ -874,6 +929,7 @@ func (q *Queries) UpdateProjectFolder(ctx context.Context, arg UpdateProjectFold
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -884,7 +940,7 @@ SET folder_id = ?,
     folder_added_at = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
-RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed
+RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at
 `
 
 type UpdateProjectFolderWithTimestampParams struct {
@@ -928,6 +984,7 @@ func (q *Queries) UpdateProjectFolderWithTimestamp(ctx context.Context, arg Upda
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -939,7 +996,7 @@ SET notes = ?,
     notes_updated_at = CURRENT_TIMESTAMP,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
-RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed
+RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at
 `
 
 type UpdateProjectNotesParams struct {
@@ -983,6 +1040,7 @@ func (q *Queries) UpdateProjectNotes(ctx context.Context, arg UpdateProjectNotes
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -7,25 +7,42 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 type Querier interface {
+	// Smart folders cannot hold projects, tracks or subfolders, so they do not count.
 	CheckFolderExists(ctx context.Context, arg CheckFolderExistsParams) (int64, error)
 	ClearAllTracksAnalysis(ctx context.Context) error
 	ClearProjectCover(ctx context.Context, id int64) (Project, error)
 	ClearTrackAnalysis(ctx context.Context, id int64) error
+	CompactTrackFile(ctx context.Context, arg CompactTrackFileParams) (int64, error)
+	ConfirmTOTPSecret(ctx context.Context, arg ConfirmTOTPSecretParams) error
+	CopyTrackFileCompaction(ctx context.Context, arg CopyTrackFileCompactionParams) error
+	// Returns no row once the challenge is out of attempts.
+	CountLoginChallengeAttempt(ctx context.Context, arg CountLoginChallengeAttemptParams) (LoginChallenge, error)
+	CountPasskeysByUser(ctx context.Context, userID int64) (int64, error)
 	CountProjectsInFolder(ctx context.Context, folderID sql.NullInt64) (int64, error)
 	CountSubfoldersInFolder(ctx context.Context, parentID sql.NullInt64) (int64, error)
 	CountTrackVersions(ctx context.Context, trackID int64) (int64, error)
+	CountUnusedRecoveryCodes(ctx context.Context, userID int64) (int64, error)
 	CountUsers(ctx context.Context) (int64, error)
+	CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error)
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error
+	CreateBackupRun(ctx context.Context, arg CreateBackupRunParams) (BackupRun, error)
 	// FEDERATION TOKENS
 	CreateFederationToken(ctx context.Context, arg CreateFederationTokenParams) (FederationToken, error)
 	CreateFolder(ctx context.Context, arg CreateFolderParams) (Folder, error)
 	CreateInviteToken(ctx context.Context, arg CreateInviteTokenParams) (InviteToken, error)
+	CreateLoginChallenge(ctx context.Context, arg CreateLoginChallengeParams) error
+	CreateOIDCLogin(ctx context.Context, arg CreateOIDCLoginParams) error
+	CreatePasskey(ctx context.Context, arg CreatePasskeyParams) (Passkey, error)
+	CreatePasskeyChallenge(ctx context.Context, arg CreatePasskeyChallengeParams) error
 	CreateProject(ctx context.Context, arg CreateProjectParams) (Project, error)
 	CreateProjectNote(ctx context.Context, arg CreateProjectNoteParams) (Note, error)
 	// PROJECT SHARE TOKENS
 	CreateProjectShareToken(ctx context.Context, arg CreateProjectShareTokenParams) (ProjectShareToken, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error
 	CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error)
 	CreateRemoteTrack(ctx context.Context, arg CreateRemoteTrackParams) (RemoteTrack, error)
 	CreateResetToken(ctx context.Context, arg CreateResetTokenParams) (InviteToken, error)
@@ -35,30 +52,48 @@ type Querier interface {
 	CreateShareToken(ctx context.Context, arg CreateShareTokenParams) (ShareToken, error)
 	CreateSharedProjectOrganization(ctx context.Context, arg CreateSharedProjectOrganizationParams) (UserSharedProjectOrganization, error)
 	CreateSharedTrackOrganization(ctx context.Context, arg CreateSharedTrackOrganizationParams) (UserSharedTrackOrganization, error)
+	CreateTakeout(ctx context.Context, arg CreateTakeoutParams) (Takeout, error)
 	CreateTrack(ctx context.Context, arg CreateTrackParams) (Track, error)
 	CreateTrackFile(ctx context.Context, arg CreateTrackFileParams) (TrackFile, error)
 	CreateTrackNote(ctx context.Context, arg CreateTrackNoteParams) (Note, error)
 	CreateTrackVersion(ctx context.Context, arg CreateTrackVersionParams) (TrackVersion, error)
+	CreateTrashedFolderContent(ctx context.Context, arg CreateTrashedFolderContentParams) error
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) (UserIdentity, error)
 	CreateUserPreferences(ctx context.Context, arg CreateUserPreferencesParams) error
 	// USER-TO-USER SHARING (SAME INSTANCE)
 	CreateUserProjectShare(ctx context.Context, arg CreateUserProjectShareParams) (UserProjectShare, error)
 	CreateUserTrackShare(ctx context.Context, arg CreateUserTrackShareParams) (UserTrackShare, error)
 	// WEBSOCKET SESSIONS
 	CreateWebSocketSession(ctx context.Context, arg CreateWebSocketSessionParams) (WebsocketSession, error)
+	DeleteAPIToken(ctx context.Context, arg DeleteAPITokenParams) (int64, error)
 	DeleteAllSharedProjectOrganizationsInFolder(ctx context.Context, arg DeleteAllSharedProjectOrganizationsInFolderParams) error
 	DeleteAllSharedTrackOrganizationsInFolder(ctx context.Context, arg DeleteAllSharedTrackOrganizationsInFolderParams) error
+	DeleteAuditEventsBefore(ctx context.Context, createdAt time.Time) (int64, error)
+	DeleteAuthLockout(ctx context.Context, arg DeleteAuthLockoutParams) error
+	DeleteAuthLockoutByID(ctx context.Context, id int64) (int64, error)
 	DeleteExpiredFederationTokens(ctx context.Context) error
+	DeleteExpiredLoginChallenges(ctx context.Context, expiresAt time.Time) error
+	DeleteExpiredOIDCLogins(ctx context.Context, expiresAt time.Time) error
+	DeleteExpiredPasskeyChallenges(ctx context.Context, expiresAt time.Time) error
 	DeleteExpiredRefreshTokens(ctx context.Context) error
 	DeleteExpiredTokens(ctx context.Context) error
 	DeleteFederationToken(ctx context.Context, arg DeleteFederationTokenParams) error
 	DeleteFederationTokenByToken(ctx context.Context, token string) error
 	DeleteFolder(ctx context.Context, arg DeleteFolderParams) error
 	DeleteFolderByID(ctx context.Context, id int64) error
+	DeleteLoginChallenge(ctx context.Context, id int64) error
 	DeleteNote(ctx context.Context, arg DeleteNoteParams) error
+	DeleteOldBackupRuns(ctx context.Context, offset int64) error
+	DeleteOldEmails(ctx context.Context, createdAt time.Time) error
+	DeleteOldTakeouts(ctx context.Context, createdAt time.Time) error
+	DeletePasskey(ctx context.Context, arg DeletePasskeyParams) (int64, error)
+	DeletePasskeysByUser(ctx context.Context, userID int64) error
+	DeletePendingUserTokens(ctx context.Context, arg DeletePendingUserTokensParams) error
 	DeleteProject(ctx context.Context, arg DeleteProjectParams) error
 	DeleteProjectShareToken(ctx context.Context, arg DeleteProjectShareTokenParams) error
 	DeleteProjectShareTokenByProject(ctx context.Context, arg DeleteProjectShareTokenByProjectParams) error
+	DeleteRecoveryCodes(ctx context.Context, userID int64) error
 	DeleteRemoteTrack(ctx context.Context, arg DeleteRemoteTrackParams) error
 	DeleteShareAccess(ctx context.Context, arg DeleteShareAccessParams) error
 	DeleteShareAccessByShare(ctx context.Context, arg DeleteShareAccessByShareParams) error
@@ -66,20 +101,35 @@ type Querier interface {
 	DeleteShareTokenByTrack(ctx context.Context, arg DeleteShareTokenByTrackParams) error
 	DeleteSharedProjectOrganization(ctx context.Context, arg DeleteSharedProjectOrganizationParams) error
 	DeleteSharedTrackOrganization(ctx context.Context, arg DeleteSharedTrackOrganizationParams) error
+	DeleteStaleAuthLockouts(ctx context.Context, arg DeleteStaleAuthLockoutsParams) error
 	DeleteStaleWebSocketSessions(ctx context.Context) error
 	DeleteTrack(ctx context.Context, arg DeleteTrackParams) error
 	DeleteTrackFile(ctx context.Context, id int64) error
 	DeleteTrackFilesByVersion(ctx context.Context, versionID int64) error
 	DeleteTrackVersion(ctx context.Context, id int64) error
+	DeleteTrashedFolderContents(ctx context.Context, folderID int64) error
 	DeleteUser(ctx context.Context, id int64) error
 	DeleteUserByID(ctx context.Context, id int64) error
+	DeleteUserIdentity(ctx context.Context, arg DeleteUserIdentityParams) (int64, error)
 	DeleteUserProjectShare(ctx context.Context, arg DeleteUserProjectShareParams) error
 	DeleteUserProjectShareByID(ctx context.Context, arg DeleteUserProjectShareByIDParams) error
+	DeleteUserTOTP(ctx context.Context, userID int64) error
 	DeleteUserTrackShare(ctx context.Context, arg DeleteUserTrackShareParams) error
 	DeleteUserTrackShareByID(ctx context.Context, arg DeleteUserTrackShareByIDParams) error
 	DeleteUserTrackShareByShareID(ctx context.Context, id int64) error
 	DeleteWebSocketSession(ctx context.Context, sessionID string) error
+	EnqueueEmail(ctx context.Context, arg EnqueueEmailParams) (EmailQueue, error)
+	ExpireTakeouts(ctx context.Context, expiresAt sql.NullTime) error
+	FailBuildingTakeouts(ctx context.Context, arg FailBuildingTakeoutsParams) error
+	FailEmail(ctx context.Context, arg FailEmailParams) error
+	FailRunningBackupRuns(ctx context.Context, arg FailRunningBackupRunsParams) error
 	FindFileByContentHash(ctx context.Context, contentHash sql.NullString) (TrackFile, error)
+	FinishBackupRun(ctx context.Context, arg FinishBackupRunParams) error
+	FinishTakeout(ctx context.Context, arg FinishTakeoutParams) (Takeout, error)
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (ApiToken, error)
+	GetAuthLockout(ctx context.Context, arg GetAuthLockoutParams) (AuthLockout, error)
+	GetAuthLockoutByID(ctx context.Context, id int64) (AuthLockout, error)
+	GetCompactionStats(ctx context.Context) (GetCompactionStatsRow, error)
 	GetCompletedTrackFile(ctx context.Context, arg GetCompletedTrackFileParams) (TrackFile, error)
 	GetFederationToken(ctx context.Context, token string) (FederationToken, error)
 	GetFederationTokenByID(ctx context.Context, id int64) (FederationToken, error)
@@ -89,7 +139,12 @@ type Querier interface {
 	// INSTANCE CONFIGURATION
 	GetInstanceConfig(ctx context.Context) (InstanceConfig, error)
 	GetInstanceSettings(ctx context.Context) (InstanceSetting, error)
+	GetInstanceSummary(ctx context.Context) (GetInstanceSummaryRow, error)
 	GetInviteTokenByToken(ctx context.Context, tokenHash string) (InviteToken, error)
+	GetLastBackupRun(ctx context.Context) (BackupRun, error)
+	GetLastSuccessfulBackupRun(ctx context.Context) (BackupRun, error)
+	GetLatestTakeout(ctx context.Context, userID int64) (Takeout, error)
+	GetLoginChallenge(ctx context.Context, tokenHash string) (LoginChallenge, error)
 	// Get the maximum custom_order across all item types at root level
 	GetMaxOrderAtRoot(ctx context.Context, userID int64) (interface{}, error)
 	// Get the maximum custom_order across all item types in a folder
@@ -103,6 +158,7 @@ type Querier interface {
 	GetMaxVersionOrder(ctx context.Context, trackID int64) (interface{}, error)
 	GetNotesByProject(ctx context.Context, projectID sql.NullInt64) ([]Note, error)
 	GetNotesByTrack(ctx context.Context, trackID sql.NullInt64) ([]Note, error)
+	GetPasskeyByCredentialID(ctx context.Context, credentialID string) (Passkey, error)
 	GetProject(ctx context.Context, arg GetProjectParams) (Project, error)
 	GetProjectByID(ctx context.Context, id int64) (Project, error)
 	GetProjectByPublicID(ctx context.Context, arg GetProjectByPublicIDParams) (GetProjectByPublicIDRow, error)
@@ -113,13 +169,17 @@ type Querier interface {
 	GetPublicProjects(ctx context.Context, arg GetPublicProjectsParams) ([]Project, error)
 	GetPublicTracks(ctx context.Context, arg GetPublicTracksParams) ([]Track, error)
 	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (RefreshToken, error)
+	GetRefreshTokenByID(ctx context.Context, id int64) (RefreshToken, error)
 	GetRemoteTrack(ctx context.Context, arg GetRemoteTrackParams) (RemoteTrack, error)
+	GetRequireTwoFactor(ctx context.Context) (bool, error)
 	GetSessionInvalidatedAt(ctx context.Context) (sql.NullTime, error)
 	GetShareAccess(ctx context.Context, arg GetShareAccessParams) (ShareAccess, error)
 	GetShareToken(ctx context.Context, token string) (ShareToken, error)
 	GetShareTokenByID(ctx context.Context, arg GetShareTokenByIDParams) (ShareToken, error)
 	GetShareTokenByTrack(ctx context.Context, arg GetShareTokenByTrackParams) (ShareToken, error)
+	GetSourceCompaction(ctx context.Context) (bool, error)
 	GetStorageStatsByUser(ctx context.Context, userID int64) (GetStorageStatsByUserRow, error)
+	GetTakeout(ctx context.Context, arg GetTakeoutParams) (Takeout, error)
 	GetTokensByUser(ctx context.Context, arg GetTokensByUserParams) ([]InviteToken, error)
 	GetTrack(ctx context.Context, arg GetTrackParams) (Track, error)
 	GetTrackByID(ctx context.Context, id int64) (Track, error)
@@ -129,9 +189,14 @@ type Querier interface {
 	GetTrackVersion(ctx context.Context, id int64) (TrackVersion, error)
 	GetTrackVersionWithOwnership(ctx context.Context, id int64) (GetTrackVersionWithOwnershipRow, error)
 	GetTrackWithDetails(ctx context.Context, arg GetTrackWithDetailsParams) (GetTrackWithDetailsRow, error)
+	GetTrashedFolder(ctx context.Context, arg GetTrashedFolderParams) (Folder, error)
+	GetTrashedProject(ctx context.Context, arg GetTrashedProjectParams) (Project, error)
+	GetTrashedTrack(ctx context.Context, arg GetTrashedTrackParams) (GetTrashedTrackRow, error)
+	GetTrashedTrackVersion(ctx context.Context, arg GetTrashedTrackVersionParams) (GetTrashedTrackVersionRow, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	GetUserByID(ctx context.Context, id int64) (User, error)
 	GetUserByUsername(ctx context.Context, username string) (User, error)
+	GetUserIdentity(ctx context.Context, arg GetUserIdentityParams) (UserIdentity, error)
 	GetUserInviteTokens(ctx context.Context, createdBy int64) ([]InviteToken, error)
 	GetUserNoteForProject(ctx context.Context, arg GetUserNoteForProjectParams) (Note, error)
 	GetUserNoteForTrack(ctx context.Context, arg GetUserNoteForTrackParams) (Note, error)
@@ -140,23 +205,42 @@ type Querier interface {
 	GetUserSessionInvalidatedAt(ctx context.Context, id int64) (sql.NullTime, error)
 	GetUserSharedProjectOrganization(ctx context.Context, arg GetUserSharedProjectOrganizationParams) (UserSharedProjectOrganization, error)
 	GetUserSharedTrackOrganization(ctx context.Context, arg GetUserSharedTrackOrganizationParams) (UserSharedTrackOrganization, error)
+	GetUserTOTP(ctx context.Context, userID int64) (UserTotp, error)
 	GetUserTrackShare(ctx context.Context, arg GetUserTrackShareParams) (UserTrackShare, error)
 	GetUserTrackShareByID(ctx context.Context, id int64) (UserTrackShare, error)
 	GetWebSocketSession(ctx context.Context, sessionID string) (WebsocketSession, error)
 	IncrementAccessCount(ctx context.Context, id int64) error
 	IncrementProjectAccessCount(ctx context.Context, id int64) error
+	// Returns no row if another attempt created the subject's row first.
+	InsertAuthAttempt(ctx context.Context, arg InsertAuthAttemptParams) (AuthLockout, error)
 	InvalidateSessions(ctx context.Context) error
+	ListAPITokensByUser(ctx context.Context, userID int64) ([]ApiToken, error)
+	ListActiveRefreshTokensByUser(ctx context.Context, arg ListActiveRefreshTokensByUserParams) ([]RefreshToken, error)
+	ListAllAuditEvents(ctx context.Context) ([]AuditEvent, error)
 	ListAllFoldersByUser(ctx context.Context, userID int64) ([]Folder, error)
 	ListAllTrackFiles(ctx context.Context) ([]TrackFile, error)
 	ListAllUsers(ctx context.Context) ([]User, error)
+	ListAuthLockouts(ctx context.Context, lastFailureAt time.Time) ([]AuthLockout, error)
+	ListBackupRuns(ctx context.Context, limit int64) ([]BackupRun, error)
+	ListCompactionCandidates(ctx context.Context, limit int64) ([]TrackFile, error)
+	ListDueEmails(ctx context.Context, arg ListDueEmailsParams) ([]EmailQueue, error)
+	ListExpiredTrashedFolders(ctx context.Context, deletedAt sql.NullTime) ([]Folder, error)
+	ListExpiredTrashedProjects(ctx context.Context, deletedAt sql.NullTime) ([]Project, error)
+	ListExpiredTrashedTrackVersions(ctx context.Context, deletedAt sql.NullTime) ([]ListExpiredTrashedTrackVersionsRow, error)
+	ListExpiredTrashedTracks(ctx context.Context, deletedAt sql.NullTime) ([]ListExpiredTrashedTracksRow, error)
 	ListFederationTokensByOrigin(ctx context.Context, arg ListFederationTokensByOriginParams) ([]FederationToken, error)
 	ListFederationTokensByUser(ctx context.Context, localUserID int64) ([]FederationToken, error)
 	ListFoldersByParent(ctx context.Context, arg ListFoldersByParentParams) ([]Folder, error)
 	ListFoldersByUser(ctx context.Context, userID int64) ([]Folder, error)
+	ListFoldersTrashedWithParent(ctx context.Context, parentID sql.NullInt64) ([]Folder, error)
+	ListKeptTakeoutFiles(ctx context.Context, expiresAt sql.NullTime) ([]sql.NullString, error)
+	ListPasskeyUserIDs(ctx context.Context) ([]int64, error)
+	ListPasskeysByUser(ctx context.Context, userID int64) ([]Passkey, error)
 	ListPlainTracksByProject(ctx context.Context, arg ListPlainTracksByProjectParams) ([]Track, error)
 	ListProjectShareTokensByProject(ctx context.Context, projectID int64) ([]ProjectShareToken, error)
 	ListProjectShareTokensByUser(ctx context.Context, userID int64) ([]ProjectShareToken, error)
 	ListProjectShareTokensWithProjectInfo(ctx context.Context, userID int64) ([]ListProjectShareTokensWithProjectInfoRow, error)
+	ListProjectStorageRefs(ctx context.Context) ([]ListProjectStorageRefsRow, error)
 	ListProjectsByUser(ctx context.Context, userID int64) ([]ListProjectsByUserRow, error)
 	ListProjectsInFolder(ctx context.Context, arg ListProjectsInFolderParams) ([]ListProjectsInFolderRow, error)
 	ListProjectsSharedByUser(ctx context.Context, sharedBy int64) ([]UserProjectShare, error)
@@ -174,6 +258,13 @@ type Querier interface {
 	ListSharedProjectOrganizationsInFolder(ctx context.Context, arg ListSharedProjectOrganizationsInFolderParams) ([]UserSharedProjectOrganization, error)
 	ListSharedTrackOrganizationsAtRoot(ctx context.Context, userID int64) ([]UserSharedTrackOrganization, error)
 	ListSharedTrackOrganizationsInFolder(ctx context.Context, arg ListSharedTrackOrganizationsInFolderParams) ([]UserSharedTrackOrganization, error)
+	ListTakeoutContributedTracks(ctx context.Context, userID int64) ([]Track, error)
+	ListTakeoutProjectNotes(ctx context.Context, userID int64) ([]ListTakeoutProjectNotesRow, error)
+	ListTakeoutProjectShareLinks(ctx context.Context, userID int64) ([]ListTakeoutProjectShareLinksRow, error)
+	ListTakeoutProjectShares(ctx context.Context, sharedBy int64) ([]ListTakeoutProjectSharesRow, error)
+	ListTakeoutTrackNotes(ctx context.Context, userID int64) ([]ListTakeoutTrackNotesRow, error)
+	ListTakeoutTrackShareLinks(ctx context.Context, userID int64) ([]ListTakeoutTrackShareLinksRow, error)
+	ListTakeoutTrackShares(ctx context.Context, sharedBy int64) ([]ListTakeoutTrackSharesRow, error)
 	ListTrackFilesByVersion(ctx context.Context, versionID int64) ([]TrackFile, error)
 	ListTrackVersions(ctx context.Context, trackID int64) ([]TrackVersion, error)
 	ListTrackVersionsWithMetadata(ctx context.Context, trackID int64) ([]ListTrackVersionsWithMetadataRow, error)
@@ -185,26 +276,60 @@ type Querier interface {
 	ListTracksWithDetailsByProjectID(ctx context.Context, projectID int64) ([]ListTracksWithDetailsByProjectIDRow, error)
 	ListTracksWithoutAnalysis(ctx context.Context) ([]ListTracksWithoutAnalysisRow, error)
 	ListTracksWithoutBPM(ctx context.Context) ([]ListTracksWithoutBPMRow, error)
+	ListTrashedFolderContents(ctx context.Context, folderID int64) ([]TrashedFolderContent, error)
+	ListTrashedFolders(ctx context.Context, userID int64) ([]Folder, error)
+	ListTrashedProjects(ctx context.Context, userID int64) ([]Project, error)
+	ListTrashedTrackVersions(ctx context.Context, userID int64) ([]ListTrashedTrackVersionsRow, error)
+	ListTrashedTracks(ctx context.Context, userID int64) ([]ListTrashedTracksRow, error)
+	ListTwoFactorUserIDs(ctx context.Context) ([]int64, error)
 	ListUnprocessedCovers(ctx context.Context) ([]Project, error)
+	ListUserIdentities(ctx context.Context, userID int64) ([]UserIdentity, error)
 	ListUserSharedProjectOrganizations(ctx context.Context, userID int64) ([]UserSharedProjectOrganization, error)
 	ListUserSharedTrackOrganizations(ctx context.Context, userID int64) ([]UserSharedTrackOrganization, error)
 	ListUsersProjectIsSharedWith(ctx context.Context, projectID int64) ([]UserProjectShare, error)
 	ListUsersTrackIsSharedWith(ctx context.Context, trackID int64) ([]UserTrackShare, error)
 	ListWebSocketSessionsByResource(ctx context.Context, arg ListWebSocketSessionsByResourceParams) ([]WebsocketSession, error)
 	MarkCoverProcessed(ctx context.Context, id int64) error
+	MarkEmailSent(ctx context.Context, arg MarkEmailSentParams) error
 	MarkTokenAsUsed(ctx context.Context, id int64) (InviteToken, error)
+	ResetFailedCompactions(ctx context.Context) error
+	RestoreFolder(ctx context.Context, arg RestoreFolderParams) error
+	RestoreProject(ctx context.Context, id int64) error
+	RestoreProjectTracks(ctx context.Context, projectID int64) error
+	RestoreTrack(ctx context.Context, id int64) error
+	RestoreTrackVersion(ctx context.Context, id int64) error
+	RetryEmail(ctx context.Context, arg RetryEmailParams) error
+	RevokeOtherRefreshTokensByUser(ctx context.Context, arg RevokeOtherRefreshTokensByUserParams) (int64, error)
 	RevokeRefreshToken(ctx context.Context, id int64) error
 	RevokeRefreshTokensByUser(ctx context.Context, userID int64) error
-	SearchTracksAccessibleByUser(ctx context.Context, arg SearchTracksAccessibleByUserParams) ([]SearchTracksAccessibleByUserRow, error)
+	RevokeUserRefreshToken(ctx context.Context, arg RevokeUserRefreshTokenParams) (int64, error)
+	RotateRefreshToken(ctx context.Context, arg RotateRefreshTokenParams) (int64, error)
 	SetActiveVersion(ctx context.Context, arg SetActiveVersionParams) error
+	SetCompactionStatus(ctx context.Context, arg SetCompactionStatusParams) error
+	SetPendingTOTPSecret(ctx context.Context, arg SetPendingTOTPSecretParams) error
+	SetRequireTwoFactor(ctx context.Context, requireTwoFactor bool) error
+	SetSourceCompaction(ctx context.Context, sourceCompaction bool) error
+	TakeOIDCLogin(ctx context.Context, stateHash string) (OidcLogin, error)
+	TakePasskeyChallenge(ctx context.Context, arg TakePasskeyChallengeParams) (PasskeyChallenge, error)
+	TouchAPIToken(ctx context.Context, arg TouchAPITokenParams) error
+	TrashFolder(ctx context.Context, arg TrashFolderParams) error
+	TrashProject(ctx context.Context, arg TrashProjectParams) error
+	TrashProjectTracks(ctx context.Context, arg TrashProjectTracksParams) error
+	TrashTrack(ctx context.Context, arg TrashTrackParams) error
+	TrashTrackVersion(ctx context.Context, arg TrashTrackVersionParams) error
+	// Returns no row if the subject is blocked, or another attempt has been
+	// counted since it was read.
+	UpdateAuthAttempt(ctx context.Context, arg UpdateAuthAttemptParams) (AuthLockout, error)
 	UpdateFederationTokenLastUsed(ctx context.Context, id int64) error
 	UpdateFolder(ctx context.Context, arg UpdateFolderParams) (Folder, error)
 	UpdateFolderName(ctx context.Context, arg UpdateFolderNameParams) (Folder, error)
 	UpdateFolderOrder(ctx context.Context, arg UpdateFolderOrderParams) error
 	UpdateFolderParent(ctx context.Context, arg UpdateFolderParentParams) (Folder, error)
+	UpdateFolderSmartQuery(ctx context.Context, arg UpdateFolderSmartQueryParams) (Folder, error)
 	UpdateInstanceConfig(ctx context.Context, arg UpdateInstanceConfigParams) (InstanceConfig, error)
 	UpdateInstanceName(ctx context.Context, name string) (InstanceSetting, error)
 	UpdateNote(ctx context.Context, arg UpdateNoteParams) (Note, error)
+	UpdatePasskeyUse(ctx context.Context, arg UpdatePasskeyUseParams) error
 	UpdateProject(ctx context.Context, arg UpdateProjectParams) (Project, error)
 	UpdateProjectCover(ctx context.Context, arg UpdateProjectCoverParams) (Project, error)
 	UpdateProjectCustomOrder(ctx context.Context, arg UpdateProjectCustomOrderParams) (Project, error)
@@ -220,6 +345,7 @@ type Querier interface {
 	UpdateShareToken(ctx context.Context, arg UpdateShareTokenParams) (ShareToken, error)
 	UpdateSharedProjectOrganization(ctx context.Context, arg UpdateSharedProjectOrganizationParams) (UserSharedProjectOrganization, error)
 	UpdateSharedTrackOrganization(ctx context.Context, arg UpdateSharedTrackOrganizationParams) (UserSharedTrackOrganization, error)
+	UpdateTOTPLastStep(ctx context.Context, arg UpdateTOTPLastStepParams) (int64, error)
 	UpdateTrack(ctx context.Context, arg UpdateTrackParams) (Track, error)
 	UpdateTrackAnalysis(ctx context.Context, arg UpdateTrackAnalysisParams) error
 	UpdateTrackBPM(ctx context.Context, arg UpdateTrackBPMParams) error
@@ -235,6 +361,7 @@ type Querier interface {
 	UpdateTranscodingStatus(ctx context.Context, arg UpdateTranscodingStatusParams) error
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
 	UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) (User, error)
+	UpdateUserIdentityLogin(ctx context.Context, arg UpdateUserIdentityLoginParams) error
 	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
 	UpdateUserPreferences(ctx context.Context, arg UpdateUserPreferencesParams) (UserPreference, error)
 	UpdateUserProjectShare(ctx context.Context, arg UpdateUserProjectShareParams) (UserProjectShare, error)
@@ -250,6 +377,7 @@ type Querier interface {
 	UpsertSharedProjectOrganization(ctx context.Context, arg UpsertSharedProjectOrganizationParams) (UserSharedProjectOrganization, error)
 	UpsertSharedTrackOrganization(ctx context.Context, arg UpsertSharedTrackOrganizationParams) (UserSharedTrackOrganization, error)
 	UpsertTrackNote(ctx context.Context, arg UpsertTrackNoteParams) (Note, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -80,6 +80,93 @@ func (q *Queries) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (
 	return i, err
 }
 
+const getRefreshTokenByID = `-- name: GetRefreshTokenByID :one
+SELECT id, user_id, token_hash, created_at, expires_at, revoked_at, last_used_at, user_agent, ip FROM refresh_tokens
+WHERE id = ?
+`
+
+func (q *Queries) GetRefreshTokenByID(ctx context.Context, id int64) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshTokenByID, id)
+	var i RefreshToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.LastUsedAt,
+		&i.UserAgent,
+		&i.Ip,
+	)
+	return i, err
+}
+
+const listActiveRefreshTokensByUser = `-- name: ListActiveRefreshTokensByUser :many
+SELECT id, user_id, token_hash, created_at, expires_at, revoked_at, last_used_at, user_agent, ip FROM refresh_tokens
+WHERE user_id = ?1
+  AND revoked_at IS NULL
+  AND expires_at > ?2
+ORDER BY COALESCE(last_used_at, created_at) DESC, id DESC
+`
+
+type ListActiveRefreshTokensByUserParams struct {
+	UserID int64     `json:"user_id"`
+	Now    time.Time `json:"now"`
+}
+
+func (q *Queries) ListActiveRefreshTokensByUser(ctx context.Context, arg ListActiveRefreshTokensByUserParams) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveRefreshTokensByUser, arg.UserID, arg.Now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []RefreshToken{}
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.TokenHash,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.LastUsedAt,
+			&i.UserAgent,
+			&i.Ip,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeOtherRefreshTokensByUser = `-- name: RevokeOtherRefreshTokensByUser :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = ?1 AND id != ?2 AND revoked_at IS NULL
+`
+
+type RevokeOtherRefreshTokensByUserParams struct {
+	UserID int64 `json:"user_id"`
+	KeepID int64 `json:"keep_id"`
+}
+
+func (q *Queries) RevokeOtherRefreshTokensByUser(ctx context.Context, arg RevokeOtherRefreshTokensByUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeOtherRefreshTokensByUser, arg.UserID, arg.KeepID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at = CURRENT_TIMESTAMP
@@ -102,6 +189,59 @@ func (q *Queries) RevokeRefreshTokensByUser(ctx context.Context, userID int64) e
 	return err
 }
 
+const revokeUserRefreshToken = `-- name: RevokeUserRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+`
+
+type RevokeUserRefreshTokenParams struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+func (q *Queries) RevokeUserRefreshToken(ctx context.Context, arg RevokeUserRefreshTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserRefreshToken, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET token_hash = ?1,
+    expires_at = ?2,
+    user_agent = ?3,
+    ip = ?4,
+    last_used_at = CURRENT_TIMESTAMP
+WHERE id = ?5 AND token_hash = ?6 AND revoked_at IS NULL
+`
+
+type RotateRefreshTokenParams struct {
+	TokenHash    string         `json:"token_hash"`
+	ExpiresAt    time.Time      `json:"expires_at"`
+	UserAgent    sql.NullString `json:"user_agent"`
+	Ip           sql.NullString `json:"ip"`
+	ID           int64          `json:"id"`
+	OldTokenHash string         `json:"old_token_hash"`
+}
+
+func (q *Queries) RotateRefreshToken(ctx context.Context, arg RotateRefreshTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rotateRefreshToken,
+		arg.TokenHash,
+		arg.ExpiresAt,
+		arg.UserAgent,
+		arg.Ip,
+		arg.ID,
+		arg.OldTokenHash,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateRefreshTokenLastUsed = `-- name: UpdateRefreshTokenLastUsed :exec
 UPDATE refresh_tokens
 SET last_used_at = CURRENT_TIMESTAMP
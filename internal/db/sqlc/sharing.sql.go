@@ -716,8 +716,8 @@ func (q *Queries) GetProjectShareTokenByProject(ctx context.Context, arg GetProj
 }
 
 const getPublicProjects = `-- name: GetPublicProjects :many
-SELECT id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed FROM projects
-WHERE visibility_status = 'public'
+SELECT id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at FROM projects
+WHERE visibility_status = 'public' AND deleted_at IS NULL
 ORDER BY created_at DESC
 LIMIT ? OFFSET ?
 `
@@ -762,6 +762,7 @@ func (q *Queries) GetPublicProjects(ctx context.Context, arg GetPublicProjectsPa
 			&i.SharedWithInstanceUsers,
 			&i.CustomOrder,
 			&i.CoverProcessed,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -777,8 +778,8 @@ func (q *Queries) GetPublicProjects(ctx context.Context, arg GetPublicProjectsPa
 }
 
 const getPublicTracks = `-- name: GetPublicTracks :many
-SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users FROM tracks
-WHERE visibility_status = 'public'
+SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at FROM tracks
+WHERE visibility_status = 'public' AND deleted_at IS NULL
 ORDER BY created_at DESC
 LIMIT ? OFFSET ?
 `
@@ -820,6 +821,7 @@ func (q *Queries) GetPublicTracks(ctx context.Context, arg GetPublicTracksParams
 			&i.PasswordHash,
 			&i.OriginInstanceUrl,
 			&i.SharedWithInstanceUsers,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -1361,9 +1363,9 @@ func (q *Queries) ListProjectsSharedByUser(ctx context.Context, sharedBy int64)
 }
 
 const listProjectsSharedWithUser = `-- name: ListProjectsSharedWithUser :many
-SELECT DISTINCT p.id, p.user_id, p.name, p.description, p.quality_override, p.created_at, p.updated_at, p.public_id, p.cover_art_path, p.cover_art_mime, p.cover_art_updated_at, p.author_override, p.folder_id, p.folder_added_at, p.notes, p.notes_author_name, p.notes_updated_at, p.visibility_status, p.allow_editing, p.allow_downloads, p.password_hash, p.origin_instance_url, p.shared_with_instance_users, p.custom_order, p.cover_processed FROM projects p
+SELECT DISTINCT p.id, p.user_id, p.name, p.description, p.quality_override, p.created_at, p.updated_at, p.public_id, p.cover_art_path, p.cover_art_mime, p.cover_art_updated_at, p.author_override, p.folder_id, p.folder_added_at, p.notes, p.notes_author_name, p.notes_updated_at, p.visibility_status, p.allow_editing, p.allow_downloads, p.password_hash, p.origin_instance_url, p.shared_with_instance_users, p.custom_order, p.cover_processed, p.deleted_at FROM projects p
 JOIN user_project_shares ups ON p.id = ups.project_id
-WHERE ups.shared_to = ?
+WHERE ups.shared_to = ? AND p.deleted_at IS NULL
 ORDER BY p.created_at DESC
 `
 
@@ -1402,6 +1404,7 @@ func (q *Queries) ListProjectsSharedWithUser(ctx context.Context, sharedTo int64
 			&i.SharedWithInstanceUsers,
 			&i.CustomOrder,
 			&i.CoverProcessed,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -1697,9 +1700,9 @@ func (q *Queries) ListTracksSharedByUser(ctx context.Context, sharedBy int64) ([
 }
 
 const listTracksSharedWithUser = `-- name: ListTracksSharedWithUser :many
-SELECT DISTINCT t.id, t.user_id, t.project_id, t.title, t.artist, t.album, t.active_version_id, t.created_at, t.updated_at, t.track_order, t."key", t.bpm, t.public_id, t.notes, t.notes_author_name, t.notes_updated_at, t.visibility_status, t.allow_editing, t.allow_downloads, t.password_hash, t.origin_instance_url, t.shared_with_instance_users FROM tracks t
+SELECT DISTINCT t.id, t.user_id, t.project_id, t.title, t.artist, t.album, t.active_version_id, t.created_at, t.updated_at, t.track_order, t."key", t.bpm, t.public_id, t.notes, t.notes_author_name, t.notes_updated_at, t.visibility_status, t.allow_editing, t.allow_downloads, t.password_hash, t.origin_instance_url, t.shared_with_instance_users, t.deleted_at FROM tracks t
 JOIN user_track_shares uts ON t.id = uts.track_id
-WHERE uts.shared_to = ?
+WHERE uts.shared_to = ? AND t.deleted_at IS NULL
 ORDER BY t.created_at DESC
 `
 
@@ -1735,6 +1738,7 @@ func (q *Queries) ListTracksSharedWithUser(ctx context.Context, sharedTo int64)
 			&i.PasswordHash,
 			&i.OriginInstanceUrl,
 			&i.SharedWithInstanceUsers,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -1980,7 +1984,7 @@ SET visibility_status = ?,
     password_hash = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
-RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed
+RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at
 `
 
 type UpdateProjectVisibilityParams struct {
@@ -2028,6 +2032,7 @@ func (q *Queries) UpdateProjectVisibility(ctx context.Context, arg UpdateProject
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -2040,7 +2045,7 @@ SET visibility_status = ?,
     password_hash = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE public_id = ? AND user_id = ?
-RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed
+RETURNING id, user_id, name, description, quality_override, created_at, updated_at, public_id, cover_art_path, cover_art_mime, cover_art_updated_at, author_override, folder_id, folder_added_at, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, custom_order, cover_processed, deleted_at
 `
 
 type UpdateProjectVisibilityByPublicIDParams struct {
@@ -2088,6 +2093,7 @@ func (q *Queries) UpdateProjectVisibilityByPublicID(ctx context.Context, arg Upd
 		&i.SharedWithInstanceUsers,
 		&i.CustomOrder,
 		&i.CoverProcessed,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -2168,7 +2174,7 @@ SET visibility_status = ?,
     password_hash = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
-RETURNING id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users
+RETURNING id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at
 `
 
 type UpdateTrackVisibilityParams struct {
@@ -2214,6 +2220,7 @@ func (q *Queries) UpdateTrackVisibility(ctx context.Context, arg UpdateTrackVisi
 		&i.PasswordHash,
 		&i.OriginInstanceUrl,
 		&i.SharedWithInstanceUsers,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -2226,7 +2233,7 @@ SET visibility_status = ?,
     password_hash = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE public_id = ? AND user_id = ?
-RETURNING id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users
+RETURNING id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at
 `
 
 type UpdateTrackVisibilityByPublicIDParams struct {
@@ -2271,6 +2278,7 @@ func (q *Queries) UpdateTrackVisibilityByPublicID(ctx context.Context, arg Updat
 		&i.PasswordHash,
 		&i.OriginInstanceUrl,
 		&i.SharedWithInstanceUsers,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -2283,7 +2291,7 @@ SET visibility_status = ?,
     password_hash = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE public_id = ?
-RETURNING id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users
+RETURNING id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at
 `
 
 type UpdateTrackVisibilityByPublicIDNoUserFilterParams struct {
@@ -2326,6 +2334,7 @@ func (q *Queries) UpdateTrackVisibilityByPublicIDNoUserFilter(ctx context.Contex
 		&i.PasswordHash,
 		&i.OriginInstanceUrl,
 		&i.SharedWithInstanceUsers,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -48,6 +48,36 @@ func (q *Queries) GetGlobalStorageStats(ctx context.Context) (GetGlobalStorageSt
 	return i, err
 }
 
+const getInstanceSummary = `-- name: GetInstanceSummary :one
+SELECT
+    (SELECT COUNT(*) FROM users) as user_count,
+    (SELECT COUNT(*) FROM projects) as project_count,
+    (SELECT COUNT(*) FROM tracks) as track_count,
+    (SELECT COUNT(*) FROM track_versions) as version_count,
+    (SELECT COUNT(*) FROM track_files) as file_count
+`
+
+type GetInstanceSummaryRow struct {
+	UserCount    interface{} `json:"user_count"`
+	ProjectCount interface{} `json:"project_count"`
+	TrackCount   interface{} `json:"track_count"`
+	VersionCount interface{} `json:"version_count"`
+	FileCount    interface{} `json:"file_count"`
+}
+
+func (q *Queries) GetInstanceSummary(ctx context.Context) (GetInstanceSummaryRow, error) {
+	row := q.db.QueryRowContext(ctx, getInstanceSummary)
+	var i GetInstanceSummaryRow
+	err := row.Scan(
+		&i.UserCount,
+		&i.ProjectCount,
+		&i.TrackCount,
+		&i.VersionCount,
+		&i.FileCount,
+	)
+	return i, err
+}
+
 const getStorageStatsByUser = `-- name: GetStorageStatsByUser :one
 SELECT
     COALESCE(SUM(tf.file_size), 0) as total_size_bytes,
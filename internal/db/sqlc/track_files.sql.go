@@ -10,10 +10,84 @@ import (
 	"database/sql"
 )
 
+const compactTrackFile = `-- name: CompactTrackFile :execrows
+UPDATE track_files
+SET file_path = ?,
+    file_size = ?,
+    content_hash = ?,
+    stored_format = ?,
+    original_codec = ?,
+    original_size = ?,
+    pcm_hash = ?,
+    compaction_status = 'compacted'
+WHERE id = ? AND file_path = ?
+`
+
+type CompactTrackFileParams struct {
+	FilePath      string         `json:"file_path"`
+	FileSize      int64          `json:"file_size"`
+	ContentHash   sql.NullString `json:"content_hash"`
+	StoredFormat  sql.NullString `json:"stored_format"`
+	OriginalCodec sql.NullString `json:"original_codec"`
+	OriginalSize  sql.NullInt64  `json:"original_size"`
+	PcmHash       sql.NullString `json:"pcm_hash"`
+	ID            int64          `json:"id"`
+	FilePath_2    string         `json:"file_path_2"`
+}
+
+func (q *Queries) CompactTrackFile(ctx context.Context, arg CompactTrackFileParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, compactTrackFile,
+		arg.FilePath,
+		arg.FileSize,
+		arg.ContentHash,
+		arg.StoredFormat,
+		arg.OriginalCodec,
+		arg.OriginalSize,
+		arg.PcmHash,
+		arg.ID,
+		arg.FilePath_2,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const copyTrackFileCompaction = `-- name: CopyTrackFileCompaction :exec
+UPDATE track_files
+SET stored_format = ?,
+    original_codec = ?,
+    original_size = ?,
+    pcm_hash = ?,
+    compaction_status = ?
+WHERE id = ?
+`
+
+type CopyTrackFileCompactionParams struct {
+	StoredFormat     sql.NullString `json:"stored_format"`
+	OriginalCodec    sql.NullString `json:"original_codec"`
+	OriginalSize     sql.NullInt64  `json:"original_size"`
+	PcmHash          sql.NullString `json:"pcm_hash"`
+	CompactionStatus sql.NullString `json:"compaction_status"`
+	ID               int64          `json:"id"`
+}
+
+func (q *Queries) CopyTrackFileCompaction(ctx context.Context, arg CopyTrackFileCompactionParams) error {
+	_, err := q.db.ExecContext(ctx, copyTrackFileCompaction,
+		arg.StoredFormat,
+		arg.OriginalCodec,
+		arg.OriginalSize,
+		arg.PcmHash,
+		arg.CompactionStatus,
+		arg.ID,
+	)
+	return err
+}
+
 const createTrackFile = `-- name: CreateTrackFile :one
 INSERT INTO track_files (version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, original_filename)
 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-RETURNING id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename
+RETURNING id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename, stored_format, original_codec, original_size, pcm_hash, compaction_status
 `
 
 type CreateTrackFileParams struct {
@@ -54,6 +128,11 @@ func (q *Queries) CreateTrackFile(ctx context.Context, arg CreateTrackFileParams
 		&i.CreatedAt,
 		&i.Waveform,
 		&i.OriginalFilename,
+		&i.StoredFormat,
+		&i.OriginalCodec,
+		&i.OriginalSize,
+		&i.PcmHash,
+		&i.CompactionStatus,
 	)
 	return i, err
 }
@@ -79,7 +158,7 @@ func (q *Queries) DeleteTrackFilesByVersion(ctx context.Context, versionID int64
 }
 
 const findFileByContentHash = `-- name: FindFileByContentHash :one
-SELECT id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename FROM track_files
+SELECT id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename, stored_format, original_codec, original_size, pcm_hash, compaction_status FROM track_files
 WHERE content_hash = ?
 LIMIT 1
 `
@@ -100,12 +179,49 @@ func (q *Queries) FindFileByContentHash(ctx context.Context, contentHash sql.Nul
 		&i.CreatedAt,
 		&i.Waveform,
 		&i.OriginalFilename,
+		&i.StoredFormat,
+		&i.OriginalCodec,
+		&i.OriginalSize,
+		&i.PcmHash,
+		&i.CompactionStatus,
+	)
+	return i, err
+}
+
+const getCompactionStats = `-- name: GetCompactionStats :one
+SELECT
+    COUNT(CASE WHEN compaction_status IS NULL THEN 1 END) as pending_files,
+    COUNT(CASE WHEN compaction_status = 'compacted' THEN 1 END) as compacted_files,
+    COUNT(CASE WHEN compaction_status = 'skipped' THEN 1 END) as skipped_files,
+    COUNT(CASE WHEN compaction_status = 'failed' THEN 1 END) as failed_files,
+    COALESCE(SUM(CASE WHEN compaction_status = 'compacted' THEN original_size - file_size ELSE 0 END), 0) as saved_bytes
+FROM track_files
+WHERE quality = 'source' AND format IN ('wav', 'aiff', 'aif')
+`
+
+type GetCompactionStatsRow struct {
+	PendingFiles   int64       `json:"pending_files"`
+	CompactedFiles int64       `json:"compacted_files"`
+	SkippedFiles   int64       `json:"skipped_files"`
+	FailedFiles    int64       `json:"failed_files"`
+	SavedBytes     interface{} `json:"saved_bytes"`
+}
+
+func (q *Queries) GetCompactionStats(ctx context.Context) (GetCompactionStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getCompactionStats)
+	var i GetCompactionStatsRow
+	err := row.Scan(
+		&i.PendingFiles,
+		&i.CompactedFiles,
+		&i.SkippedFiles,
+		&i.FailedFiles,
+		&i.SavedBytes,
 	)
 	return i, err
 }
 
 const getCompletedTrackFile = `-- name: GetCompletedTrackFile :one
-SELECT id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename FROM track_files
+SELECT id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename, stored_format, original_codec, original_size, pcm_hash, compaction_status FROM track_files
 WHERE version_id = ? AND quality = ? AND transcoding_status = 'completed'
 `
 
@@ -130,12 +246,17 @@ func (q *Queries) GetCompletedTrackFile(ctx context.Context, arg GetCompletedTra
 		&i.CreatedAt,
 		&i.Waveform,
 		&i.OriginalFilename,
+		&i.StoredFormat,
+		&i.OriginalCodec,
+		&i.OriginalSize,
+		&i.PcmHash,
+		&i.CompactionStatus,
 	)
 	return i, err
 }
 
 const getTrackFile = `-- name: GetTrackFile :one
-SELECT id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename FROM track_files
+SELECT id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename, stored_format, original_codec, original_size, pcm_hash, compaction_status FROM track_files
 WHERE version_id = ? AND quality = ?
 `
 
@@ -160,12 +281,17 @@ func (q *Queries) GetTrackFile(ctx context.Context, arg GetTrackFileParams) (Tra
 		&i.CreatedAt,
 		&i.Waveform,
 		&i.OriginalFilename,
+		&i.StoredFormat,
+		&i.OriginalCodec,
+		&i.OriginalSize,
+		&i.PcmHash,
+		&i.CompactionStatus,
 	)
 	return i, err
 }
 
 const listAllTrackFiles = `-- name: ListAllTrackFiles :many
-SELECT id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename FROM track_files
+SELECT id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename, stored_format, original_codec, original_size, pcm_hash, compaction_status FROM track_files
 ORDER BY id ASC
 `
 
@@ -191,6 +317,62 @@ func (q *Queries) ListAllTrackFiles(ctx context.Context) ([]TrackFile, error) {
 			&i.CreatedAt,
 			&i.Waveform,
 			&i.OriginalFilename,
+			&i.StoredFormat,
+			&i.OriginalCodec,
+			&i.OriginalSize,
+			&i.PcmHash,
+			&i.CompactionStatus,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCompactionCandidates = `-- name: ListCompactionCandidates :many
+SELECT id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename, stored_format, original_codec, original_size, pcm_hash, compaction_status FROM track_files
+WHERE quality = 'source'
+  AND format IN ('wav', 'aiff', 'aif')
+  AND compaction_status IS NULL
+  AND transcoding_status = 'completed'
+ORDER BY id ASC
+LIMIT ?
+`
+
+func (q *Queries) ListCompactionCandidates(ctx context.Context, limit int64) ([]TrackFile, error) {
+	rows, err := q.db.QueryContext(ctx, listCompactionCandidates, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []TrackFile{}
+	for rows.Next() {
+		var i TrackFile
+		if err := rows.Scan(
+			&i.ID,
+			&i.VersionID,
+			&i.Quality,
+			&i.FilePath,
+			&i.FileSize,
+			&i.Format,
+			&i.Bitrate,
+			&i.ContentHash,
+			&i.TranscodingStatus,
+			&i.CreatedAt,
+			&i.Waveform,
+			&i.OriginalFilename,
+			&i.StoredFormat,
+			&i.OriginalCodec,
+			&i.OriginalSize,
+			&i.PcmHash,
+			&i.CompactionStatus,
 		); err != nil {
 			return nil, err
 		}
@@ -206,7 +388,7 @@ func (q *Queries) ListAllTrackFiles(ctx context.Context) ([]TrackFile, error) {
 }
 
 const listTrackFilesByVersion = `-- name: ListTrackFilesByVersion :many
-SELECT id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename FROM track_files
+SELECT id, version_id, quality, file_path, file_size, format, bitrate, content_hash, transcoding_status, created_at, waveform, original_filename, stored_format, original_codec, original_size, pcm_hash, compaction_status FROM track_files
 WHERE version_id = ?
 `
 
@@ -232,6 +414,11 @@ func (q *Queries) ListTrackFilesByVersion(ctx context.Context, versionID int64)
 			&i.CreatedAt,
 			&i.Waveform,
 			&i.OriginalFilename,
+			&i.StoredFormat,
+			&i.OriginalCodec,
+			&i.OriginalSize,
+			&i.PcmHash,
+			&i.CompactionStatus,
 		); err != nil {
 			return nil, err
 		}
@@ -246,6 +433,33 @@ func (q *Queries) ListTrackFilesByVersion(ctx context.Context, versionID int64)
 	return items, nil
 }
 
+const resetFailedCompactions = `-- name: ResetFailedCompactions :exec
+UPDATE track_files
+SET compaction_status = NULL
+WHERE compaction_status = 'failed'
+`
+
+func (q *Queries) ResetFailedCompactions(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, resetFailedCompactions)
+	return err
+}
+
+const setCompactionStatus = `-- name: SetCompactionStatus :exec
+UPDATE track_files
+SET compaction_status = ?
+WHERE id = ?
+`
+
+type SetCompactionStatusParams struct {
+	CompactionStatus sql.NullString `json:"compaction_status"`
+	ID               int64          `json:"id"`
+}
+
+func (q *Queries) SetCompactionStatus(ctx context.Context, arg SetCompactionStatusParams) error {
+	_, err := q.db.ExecContext(ctx, setCompactionStatus, arg.CompactionStatus, arg.ID)
+	return err
+}
+
 const updateTrackFileSize = `-- name: UpdateTrackFileSize :exec
 UPDATE track_files
 SET file_size = ?
@@ -34,7 +34,7 @@ func (q *Queries) ClearTrackAnalysis(ctx context.Context, id int64) error {
 const createTrack = `-- name: CreateTrack :one
 INSERT INTO tracks (user_id, project_id, title, artist, album, public_id)
 VALUES (?, ?, ?, ?, ?, ?)
-RETURNING id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users
+RETURNING id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at
 `
 
 type CreateTrackParams struct {
@@ -79,6 +79,7 @@ func (q *Queries) CreateTrack(ctx context.Context, arg CreateTrackParams) (Track
 		&i.PasswordHash,
 		&i.OriginInstanceUrl,
 		&i.SharedWithInstanceUsers,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -112,8 +113,8 @@ func (q *Queries) GetMaxTrackOrderByProject(ctx context.Context, projectID int64
 }
 
 const getTrack = `-- name: GetTrack :one
-SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users FROM tracks
-WHERE id = ? AND user_id = ?
+SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at FROM tracks
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL
 `
 
 type GetTrackParams struct {
@@ -147,13 +148,14 @@ func (q *Queries) GetTrack(ctx context.Context, arg GetTrackParams) (Track, erro
 		&i.PasswordHash,
 		&i.OriginInstanceUrl,
 		&i.SharedWithInstanceUsers,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getTrackByID = `-- name: GetTrackByID :one
-SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users FROM tracks
-WHERE id = ?
+SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at FROM tracks
+WHERE id = ? AND deleted_at IS NULL
 `
 
 func (q *Queries) GetTrackByID(ctx context.Context, id int64) (Track, error) {
@@ -182,13 +184,14 @@ func (q *Queries) GetTrackByID(ctx context.Context, id int64) (Track, error) {
 		&i.PasswordHash,
 		&i.OriginInstanceUrl,
 		&i.SharedWithInstanceUsers,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getTrackByPublicID = `-- name: GetTrackByPublicID :one
-SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users FROM tracks
-WHERE public_id = ? AND user_id = ?
+SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at FROM tracks
+WHERE public_id = ? AND user_id = ? AND deleted_at IS NULL
 `
 
 type GetTrackByPublicIDParams struct {
@@ -222,13 +225,14 @@ func (q *Queries) GetTrackByPublicID(ctx context.Context, arg GetTrackByPublicID
 		&i.PasswordHash,
 		&i.OriginInstanceUrl,
 		&i.SharedWithInstanceUsers,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getTrackByPublicIDNoFilter = `-- name: GetTrackByPublicIDNoFilter :one
-SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users FROM tracks
-WHERE public_id = ?
+SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at FROM tracks
+WHERE public_id = ? AND deleted_at IS NULL
 `
 
 func (q *Queries) GetTrackByPublicIDNoFilter(ctx context.Context, publicID string) (Track, error) {
@@ -257,6 +261,7 @@ func (q *Queries) GetTrackByPublicIDNoFilter(ctx context.Context, publicID strin
 		&i.PasswordHash,
 		&i.OriginInstanceUrl,
 		&i.SharedWithInstanceUsers,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -286,7 +291,7 @@ FROM tracks t
 LEFT JOIN track_versions tv ON t.active_version_id = tv.id
 LEFT JOIN track_files tf ON tv.id = tf.version_id AND tf.quality = 'lossy'
 JOIN projects p ON t.project_id = p.id
-WHERE t.id = ? AND t.user_id = ?
+WHERE t.id = ? AND t.user_id = ? AND t.deleted_at IS NULL
 `
 
 type GetTrackWithDetailsParams struct {
@@ -344,8 +349,8 @@ func (q *Queries) GetTrackWithDetails(ctx context.Context, arg GetTrackWithDetai
 }
 
 const listPlainTracksByProject = `-- name: ListPlainTracksByProject :many
-SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users FROM tracks
-WHERE user_id = ? AND project_id = ?
+SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at FROM tracks
+WHERE user_id = ? AND project_id = ? AND deleted_at IS NULL
 ORDER BY track_order ASC
 `
 
@@ -386,6 +391,7 @@ func (q *Queries) ListPlainTracksByProject(ctx context.Context, arg ListPlainTra
 			&i.PasswordHash,
 			&i.OriginInstanceUrl,
 			&i.SharedWithInstanceUsers,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -402,7 +408,7 @@ func (q *Queries) ListPlainTracksByProject(ctx context.Context, arg ListPlainTra
 
 const listTracksByProject = `-- name: ListTracksByProject :many
 SELECT
-    t.id, t.user_id, t.project_id, t.title, t.artist, t.album, t.active_version_id, t.created_at, t.updated_at, t.track_order, t."key", t.bpm, t.public_id, t.notes, t.notes_author_name, t.notes_updated_at, t.visibility_status, t.allow_editing, t.allow_downloads, t.password_hash, t.origin_instance_url, t.shared_with_instance_users,
+    t.id, t.user_id, t.project_id, t.title, t.artist, t.album, t.active_version_id, t.created_at, t.updated_at, t.track_order, t."key", t.bpm, t.public_id, t.notes, t.notes_author_name, t.notes_updated_at, t.visibility_status, t.allow_editing, t.allow_downloads, t.password_hash, t.origin_instance_url, t.shared_with_instance_users, t.deleted_at,
     COALESCE(tv.version_name, '') as active_version_name,
     tv.duration_seconds as active_version_duration_seconds,
     p.name as project_name,
@@ -419,7 +425,7 @@ FROM tracks t
 LEFT JOIN track_versions tv ON t.active_version_id = tv.id
 LEFT JOIN track_files tf ON tv.id = tf.version_id AND tf.quality = 'lossy'
 JOIN projects p ON t.project_id = p.id
-WHERE t.user_id = ? AND t.project_id = ?
+WHERE t.user_id = ? AND t.project_id = ? AND t.deleted_at IS NULL
 ORDER BY t.track_order ASC
 `
 
@@ -451,6 +457,7 @@ type ListTracksByProjectRow struct {
 	PasswordHash                 sql.NullString  `json:"password_hash"`
 	OriginInstanceUrl            sql.NullString  `json:"origin_instance_url"`
 	SharedWithInstanceUsers      sql.NullBool    `json:"shared_with_instance_users"`
+	DeletedAt                    sql.NullTime    `json:"deleted_at"`
 	ActiveVersionName            string          `json:"active_version_name"`
 	ActiveVersionDurationSeconds sql.NullFloat64 `json:"active_version_duration_seconds"`
 	ProjectName                  string          `json:"project_name"`
@@ -491,6 +498,7 @@ func (q *Queries) ListTracksByProject(ctx context.Context, arg ListTracksByProje
 			&i.PasswordHash,
 			&i.OriginInstanceUrl,
 			&i.SharedWithInstanceUsers,
+			&i.DeletedAt,
 			&i.ActiveVersionName,
 			&i.ActiveVersionDurationSeconds,
 			&i.ProjectName,
@@ -512,8 +520,8 @@ func (q *Queries) ListTracksByProject(ctx context.Context, arg ListTracksByProje
 }
 
 const listTracksByProjectID = `-- name: ListTracksByProjectID :many
-SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users FROM tracks
-WHERE project_id = ?
+SELECT id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at FROM tracks
+WHERE project_id = ? AND deleted_at IS NULL
 ORDER BY track_order ASC
 `
 
@@ -549,6 +557,7 @@ func (q *Queries) ListTracksByProjectID(ctx context.Context, projectID int64) ([
 			&i.PasswordHash,
 			&i.OriginInstanceUrl,
 			&i.SharedWithInstanceUsers,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -565,7 +574,7 @@ func (q *Queries) ListTracksByProjectID(ctx context.Context, projectID int64) ([
 
 const listTracksByUser = `-- name: ListTracksByUser :many
 SELECT
-    t.id, t.user_id, t.project_id, t.title, t.artist, t.album, t.active_version_id, t.created_at, t.updated_at, t.track_order, t."key", t.bpm, t.public_id, t.notes, t.notes_author_name, t.notes_updated_at, t.visibility_status, t.allow_editing, t.allow_downloads, t.password_hash, t.origin_instance_url, t.shared_with_instance_users,
+    t.id, t.user_id, t.project_id, t.title, t.artist, t.album, t.active_version_id, t.created_at, t.updated_at, t.track_order, t."key", t.bpm, t.public_id, t.notes, t.notes_author_name, t.notes_updated_at, t.visibility_status, t.allow_editing, t.allow_downloads, t.password_hash, t.origin_instance_url, t.shared_with_instance_users, t.deleted_at,
     COALESCE(tv.version_name, '') as active_version_name,
     tv.duration_seconds as active_version_duration_seconds,
     p.name as project_name,
@@ -575,7 +584,7 @@ FROM tracks t
 LEFT JOIN track_versions tv ON t.active_version_id = tv.id
 LEFT JOIN track_files tf ON tv.id = tf.version_id AND tf.quality = 'lossy'
 JOIN projects p ON t.project_id = p.id
-WHERE p.user_id = ?
+WHERE p.user_id = ? AND t.deleted_at IS NULL AND p.deleted_at IS NULL
 ORDER BY t.created_at DESC
 `
 
@@ -602,6 +611,7 @@ type ListTracksByUserRow struct {
 	PasswordHash                 sql.NullString  `json:"password_hash"`
 	OriginInstanceUrl            sql.NullString  `json:"origin_instance_url"`
 	SharedWithInstanceUsers      sql.NullBool    `json:"shared_with_instance_users"`
+	DeletedAt                    sql.NullTime    `json:"deleted_at"`
 	ActiveVersionName            string          `json:"active_version_name"`
 	ActiveVersionDurationSeconds sql.NullFloat64 `json:"active_version_duration_seconds"`
 	ProjectName                  string          `json:"project_name"`
@@ -641,6 +651,7 @@ func (q *Queries) ListTracksByUser(ctx context.Context, userID int64) ([]ListTra
 			&i.PasswordHash,
 			&i.OriginInstanceUrl,
 			&i.SharedWithInstanceUsers,
+			&i.DeletedAt,
 			&i.ActiveVersionName,
 			&i.ActiveVersionDurationSeconds,
 			&i.ProjectName,
@@ -662,7 +673,7 @@ func (q *Queries) ListTracksByUser(ctx context.Context, userID int64) ([]ListTra
 
 const listTracksWithDetailsByProjectID = `-- name: ListTracksWithDetailsByProjectID :many
 SELECT
-    t.id, t.user_id, t.project_id, t.title, t.artist, t.album, t.active_version_id, t.created_at, t.updated_at, t.track_order, t."key", t.bpm, t.public_id, t.notes, t.notes_author_name, t.notes_updated_at, t.visibility_status, t.allow_editing, t.allow_downloads, t.password_hash, t.origin_instance_url, t.shared_with_instance_users,
+    t.id, t.user_id, t.project_id, t.title, t.artist, t.album, t.active_version_id, t.created_at, t.updated_at, t.track_order, t."key", t.bpm, t.public_id, t.notes, t.notes_author_name, t.notes_updated_at, t.visibility_status, t.allow_editing, t.allow_downloads, t.password_hash, t.origin_instance_url, t.shared_with_instance_users, t.deleted_at,
     COALESCE(tv.version_name, '') as active_version_name,
     tv.duration_seconds as active_version_duration_seconds,
     p.name as project_name,
@@ -679,7 +690,7 @@ FROM tracks t
 LEFT JOIN track_versions tv ON t.active_version_id = tv.id
 LEFT JOIN track_files tf ON tv.id = tf.version_id AND tf.quality = 'lossy'
 JOIN projects p ON t.project_id = p.id
-WHERE t.project_id = ?
+WHERE t.project_id = ? AND t.deleted_at IS NULL
 ORDER BY t.track_order ASC
 `
 
@@ -706,6 +717,7 @@ type ListTracksWithDetailsByProjectIDRow struct {
 	PasswordHash                 sql.NullString  `json:"password_hash"`
 	OriginInstanceUrl            sql.NullString  `json:"origin_instance_url"`
 	SharedWithInstanceUsers      sql.NullBool    `json:"shared_with_instance_users"`
+	DeletedAt                    sql.NullTime    `json:"deleted_at"`
 	ActiveVersionName            string          `json:"active_version_name"`
 	ActiveVersionDurationSeconds sql.NullFloat64 `json:"active_version_duration_seconds"`
 	ProjectName                  string          `json:"project_name"`
@@ -746,6 +758,7 @@ func (q *Queries) ListTracksWithDetailsByProjectID(ctx context.Context, projectI
 			&i.PasswordHash,
 			&i.OriginInstanceUrl,
 			&i.SharedWithInstanceUsers,
+			&i.DeletedAt,
 			&i.ActiveVersionName,
 			&i.ActiveVersionDurationSeconds,
 			&i.ProjectName,
@@ -852,140 +865,6 @@ func (q *Queries) ListTracksWithoutBPM(ctx context.Context) ([]ListTracksWithout
 	return items, nil
 }
 
-const searchTracksAccessibleByUser = `-- name: SearchTracksAccessibleByUser :many
-SELECT DISTINCT
-    t.id,
-    t.user_id,
-    t.project_id,
-    t.public_id,
-    t.title,
-    t.artist,
-    t.album,
-    t.key,
-    t.bpm,
-    t.notes,
-    t.notes_author_name,
-    t.notes_updated_at,
-    t.active_version_id,
-    t.track_order,
-    t.visibility_status,
-    t.created_at,
-    t.updated_at,
-    COALESCE(tv.version_name, '') as active_version_name,
-    tv.duration_seconds as active_version_duration_seconds,
-    p.name as project_name,
-    tf.waveform as waveform,
-    tf.transcoding_status as lossy_transcoding_status,
-    CASE WHEN EXISTS (
-        SELECT 1 FROM user_project_shares ups
-        WHERE ups.project_id = t.project_id
-        AND ups.shared_to = ?1
-    ) THEN 1 ELSE 0 END as is_shared
-FROM tracks t
-LEFT JOIN track_versions tv ON t.active_version_id = tv.id
-LEFT JOIN track_files tf ON tv.id = tf.version_id AND tf.quality = 'lossy'
-JOIN projects p ON t.project_id = p.id
-WHERE (
-    -- User's own projects
-    p.user_id = ?1
-    OR
-    -- Projects shared with user
-    EXISTS (
-        SELECT 1 FROM user_project_shares ups
-        WHERE ups.project_id = p.id
-        AND ups.shared_to = ?1
-    )
-)
-AND (
-    -- Search filter (case-insensitive)
-    ?2 = '' OR
-    LOWER(t.title) LIKE '%' || LOWER(?2) || '%' OR
-    LOWER(COALESCE(t.artist, '')) LIKE '%' || LOWER(?2) || '%' OR
-    LOWER(p.name) LIKE '%' || LOWER(?2) || '%'
-)
-ORDER BY t.updated_at DESC
-LIMIT ?3
-`
-
-type SearchTracksAccessibleByUserParams struct {
-	UserID      int64       `json:"user_id"`
-	SearchQuery interface{} `json:"search_query"`
-	LimitCount  int64       `json:"limit_count"`
-}
-
-type SearchTracksAccessibleByUserRow struct {
-	ID                           int64           `json:"id"`
-	UserID                       int64           `json:"user_id"`
-	ProjectID                    int64           `json:"project_id"`
-	PublicID                     string          `json:"public_id"`
-	Title                        string          `json:"title"`
-	Artist                       sql.NullString  `json:"artist"`
-	Album                        sql.NullString  `json:"album"`
-	Key                          sql.NullString  `json:"key"`
-	Bpm                          sql.NullInt64   `json:"bpm"`
-	Notes                        sql.NullString  `json:"notes"`
-	NotesAuthorName              sql.NullString  `json:"notes_author_name"`
-	NotesUpdatedAt               sql.NullTime    `json:"notes_updated_at"`
-	ActiveVersionID              sql.NullInt64   `json:"active_version_id"`
-	TrackOrder                   int64           `json:"track_order"`
-	VisibilityStatus             string          `json:"visibility_status"`
-	CreatedAt                    sql.NullTime    `json:"created_at"`
-	UpdatedAt                    sql.NullTime    `json:"updated_at"`
-	ActiveVersionName            string          `json:"active_version_name"`
-	ActiveVersionDurationSeconds sql.NullFloat64 `json:"active_version_duration_seconds"`
-	ProjectName                  string          `json:"project_name"`
-	Waveform                     sql.NullString  `json:"waveform"`
-	LossyTranscodingStatus       sql.NullString  `json:"lossy_transcoding_status"`
-	IsShared                     int64           `json:"is_shared"`
-}
-
-func (q *Queries) SearchTracksAccessibleByUser(ctx context.Context, arg SearchTracksAccessibleByUserParams) ([]SearchTracksAccessibleByUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, searchTracksAccessibleByUser, arg.UserID, arg.SearchQuery, arg.LimitCount)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	items := []SearchTracksAccessibleByUserRow{}
-	for rows.Next() {
-		var i SearchTracksAccessibleByUserRow
-		if err := rows.Scan(
-			&i.ID,
-			&i.UserID,
-			&i.ProjectID,
-			&i.PublicID,
-			&i.Title,
-			&i.Artist,
-			&i.Album,
-			&i.Key,
-			&i.Bpm,
-			&i.Notes,
-			&i.NotesAuthorName,
-			&i.NotesUpdatedAt,
-			&i.ActiveVersionID,
-			&i.TrackOrder,
-			&i.VisibilityStatus,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.ActiveVersionName,
-			&i.ActiveVersionDurationSeconds,
-			&i.ProjectName,
-			&i.Waveform,
-			&i.LossyTranscodingStatus,
-			&i.IsShared,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
-}
-
 const setActiveVersion = `-- name: SetActiveVersion :exec
 UPDATE tracks
 SET active_version_id = ?, updated_at = CURRENT_TIMESTAMP
@@ -1015,7 +894,7 @@ SET title = COALESCE(?, title),
     notes_updated_at = CASE WHEN ? IS NOT NULL THEN CURRENT_TIMESTAMP ELSE notes_updated_at END,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
-RETURNING id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users
+RETURNING id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at
 `
 
 type UpdateTrackParams struct {
@@ -1070,6 +949,7 @@ func (q *Queries) UpdateTrack(ctx context.Context, arg UpdateTrackParams) (Track
 		&i.PasswordHash,
 		&i.OriginInstanceUrl,
 		&i.SharedWithInstanceUsers,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -1114,7 +994,7 @@ SET notes = ?,
     notes_updated_at = CURRENT_TIMESTAMP,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ? AND user_id = ?
-RETURNING id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users
+RETURNING id, user_id, project_id, title, artist, album, active_version_id, created_at, updated_at, track_order, "key", bpm, public_id, notes, notes_author_name, notes_updated_at, visibility_status, allow_editing, allow_downloads, password_hash, origin_instance_url, shared_with_instance_users, deleted_at
 `
 
 type UpdateTrackNotesParams struct {
@@ -1155,6 +1035,7 @@ func (q *Queries) UpdateTrackNotes(ctx context.Context, arg UpdateTrackNotesPara
 		&i.PasswordHash,
 		&i.OriginInstanceUrl,
 		&i.SharedWithInstanceUsers,
+		&i.DeletedAt,
 	)
 	return i, err
 }
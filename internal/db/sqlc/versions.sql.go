@@ -12,7 +12,7 @@ import (
 
 const countTrackVersions = `-- name: CountTrackVersions :one
 SELECT COUNT(*) FROM track_versions
-WHERE track_id = ?
+WHERE track_id = ? AND deleted_at IS NULL
 `
 
 func (q *Queries) CountTrackVersions(ctx context.Context, trackID int64) (int64, error) {
@@ -25,7 +25,7 @@ func (q *Queries) CountTrackVersions(ctx context.Context, trackID int64) (int64,
 const createTrackVersion = `-- name: CreateTrackVersion :one
 INSERT INTO track_versions (track_id, version_name, notes, duration_seconds, version_order)
 VALUES (?, ?, ?, ?, ?)
-RETURNING id, track_id, version_name, notes, duration_seconds, version_order, created_at, updated_at
+RETURNING id, track_id, version_name, notes, duration_seconds, version_order, created_at, updated_at, deleted_at
 `
 
 type CreateTrackVersionParams struct {
@@ -54,6 +54,7 @@ func (q *Queries) CreateTrackVersion(ctx context.Context, arg CreateTrackVersion
 		&i.VersionOrder,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -82,8 +83,8 @@ func (q *Queries) GetMaxVersionOrder(ctx context.Context, trackID int64) (interf
 }
 
 const getTrackVersion = `-- name: GetTrackVersion :one
-SELECT id, track_id, version_name, notes, duration_seconds, version_order, created_at, updated_at FROM track_versions
-WHERE id = ?
+SELECT id, track_id, version_name, notes, duration_seconds, version_order, created_at, updated_at, deleted_at FROM track_versions
+WHERE id = ? AND deleted_at IS NULL
 `
 
 func (q *Queries) GetTrackVersion(ctx context.Context, id int64) (TrackVersion, error) {
@@ -98,15 +99,16 @@ func (q *Queries) GetTrackVersion(ctx context.Context, id int64) (TrackVersion,
 		&i.VersionOrder,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getTrackVersionWithOwnership = `-- name: GetTrackVersionWithOwnership :one
-SELECT tv.id, tv.track_id, tv.version_name, tv.notes, tv.duration_seconds, tv.version_order, tv.created_at, tv.updated_at, t.user_id
+SELECT tv.id, tv.track_id, tv.version_name, tv.notes, tv.duration_seconds, tv.version_order, tv.created_at, tv.updated_at, tv.deleted_at, t.user_id
 FROM track_versions tv
 JOIN tracks t ON tv.track_id = t.id
-WHERE tv.id = ?
+WHERE tv.id = ? AND tv.deleted_at IS NULL
 `
 
 type GetTrackVersionWithOwnershipRow struct {
@@ -118,6 +120,7 @@ type GetTrackVersionWithOwnershipRow struct {
 	VersionOrder    int64           `json:"version_order"`
 	CreatedAt       sql.NullTime    `json:"created_at"`
 	UpdatedAt       sql.NullTime    `json:"updated_at"`
+	DeletedAt       sql.NullTime    `json:"deleted_at"`
 	UserID          int64           `json:"user_id"`
 }
 
@@ -133,14 +136,15 @@ func (q *Queries) GetTrackVersionWithOwnership(ctx context.Context, id int64) (G
 		&i.VersionOrder,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
 		&i.UserID,
 	)
 	return i, err
 }
 
 const listTrackVersions = `-- name: ListTrackVersions :many
-SELECT id, track_id, version_name, notes, duration_seconds, version_order, created_at, updated_at FROM track_versions
-WHERE track_id = ?
+SELECT id, track_id, version_name, notes, duration_seconds, version_order, created_at, updated_at, deleted_at FROM track_versions
+WHERE track_id = ? AND deleted_at IS NULL
 ORDER BY version_order ASC, created_at ASC
 `
 
@@ -162,6 +166,7 @@ func (q *Queries) ListTrackVersions(ctx context.Context, trackID int64) ([]Track
 			&i.VersionOrder,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -178,7 +183,7 @@ func (q *Queries) ListTrackVersions(ctx context.Context, trackID int64) ([]Track
 
 const listTrackVersionsWithMetadata = `-- name: ListTrackVersionsWithMetadata :many
 SELECT 
-    tv.id, tv.track_id, tv.version_name, tv.notes, tv.duration_seconds, tv.version_order, tv.created_at, tv.updated_at,
+    tv.id, tv.track_id, tv.version_name, tv.notes, tv.duration_seconds, tv.version_order, tv.created_at, tv.updated_at, tv.deleted_at,
     tf_source.file_size as source_file_size,
     tf_source.format as source_format,
     tf_source.bitrate as source_bitrate,
@@ -186,7 +191,7 @@ SELECT
 FROM track_versions tv
 LEFT JOIN track_files tf_source ON tv.id = tf_source.version_id AND tf_source.quality = 'source'
 LEFT JOIN track_files tf_lossy ON tv.id = tf_lossy.version_id AND tf_lossy.quality = 'lossy'
-WHERE tv.track_id = ?
+WHERE tv.track_id = ? AND tv.deleted_at IS NULL
 ORDER BY tv.version_order ASC, tv.created_at ASC
 `
 
@@ -199,6 +204,7 @@ type ListTrackVersionsWithMetadataRow struct {
 	VersionOrder           int64           `json:"version_order"`
 	CreatedAt              sql.NullTime    `json:"created_at"`
 	UpdatedAt              sql.NullTime    `json:"updated_at"`
+	DeletedAt              sql.NullTime    `json:"deleted_at"`
 	SourceFileSize         sql.NullInt64   `json:"source_file_size"`
 	SourceFormat           sql.NullString  `json:"source_format"`
 	SourceBitrate          sql.NullInt64   `json:"source_bitrate"`
@@ -223,6 +229,7 @@ func (q *Queries) ListTrackVersionsWithMetadata(ctx context.Context, trackID int
 			&i.VersionOrder,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DeletedAt,
 			&i.SourceFileSize,
 			&i.SourceFormat,
 			&i.SourceBitrate,
@@ -247,7 +254,7 @@ SET version_name = COALESCE(?, version_name),
     notes = COALESCE(?, notes),
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ?
-RETURNING id, track_id, version_name, notes, duration_seconds, version_order, created_at, updated_at
+RETURNING id, track_id, version_name, notes, duration_seconds, version_order, created_at, updated_at, deleted_at
 `
 
 type UpdateTrackVersionParams struct {
@@ -268,6 +275,7 @@ func (q *Queries) UpdateTrackVersion(ctx context.Context, arg UpdateTrackVersion
 		&i.VersionOrder,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
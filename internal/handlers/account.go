@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/middleware"
+	authsvc "ramiro-uziel/vault/internal/service"
+)
+
+// ChangePassword sets a new password for a logged-in user. Every other
+// session is logged out.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	req, err := httputil.DecodeJSON[ChangePasswordRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		return apperr.NewBadRequest("current_password and new_password are required")
+	}
+
+	ctx := r.Context()
+
+	sessionID, _ := middleware.GetSessionID(ctx)
+	if err := h.authService.ChangePassword(ctx, int64(userID), req.CurrentPassword, req.NewPassword, sessionID); err != nil {
+		return mapAuthError(err)
+	}
+
+	h.audit.Record(ctx, authsvc.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     authsvc.AuditPasswordChange,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(userID),
+	})
+
+	return httputil.OKResult(w, map[string]interface{}{
+		"message": "password changed successfully",
+	})
+}
+
+// RequestEmailChange sends a confirmation link to the new address. The
+// email stays the same until the link is followed.
+func (h *AuthHandler) RequestEmailChange(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	req, err := httputil.DecodeJSON[ChangeEmailRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Password == "" || req.Email == "" {
+		return apperr.NewBadRequest("password and email are required")
+	}
+
+	ctx := r.Context()
+
+	change, err := h.authService.RequestEmailChange(ctx, int64(userID), req.Password, req.Email)
+	if err != nil {
+		return mapAuthError(err)
+	}
+
+	if h.email.Enabled() {
+		username, _ := middleware.GetUsername(ctx)
+		if err := h.email.QueueEmailChange(ctx, change.Email, username, change.Token, change.ExpiresAt); err != nil {
+			return apperr.NewInternal("failed to send confirmation email", err)
+		}
+	} else {
+		slog.WarnContext(ctx, "Email delivery is not configured; send the confirmation link by hand",
+			"user_id", userID,
+			"email", change.Email,
+			"link", "/confirm-email?token="+change.Token,
+		)
+	}
+
+	return httputil.OKResult(w, map[string]interface{}{
+		"email":      change.Email,
+		"expires_at": httputil.FormatTime(change.ExpiresAt),
+	})
+}
+
+// ConfirmEmailChange applies an email change from its confirmation link.
+// It needs no session, since the link may be opened in another browser.
+func (h *AuthHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) error {
+	req, err := httputil.DecodeJSON[ConfirmEmailRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Token == "" {
+		return apperr.NewBadRequest("token is required")
+	}
+
+	ctx := r.Context()
+
+	result, err := h.authService.ConfirmEmailChange(ctx, req.Token)
+	if err != nil {
+		return mapAuthError(err)
+	}
+
+	actor := shared.AuditActor(r)
+	actor.UserID = result.User.ID
+	actor.Username = result.User.Username
+	h.audit.Record(ctx, authsvc.AuditEvent{
+		Actor:      actor,
+		Action:     authsvc.AuditEmailChange,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(result.User.ID, 10),
+		Before:     map[string]any{"email": result.PreviousEmail},
+		After:      map[string]any{"email": result.User.Email},
+	})
+
+	return httputil.OKResult(w, map[string]interface{}{
+		"user": serviceUserToResponse(result.User),
+	})
+}
@@ -2,25 +2,37 @@ package handlers
 
 import (
 	"database/sql"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"ramiro-uziel/vault/internal/apperr"
 	"ramiro-uziel/vault/internal/auth"
 	"ramiro-uziel/vault/internal/db"
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/handlers/shared"
 	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
 )
 
 type AdminHandler struct {
 	db         *db.DB
+	twoFactor  service.TwoFactorService
+	email      service.EmailService
+	lockouts   service.LockoutService
 	authConfig auth.Config
+	audit      service.AuditService
 }
 
-func NewAdminHandler(database *db.DB, authConfig auth.Config) *AdminHandler {
+func NewAdminHandler(database *db.DB, twoFactor service.TwoFactorService, email service.EmailService, lockouts service.LockoutService, authConfig auth.Config, audit service.AuditService) *AdminHandler {
 	return &AdminHandler{
 		db:         database,
+		twoFactor:  twoFactor,
+		email:      email,
+		lockouts:   lockouts,
 		authConfig: authConfig,
+		audit:      audit,
 	}
 }
 
@@ -79,15 +91,22 @@ func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) error {
 		return apperr.NewInternal("failed to list users", err)
 	}
 
+	twoFactorUsers, err := h.twoFactor.EnabledUserIDs(ctx)
+	if err != nil {
+		return apperr.NewInternal("failed to list two-factor users", err)
+	}
+
 	userResponses := make([]UserResponse, 0, len(users))
 	for _, u := range users {
+		twoFactorEnabled := twoFactorUsers[u.ID]
 		userResponses = append(userResponses, UserResponse{
-			ID:        u.ID,
-			Username:  u.Username,
-			Email:     u.Email,
-			IsAdmin:   u.IsAdmin,
-			IsOwner:   u.IsOwner,
-			CreatedAt: u.CreatedAt.Time,
+			ID:               u.ID,
+			Username:         u.Username,
+			Email:            u.Email,
+			IsAdmin:          u.IsAdmin,
+			IsOwner:          u.IsOwner,
+			TwoFactorEnabled: &twoFactorEnabled,
+			CreatedAt:        u.CreatedAt.Time,
 		})
 	}
 
@@ -138,10 +157,29 @@ func (h *AdminHandler) CreateInvite(w http.ResponseWriter, r *http.Request) erro
 		return apperr.NewInternal("failed to create invite", err)
 	}
 
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditUserInvite,
+		TargetType: "invite",
+		TargetID:   strconv.FormatInt(inviteToken.ID, 10),
+		After:      map[string]any{"email": inviteToken.Email},
+	})
+
+	// The token is returned either way, for sharing the link by other means.
+	emailQueued := false
+	if inviteToken.Email != "" && h.email.Enabled() {
+		if err := h.email.QueueInvite(ctx, inviteToken.Email, user.Username, token, inviteToken.ExpiresAt); err != nil {
+			slog.ErrorContext(ctx, "Failed to queue invite email", "invite_id", inviteToken.ID, "error", err)
+		} else {
+			emailQueued = true
+		}
+	}
+
 	return httputil.OKResult(w, map[string]interface{}{
-		"id":    inviteToken.ID,
-		"token": token,
-		"email": inviteToken.Email,
+		"id":           inviteToken.ID,
+		"token":        token,
+		"email":        inviteToken.Email,
+		"email_queued": emailQueued,
 	})
 }
 
@@ -185,6 +223,15 @@ func (h *AdminHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) er
 		return apperr.NewInternal("failed to update user role", err)
 	}
 
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditUserRole,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(user.ID, 10),
+		Before:     map[string]any{"username": targetUser.Username, "is_admin": targetUser.IsAdmin},
+		After:      map[string]any{"username": user.Username, "is_admin": user.IsAdmin},
+	})
+
 	return httputil.OKResult(w, UserResponse{
 		ID:        user.ID,
 		Username:  user.Username,
@@ -235,6 +282,15 @@ func (h *AdminHandler) RenameUser(w http.ResponseWriter, r *http.Request) error
 		return apperr.NewConflict("username already exists or user not found")
 	}
 
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditUserRename,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(user.ID, 10),
+		Before:     map[string]any{"username": admin.Username},
+		After:      map[string]any{"username": user.Username},
+	})
+
 	return httputil.OKResult(w, UserResponse{
 		ID:        user.ID,
 		Username:  user.Username,
@@ -297,6 +353,14 @@ func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) error
 		return apperr.NewInternal("failed to delete user", err)
 	}
 
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditUserDelete,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(userID, 10),
+		Before:     map[string]any{"username": targetUser.Username, "email": targetUser.Email, "is_admin": targetUser.IsAdmin},
+	})
+
 	httputil.NoContent(w)
 	return nil
 }
@@ -357,20 +421,199 @@ func (h *AdminHandler) CreateResetLink(w http.ResponseWriter, r *http.Request) e
 		return apperr.NewInternal("failed to create reset link", err)
 	}
 
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditUserResetLink,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(user.ID, 10),
+		After:      map[string]any{"email": resetToken.Email},
+	})
+
+	emailQueued := false
+	if resetToken.Email != "" && h.email.Enabled() {
+		if err := h.email.QueuePasswordReset(ctx, resetToken.Email, user.Username, token, resetToken.ExpiresAt); err != nil {
+			slog.ErrorContext(ctx, "Failed to queue password reset email", "user_id", user.ID, "error", err)
+		} else {
+			emailQueued = true
+		}
+	}
+
 	return httputil.OKResult(w, map[string]interface{}{
-		"id":    resetToken.ID,
-		"token": token,
-		"email": resetToken.Email,
+		"id":           resetToken.ID,
+		"token":        token,
+		"email":        resetToken.Email,
+		"email_queued": emailQueued,
+	})
+}
+
+// ResetTwoFactor removes a user's second factors, passkeys included, and
+// their recovery codes, for users locked out of their authenticator. If the instance requires 2FA they
+// enroll again at their next login.
+func (h *AdminHandler) ResetTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	adminID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	ctx := r.Context()
+
+	admin, err := h.db.Queries.GetUserByID(ctx, int64(adminID))
+	if err != nil {
+		return apperr.NewNotFound("user not found")
+	}
+
+	if !admin.IsAdmin {
+		return apperr.NewForbidden("admin access required")
+	}
+
+	userID, err := httputil.PathInt64(r, "id")
+	if err != nil {
+		return err
+	}
+
+	user, err := h.db.Queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return apperr.NewNotFound("user not found")
+	}
+
+	if user.IsOwner && !admin.IsOwner {
+		return apperr.NewForbidden("only owner can reset the owner's second factor")
+	}
+
+	if err := h.twoFactor.Reset(ctx, user.ID); err != nil {
+		return apperr.NewInternal("failed to reset two-factor authentication", err)
+	}
+
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditTwoFactorReset,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(user.ID, 10),
+		Before:     map[string]any{"username": user.Username},
+	})
+
+	httputil.NoContent(w)
+	return nil
+}
+
+func (h *AdminHandler) GetSecuritySettings(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	required, err := h.twoFactor.Required(r.Context())
+	if err != nil {
+		return apperr.NewInternal("failed to get security settings", err)
+	}
+	return httputil.OKResult(w, SecuritySettingsResponse{RequireTwoFactor: required})
+}
+
+// UpdateSecuritySettings changes the instance-wide login policy. Requiring
+// 2FA does not end existing sessions; it applies from each user's next login.
+func (h *AdminHandler) UpdateSecuritySettings(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	req, err := httputil.DecodeJSON[UpdateSecuritySettingsRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	ctx := r.Context()
+
+	before, err := h.twoFactor.Required(ctx)
+	if err != nil {
+		return apperr.NewInternal("failed to get security settings", err)
+	}
+
+	after := before
+	if req.RequireTwoFactor != nil {
+		after = *req.RequireTwoFactor
+		if err := h.twoFactor.SetRequired(ctx, after); err != nil {
+			return apperr.NewInternal("failed to update security settings", err)
+		}
+	}
+
+	if after != before {
+		h.audit.Record(ctx, service.AuditEvent{
+			Actor:      shared.AuditActor(r),
+			Action:     service.AuditInstanceSecurity,
+			TargetType: "instance",
+			Before:     map[string]any{"require_two_factor": before},
+			After:      map[string]any{"require_two_factor": after},
+		})
+	}
+
+	return httputil.OKResult(w, SecuritySettingsResponse{RequireTwoFactor: after})
+}
+
+// ListLockouts lists the accounts and share links with recent failed
+// password attempts, including those locked out.
+func (h *AdminHandler) ListLockouts(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	lockouts, err := h.lockouts.List(r.Context())
+	if err != nil {
+		return apperr.NewInternal("failed to list lockouts", err)
+	}
+	return httputil.OKResult(w, lockouts)
+}
+
+// Unlock forgets the failed attempts against an account or share link,
+// ending its lockout.
+func (h *AdminHandler) Unlock(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	lockoutID, err := httputil.PathInt64(r, "id")
+	if err != nil {
+		return err
+	}
+
+	ctx := r.Context()
+
+	lockout, err := h.lockouts.Unlock(ctx, lockoutID)
+	if err != nil {
+		return httputil.HandleDBError(err, "lockout not found", "failed to unlock")
+	}
+
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditUnlock,
+		TargetType: lockout.Scope,
+		TargetID:   lockout.Subject,
+		Before:     map[string]any{"failures": lockout.Failures, "locked": lockout.Locked},
 	})
+
+	httputil.NoContent(w)
+	return nil
+}
+
+func (h *AdminHandler) requireAdmin(r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	user, err := h.db.Queries.GetUserByID(r.Context(), int64(userID))
+	if err != nil || !user.IsAdmin {
+		return apperr.NewForbidden("admin access required")
+	}
+	return nil
 }
 
 type UserResponse struct {
-	ID        int64     `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	IsAdmin   bool      `json:"is_admin"`
-	IsOwner   bool      `json:"is_owner"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               int64     `json:"id"`
+	Username         string    `json:"username"`
+	Email            string    `json:"email"`
+	IsAdmin          bool      `json:"is_admin"`
+	IsOwner          bool      `json:"is_owner"`
+	TwoFactorEnabled *bool     `json:"two_factor_enabled,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
 }
 
 type CreateInviteRequest struct {
@@ -390,3 +633,11 @@ type RenameUserRequest struct {
 type CreateResetLinkRequest struct {
 	UserID int64 `json:"user_id"`
 }
+
+type SecuritySettingsResponse struct {
+	RequireTwoFactor bool `json:"require_two_factor"`
+}
+
+type UpdateSecuritySettingsRequest struct {
+	RequireTwoFactor *bool `json:"require_two_factor,omitempty"`
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
+)
+
+type APITokenHandler struct {
+	tokens service.APITokenService
+	audit  service.AuditService
+}
+
+func NewAPITokenHandler(tokens service.APITokenService, audit service.AuditService) *APITokenHandler {
+	return &APITokenHandler{
+		tokens: tokens,
+		audit:  audit,
+	}
+}
+
+type CreateAPITokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is an RFC 3339 time; without it the token does not expire.
+	ExpiresAt *string `json:"expires_at"`
+}
+
+func (h *APITokenHandler) ListTokens(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	tokens, err := h.tokens.List(r.Context(), int64(userID))
+	if err != nil {
+		return apperr.NewInternal("failed to list tokens", err)
+	}
+	return httputil.OKResult(w, tokens)
+}
+
+// CreateToken creates a token and returns it in the clear, the only time it
+// is shown.
+func (h *APITokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	req, err := httputil.DecodeJSON[CreateAPITokenRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		return apperr.NewBadRequest("name is required")
+	}
+	if len(req.Scopes) == 0 {
+		return apperr.NewBadRequest("at least one scope is required")
+	}
+
+	input := service.CreateAPITokenInput{
+		Name:   req.Name,
+		Scopes: req.Scopes,
+	}
+	if req.ExpiresAt != nil && *req.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil {
+			return apperr.NewBadRequest("expires_at must be an RFC 3339 time")
+		}
+		input.ExpiresAt = &expiresAt
+	}
+
+	ctx := r.Context()
+
+	token, err := h.tokens.Create(ctx, int64(userID), input)
+	switch {
+	case errors.Is(err, service.ErrInvalidScope):
+		return apperr.NewBadRequest("scopes must be read, upload, share-manage or admin")
+	case errors.Is(err, service.ErrScopeNotAllowed):
+		return apperr.NewForbidden("only admins can create tokens with the admin scope")
+	case errors.Is(err, service.ErrInvalidTokenExpiry):
+		return apperr.NewBadRequest("expires_at must be in the future")
+	case err != nil:
+		return apperr.NewInternal("failed to create token", err)
+	}
+
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditTokenCreate,
+		TargetType: "api_token",
+		TargetID:   strconv.FormatInt(token.ID, 10),
+		After: map[string]any{
+			"name":       token.Name,
+			"scopes":     token.Scopes,
+			"expires_at": token.ExpiresAt,
+		},
+	})
+
+	return httputil.CreatedResult(w, token)
+}
+
+func (h *APITokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	tokenID, err := httputil.PathInt64(r, "id")
+	if err != nil {
+		return err
+	}
+
+	ctx := r.Context()
+
+	if err := h.tokens.Revoke(ctx, int64(userID), tokenID); err != nil {
+		return httputil.HandleDBError(err, "token not found", "failed to revoke token")
+	}
+
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditTokenRevoke,
+		TargetType: "api_token",
+		TargetID:   strconv.FormatInt(tokenID, 10),
+	})
+
+	httputil.NoContent(w)
+	return nil
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
+)
+
+const defaultAuditLimit = 100
+
+type AuditHandler struct {
+	db    *db.DB
+	audit service.AuditService
+}
+
+func NewAuditHandler(database *db.DB, audit service.AuditService) *AuditHandler {
+	return &AuditHandler{db: database, audit: audit}
+}
+
+type AuditEventResponse struct {
+	ID            int64           `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ActorID       *int64          `json:"actor_id,omitempty"`
+	ActorUsername *string         `json:"actor_username,omitempty"`
+	IP            *string         `json:"ip,omitempty"`
+	UserAgent     *string         `json:"user_agent,omitempty"`
+	Action        string          `json:"action"`
+	TargetType    *string         `json:"target_type,omitempty"`
+	TargetID      *string         `json:"target_id,omitempty"`
+	Before        json.RawMessage `json:"before,omitempty"`
+	After         json.RawMessage `json:"after,omitempty"`
+}
+
+// ListAuditEvents returns audit events newest first. When more are available
+// the X-Next-Cursor header holds the cursor for the next page.
+func (h *AuditHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	filter, err := parseAuditFilter(query)
+	if err != nil {
+		return err
+	}
+
+	limit := int64(defaultAuditLimit)
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		beforeID, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || beforeID <= 0 {
+			return apperr.NewBadRequest("invalid cursor")
+		}
+		filter.BeforeID = beforeID
+	}
+	filter.Limit = limit + 1
+
+	events, err := h.audit.List(r.Context(), filter)
+	if err != nil {
+		return apperr.NewInternal("failed to query audit log", err)
+	}
+
+	if int64(len(events)) > limit {
+		events = events[:limit]
+		w.Header().Set("X-Next-Cursor", strconv.FormatInt(events[len(events)-1].ID, 10))
+	}
+
+	response := make([]AuditEventResponse, len(events))
+	for i, event := range events {
+		response[i] = convertAuditEvent(event)
+	}
+	return httputil.OKResult(w, response)
+}
+
+// ExportAuditEvents streams every matching audit event as JSON lines, newest
+// first.
+func (h *AuditHandler) ExportAuditEvents(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		return err
+	}
+
+	h.audit.Record(r.Context(), service.AuditEvent{
+		Actor:  shared.AuditActor(r),
+		Action: service.AuditAuditExport,
+		After:  r.URL.Query(),
+	})
+
+	filename := fmt.Sprintf("vault-audit-%s.jsonl", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	enc := json.NewEncoder(w)
+	return h.audit.Each(r.Context(), filter, func(event sqlc.AuditEvent) error {
+		return enc.Encode(convertAuditEvent(event))
+	})
+}
+
+func (h *AuditHandler) requireAdmin(r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	user, err := h.db.Queries.GetUserByID(r.Context(), int64(userID))
+	if err != nil || !user.IsAdmin {
+		return apperr.NewForbidden("admin access required")
+	}
+	return nil
+}
+
+// parseAuditFilter reads audit log filters from query parameters. Actions may
+// be repeated or comma-separated; dates are YYYY-MM-DD or RFC 3339.
+func parseAuditFilter(query url.Values) (db.AuditFilter, error) {
+	filter := db.AuditFilter{
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+
+	for _, value := range query["action"] {
+		for _, action := range strings.Split(value, ",") {
+			if action = strings.TrimSpace(action); action != "" {
+				filter.Actions = append(filter.Actions, action)
+			}
+		}
+	}
+
+	if actorStr := query.Get("actor_id"); actorStr != "" {
+		actorID, err := strconv.ParseInt(actorStr, 10, 64)
+		if err != nil {
+			return filter, apperr.NewBadRequest("invalid actor_id")
+		}
+		filter.ActorID = &actorID
+	}
+
+	var err error
+	if filter.Since, err = parseAuditTime(query, "since"); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = parseAuditTime(query, "until"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func parseAuditTime(query url.Values, name string) (*time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, apperr.NewBadRequest("invalid " + name)
+}
+
+func convertAuditEvent(event sqlc.AuditEvent) AuditEventResponse {
+	response := AuditEventResponse{
+		ID:            event.ID,
+		CreatedAt:     event.CreatedAt,
+		ActorUsername: httputil.NullStringToPtr(event.ActorUsername),
+		IP:            httputil.NullStringToPtr(event.Ip),
+		UserAgent:     httputil.NullStringToPtr(event.UserAgent),
+		Action:        event.Action,
+		TargetType:    httputil.NullStringToPtr(event.TargetType),
+		TargetID:      httputil.NullStringToPtr(event.TargetID),
+	}
+	if event.ActorID.Valid {
+		response.ActorID = &event.ActorID.Int64
+	}
+	if event.BeforeSummary.Valid {
+		response.Before = json.RawMessage(event.BeforeSummary.String)
+	}
+	if event.AfterSummary.Valid {
+		response.After = json.RawMessage(event.AfterSummary.String)
+	}
+	return response
+}
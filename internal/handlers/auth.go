@@ -2,24 +2,36 @@ package handlers
 
 import (
 	"database/sql"
-	"net"
+	"log/slog"
 	"net/http"
+	"strconv"
 
 	"ramiro-uziel/vault/internal/apperr"
 	"ramiro-uziel/vault/internal/auth"
+	"ramiro-uziel/vault/internal/handlers/shared"
 	"ramiro-uziel/vault/internal/httputil"
 	authsvc "ramiro-uziel/vault/internal/service"
 )
 
 type AuthHandler struct {
 	authService authsvc.AuthService
+	twoFactor   authsvc.TwoFactorService
+	oidc        authsvc.OIDCService
+	email       authsvc.EmailService
+	lockouts    authsvc.LockoutService
 	authConfig  auth.Config
+	audit       authsvc.AuditService
 }
 
-func NewAuthHandler(authService authsvc.AuthService, authConfig auth.Config) *AuthHandler {
+func NewAuthHandler(authService authsvc.AuthService, twoFactor authsvc.TwoFactorService, oidc authsvc.OIDCService, email authsvc.EmailService, lockouts authsvc.LockoutService, authConfig auth.Config, audit authsvc.AuditService) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
+		twoFactor:   twoFactor,
+		oidc:        oidc,
+		email:       email,
+		lockouts:    lockouts,
 		authConfig:  authConfig,
+		audit:       audit,
 	}
 }
 
@@ -41,6 +53,36 @@ func mapAuthError(err error) error {
 		return apperr.NewUnauthorized("token expired")
 	case authsvc.ErrInvalidTokenType:
 		return apperr.NewUnauthorized("invalid token type")
+	case authsvc.ErrInvalidTwoFactorCode:
+		return apperr.NewUnauthorized("invalid two-factor code")
+	case authsvc.ErrTwoFactorNotEnabled:
+		return apperr.NewBadRequest("two-factor authentication is not enabled")
+	case authsvc.ErrTwoFactorEnabled:
+		return apperr.NewConflict("two-factor authentication is already enabled")
+	case authsvc.ErrNoPendingEnrollment:
+		return apperr.NewBadRequest("no two-factor enrollment in progress")
+	case authsvc.ErrTwoFactorRequired:
+		return apperr.NewForbidden("two-factor authentication is required on this instance")
+	case authsvc.ErrPasskeysDisabled:
+		return apperr.NewNotFound("passkeys are not configured on this instance")
+	case authsvc.ErrInvalidPasskey:
+		return apperr.NewUnauthorized("passkey could not be verified")
+	case authsvc.ErrPasskeyExists:
+		return apperr.NewConflict("passkey is already registered")
+	case authsvc.ErrNoPasskeys:
+		return apperr.NewBadRequest("no passkeys registered")
+	case authsvc.ErrIncorrectPassword:
+		return apperr.NewUnauthorized("incorrect password")
+	case authsvc.ErrInvalidEmail:
+		return apperr.NewBadRequest("invalid email address")
+	case authsvc.ErrEmailTaken:
+		return apperr.NewConflict("email is already registered")
+	case authsvc.ErrOIDCDisabled:
+		return apperr.NewNotFound("single sign-on is not configured on this instance")
+	case authsvc.ErrOIDCProvider:
+		return apperr.New(http.StatusBadGateway, err, "identity provider is unavailable")
+	case authsvc.ErrIdentityLinked:
+		return apperr.NewConflict("identity is linked to another account")
 	default:
 		return apperr.NewInternal("authentication error", err)
 	}
@@ -89,11 +131,54 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) error {
 		return apperr.NewBadRequest("username and password are required")
 	}
 
+	// Blocked attempts are turned away before the password is checked, so
+	// guessing gets no answers while an account is locked.
+	lockoutKey := authsvc.AccountLockout(req.Username)
+	lockout, err := h.lockouts.Attempt(r.Context(), lockoutKey)
+	if err != nil {
+		return shared.LockedOut(w, err)
+	}
+
+	actor := shared.AuditActor(r)
 	user, err := h.authService.Login(r.Context(), req.Username, req.Password)
+	if err == authsvc.ErrInvalidCredentials {
+		actor.Username = req.Username
+		h.audit.Record(r.Context(), authsvc.AuditEvent{
+			Actor:  actor,
+			Action: authsvc.AuditLoginFailed,
+		})
+		shared.FailedAttempt(r, h.audit, lockoutKey, lockout)
+	}
 	if err != nil {
 		return mapAuthError(err)
 	}
 
+	// With a second factor, the password only earns a challenge.
+	challenge, err := h.twoFactor.StartLogin(r.Context(), user.ID)
+	if err != nil {
+		return apperr.NewInternal("failed to start two-factor login", err)
+	}
+	if challenge != nil {
+		return httputil.OKResult(w, map[string]interface{}{
+			"two_factor_required": true,
+			"enrollment_required": challenge.EnrollmentRequired,
+			"methods":             challenge.Methods,
+			"challenge":           challenge.Token,
+			"expires_at":          httputil.FormatTime(challenge.ExpiresAt),
+		})
+	}
+
+	if err := h.startSession(w, r, user, nil); err != nil {
+		return err
+	}
+	return httputil.OKResult(w, map[string]interface{}{
+		"user": serviceUserToResponse(user),
+	})
+}
+
+// startSession sets the session cookies for a user who has passed every
+// login step, and records the login.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, user *authsvc.User, details any) error {
 	meta := sessionMetaFromRequest(r)
 	session, err := h.authService.CreateSession(r.Context(), int(user.ID), user.Username, meta)
 	if err != nil {
@@ -102,9 +187,19 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) error {
 
 	httputil.SetAuthCookies(w, session.AccessToken, session.RefreshToken, session.CSRFToken, h.authConfig)
 
-	return httputil.OKResult(w, map[string]interface{}{
-		"user": serviceUserToResponse(user),
+	if err := h.lockouts.Succeed(r.Context(), authsvc.AccountLockout(user.Username)); err != nil {
+		slog.WarnContext(r.Context(), "Failed to clear failed login attempts", "user_id", user.ID, "error", err)
+	}
+
+	actor := shared.AuditActor(r)
+	actor.UserID = user.ID
+	actor.Username = user.Username
+	h.audit.Record(r.Context(), authsvc.AuditEvent{
+		Actor:  actor,
+		Action: authsvc.AuditLogin,
+		After:  details,
 	})
+	return nil
 }
 
 func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) error {
@@ -204,10 +299,20 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) erro
 		return apperr.NewBadRequest("password and reset_token are required")
 	}
 
-	if err := h.authService.ResetPassword(r.Context(), req.ResetToken, req.Password); err != nil {
+	userID, err := h.authService.ResetPassword(r.Context(), req.ResetToken, req.Password)
+	if err != nil {
 		return mapAuthError(err)
 	}
 
+	actor := shared.AuditActor(r)
+	actor.UserID = userID
+	h.audit.Record(r.Context(), authsvc.AuditEvent{
+		Actor:      actor,
+		Action:     authsvc.AuditPasswordReset,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(userID, 10),
+	})
+
 	return httputil.OKResult(w, map[string]interface{}{
 		"message": "password reset successfully",
 	})
@@ -285,6 +390,14 @@ func (h *AuthHandler) DeleteSelf(w http.ResponseWriter, r *http.Request) error {
 		return apperr.NewInternal("failed to delete account", err)
 	}
 
+	h.audit.Record(ctx, authsvc.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     authsvc.AuditAccountDelete,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(user.ID, 10),
+		Before:     map[string]any{"username": user.Username, "email": user.Email},
+	})
+
 	httputil.ClearAuthCookies(w, h.authConfig)
 	httputil.NoContent(w)
 	return nil
@@ -321,12 +434,7 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) error {
 }
 
 func sessionMetaFromRequest(r *http.Request) authsvc.SessionMeta {
-	userAgent := r.Header.Get("User-Agent")
-	ip := r.RemoteAddr
-	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
-		ip = host
-	}
-	return authsvc.SessionMeta{UserAgent: userAgent, IP: ip}
+	return authsvc.SessionMeta{UserAgent: r.Header.Get("User-Agent"), IP: httputil.ClientIP(r)}
 }
 
 func serviceUserToResponse(user *authsvc.User) map[string]interface{} {
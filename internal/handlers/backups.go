@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
+)
+
+type BackupsHandler struct {
+	db      *db.DB
+	backups service.BackupService
+	audit   service.AuditService
+}
+
+func NewBackupsHandler(database *db.DB, backups service.BackupService, audit service.AuditService) *BackupsHandler {
+	return &BackupsHandler{
+		db:      database,
+		backups: backups,
+		audit:   audit,
+	}
+}
+
+type BackupRunResponse struct {
+	ID          int64   `json:"id"`
+	Trigger     string  `json:"trigger"`
+	Status      string  `json:"status"`
+	StartedAt   string  `json:"started_at"`
+	FinishedAt  *string `json:"finished_at,omitempty"`
+	Destination string  `json:"destination"`
+	Name        *string `json:"name,omitempty"`
+	SizeBytes   *int64  `json:"size_bytes,omitempty"`
+	Error       *string `json:"error,omitempty"`
+}
+
+type StoredBackupResponse struct {
+	Name      string  `json:"name"`
+	SizeBytes int64   `json:"size_bytes"`
+	ModTime   string  `json:"modified_at"`
+	Kind      *string `json:"kind,omitempty"`
+	Base      *string `json:"base,omitempty"`
+}
+
+type BackupStatusResponse struct {
+	Scheduled        bool                   `json:"scheduled"`
+	Interval         string                 `json:"interval,omitempty"`
+	KeepDaily        int                    `json:"keep_daily"`
+	KeepWeekly       int                    `json:"keep_weekly"`
+	FullEvery        int                    `json:"full_every"`
+	Destination      string                 `json:"destination"`
+	Running          bool                   `json:"running"`
+	NextRunAt        *string                `json:"next_run_at,omitempty"`
+	LastRun          *BackupRunResponse     `json:"last_run,omitempty"`
+	LastSuccess      *BackupRunResponse     `json:"last_success,omitempty"`
+	Backups          []StoredBackupResponse `json:"backups"`
+	DestinationError *string                `json:"destination_error,omitempty"`
+}
+
+func (h *BackupsHandler) requireAdmin(r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	user, err := h.db.Queries.GetUserByID(r.Context(), int64(userID))
+	if err != nil || !user.IsAdmin {
+		return apperr.NewForbidden("admin access required")
+	}
+	return nil
+}
+
+// GetBackups reports the backup schedule, the last run and last success, and
+// the backups kept at the destination, newest first.
+func (h *BackupsHandler) GetBackups(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	status, err := h.backups.Status(r.Context())
+	if err != nil {
+		return apperr.NewInternal("failed to get backup status", err)
+	}
+
+	response := BackupStatusResponse{
+		Scheduled:   status.Policy.Interval > 0,
+		KeepDaily:   status.Policy.KeepDaily,
+		KeepWeekly:  status.Policy.KeepWeekly,
+		FullEvery:   max(status.Policy.FullEvery, 1),
+		Destination: status.Destination,
+		Running:     status.Running,
+		LastRun:     convertBackupRun(status.LastRun),
+		LastSuccess: convertBackupRun(status.LastSuccess),
+		Backups:     make([]StoredBackupResponse, 0, len(status.Backups)),
+	}
+	if status.Policy.Interval > 0 {
+		response.Interval = status.Policy.Interval.String()
+	}
+	if !status.NextRunAt.IsZero() {
+		response.NextRunAt = httputil.StringToPtr(httputil.FormatTime(status.NextRunAt))
+	}
+	for i := len(status.Backups) - 1; i >= 0; i-- {
+		backup := status.Backups[i]
+		response.Backups = append(response.Backups, StoredBackupResponse{
+			Name:      backup.Path,
+			SizeBytes: backup.Size,
+			ModTime:   httputil.FormatTime(backup.ModTime),
+			Kind:      httputil.StringToPtr(backup.Kind),
+			Base:      httputil.StringToPtr(backup.Base),
+		})
+	}
+	if status.BackupsError != nil {
+		response.DestinationError = httputil.StringToPtr(status.BackupsError.Error())
+	}
+
+	return httputil.OKResult(w, response)
+}
+
+// RunBackup starts a backup in the background. Its progress shows in
+// GetBackups.
+func (h *BackupsHandler) RunBackup(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	status, err := h.backups.Status(r.Context())
+	if err != nil {
+		return apperr.NewInternal("failed to get backup status", err)
+	}
+	if status.Running {
+		return apperr.NewConflict("a backup is already running")
+	}
+
+	h.audit.Record(r.Context(), service.AuditEvent{
+		Actor:  shared.AuditActor(r),
+		Action: service.AuditInstanceBackup,
+	})
+
+	// The backup outlives the request. Failures are recorded in its run.
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		if _, err := h.backups.Run(ctx, service.BackupManual); err != nil {
+			slog.Warn("Manual backup failed", "error", err)
+		}
+	}()
+
+	httputil.WriteJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+	return nil
+}
+
+func convertBackupRun(run *sqlc.BackupRun) *BackupRunResponse {
+	if run == nil {
+		return nil
+	}
+	return &BackupRunResponse{
+		ID:          run.ID,
+		Trigger:     run.Trigger,
+		Status:      run.Status,
+		StartedAt:   httputil.FormatTime(run.StartedAt),
+		FinishedAt:  httputil.FormatNullTime(run.FinishedAt),
+		Destination: run.Destination,
+		Name:        httputil.NullStringToPtr(run.Name),
+		SizeBytes:   httputil.NullInt64ToPtr(run.SizeBytes),
+		Error:       httputil.NullStringToPtr(run.Error),
+	}
+}
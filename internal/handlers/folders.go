@@ -4,24 +4,30 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"sort"
 	"strconv"
+	"time"
 
 	"ramiro-uziel/vault/internal/apperr"
 	"ramiro-uziel/vault/internal/db"
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
 	"ramiro-uziel/vault/internal/handlers/shared"
 	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
 )
 
 type FoldersHandler struct {
-	db *db.DB
+	db    *db.DB
+	trash service.TrashService
+	audit service.AuditService
 }
 
-func NewFoldersHandler(database *db.DB) *FoldersHandler {
-	return &FoldersHandler{db: database}
+func NewFoldersHandler(database *db.DB, trash service.TrashService, audit service.AuditService) *FoldersHandler {
+	return &FoldersHandler{db: database, trash: trash, audit: audit}
 }
 
 func (h *FoldersHandler) CreateFolder(w http.ResponseWriter, r *http.Request) error {
@@ -39,6 +45,11 @@ func (h *FoldersHandler) CreateFolder(w http.ResponseWriter, r *http.Request) er
 		return apperr.NewBadRequest("folder name is required")
 	}
 
+	smartQuery, err := encodeSmartQuery(req.SmartQuery)
+	if err != nil {
+		return err
+	}
+
 	var parentID sql.NullInt64
 	if req.ParentID != nil {
 		count, err := h.db.CheckFolderExists(r.Context(), sqlc.CheckFolderExistsParams{
@@ -72,12 +83,17 @@ func (h *FoldersHandler) CreateFolder(w http.ResponseWriter, r *http.Request) er
 		ParentID:    parentID,
 		Name:        req.Name,
 		FolderOrder: folderOrder,
+		SmartQuery:  smartQuery,
 	})
 	if err != nil {
 		return apperr.NewInternal("failed to create folder", err)
 	}
 
-	return httputil.CreatedResult(w, convertFolder(folder))
+	response, err := h.convertFolderWithCount(r.Context(), int64(userID), folder)
+	if err != nil {
+		return err
+	}
+	return httputil.CreatedResult(w, response)
 }
 
 // ListFolders returns folders for the current user. Query param parent_id (optional): if omitted, returns root folders.
@@ -112,9 +128,9 @@ func (h *FoldersHandler) ListFolders(w http.ResponseWriter, r *http.Request) err
 		}
 	}
 
-	response := make([]FolderResponse, len(folders))
-	for i, folder := range folders {
-		response[i] = convertFolder(folder)
+	response, err := h.convertFolders(r.Context(), int64(userID), folders)
+	if err != nil {
+		return err
 	}
 	return httputil.OKResult(w, response)
 }
@@ -130,9 +146,9 @@ func (h *FoldersHandler) ListAllFolders(w http.ResponseWriter, r *http.Request)
 		return apperr.NewInternal("failed to query folders", err)
 	}
 
-	response := make([]FolderResponse, len(folders))
-	for i, folder := range folders {
-		response[i] = convertFolder(folder)
+	response, err := h.convertFolders(r.Context(), int64(userID), folders)
+	if err != nil {
+		return err
 	}
 	return httputil.OKResult(w, response)
 }
@@ -156,7 +172,11 @@ func (h *FoldersHandler) GetFolder(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 
-	return httputil.OKResult(w, convertFolder(folder))
+	response, err := h.convertFolderWithCount(r.Context(), int64(userID), folder)
+	if err != nil {
+		return err
+	}
+	return httputil.OKResult(w, response)
 }
 
 func (h *FoldersHandler) UpdateFolder(w http.ResponseWriter, r *http.Request) error {
@@ -183,6 +203,18 @@ func (h *FoldersHandler) UpdateFolder(w http.ResponseWriter, r *http.Request) er
 		return apperr.NewBadRequest("invalid request body")
 	}
 
+	// A folder is smart or regular for life; only the query can change.
+	var smartQuery sql.NullString
+	if req.SmartQuery != nil {
+		if !currentFolder.SmartQuery.Valid {
+			return apperr.NewBadRequest("only smart folders have a query")
+		}
+		smartQuery, err = encodeSmartQuery(req.SmartQuery)
+		if err != nil {
+			return err
+		}
+	}
+
 	name := currentFolder.Name
 	if req.Name != nil {
 		name = *req.Name
@@ -221,66 +253,22 @@ func (h *FoldersHandler) UpdateFolder(w http.ResponseWriter, r *http.Request) er
 		return apperr.NewInternal("failed to update folder", err)
 	}
 
-	return httputil.OKResult(w, convertFolder(folder))
-}
-
-func (h *FoldersHandler) deleteFolderRecursive(ctx context.Context, folderID, userID int64) error {
-	projects, err := h.db.ListProjectsInFolder(ctx, sqlc.ListProjectsInFolderParams{
-		FolderID: sql.NullInt64{Int64: folderID, Valid: true},
-		UserID:   userID,
-	})
-	if err != nil {
-		return err
-	}
-
-	for _, project := range projects {
-		_, err := h.db.UpdateProjectFolder(ctx, sqlc.UpdateProjectFolderParams{
-			FolderID: sql.NullInt64{Valid: false},
-			Column2:  nil,
-			ID:       project.ID,
-			UserID:   userID,
+	if req.SmartQuery != nil {
+		folder, err = h.db.UpdateFolderSmartQuery(r.Context(), sqlc.UpdateFolderSmartQueryParams{
+			SmartQuery: smartQuery,
+			ID:         id,
+			UserID:     int64(userID),
 		})
 		if err != nil {
-			return err
-		}
-	}
-
-	sharedOrgs, err := h.db.ListSharedProjectOrganizationsInFolder(ctx, sqlc.ListSharedProjectOrganizationsInFolderParams{
-		UserID:   userID,
-		FolderID: sql.NullInt64{Int64: folderID, Valid: true},
-	})
-	if err == nil {
-		for _, org := range sharedOrgs {
-			_, err := h.db.UpsertSharedProjectOrganization(ctx, sqlc.UpsertSharedProjectOrganizationParams{
-				UserID:      userID,
-				ProjectID:   org.ProjectID,
-				FolderID:    sql.NullInt64{Valid: false},
-				CustomOrder: org.CustomOrder,
-			})
-			if err != nil {
-				return err
-			}
+			return apperr.NewInternal("failed to update smart folder query", err)
 		}
 	}
 
-	subfolders, err := h.db.ListFoldersByParent(ctx, sqlc.ListFoldersByParentParams{
-		UserID:   userID,
-		ParentID: sql.NullInt64{Int64: folderID, Valid: true},
-	})
+	response, err := h.convertFolderWithCount(r.Context(), int64(userID), folder)
 	if err != nil {
 		return err
 	}
-
-	for _, subfolder := range subfolders {
-		if err := h.deleteFolderRecursive(ctx, subfolder.ID, userID); err != nil {
-			return err
-		}
-	}
-
-	return h.db.DeleteFolder(ctx, sqlc.DeleteFolderParams{
-		ID:     folderID,
-		UserID: userID,
-	})
+	return httputil.OKResult(w, response)
 }
 
 func (h *FoldersHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) error {
@@ -294,15 +282,32 @@ func (h *FoldersHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) er
 		return err
 	}
 
-	err = h.deleteFolderRecursive(r.Context(), id, int64(userID))
-	if err != nil {
-		return apperr.NewInternal("failed to delete folder", err)
+	folder, err := h.db.GetFolder(r.Context(), sqlc.GetFolderParams{
+		ID:     id,
+		UserID: int64(userID),
+	})
+	if err := httputil.HandleDBError(err, "folder not found", "failed to query folder"); err != nil {
+		return err
+	}
+
+	err = h.trash.TrashFolder(r.Context(), id, int64(userID))
+	if err := httputil.HandleDBError(err, "folder not found", "failed to delete folder"); err != nil {
+		return err
 	}
 
+	h.audit.Record(r.Context(), service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditFolderDelete,
+		TargetType: "folder",
+		TargetID:   strconv.FormatInt(id, 10),
+		Before:     map[string]any{"name": folder.Name},
+	})
+
 	return httputil.NoContentResult(w)
 }
 
-// EmptyFolder moves all projects and subfolders to the folder's parent (or root if at root).
+// EmptyFolder moves all projects and subfolders to the folder's parent (or root if at root),
+// then moves the emptied folder to the trash.
 func (h *FoldersHandler) EmptyFolder(w http.ResponseWriter, r *http.Request) error {
 	userID, err := httputil.RequireUserID(r)
 	if err != nil {
@@ -368,6 +373,24 @@ func (h *FoldersHandler) EmptyFolder(w http.ResponseWriter, r *http.Request) err
 		}
 	}
 
+	sharedTrackOrgs, err := h.db.ListSharedTrackOrganizationsInFolder(r.Context(), sqlc.ListSharedTrackOrganizationsInFolderParams{
+		UserID:   int64(userID),
+		FolderID: sql.NullInt64{Int64: id, Valid: true},
+	})
+	if err == nil {
+		for _, org := range sharedTrackOrgs {
+			_, err := h.db.UpsertSharedTrackOrganization(r.Context(), sqlc.UpsertSharedTrackOrganizationParams{
+				UserID:      int64(userID),
+				TrackID:     org.TrackID,
+				FolderID:    targetParentID,
+				CustomOrder: org.CustomOrder,
+			})
+			if err != nil {
+				return apperr.NewInternal("failed to move shared tracks out of folder", err)
+			}
+		}
+	}
+
 	subfolders, err := h.db.ListFoldersByParent(r.Context(), sqlc.ListFoldersByParentParams{
 		UserID:   int64(userID),
 		ParentID: sql.NullInt64{Int64: id, Valid: true},
@@ -387,9 +410,10 @@ func (h *FoldersHandler) EmptyFolder(w http.ResponseWriter, r *http.Request) err
 		}
 	}
 
-	err = h.db.DeleteFolder(r.Context(), sqlc.DeleteFolderParams{
-		ID:     id,
-		UserID: int64(userID),
+	err = h.db.TrashFolder(r.Context(), sqlc.TrashFolderParams{
+		DeletedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		ID:        id,
+		UserID:    int64(userID),
 	})
 	if err != nil {
 		return apperr.NewInternal("failed to delete folder", err)
@@ -417,6 +441,10 @@ func (h *FoldersHandler) GetFolderContents(w http.ResponseWriter, r *http.Reques
 		return err
 	}
 
+	if folder.SmartQuery.Valid {
+		return h.getSmartFolderContents(w, r, int64(userID), folder)
+	}
+
 	subfolders, err := h.db.ListFoldersByParent(r.Context(), sqlc.ListFoldersByParentParams{
 		UserID:   int64(userID),
 		ParentID: sql.NullInt64{Int64: id, Valid: true},
@@ -450,9 +478,9 @@ func (h *FoldersHandler) GetFolderContents(w http.ResponseWriter, r *http.Reques
 		sharedProjects = append(sharedProjects, project)
 	}
 
-	folderResponses := make([]FolderResponse, len(subfolders))
-	for i, f := range subfolders {
-		folderResponses[i] = convertFolder(f)
+	folderResponses, err := h.convertFolders(r.Context(), int64(userID), subfolders)
+	if err != nil {
+		return err
 	}
 
 	sharedOrgMap := make(map[int64]sqlc.UserSharedProjectOrganization)
@@ -615,17 +643,114 @@ func (h *FoldersHandler) GetFolderContents(w http.ResponseWriter, r *http.Reques
 	return httputil.OKResult(w, response)
 }
 
+// getSmartFolderContents resolves a smart folder by running its saved search.
+// Query params sort, order, cursor and limit page through the results as in
+// track search.
+func (h *FoldersHandler) getSmartFolderContents(w http.ResponseWriter, r *http.Request, userID int64, folder sqlc.Folder) error {
+	response, err := h.convertFolderWithCount(r.Context(), userID, folder)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	var limit int64
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	rows, next, err := h.db.SearchTracks(r.Context(), db.TrackSearch{
+		UserID:       userID,
+		TrackFilters: *response.SmartQuery,
+		Sort:         query.Get("sort"),
+		Order:        query.Get("order"),
+		Cursor:       query.Get("cursor"),
+		Limit:        limit,
+	})
+	if errors.Is(err, db.ErrInvalidSearch) {
+		return apperr.NewBadRequest(err.Error())
+	}
+	if err != nil {
+		return apperr.NewInternal("failed to resolve smart folder", err)
+	}
+
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
+	return httputil.OKResult(w, FolderContentsResponse{
+		Folder:       response,
+		Folders:      []FolderResponse{},
+		Projects:     []shared.ProjectResponse{},
+		SharedTracks: []shared.SharedTrackResponse{},
+		Tracks:       shared.ConvertTrackSearchRows(rows),
+	})
+}
+
+// encodeSmartQuery validates a smart folder query and returns it as stored in
+// the folders table. A nil query makes a regular folder.
+func encodeSmartQuery(filters *db.TrackFilters) (sql.NullString, error) {
+	if filters == nil {
+		return sql.NullString{}, nil
+	}
+	if err := filters.Validate(); err != nil {
+		return sql.NullString{}, apperr.NewBadRequest(err.Error())
+	}
+	data, err := json.Marshal(filters)
+	if err != nil {
+		return sql.NullString{}, apperr.NewInternal("failed to encode smart folder query", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// convertFolders converts folders and counts the tracks in any smart folders
+// among them.
+func (h *FoldersHandler) convertFolders(ctx context.Context, userID int64, folders []sqlc.Folder) ([]FolderResponse, error) {
+	response := make([]FolderResponse, len(folders))
+	for i, folder := range folders {
+		converted, err := h.convertFolderWithCount(ctx, userID, folder)
+		if err != nil {
+			return nil, err
+		}
+		response[i] = converted
+	}
+	return response, nil
+}
+
+func (h *FoldersHandler) convertFolderWithCount(ctx context.Context, userID int64, folder sqlc.Folder) (FolderResponse, error) {
+	response := convertFolder(folder)
+	if response.SmartQuery == nil {
+		return response, nil
+	}
+
+	count, err := h.db.CountTracks(ctx, userID, *response.SmartQuery)
+	if err != nil {
+		return response, apperr.NewInternal("failed to count smart folder tracks", err)
+	}
+	response.TrackCount = &count
+	return response, nil
+}
+
 func convertFolder(folder sqlc.Folder) FolderResponse {
 	var parentID *int64
 	if folder.ParentID.Valid {
 		parentID = &folder.ParentID.Int64
 	}
 
+	var smartQuery *db.TrackFilters
+	if folder.SmartQuery.Valid {
+		smartQuery = &db.TrackFilters{}
+		if err := json.Unmarshal([]byte(folder.SmartQuery.String), smartQuery); err != nil {
+			slog.Warn("Invalid smart folder query", "folder_id", folder.ID, "error", err)
+		}
+	}
+
 	return FolderResponse{
 		ID:          folder.ID,
 		Name:        folder.Name,
 		ParentID:    parentID,
 		FolderOrder: folder.FolderOrder,
+		SmartQuery:  smartQuery,
 		CreatedAt:   httputil.FormatNullTimeString(folder.CreatedAt),
 		UpdatedAt:   httputil.FormatNullTimeString(folder.UpdatedAt),
 	}
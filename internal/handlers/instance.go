@@ -2,10 +2,11 @@ package handlers
 
 import (
 	"archive/zip"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -15,32 +16,32 @@ import (
 	"ramiro-uziel/vault/internal/auth"
 	"ramiro-uziel/vault/internal/db"
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/handlers/shared"
 	"ramiro-uziel/vault/internal/httputil"
-
-	_ "github.com/mattn/go-sqlite3"
+	"ramiro-uziel/vault/internal/service"
+	"ramiro-uziel/vault/internal/storage"
 )
 
 type InstanceHandler struct {
 	db      *db.DB
 	dataDir string
+	storage storage.Storage
+	keyring *storage.Keyring
 	wsHub   *WSHub
+	audit   service.AuditService
 }
 
-func NewInstanceHandler(database *db.DB, dataDir string, wsHub *WSHub) *InstanceHandler {
+func NewInstanceHandler(database *db.DB, dataDir string, storageAdapter storage.Storage, keyring *storage.Keyring, wsHub *WSHub, audit service.AuditService) *InstanceHandler {
 	return &InstanceHandler{
 		db:      database,
 		dataDir: dataDir,
+		storage: storageAdapter,
+		keyring: keyring,
 		wsHub:   wsHub,
+		audit:   audit,
 	}
 }
 
-type ExportManifest struct {
-	Version      string    `json:"version"`
-	AppVersion   string    `json:"app_version"`
-	InstanceName string    `json:"instance_name"`
-	CreatedAt    time.Time `json:"created_at"`
-}
-
 // GetExportSize returns the estimated export size in bytes
 func (h *InstanceHandler) GetExportSize(w http.ResponseWriter, r *http.Request) error {
 	userID, err := httputil.RequireUserID(r)
@@ -64,14 +65,11 @@ func (h *InstanceHandler) GetExportSize(w http.ResponseWriter, r *http.Request)
 		totalBytes += info.Size()
 	}
 
-	// Projects directory
-	projectsDir := filepath.Join(h.dataDir, "projects")
-	filepath.Walk(projectsDir, func(_ string, info os.FileInfo, err error) error {
-		if err == nil && !info.IsDir() {
-			totalBytes += info.Size()
-		}
-		return nil
-	})
+	_, storedBytes, err := service.CountArchiveFiles(r.Context(), h.storage)
+	if err != nil {
+		return apperr.NewInternal("failed to list stored files", err)
+	}
+	totalBytes += storedBytes
 
 	return httputil.OKResult(w, map[string]int64{"size_bytes": totalBytes})
 }
@@ -94,46 +92,27 @@ func (h *InstanceHandler) ExportInstance(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return apperr.NewInternal("failed to get instance info", err)
 	}
-
-	// Use a dedicated read-only connection for checkpoint: ForceCheckpoint(TRUNCATE) deadlocks with the pool
-	dbPath := h.db.GetPath()
-	tmpDB, err := sql.Open("sqlite3", fmt.Sprintf("%s?_journal_mode=WAL&mode=ro", dbPath))
+	totalFiles, _, err := service.CountArchiveFiles(ctx, h.storage)
 	if err != nil {
-		return apperr.NewInternal("failed to open database for export", err)
+		return apperr.NewInternal("failed to list stored files", err)
 	}
-	tmpDB.SetMaxOpenConns(1)
-	tmpDB.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
-	tmpDB.Close()
+
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:  shared.AuditActor(r),
+		Action: service.AuditInstanceExport,
+	})
 
 	filename := fmt.Sprintf("vault-backup-%s-%d.zip",
 		instanceInfo.Name, time.Now().Unix())
 	w.Header().Set("Content-Type", "application/zip")
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
 
-	zw := zip.NewWriter(w)
-	defer zw.Close()
-
-	manifest := ExportManifest{
-		Version:      "1.0",
-		AppVersion:   "v0.0.1",
-		InstanceName: instanceInfo.Name,
-		CreatedAt:    time.Now().UTC(),
-	}
-	manifestJSON, _ := json.Marshal(manifest)
-
-	// Count total files for progress reporting
-	totalFiles := 2 // manifest.json + vault.db
-	if _, err := os.Stat(dbPath + "-wal"); err == nil {
-		totalFiles++ // vault.db-wal
-	}
-	projectsDir := filepath.Join(h.dataDir, "projects")
-	if _, err := os.Stat(projectsDir); err == nil {
-		filepath.Walk(projectsDir, func(_ string, info os.FileInfo, err error) error {
-			if err == nil && !info.IsDir() {
-				totalFiles++
-			}
-			return nil
-		})
+	manifest := service.ExportManifest{
+		Version:         service.ArchiveVersion,
+		AppVersion:      "v0.0.1",
+		InstanceName:    instanceInfo.Name,
+		CreatedAt:       time.Now().UTC(),
+		EncryptionKeyID: h.keyring.CurrentKeyID(),
 	}
 
 	currentFile := 0
@@ -142,71 +121,13 @@ func (h *InstanceHandler) ExportInstance(w http.ResponseWriter, r *http.Request)
 		h.sendExportProgress(userID, currentFile, totalFiles, filename)
 	}
 
-	manifestFile, err := zw.Create("manifest.json")
-	if err != nil {
-		return apperr.NewInternal("failed to create manifest in ZIP", err)
-	}
-	manifestFile.Write(manifestJSON)
-	sendProgress("manifest.json")
-
-	dbFile, err := os.Open(dbPath)
-	if err == nil {
-		defer dbFile.Close()
-		if zipFile, err := zw.Create("vault.db"); err == nil {
-			io.Copy(zipFile, dbFile)
-		}
-	}
-	sendProgress("vault.db")
-
-	// Include WAL for complete backup
-	walPath := dbPath + "-wal"
-	if walFile, err := os.Open(walPath); err == nil {
-		defer walFile.Close()
-		if zipFile, err := zw.Create("vault.db-wal"); err == nil {
-			io.Copy(zipFile, walFile)
-		}
-		sendProgress("vault.db-wal")
-	}
-
-	if _, err := os.Stat(projectsDir); err == nil {
-		h.addDirToZip(zw, projectsDir, "projects", sendProgress)
+	// The response has started, so a failure can only cut the download short.
+	if err := service.WriteInstanceArchive(ctx, w, h.db, h.storage, manifest, sendProgress); err != nil {
+		slog.Error("Instance export failed", "error", err)
 	}
-
 	return nil
 }
 
-func (h *InstanceHandler) addDirToZip(zw *zip.Writer, dir string, prefix string, onFile func(string)) error {
-	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-
-		rel, _ := filepath.Rel(dir, path)
-		zipPath := filepath.Join(prefix, rel)
-
-		if info.IsDir() {
-			return nil
-		}
-
-		file, err := os.Open(path)
-		if err != nil {
-			return nil // Skip on error
-		}
-		defer file.Close()
-
-		zipFile, err := zw.Create(zipPath)
-		if err != nil {
-			return nil
-		}
-
-		io.Copy(zipFile, file)
-		if onFile != nil {
-			onFile(zipPath)
-		}
-		return nil
-	})
-}
-
 func (h *InstanceHandler) sendExportProgress(userID int, current, total int, filename string) {
 	if h.wsHub == nil {
 		return
@@ -236,7 +157,10 @@ func (h *InstanceHandler) sendImportProgress(userID int, stage string, current,
 	})
 }
 
-// ImportInstance replaces data with uploaded backup
+// ImportInstance replaces data with uploaded backup. The archive is
+// extracted and its database checked and migrated on the side first; with
+// dry_run=true the plan is returned and nothing else happens. If any step of
+// the swap fails, the previous database and files are put back.
 func (h *InstanceHandler) ImportInstance(w http.ResponseWriter, r *http.Request) error {
 	userID, err := httputil.RequireUserID(r)
 	if err != nil {
@@ -249,7 +173,15 @@ func (h *InstanceHandler) ImportInstance(w http.ResponseWriter, r *http.Request)
 	if err != nil || !user.IsAdmin {
 		return apperr.NewForbidden("admin access required")
 	}
+	// An import replaces the data directory, which other backends do not
+	// read files from.
+	if _, ok := h.storage.(*storage.FilesystemStorage); !ok {
+		return apperr.NewConflict("instance import is only supported with the filesystem storage backend")
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
 
+	r.Body = http.MaxBytesReader(w, r.Body, service.MaxImportSize)
 	if err := r.ParseMultipartForm(32 << 20); err != nil { // 32MB memory, rest spills to disk
 		return apperr.NewBadRequest("failed to parse form")
 	}
@@ -281,103 +213,141 @@ func (h *InstanceHandler) ImportInstance(w http.ResponseWriter, r *http.Request)
 	}
 	defer zr.Close()
 
-	totalFiles := len(zr.File)
-	hasManifest := false
-	hasDB := false
 	tmpExtractDir, err := os.MkdirTemp(h.dataDir, "vault-extract-*")
 	if err != nil {
 		return apperr.NewInternal("failed to create temp directory", err)
 	}
 	defer os.RemoveAll(tmpExtractDir)
 
-	for i, f := range zr.File {
-		if f.Name == "manifest.json" {
-			hasManifest = true
-		}
-		if f.Name == "vault.db" {
-			hasDB = true
-		}
+	totalFiles := len(zr.File)
+	extracted := 0
+	err = service.ExtractInstanceArchive(&zr.Reader, tmpExtractDir, func(name string) {
+		extracted++
+		h.sendImportProgress(userID, "extracting", extracted, totalFiles, name)
+	})
+	if err != nil {
+		return importError(err, "failed to extract backup")
+	}
 
-		if err := h.extractZipFile(f, tmpExtractDir); err != nil {
-			return apperr.NewBadRequest("failed to extract backup")
-		}
-		h.sendImportProgress(userID, "extracting", i+1, totalFiles, f.Name)
+	h.sendImportProgress(userID, "validating", 0, 0, "")
+
+	plan, err := service.PrepareImport(ctx, tmpExtractDir, h.db, h.dataDir)
+	if err != nil {
+		return importError(err, "failed to validate backup")
 	}
 
-	if !hasManifest || !hasDB {
-		return apperr.NewBadRequest("invalid backup: missing manifest or database")
+	if keyID, err := h.findUnreadableKey(filepath.Join(tmpExtractDir, "projects")); err != nil {
+		return apperr.NewInternal("failed to inspect backup files", err)
+	} else if keyID != "" {
+		return apperr.NewBadRequest(fmt.Sprintf("backup contains files encrypted with key %s, which is not configured on this instance", keyID))
+	}
+
+	if dryRun {
+		return httputil.OKResult(w, ImportResponse{Status: "valid", Plan: plan})
 	}
 
 	h.sendImportProgress(userID, "replacing", 0, 0, "")
 
+	// This instance's audit log is appended to the imported one.
+	auditEvents, err := h.audit.Carry(ctx)
+	if err != nil {
+		return apperr.NewInternal("failed to read audit log", err)
+	}
+
 	if err := h.db.ForceCheckpoint(); err != nil {
 		return apperr.NewInternal("failed to prepare current database", err)
 	}
 
 	h.db.Close()
 
-	dbPath := h.db.GetPath()
-	newDBPath := filepath.Join(tmpExtractDir, "vault.db")
-
-	if err := os.Rename(newDBPath, dbPath); err != nil {
-		return apperr.NewInternal("failed to replace database", err)
-	}
-
-	projectsDir := filepath.Join(h.dataDir, "projects")
-	tmpProjectsDir := filepath.Join(tmpExtractDir, "projects")
-
-	if _, err := os.Stat(tmpProjectsDir); err == nil {
-		oldProjectsDir := filepath.Join(h.dataDir, "projects.backup")
-		os.RemoveAll(oldProjectsDir)
-		if err := os.Rename(projectsDir, oldProjectsDir); err == nil {
-			if err := os.Rename(tmpProjectsDir, projectsDir); err != nil {
-				os.RemoveAll(projectsDir)
-				os.Rename(oldProjectsDir, projectsDir)
-				return apperr.NewInternal("failed to replace projects", err)
-			}
-			os.RemoveAll(oldProjectsDir)
+	swap, err := service.SwapDataDir(h.dataDir, tmpExtractDir)
+	if err != nil {
+		if reconnectErr := h.db.Reconnect(); reconnectErr != nil {
+			slog.Error("Failed to reopen database after a failed import", "error", reconnectErr)
 		}
+		return apperr.NewInternal("failed to replace data", err)
 	}
 
-	if err := h.db.Reconnect(); err != nil {
-		return apperr.NewInternal("failed to reconnect database", err)
+	if err := h.finishImport(r, auditEvents); err != nil {
+		h.db.Close()
+		if rollbackErr := swap.Rollback(); rollbackErr != nil {
+			slog.Error("Failed to roll back import", "error", rollbackErr)
+		}
+		if reconnectErr := h.db.Reconnect(); reconnectErr != nil {
+			slog.Error("Failed to reopen database after rolling back import", "error", reconnectErr)
+		}
+		return apperr.NewInternal("import failed and was rolled back", err)
 	}
-
-	// Invalidate sessions so existing JWTs no longer work
-	if err := h.db.Queries.InvalidateSessions(ctx); err != nil {
-		return apperr.NewInternal("failed to invalidate sessions", err)
+	if err := swap.Commit(); err != nil {
+		slog.Warn("Failed to remove previous data after import", "error", err)
 	}
 
 	// Return success - frontend will handle redirect and token clearing
-	return httputil.OKResult(w, map[string]string{"status": "success"})
+	return httputil.OKResult(w, ImportResponse{Status: "success", Plan: plan})
 }
 
-// extractZipFile extracts a single file from ZIP
-func (h *InstanceHandler) extractZipFile(f *zip.File, dest string) error {
-	path := filepath.Join(dest, f.Name)
+type ImportResponse struct {
+	Status string              `json:"status"`
+	Plan   *service.ImportPlan `json:"plan"`
+}
 
-	if f.FileInfo().IsDir() {
-		return os.MkdirAll(path, f.FileInfo().Mode())
+// finishImport opens the imported database and carries over what must
+// survive the import.
+func (h *InstanceHandler) finishImport(r *http.Request, auditEvents []sqlc.AuditEvent) error {
+	ctx := r.Context()
+
+	if err := h.db.Reconnect(); err != nil {
+		return fmt.Errorf("failed to reconnect database: %w", err)
 	}
 
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return err
+	if err := h.audit.Restore(ctx, auditEvents); err != nil {
+		return fmt.Errorf("failed to restore audit log: %w", err)
 	}
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:  shared.AuditActor(r),
+		Action: service.AuditInstanceImport,
+	})
 
-	rc, err := f.Open()
-	if err != nil {
-		return err
+	// Invalidate sessions so existing JWTs no longer work
+	if err := h.db.Queries.InvalidateSessions(ctx); err != nil {
+		return fmt.Errorf("failed to invalidate sessions: %w", err)
 	}
-	defer rc.Close()
+	return nil
+}
 
-	w, err := os.Create(path)
-	if err != nil {
-		return err
+// importError reports archives that cannot be imported as bad requests.
+func importError(err error, message string) error {
+	if errors.Is(err, service.ErrInvalidArchive) {
+		return apperr.NewBadRequest(err.Error())
 	}
-	defer w.Close()
+	return apperr.NewInternal(message, err)
+}
 
-	_, err = io.Copy(w, rc)
-	return err
+// findUnreadableKey returns the ID of the first master key found in dir that
+// this instance cannot unwrap, or "" if every file is readable.
+func (h *InstanceHandler) findUnreadableKey(dir string) (string, error) {
+	var missing string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		keyID, err := storage.ReadKeyID(path)
+		if err != nil {
+			return err
+		}
+		if keyID != "" && !h.keyring.Has(keyID) {
+			missing = keyID
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return missing, err
 }
 
 // ResetInstance deletes all data and restores to clean state
@@ -417,6 +387,13 @@ func (h *InstanceHandler) ResetInstance(w http.ResponseWriter, r *http.Request)
 		return apperr.NewBadRequest("instance name does not match")
 	}
 
+	// The audit log outlives the reset so it still shows who reset the
+	// instance and what happened before.
+	auditEvents, err := h.audit.Carry(ctx)
+	if err != nil {
+		return apperr.NewInternal("failed to read audit log", err)
+	}
+
 	h.db.Close()
 
 	dbPath := h.db.GetPath()
@@ -432,6 +409,15 @@ func (h *InstanceHandler) ResetInstance(w http.ResponseWriter, r *http.Request)
 		return apperr.NewInternal("failed to reinitialize database", err)
 	}
 
+	if err := h.audit.Restore(ctx, auditEvents); err != nil {
+		return apperr.NewInternal("failed to restore audit log", err)
+	}
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:  shared.AuditActor(r),
+		Action: service.AuditInstanceReset,
+		Before: map[string]any{"name": instanceInfo.Name},
+	})
+
 	if req.NewAdmin != nil {
 		if req.NewAdmin.Username == "" || req.NewAdmin.Email == "" || req.NewAdmin.Password == "" {
 			return apperr.NewBadRequest("new admin credentials are required")
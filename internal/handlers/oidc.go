@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/auth"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/httputil"
+	authsvc "ramiro-uziel/vault/internal/service"
+)
+
+// GetOIDC tells the login page whether to offer single sign-on.
+func (h *AuthHandler) GetOIDC(w http.ResponseWriter, r *http.Request) error {
+	return httputil.OKResult(w, map[string]interface{}{
+		"enabled":       h.oidc.Enabled(),
+		"provider_name": h.oidc.ProviderName(),
+	})
+}
+
+// BeginOIDCLogin sends the browser to the identity provider. An invite token
+// in the query lets a new user's account be created from the invite.
+func (h *AuthHandler) BeginOIDCLogin(w http.ResponseWriter, r *http.Request) error {
+	redirect, err := h.oidc.Begin(r.Context(), authsvc.OIDCBeginInput{
+		InviteToken: r.URL.Query().Get("invite"),
+	})
+	if err != nil {
+		return mapAuthError(err)
+	}
+
+	httputil.SetOIDCStateCookie(w, redirect.State, h.authConfig, time.Until(redirect.ExpiresAt))
+	http.Redirect(w, r, redirect.URL, http.StatusFound)
+	return nil
+}
+
+// BeginOIDCLink starts a login at the identity provider that links the
+// identity to the current user. It returns the URL for the browser to open.
+func (h *AuthHandler) BeginOIDCLink(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	linkUserID := int64(userID)
+	redirect, err := h.oidc.Begin(r.Context(), authsvc.OIDCBeginInput{
+		LinkUserID: &linkUserID,
+	})
+	if err != nil {
+		return mapAuthError(err)
+	}
+
+	httputil.SetOIDCStateCookie(w, redirect.State, h.authConfig, time.Until(redirect.ExpiresAt))
+	return httputil.OKResult(w, map[string]interface{}{
+		"redirect_url": redirect.URL,
+	})
+}
+
+// OIDCCallback finishes a login when the identity provider redirects back.
+// It answers with redirects into the app rather than JSON, since the browser
+// navigates here.
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	ctx := r.Context()
+
+	cookie, cookieErr := r.Cookie(auth.OIDCStateCookieName)
+	httputil.ClearOIDCStateCookie(w, h.authConfig)
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		slog.WarnContext(ctx, "Identity provider returned an error",
+			"error", providerErr,
+			"description", query.Get("error_description"),
+		)
+		return h.oidcFailed(w, r, "provider_error")
+	}
+
+	// The state must come back to the browser that started the login.
+	state := query.Get("state")
+	if cookieErr != nil || state == "" || cookie.Value != state {
+		return h.oidcFailed(w, r, "invalid_state")
+	}
+
+	result, err := h.oidc.Complete(ctx, state, query.Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, authsvc.ErrInvalidToken):
+			return h.oidcFailed(w, r, "invalid_state")
+		case errors.Is(err, authsvc.ErrNoLinkedAccount):
+			return h.oidcFailed(w, r, "no_account")
+		case errors.Is(err, authsvc.ErrIdentityLinked):
+			return h.oidcFailed(w, r, "already_linked")
+		case errors.Is(err, authsvc.ErrOIDCProvider):
+			return h.oidcFailed(w, r, "provider_error")
+		}
+		slog.ErrorContext(ctx, "Single sign-on failed", "error", err)
+		return h.oidcFailed(w, r, "server_error")
+	}
+
+	if result.Linked {
+		actor := shared.AuditActor(r)
+		actor.UserID = result.User.ID
+		actor.Username = result.User.Username
+		h.audit.Record(ctx, authsvc.AuditEvent{
+			Actor:      actor,
+			Action:     authsvc.AuditIdentityLink,
+			TargetType: "user",
+			TargetID:   strconv.FormatInt(result.User.ID, 10),
+			After: map[string]any{
+				"provisioned": result.Provisioned,
+			},
+		})
+	}
+
+	if result.LinkOnly {
+		http.Redirect(w, r, "/profile?sso=linked", http.StatusFound)
+		return nil
+	}
+
+	// The provider stands in for the password and any second factor.
+	if err := h.startSession(w, r, result.User, map[string]any{"method": "oidc"}); err != nil {
+		slog.ErrorContext(ctx, "Failed to start session after single sign-on", "error", err)
+		return h.oidcFailed(w, r, "server_error")
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+	return nil
+}
+
+func (h *AuthHandler) oidcFailed(w http.ResponseWriter, r *http.Request, reason string) error {
+	http.Redirect(w, r, "/login?sso_error="+url.QueryEscape(reason), http.StatusFound)
+	return nil
+}
+
+func (h *AuthHandler) ListIdentities(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	identities, err := h.oidc.ListIdentities(r.Context(), int64(userID))
+	if err != nil {
+		return apperr.NewInternal("failed to list identities", err)
+	}
+	return httputil.OKResult(w, identities)
+}
+
+func (h *AuthHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	identityID, err := httputil.PathInt64(r, "id")
+	if err != nil {
+		return err
+	}
+
+	ctx := r.Context()
+
+	if err := h.oidc.Unlink(ctx, int64(userID), identityID); err != nil {
+		return httputil.HandleDBError(err, "identity not found", "failed to unlink identity")
+	}
+
+	h.audit.Record(ctx, authsvc.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     authsvc.AuditIdentityUnlink,
+		TargetType: "identity",
+		TargetID:   strconv.FormatInt(identityID, 10),
+	})
+
+	httputil.NoContent(w)
+	return nil
+}
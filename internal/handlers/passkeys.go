@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/httputil"
+	authsvc "ramiro-uziel/vault/internal/service"
+	"ramiro-uziel/vault/internal/webauthn"
+)
+
+type FinishPasskeyRegistrationRequest struct {
+	Name       string                         `json:"name"`
+	Credential *webauthn.RegistrationResponse `json:"credential"`
+}
+
+type PasskeyLoginRequest struct {
+	// Challenge is the login challenge from the password step, when the
+	// passkey is a second factor.
+	Challenge  string                           `json:"challenge"`
+	Credential *webauthn.AuthenticationResponse `json:"credential"`
+}
+
+// BeginPasskeyRegistration returns the options for
+// navigator.credentials.create. Like setting up TOTP, it takes the user's
+// password.
+func (h *AuthHandler) BeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	req, err := httputil.DecodeJSON[TwoFactorSetupRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Password == "" {
+		return apperr.NewBadRequest("password is required")
+	}
+
+	if err := h.verifyPassword(r, req.Password); err != nil {
+		return err
+	}
+
+	options, err := h.authService.BeginPasskeyRegistration(r.Context(), int64(userID))
+	if err != nil {
+		return mapAuthError(err)
+	}
+	return httputil.OKResult(w, map[string]interface{}{
+		"publicKey": options,
+	})
+}
+
+func (h *AuthHandler) FinishPasskeyRegistration(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	req, err := httputil.DecodeJSON[FinishPasskeyRegistrationRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Credential == nil {
+		return apperr.NewBadRequest("credential is required")
+	}
+
+	ctx := r.Context()
+
+	passkey, err := h.authService.FinishPasskeyRegistration(ctx, int64(userID), req.Name, req.Credential)
+	if err != nil {
+		return mapAuthError(err)
+	}
+
+	h.audit.Record(ctx, authsvc.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     authsvc.AuditPasskeyAdd,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(userID),
+		After:      map[string]any{"passkey_id": passkey.ID, "name": passkey.Name},
+	})
+
+	return httputil.CreatedResult(w, passkey)
+}
+
+func (h *AuthHandler) ListPasskeys(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	passkeys, err := h.authService.ListPasskeys(r.Context(), int64(userID))
+	if err != nil {
+		return apperr.NewInternal("failed to list passkeys", err)
+	}
+	return httputil.OKResult(w, passkeys)
+}
+
+// DeletePasskey revokes one of the user's passkeys. While the instance
+// requires 2FA, the last second factor a user has cannot be removed.
+func (h *AuthHandler) DeletePasskey(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	passkeyID, err := httputil.PathInt64(r, "id")
+	if err != nil {
+		return err
+	}
+
+	ctx := r.Context()
+
+	status, err := h.twoFactor.Status(ctx, int64(userID))
+	if err != nil {
+		return apperr.NewInternal("failed to get two-factor status", err)
+	}
+	if status.Required && !status.Enabled && status.Passkeys <= 1 {
+		return mapAuthError(authsvc.ErrTwoFactorRequired)
+	}
+
+	if err := h.authService.DeletePasskey(ctx, int64(userID), passkeyID); err != nil {
+		return httputil.HandleDBError(err, "passkey not found", "failed to delete passkey")
+	}
+
+	h.audit.Record(ctx, authsvc.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     authsvc.AuditPasskeyRemove,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(userID),
+		Before:     map[string]any{"passkey_id": passkeyID},
+	})
+
+	httputil.NoContent(w)
+	return nil
+}
+
+// BeginPasskeyLogin returns the options for a passwordless login, where the
+// browser offers the user the passkeys it holds for this instance.
+func (h *AuthHandler) BeginPasskeyLogin(w http.ResponseWriter, r *http.Request) error {
+	options, err := h.authService.BeginPasskeyLogin(r.Context(), nil)
+	if err != nil {
+		return mapAuthError(err)
+	}
+	return httputil.OKResult(w, map[string]interface{}{
+		"publicKey": options,
+	})
+}
+
+// FinishPasskeyLogin signs in with a passkey alone. The passkey has to
+// verify the user, so it stands in for both the password and the second
+// factor.
+func (h *AuthHandler) FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) error {
+	req, err := httputil.DecodeJSON[PasskeyLoginRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Credential == nil {
+		return apperr.NewBadRequest("credential is required")
+	}
+
+	ctx := r.Context()
+
+	user, err := h.authService.FinishPasskeyLogin(ctx, nil, req.Credential)
+	if errors.Is(err, authsvc.ErrInvalidPasskey) {
+		h.audit.Record(ctx, authsvc.AuditEvent{
+			Actor:  shared.AuditActor(r),
+			Action: authsvc.AuditLoginFailed,
+			After:  map[string]any{"method": "passkey"},
+		})
+	}
+	if err != nil {
+		return mapAuthError(err)
+	}
+
+	if err := h.startSession(w, r, user, map[string]any{"method": "passkey"}); err != nil {
+		return err
+	}
+	return httputil.OKResult(w, map[string]interface{}{
+		"user": serviceUserToResponse(user),
+	})
+}
+
+// LoginTwoFactorPasskeyBegin returns the options for answering a login
+// challenge with one of the user's passkeys.
+func (h *AuthHandler) LoginTwoFactorPasskeyBegin(w http.ResponseWriter, r *http.Request) error {
+	req, err := httputil.DecodeJSON[PasskeyLoginRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Challenge == "" {
+		return apperr.NewBadRequest("challenge is required")
+	}
+
+	ctx := r.Context()
+
+	userID, err := h.twoFactor.ChallengeUser(ctx, req.Challenge)
+	if err != nil {
+		return mapAuthError(err)
+	}
+
+	options, err := h.authService.BeginPasskeyLogin(ctx, &userID)
+	if err != nil {
+		return mapAuthError(err)
+	}
+	return httputil.OKResult(w, map[string]interface{}{
+		"publicKey": options,
+	})
+}
+
+// LoginTwoFactorPasskey completes a login challenge with a passkey.
+func (h *AuthHandler) LoginTwoFactorPasskey(w http.ResponseWriter, r *http.Request) error {
+	req, err := httputil.DecodeJSON[PasskeyLoginRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Challenge == "" || req.Credential == nil {
+		return apperr.NewBadRequest("challenge and credential are required")
+	}
+
+	ctx := r.Context()
+
+	var user *authsvc.User
+	_, err = h.twoFactor.CompletePasskeyLogin(ctx, req.Challenge, func(userID int64) error {
+		var err error
+		user, err = h.authService.FinishPasskeyLogin(ctx, &userID, req.Credential)
+		if errors.Is(err, authsvc.ErrInvalidPasskey) {
+			actor := shared.AuditActor(r)
+			actor.UserID = userID
+			h.audit.Record(ctx, authsvc.AuditEvent{
+				Actor:  actor,
+				Action: authsvc.AuditLoginFailed,
+				After:  map[string]any{"step": "two_factor"},
+			})
+		}
+		return err
+	})
+	if err != nil {
+		return mapAuthError(err)
+	}
+	if err := h.startSession(w, r, user, map[string]any{"second_factor": "passkey"}); err != nil {
+		return err
+	}
+	return httputil.OKResult(w, map[string]interface{}{
+		"user": serviceUserToResponse(user),
+	})
+}
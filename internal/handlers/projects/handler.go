@@ -1,12 +1,11 @@
 package projects
 
 import (
-	"archive/zip"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -20,19 +19,29 @@ import (
 	"ramiro-uziel/vault/internal/httputil"
 	"ramiro-uziel/vault/internal/ids"
 	"ramiro-uziel/vault/internal/service"
+	"ramiro-uziel/vault/internal/storage"
+	"ramiro-uziel/vault/internal/transcoding"
 )
 
 type ProjectsHandler struct {
-	service service.ProjectService
-	db      *db.DB
-	dataDir string
+	service    service.ProjectService
+	db         *db.DB
+	storage    storage.Storage
+	transcoder Transcoder
+	audit      service.AuditService
 }
 
-func NewProjectsHandler(svc service.ProjectService, database *db.DB, dataDir string) *ProjectsHandler {
+type Transcoder interface {
+	TranscodeVersion(ctx context.Context, input transcoding.TranscodeVersionInput) error
+}
+
+func NewProjectsHandler(svc service.ProjectService, database *db.DB, storageAdapter storage.Storage, transcoder Transcoder, audit service.AuditService) *ProjectsHandler {
 	return &ProjectsHandler{
-		service: svc,
-		db:      database,
-		dataDir: dataDir,
+		service:    svc,
+		db:         database,
+		storage:    storageAdapter,
+		transcoder: transcoder,
+		audit:      audit,
 	}
 }
 
@@ -275,11 +284,27 @@ func (h *ProjectsHandler) DeleteProject(w http.ResponseWriter, r *http.Request)
 
 	publicID := r.PathValue("id")
 
+	project, err := h.db.GetProjectByPublicID(r.Context(), sqlc.GetProjectByPublicIDParams{
+		PublicID: publicID,
+		UserID:   int64(userID),
+	})
+	if err := httputil.HandleDBError(err, "project not found", "failed to query project"); err != nil {
+		return err
+	}
+
 	err = h.service.DeleteProject(r.Context(), publicID, int64(userID))
 	if err := httputil.HandleDBError(err, "project not found", "failed to delete project"); err != nil {
 		return err
 	}
 
+	h.audit.Record(r.Context(), service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditProjectDelete,
+		TargetType: "project",
+		TargetID:   publicID,
+		Before:     map[string]any{"name": project.Name},
+	})
+
 	return httputil.NoContentResult(w)
 }
 
@@ -342,11 +367,8 @@ func (h *ProjectsHandler) DuplicateProject(w http.ResponseWriter, r *http.Reques
 		oldCoverPath := originalProject.CoverArtPath.String
 		oldCoverDir := filepath.Dir(oldCoverPath)
 		newCoverDir := strings.Replace(oldCoverDir, originalProject.PublicID, duplicateProject.PublicID, 1)
-		if err := os.MkdirAll(newCoverDir, 0o755); err != nil {
-			return apperr.NewInternal("failed to create project directory", err)
-		}
 		newCoverPath := filepath.Join(newCoverDir, filepath.Base(oldCoverPath))
-		if err := copyFileForProject(oldCoverPath, newCoverPath); err == nil {
+		if err := h.storage.CopyFile(ctx, oldCoverPath, newCoverPath); err == nil {
 			_, err = queries.UpdateProjectCover(ctx, sqlc.UpdateProjectCoverParams{
 				CoverArtPath: sql.NullString{String: newCoverPath, Valid: true},
 				CoverArtMime: originalProject.CoverArtMime,
@@ -421,11 +443,7 @@ func (h *ProjectsHandler) DuplicateProject(w http.ResponseWriter, r *http.Reques
 					fmt.Sprintf("tracks/%d/versions/%d", duplicateTrack.ID, newVersion.ID), 1)
 				newPath := filepath.Join(newDir, fileName)
 
-				if err := os.MkdirAll(newDir, 0o755); err != nil {
-					return apperr.NewInternal("failed to create version directory", err)
-				}
-
-				if err := copyFileForProject(oldPath, newPath); err != nil {
+				if err := h.storage.CopyFile(ctx, oldPath, newPath); err != nil {
 					return apperr.NewInternal("failed to copy file", err)
 				}
 
@@ -444,6 +462,20 @@ func (h *ProjectsHandler) DuplicateProject(w http.ResponseWriter, r *http.Reques
 					return apperr.NewInternal("failed to create file record", err)
 				}
 
+				if file.CompactionStatus.Valid {
+					err = queries.CopyTrackFileCompaction(ctx, sqlc.CopyTrackFileCompactionParams{
+						StoredFormat:     file.StoredFormat,
+						OriginalCodec:    file.OriginalCodec,
+						OriginalSize:     file.OriginalSize,
+						PcmHash:          file.PcmHash,
+						CompactionStatus: file.CompactionStatus,
+						ID:               newFile.ID,
+					})
+					if err != nil {
+						return apperr.NewInternal("failed to create file record", err)
+					}
+				}
+
 				if file.Waveform.Valid {
 					err = queries.UpdateWaveform(ctx, sqlc.UpdateWaveformParams{
 						Waveform: file.Waveform,
@@ -537,70 +569,17 @@ func (h *ProjectsHandler) ExportProject(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	tracks, err := queries.ListPlainTracksByProject(ctx, sqlc.ListPlainTracksByProjectParams{
-		UserID:    int64(userID),
-		ProjectID: project.ID,
-	})
+	tracks, err := queries.ListTracksByProjectID(ctx, project.ID)
 	if err != nil {
 		return apperr.NewInternal("failed to list tracks", err)
 	}
 
 	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, sanitizeFilename(project.Name)))
-
-	// Create zip writer
-	zipWriter := zip.NewWriter(w)
-	defer zipWriter.Close()
-
-	if project.CoverArtPath.Valid {
-		// Cover art path is stored as absolute path in database
-		coverPath := project.CoverArtPath.String
-		if _, err := os.Stat(coverPath); err == nil {
-			ext := filepath.Ext(coverPath)
-			if err := addFileToZip(zipWriter, coverPath, "cover"+ext); err != nil {
-				slog.Debug("failed to add cover to zip", "error", err)
-			}
-		}
-	}
-
-	usedNames := make(map[string]int)
-
-	for _, track := range tracks {
-		if !track.ActiveVersionID.Valid {
-			continue
-		}
-
-		sourceFile, err := queries.GetTrackFile(ctx, sqlc.GetTrackFileParams{
-			VersionID: track.ActiveVersionID.Int64,
-			Quality:   "source",
-		})
-		if err != nil {
-			continue
-		}
-
-		filePath := sourceFile.FilePath
-		if _, err := os.Stat(filePath); err != nil {
-			continue
-		}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, service.ProjectArchiveName(project)))
 
-		baseName := sanitizeFilename(track.Title)
-		ext := filepath.Ext(sourceFile.FilePath)
-		if ext == "" {
-			ext = "." + sourceFile.Format
-		}
-		zipName := baseName + ext
-
-		if count, exists := usedNames[zipName]; exists {
-			usedNames[zipName] = count + 1
-			zipName = fmt.Sprintf("%s (%d)%s", baseName, count+1, ext)
-		} else {
-			usedNames[zipName] = 1
-		}
-
-		if err := addFileToZip(zipWriter, filePath, zipName); err != nil {
-			slog.Debug("failed to add track to zip", "track_title", track.Title, "error", err)
-			continue
-		}
+	// The response has started, so a failure can only cut the download short.
+	if err := service.WriteProjectArchive(ctx, w, queries, h.storage, project, tracks); err != nil {
+		slog.Debug("project export failed", "project_id", project.PublicID, "error", err)
 	}
 	return nil
 }
@@ -0,0 +1,409 @@
+package projects
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"ramiro-uziel/vault/internal/apperr"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/ids"
+	"ramiro-uziel/vault/internal/service"
+	"ramiro-uziel/vault/internal/sqlutil"
+	"ramiro-uziel/vault/internal/storage"
+	"ramiro-uziel/vault/internal/transcoding"
+)
+
+// mergeNotes folds the notes several users left into the one note the
+// importing user can have, most recent first as they were exported.
+func mergeNotes(notes []service.NoteExport) (content, authorName string, ok bool) {
+	if len(notes) == 0 {
+		return "", "", false
+	}
+	if len(notes) == 1 {
+		return notes[0].Content, notes[0].AuthorName, true
+	}
+	parts := make([]string, 0, len(notes))
+	for _, note := range notes {
+		parts = append(parts, note.AuthorName+":\n"+note.Content)
+	}
+	return strings.Join(parts, "\n\n"), notes[0].AuthorName, true
+}
+
+// importedSource is a version source saved by an import, transcoded once
+// the import is committed.
+type importedSource struct {
+	versionID     int64
+	path          string
+	trackPublicID string
+}
+
+// ImportProject recreates a project exported with ExportProject under the
+// current user. The project, its tracks and versions get new IDs; sharing
+// and folders are not carried over. Per-user notes become the importing
+// user's.
+func (h *ProjectsHandler) ImportProject(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("user not found in context")
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, service.MaxImportSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil { // 32MB memory, rest spills to disk
+		return apperr.NewBadRequest("failed to parse form")
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return apperr.NewBadRequest("no file provided")
+	}
+	defer file.Close()
+
+	zr, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		return apperr.NewBadRequest("invalid ZIP file")
+	}
+	archive, err := service.OpenProjectArchive(zr)
+	if err != nil {
+		return projectImportError(err, "failed to read project archive")
+	}
+	manifest := archive.Manifest
+
+	for _, track := range manifest.Tracks {
+		for _, version := range track.Versions {
+			if !transcoding.AllowedAudioExtensions[strings.ToLower(path.Ext(version.File))] {
+				return apperr.NewBadRequest(fmt.Sprintf("unsupported file format: %s", version.File))
+			}
+		}
+	}
+
+	ctx := r.Context()
+
+	projectPublicID, err := ids.NewPublicID()
+	if err != nil {
+		return apperr.NewInternal("failed to generate project id", err)
+	}
+
+	sources, err := h.importProject(ctx, int64(userID), projectPublicID, archive)
+	if err != nil {
+		// Files saved before the failure are not referenced by anything.
+		if cleanupErr := h.storage.DeleteProject(context.WithoutCancel(ctx), storage.DeleteProjectInput{ProjectPublicID: projectPublicID}); cleanupErr != nil {
+			slog.Warn("failed to clean up files of failed project import", "project_id", projectPublicID, "error", cleanupErr)
+		}
+		return projectImportError(err, "failed to import project")
+	}
+
+	if manifest.Project.Cover != "" {
+		if err := h.importCover(ctx, int64(userID), projectPublicID, archive); err != nil {
+			slog.Debug("failed to import project cover", "error", err)
+		}
+	}
+
+	if h.transcoder != nil {
+		for _, source := range sources {
+			err := h.transcoder.TranscodeVersion(ctx, transcoding.TranscodeVersionInput{
+				VersionID:      source.versionID,
+				SourceFilePath: source.path,
+				TrackPublicID:  source.trackPublicID,
+				UserID:         int64(userID),
+			})
+			if err != nil {
+				slog.Debug("failed to queue transcoding", "error", err)
+			}
+		}
+	}
+
+	project, err := h.service.GetProject(ctx, projectPublicID, int64(userID))
+	if err != nil {
+		return apperr.NewInternal("failed to fetch imported project", err)
+	}
+
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditProjectImport,
+		TargetType: "project",
+		TargetID:   projectPublicID,
+		After: map[string]any{
+			"name":     project.Name,
+			"tracks":   len(manifest.Tracks),
+			"versions": len(sources),
+		},
+	})
+
+	return httputil.CreatedResult(w, shared.ConvertProject(project))
+}
+
+// importProject creates the project, its tracks and versions in one
+// transaction and saves their sources. It returns the saved sources.
+func (h *ProjectsHandler) importProject(ctx context.Context, userID int64, projectPublicID string, archive *service.ProjectArchive) ([]importedSource, error) {
+	manifest := archive.Manifest
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := sqlc.New(tx)
+
+	var qualityOverride sql.NullString
+	if q := manifest.Project.QualityOverride; q != nil && (*q == "source" || *q == "lossless" || *q == "lossy") {
+		qualityOverride = sql.NullString{String: *q, Valid: true}
+	}
+
+	project, err := queries.CreateProject(ctx, sqlc.CreateProjectParams{
+		UserID:          userID,
+		Name:            manifest.Project.Name,
+		Description:     sqlutil.NullString(manifest.Project.Description),
+		QualityOverride: qualityOverride,
+		PublicID:        projectPublicID,
+		AuthorOverride:  sqlutil.NullString(manifest.Project.AuthorOverride),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create project: %w", err)
+	}
+
+	if manifest.Project.Notes != nil {
+		_, err = queries.UpdateProjectNotes(ctx, sqlc.UpdateProjectNotesParams{
+			Notes:           sqlutil.NullString(manifest.Project.Notes),
+			NotesAuthorName: sqlutil.NullString(manifest.Project.NotesAuthorName),
+			ID:              project.ID,
+			UserID:          userID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to set project notes: %w", err)
+		}
+	}
+	if content, author, ok := mergeNotes(manifest.Project.UserNotes); ok {
+		_, err = queries.UpsertProjectNote(ctx, sqlc.UpsertProjectNoteParams{
+			UserID:     userID,
+			ProjectID:  sql.NullInt64{Int64: project.ID, Valid: true},
+			Content:    content,
+			AuthorName: author,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to import project notes: %w", err)
+		}
+	}
+
+	tracks := slices.Clone(manifest.Tracks)
+	slices.SortStableFunc(tracks, func(a, b service.TrackExport) int {
+		return int(a.Order - b.Order)
+	})
+
+	var sources []importedSource
+	for order, trackExport := range tracks {
+		trackSources, err := h.importTrack(ctx, queries, userID, project, int64(order), trackExport, archive)
+		if err != nil {
+			return nil, fmt.Errorf("track %q: %w", trackExport.Title, err)
+		}
+		sources = append(sources, trackSources...)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to finalize import: %w", err)
+	}
+	return sources, nil
+}
+
+func (h *ProjectsHandler) importTrack(ctx context.Context, queries *sqlc.Queries, userID int64, project sqlc.Project, order int64, trackExport service.TrackExport, archive *service.ProjectArchive) ([]importedSource, error) {
+	trackPublicID, err := ids.NewPublicID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate track id: %w", err)
+	}
+
+	track, err := queries.CreateTrack(ctx, sqlc.CreateTrackParams{
+		UserID:    userID,
+		ProjectID: project.ID,
+		Title:     trackExport.Title,
+		Artist:    sqlutil.NullString(trackExport.Artist),
+		Album:     sqlutil.NullString(trackExport.Album),
+		PublicID:  trackPublicID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create track: %w", err)
+	}
+
+	var notesChanged any
+	if trackExport.Notes != nil {
+		notesChanged = true
+	}
+	_, err = queries.UpdateTrack(ctx, sqlc.UpdateTrackParams{
+		Title:           trackExport.Title,
+		ProjectID:       project.ID,
+		Key:             sqlutil.NullString(trackExport.Key),
+		Bpm:             sqlutil.NullInt64(trackExport.BPM),
+		Notes:           sqlutil.NullString(trackExport.Notes),
+		NotesAuthorName: sqlutil.NullString(trackExport.NotesAuthorName),
+		Column9:         notesChanged,
+		ID:              track.ID,
+		UserID:          userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set track metadata: %w", err)
+	}
+
+	err = queries.UpdateTrackOrder(ctx, sqlc.UpdateTrackOrderParams{
+		TrackOrder: order,
+		ID:         track.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set track order: %w", err)
+	}
+
+	if content, author, ok := mergeNotes(trackExport.UserNotes); ok {
+		_, err = queries.UpsertTrackNote(ctx, sqlc.UpsertTrackNoteParams{
+			UserID:     userID,
+			TrackID:    sql.NullInt64{Int64: track.ID, Valid: true},
+			Content:    content,
+			AuthorName: author,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to import track notes: %w", err)
+		}
+	}
+
+	// Without an active version marked, the last one is.
+	hasActive := slices.ContainsFunc(trackExport.Versions, func(v service.VersionExport) bool { return v.Active })
+
+	var sources []importedSource
+	var activeVersionID int64
+	for _, versionExport := range trackExport.Versions {
+		version, err := queries.CreateTrackVersion(ctx, sqlc.CreateTrackVersionParams{
+			TrackID:         track.ID,
+			VersionName:     versionExport.Name,
+			Notes:           sqlutil.NullString(versionExport.Notes),
+			DurationSeconds: sqlutil.NullFloat64(versionExport.DurationSeconds),
+			VersionOrder:    versionExport.Order,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create version: %w", err)
+		}
+		if versionExport.Active || !hasActive {
+			activeVersionID = version.ID
+		}
+
+		sourcePath, err := h.importSource(ctx, queries, project, track, version, versionExport, archive)
+		if err != nil {
+			return nil, fmt.Errorf("version %q: %w", versionExport.Name, err)
+		}
+		sources = append(sources, importedSource{
+			versionID:     version.ID,
+			path:          sourcePath,
+			trackPublicID: track.PublicID,
+		})
+	}
+
+	if activeVersionID != 0 {
+		err = queries.SetActiveVersion(ctx, sqlc.SetActiveVersionParams{
+			ActiveVersionID: sql.NullInt64{Int64: activeVersionID, Valid: true},
+			ID:              track.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to set active version: %w", err)
+		}
+	}
+	return sources, nil
+}
+
+// importSource saves a version's source from the archive and records it, as
+// an upload would. It returns where the source was saved.
+func (h *ProjectsHandler) importSource(ctx context.Context, queries *sqlc.Queries, project sqlc.Project, track sqlc.Track, version sqlc.TrackVersion, versionExport service.VersionExport, archive *service.ProjectArchive) (string, error) {
+	reader, err := archive.Open(versionExport.File)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", service.ErrInvalidProjectArchive, err)
+	}
+	defer reader.Close()
+
+	originalName := path.Base(versionExport.File)
+	if versionExport.OriginalFilename != nil && *versionExport.OriginalFilename != "" {
+		originalName = *versionExport.OriginalFilename
+	}
+
+	saveResult, err := h.storage.SaveTrackSource(ctx, storage.SaveTrackSourceInput{
+		ProjectPublicID: project.PublicID,
+		TrackID:         track.ID,
+		VersionID:       version.ID,
+		// The entry's extension is the format the file is in.
+		OriginalName: strings.TrimSuffix(originalName, path.Ext(originalName)) + path.Ext(versionExport.File),
+		Reader:       reader,
+	})
+	if errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrFormat) {
+		return "", fmt.Errorf("%w: %s: %v", service.ErrInvalidProjectArchive, versionExport.File, err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+
+	metadata, err := transcoding.ExtractStoredMetadata(ctx, h.storage, saveResult.Path)
+	if err != nil {
+		slog.Debug("failed to extract metadata", "error", err)
+		metadata = &transcoding.AudioMetadata{}
+	}
+
+	if versionExport.DurationSeconds == nil && metadata.Duration > 0 {
+		if err := queries.UpdateTrackVersionDuration(ctx, sqlc.UpdateTrackVersionDurationParams{
+			DurationSeconds: sql.NullFloat64{Float64: metadata.Duration, Valid: true},
+			ID:              version.ID,
+		}); err != nil {
+			slog.Debug("failed to persist version duration", "error", err)
+		}
+	}
+
+	var bitrate sql.NullInt64
+	if metadata.Bitrate > 0 {
+		bitrate = sql.NullInt64{Int64: int64(metadata.Bitrate), Valid: true}
+	}
+
+	_, err = queries.CreateTrackFile(ctx, sqlc.CreateTrackFileParams{
+		VersionID:         version.ID,
+		Quality:           "source",
+		FilePath:          saveResult.Path,
+		FileSize:          saveResult.Size,
+		Format:            saveResult.Format,
+		Bitrate:           bitrate,
+		ContentHash:       sql.NullString{String: saveResult.ContentHash, Valid: saveResult.ContentHash != ""},
+		TranscodingStatus: sql.NullString{String: "completed", Valid: true},
+		OriginalFilename:  sql.NullString{String: originalName, Valid: true},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create track file record: %w", err)
+	}
+	return saveResult.Path, nil
+}
+
+// importCover sets the cover of an imported project through the same
+// processing as an upload.
+func (h *ProjectsHandler) importCover(ctx context.Context, userID int64, projectPublicID string, archive *service.ProjectArchive) error {
+	cover := archive.Manifest.Project.Cover
+	reader, err := archive.Open(cover)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = h.service.UploadCover(ctx, service.UploadCoverInput{
+		UserID:   userID,
+		PublicID: projectPublicID,
+		Filename: cover,
+		Reader:   io.LimitReader(reader, maxCoverUploadSize),
+	})
+	return err
+}
+
+func projectImportError(err error, message string) error {
+	if errors.Is(err, service.ErrInvalidProjectArchive) {
+		return apperr.NewBadRequest(err.Error())
+	}
+	return apperr.NewInternal(message, err)
+}
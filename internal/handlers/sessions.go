@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/auth"
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/middleware"
+	"ramiro-uziel/vault/internal/service"
+)
+
+type SessionHandler struct {
+	db         *db.DB
+	sessions   service.SessionService
+	authConfig auth.Config
+	audit      service.AuditService
+}
+
+func NewSessionHandler(database *db.DB, sessions service.SessionService, authConfig auth.Config, audit service.AuditService) *SessionHandler {
+	return &SessionHandler{
+		db:         database,
+		sessions:   sessions,
+		authConfig: authConfig,
+		audit:      audit,
+	}
+}
+
+func (h *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	currentID, _ := middleware.GetSessionID(r.Context())
+	sessions, err := h.sessions.List(r.Context(), int64(userID), currentID)
+	if err != nil {
+		return apperr.NewInternal("failed to list sessions", err)
+	}
+	return httputil.OKResult(w, sessions)
+}
+
+// RevokeSession logs one of the user's sessions out. Revoking the current
+// session also clears its cookies, like logging out.
+func (h *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	sessionID, err := httputil.PathInt64(r, "id")
+	if err != nil {
+		return err
+	}
+
+	ctx := r.Context()
+
+	if err := h.sessions.Revoke(ctx, int64(userID), sessionID); err != nil {
+		return httputil.HandleDBError(err, "session not found", "failed to revoke session")
+	}
+
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditSessionRevoke,
+		TargetType: "session",
+		TargetID:   strconv.FormatInt(sessionID, 10),
+	})
+
+	if currentID, ok := middleware.GetSessionID(ctx); ok && currentID == sessionID {
+		httputil.ClearAuthCookies(w, h.authConfig)
+	}
+
+	httputil.NoContent(w)
+	return nil
+}
+
+// RevokeOtherSessions logs the user out everywhere but the current session.
+func (h *SessionHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	ctx := r.Context()
+
+	currentID, _ := middleware.GetSessionID(ctx)
+	revoked, err := h.sessions.RevokeOthers(ctx, int64(userID), currentID)
+	if err != nil {
+		return apperr.NewInternal("failed to revoke sessions", err)
+	}
+
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditSessionRevoke,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(userID),
+		After:      map[string]any{"revoked": revoked, "kept_session_id": currentID},
+	})
+
+	return httputil.OKResult(w, map[string]interface{}{
+		"revoked": revoked,
+	})
+}
+
+func (h *SessionHandler) AdminListSessions(w http.ResponseWriter, r *http.Request) error {
+	user, err := h.adminTarget(r)
+	if err != nil {
+		return err
+	}
+
+	currentID, _ := middleware.GetSessionID(r.Context())
+	sessions, err := h.sessions.List(r.Context(), user.ID, currentID)
+	if err != nil {
+		return apperr.NewInternal("failed to list sessions", err)
+	}
+	return httputil.OKResult(w, sessions)
+}
+
+func (h *SessionHandler) AdminRevokeSession(w http.ResponseWriter, r *http.Request) error {
+	user, err := h.adminTarget(r)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := httputil.PathInt64(r, "sessionId")
+	if err != nil {
+		return err
+	}
+
+	ctx := r.Context()
+
+	if err := h.sessions.Revoke(ctx, user.ID, sessionID); err != nil {
+		return httputil.HandleDBError(err, "session not found", "failed to revoke session")
+	}
+
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditUserSessions,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(user.ID, 10),
+		Before:     map[string]any{"username": user.Username},
+		After:      map[string]any{"session_id": sessionID},
+	})
+
+	httputil.NoContent(w)
+	return nil
+}
+
+// AdminRevokeAllSessions logs a user out of every session.
+func (h *SessionHandler) AdminRevokeAllSessions(w http.ResponseWriter, r *http.Request) error {
+	user, err := h.adminTarget(r)
+	if err != nil {
+		return err
+	}
+
+	ctx := r.Context()
+
+	revoked, err := h.sessions.RevokeOthers(ctx, user.ID, 0)
+	if err != nil {
+		return apperr.NewInternal("failed to revoke sessions", err)
+	}
+
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditUserSessions,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(user.ID, 10),
+		Before:     map[string]any{"username": user.Username},
+		After:      map[string]any{"revoked": revoked},
+	})
+
+	return httputil.OKResult(w, map[string]interface{}{
+		"revoked": revoked,
+	})
+}
+
+// adminTarget checks that the caller is an admin and returns the user named
+// in the path. Only the owner can see or end the owner's sessions.
+func (h *SessionHandler) adminTarget(r *http.Request) (*sqlc.User, error) {
+	adminID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return nil, apperr.NewUnauthorized("unauthorized")
+	}
+
+	ctx := r.Context()
+
+	admin, err := h.db.Queries.GetUserByID(ctx, int64(adminID))
+	if err != nil || !admin.IsAdmin {
+		return nil, apperr.NewForbidden("admin access required")
+	}
+
+	userID, err := httputil.PathInt64(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := h.db.Queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, apperr.NewNotFound("user not found")
+	}
+
+	if user.IsOwner && !admin.IsOwner {
+		return nil, apperr.NewForbidden("only owner can manage the owner's sessions")
+	}
+	return &user, nil
+}
@@ -0,0 +1,24 @@
+package shared
+
+import (
+	"net/http"
+
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
+)
+
+// AuditActor identifies who made r for the audit log: the signed-in user, if
+// any, and the client's address and user agent.
+func AuditActor(r *http.Request) service.AuditActor {
+	actor := service.AuditActor{
+		IP:        httputil.ClientIP(r),
+		UserAgent: r.Header.Get("User-Agent"),
+	}
+	if userID, err := httputil.RequireUserID(r); err == nil {
+		actor.UserID = int64(userID)
+	}
+	if username, err := httputil.RequireUsername(r); err == nil {
+		actor.Username = username
+	}
+	return actor
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 
+	"ramiro-uziel/vault/internal/db"
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
 	"ramiro-uziel/vault/internal/httputil"
 	"ramiro-uziel/vault/internal/models"
@@ -166,3 +167,42 @@ func BuildCoverURL(publicID string, updatedAt sql.NullTime) string {
 	}
 	return base
 }
+
+// ConvertTrackSearchRows converts track search results to responses
+func ConvertTrackSearchRows(rows []db.TrackSearchRow) []TrackSearchResponse {
+	result := make([]TrackSearchResponse, len(rows))
+	for i, row := range rows {
+		result[i] = TrackSearchResponse{
+			TrackListResponse: TrackListResponse{
+				TrackResponse: TrackResponse{
+					ID:                           row.ID,
+					UserID:                       row.UserID,
+					ProjectID:                    row.ProjectID,
+					PublicID:                     row.PublicID,
+					Title:                        row.Title,
+					Artist:                       httputil.NullStringToPtr(row.Artist),
+					Album:                        httputil.NullStringToPtr(row.Album),
+					Key:                          httputil.NullStringToPtr(row.Key),
+					Bpm:                          httputil.NullInt64ToPtr(row.Bpm),
+					Notes:                        httputil.NullStringToPtr(row.Notes),
+					NotesAuthorName:              httputil.NullStringToPtr(row.NotesAuthorName),
+					NotesUpdatedAt:               httputil.FormatNullTime(row.NotesUpdatedAt),
+					ActiveVersionID:              httputil.NullInt64ToPtr(row.ActiveVersionID),
+					ActiveVersionDurationSeconds: httputil.NullFloat64ToPtr(row.ActiveVersionDurationSeconds),
+					TrackOrder:                   row.TrackOrder,
+					VisibilityStatus:             row.VisibilityStatus,
+					CreatedAt:                    httputil.FormatNullTimeString(row.CreatedAt),
+					UpdatedAt:                    httputil.FormatNullTimeString(row.UpdatedAt),
+					Waveform:                     httputil.NullStringToPtr(row.Waveform),
+					LossyTranscodingStatus:       httputil.NullStringToPtr(row.LossyTranscodingStatus),
+				},
+				ActiveVersionName: &row.ActiveVersionName,
+				ProjectName:       &row.ProjectName,
+				IsShared:          row.IsShared == 1,
+			},
+			MatchedIn: httputil.StringToPtr(row.MatchedIn),
+			Snippet:   httputil.StringToPtr(row.Snippet),
+		}
+	}
+	return result
+}
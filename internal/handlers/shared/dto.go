@@ -79,6 +79,14 @@ type TrackListResponse struct {
 	CanDownload       *bool   `json:"can_download,omitempty"`
 }
 
+// TrackSearchResponse is a track search result. MatchedIn is one of "track",
+// "project", "version" or "note"; Snippet is HTML with the hits in <mark>.
+type TrackSearchResponse struct {
+	TrackListResponse
+	MatchedIn *string `json:"matched_in,omitempty"`
+	Snippet   *string `json:"snippet,omitempty"`
+}
+
 // UpdateTrackRequest for updating track metadata
 type UpdateTrackRequest struct {
 	Title           *string `json:"title,omitempty"`
@@ -0,0 +1,51 @@
+package shared
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
+)
+
+// LockedOut turns an error from LockoutService.Attempt into a response: 429
+// with a Retry-After header for a blocked key, and 500 for anything else.
+func LockedOut(w http.ResponseWriter, err error) error {
+	var locked *service.LockedOutError
+	if !errors.As(err, &locked) {
+		return apperr.NewInternal("failed to check failed attempts", err)
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	return apperr.New(http.StatusTooManyRequests, err, "too many failed attempts, try again later")
+}
+
+// FailedAttempt reports an attempt that LockoutService.Attempt counted and
+// whose credential turned out wrong. If it locked the key out, that goes in
+// the audit log for admins to see.
+func FailedAttempt(r *http.Request, audit service.AuditService, key service.LockoutKey, lockout *service.Lockout) {
+	if !lockout.Locked {
+		return
+	}
+
+	ctx := r.Context()
+	slog.WarnContext(ctx, "Locked out after repeated failed attempts",
+		"scope", key.Scope,
+		"subject", key.Subject,
+		"failures", lockout.Failures,
+		"until", *lockout.BlockedUntil,
+	)
+	audit.Record(ctx, service.AuditEvent{
+		Actor:      AuditActor(r),
+		Action:     service.AuditLockout,
+		TargetType: key.Scope,
+		TargetID:   key.Subject,
+		After: map[string]any{
+			"failures":      lockout.Failures,
+			"blocked_until": httputil.FormatTime(*lockout.BlockedUntil),
+		},
+	})
+}
@@ -0,0 +1,40 @@
+package shared
+
+import (
+	"errors"
+	"net/http"
+
+	"ramiro-uziel/vault/internal/apperr"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/storage"
+	"ramiro-uziel/vault/internal/transcoding"
+)
+
+// ServeSourceDownload sends a source file as an attachment named baseName.
+// Sources compacted to FLAC are decoded back to the format they were uploaded
+// in, unless the client asks for the stored file with ?format=flac.
+func ServeSourceDownload(w http.ResponseWriter, r *http.Request, store storage.Storage, file sqlc.TrackFile, baseName string) error {
+	if !transcoding.IsCompacted(file) || r.URL.Query().Get("format") == transcoding.StoredFormat(file) {
+		return httputil.ServeStoredFile(w, r, store, file.FilePath, httputil.ServeFileOptions{
+			ContentType:   "application/octet-stream",
+			Filename:      baseName + "." + transcoding.StoredFormat(file),
+			AllowRedirect: true,
+		})
+	}
+
+	stream, err := transcoding.OpenSource(r.Context(), store, file)
+	if errors.Is(err, storage.ErrNotExist) {
+		return apperr.NewNotFound("file not found")
+	}
+	if err != nil {
+		return apperr.NewInternal("failed to restore source file", err)
+	}
+	defer stream.Reader.Close()
+
+	httputil.ServeStream(w, r, file.FilePath, stream, httputil.ServeFileOptions{
+		ContentType: "application/octet-stream",
+		Filename:    baseName + "." + file.Format,
+	})
+	return nil
+}
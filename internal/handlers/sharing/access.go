@@ -12,8 +12,7 @@ import (
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
 	"ramiro-uziel/vault/internal/handlers"
 	"ramiro-uziel/vault/internal/httputil"
-
-	"golang.org/x/crypto/bcrypt"
+	"ramiro-uziel/vault/internal/service"
 )
 
 func (h *SharingHandler) AcceptShare(w http.ResponseWriter, r *http.Request) error {
@@ -50,8 +49,10 @@ func (h *SharingHandler) AcceptShare(w http.ResponseWriter, r *http.Request) err
 			if req.Password == "" {
 				return apperr.NewUnauthorized("password required")
 			}
-			if err := bcrypt.CompareHashAndPassword([]byte(trackShareToken.PasswordHash.String), []byte(req.Password)); err != nil {
+			if err := h.checkSharePassword(w, r, "track", trackShareToken.ID, trackShareToken.PasswordHash.String, req.Password); errors.Is(err, errSharePassword) {
 				return apperr.NewUnauthorized("invalid password")
+			} else if err != nil {
+				return err
 			}
 		}
 	} else if errors.Is(err, sql.ErrNoRows) {
@@ -69,8 +70,10 @@ func (h *SharingHandler) AcceptShare(w http.ResponseWriter, r *http.Request) err
 			if req.Password == "" {
 				return apperr.NewUnauthorized("password required")
 			}
-			if err := bcrypt.CompareHashAndPassword([]byte(projectShareToken.PasswordHash.String), []byte(req.Password)); err != nil {
+			if err := h.checkSharePassword(w, r, "project", projectShareToken.ID, projectShareToken.PasswordHash.String, req.Password); errors.Is(err, errSharePassword) {
 				return apperr.NewUnauthorized("invalid password")
+			} else if err != nil {
+				return err
 			}
 		}
 	} else {
@@ -200,6 +203,7 @@ func (h *SharingHandler) LeaveSharedProject(w http.ResponseWriter, r *http.Reque
 		}
 
 		slog.Info("Deleted track shares", "count", deletedCount, "projectID", project.ID, "userID", userID)
+		h.recordShare(r, service.AuditShareLeave, "project", projectPublicID, nil, map[string]any{"tracks": deletedCount})
 		return httputil.NoContentResult(w)
 	}
 
@@ -216,6 +220,8 @@ func (h *SharingHandler) LeaveSharedProject(w http.ResponseWriter, r *http.Reque
 		return apperr.NewInternal("failed to leave project", err)
 	}
 
+	h.recordShare(r, service.AuditShareLeave, "project", projectPublicID, nil, nil)
+
 	return httputil.NoContentResult(w)
 }
 
@@ -267,5 +273,6 @@ func (h *SharingHandler) LeaveSharedTrack(w http.ResponseWriter, r *http.Request
 	}
 
 	slog.Info("Successfully left shared track", "trackID", trackID, "userID", userID)
+	h.recordShare(r, service.AuditShareLeave, "track", trackIDStr, nil, nil)
 	return httputil.NoContentResult(w)
 }
@@ -4,12 +4,13 @@ import (
 	"archive/zip"
 	"io"
 	"net/http"
-	"os"
 	"time"
 
 	"ramiro-uziel/vault/internal/apperr"
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/handlers/shared"
 	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/transcoding"
 )
 
 func (h *SharingHandler) DownloadSharedTrack(w http.ResponseWriter, r *http.Request) error {
@@ -55,10 +56,7 @@ func (h *SharingHandler) DownloadSharedTrack(w http.ResponseWriter, r *http.Requ
 	if err != nil {
 		return apperr.NewNotFound("no audio file available")
 	}
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+track.Title+"."+trackFile.Format+"\"")
-	w.Header().Set("Content-Type", "application/octet-stream")
-	http.ServeFile(w, r, trackFile.FilePath)
-	return nil
+	return shared.ServeSourceDownload(w, r, h.storage, trackFile, track.Title)
 }
 
 func (h *SharingHandler) DownloadShared(w http.ResponseWriter, r *http.Request) error {
@@ -129,19 +127,19 @@ func (h *SharingHandler) DownloadShared(w http.ResponseWriter, r *http.Request)
 			continue
 		}
 
-		file, err := os.Open(trackFile.FilePath)
+		stream, err := transcoding.OpenSource(ctx, h.storage, trackFile)
 		if err != nil {
 			continue
 		}
 
 		zipEntry, err := zipWriter.Create(track.Title + "." + trackFile.Format)
 		if err != nil {
-			file.Close()
+			stream.Reader.Close()
 			continue
 		}
 
-		_, err = io.Copy(zipEntry, file)
-		file.Close()
+		_, err = io.Copy(zipEntry, stream.Reader)
+		stream.Reader.Close()
 		if err != nil {
 			continue
 		}
@@ -198,8 +196,5 @@ func (h *SharingHandler) DownloadSharedProjectTrack(w http.ResponseWriter, r *ht
 	if err != nil {
 		return apperr.NewNotFound("no audio file available")
 	}
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+track.Title+"."+trackFile.Format+"\"")
-	w.Header().Set("Content-Type", "application/octet-stream")
-	http.ServeFile(w, r, trackFile.FilePath)
-	return nil
+	return shared.ServeSourceDownload(w, r, h.storage, trackFile, track.Title)
 }
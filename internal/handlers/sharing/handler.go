@@ -3,23 +3,78 @@ package sharing
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 
 	"ramiro-uziel/vault/internal/db"
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/middleware"
+	"ramiro-uziel/vault/internal/service"
 	"ramiro-uziel/vault/internal/storage"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type SharingHandler struct {
-	db      *db.DB
-	storage storage.Storage
+	db       *db.DB
+	storage  storage.Storage
+	email    service.EmailService
+	lockouts service.LockoutService
+	audit    service.AuditService
 }
 
-func NewSharingHandler(database *db.DB, storageAdapter storage.Storage) *SharingHandler {
-	return &SharingHandler{db: database, storage: storageAdapter}
+func NewSharingHandler(database *db.DB, storageAdapter storage.Storage, email service.EmailService, lockouts service.LockoutService, audit service.AuditService) *SharingHandler {
+	return &SharingHandler{db: database, storage: storageAdapter, email: email, lockouts: lockouts, audit: audit}
+}
+
+// Targets of sharing audit events: shares with users, share links, and the
+// visibility of tracks and projects.
+const (
+	auditProjectShare = "project_share"
+	auditTrackShare   = "track_share"
+	auditProjectLink  = "project_link"
+	auditTrackLink    = "track_link"
+)
+
+func (h *SharingHandler) recordShare(r *http.Request, action, targetType, targetID string, before, after any) {
+	h.audit.Record(r.Context(), service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     before,
+		After:      after,
+	})
+}
+
+// notifyShared emails the users something was shared with. A failure is
+// logged rather than failing the share, which has already been made.
+func (h *SharingHandler) notifyShared(r *http.Request, kind, name, publicID string, recipientIDs []int64) {
+	if !h.email.Enabled() {
+		return
+	}
+
+	ctx := r.Context()
+	sharedBy, _ := middleware.GetUsername(ctx)
+	for _, recipientID := range recipientIDs {
+		if err := h.email.QueueShareNotification(ctx, service.ShareNotification{
+			RecipientID: recipientID,
+			SharedBy:    sharedBy,
+			Kind:        kind,
+			Name:        name,
+			PublicID:    publicID,
+		}); err != nil {
+			slog.ErrorContext(ctx, "Failed to queue share notification",
+				"kind", kind,
+				"public_id", publicID,
+				"recipient_id", recipientID,
+				"error", err,
+			)
+		}
+	}
 }
 
 func buildShareURL(r *http.Request, token string) string {
@@ -30,6 +85,31 @@ func buildShareURL(r *http.Request, token string) string {
 	return fmt.Sprintf("%s://%s/share/%s", scheme, r.Host, token)
 }
 
+// errSharePassword is returned by checkSharePassword for a wrong password.
+var errSharePassword = errors.New("invalid share password")
+
+// checkSharePassword compares password with a share link's, counting wrong
+// ones against the link. While too many have been tried it fails with a
+// 429 error without comparing. kind is "track" or "project".
+func (h *SharingHandler) checkSharePassword(w http.ResponseWriter, r *http.Request, kind string, shareTokenID int64, hash, password string) error {
+	ctx := r.Context()
+	key := service.ShareLockout(kind, shareTokenID)
+	lockout, err := h.lockouts.Attempt(ctx, key)
+	if err != nil {
+		return shared.LockedOut(w, err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		shared.FailedAttempt(r, h.audit, key, lockout)
+		return errSharePassword
+	}
+
+	if err := h.lockouts.Succeed(ctx, key); err != nil {
+		slog.WarnContext(ctx, "Failed to clear failed share password attempts", "share", key.Subject, "error", err)
+	}
+	return nil
+}
+
 func hashSharePassword(password *string) (sql.NullString, error) {
 	if password == nil || *password == "" {
 		return sql.NullString{Valid: false}, nil
@@ -59,3 +139,13 @@ func (h *SharingHandler) canManageTrackShares(ctx context.Context, track sqlc.Tr
 
 	return false, nil
 }
+
+// linkSummary describes a share link's settings for the audit log.
+func linkSummary(allowEditing, allowDownloads, hasPassword bool, visibility string) map[string]any {
+	return map[string]any{
+		"allow_editing":   allowEditing,
+		"allow_downloads": allowDownloads,
+		"has_password":    hasPassword,
+		"visibility":      visibility,
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"ramiro-uziel/vault/internal/apperr"
@@ -13,8 +15,7 @@ import (
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
 	"ramiro-uziel/vault/internal/handlers"
 	"ramiro-uziel/vault/internal/httputil"
-
-	"golang.org/x/crypto/bcrypt"
+	"ramiro-uziel/vault/internal/service"
 )
 
 func (h *SharingHandler) CreateProjectShareToken(w http.ResponseWriter, r *http.Request) error {
@@ -85,6 +86,8 @@ func (h *SharingHandler) CreateProjectShareToken(w http.ResponseWriter, r *http.
 		return apperr.NewInternal("failed to create share token", err)
 	}
 
+	h.recordShare(r, service.AuditShareCreate, auditProjectLink, strconv.FormatInt(shareToken.ID, 10), nil, linkSummary(shareToken.AllowEditing, shareToken.AllowDownloads, shareToken.PasswordHash.Valid, shareToken.VisibilityType))
+
 	response := &handlers.ProjectShareTokenResponse{
 		ID:                 shareToken.ID,
 		Token:              shareToken.Token,
@@ -120,8 +123,10 @@ func (h *SharingHandler) ValidateProjectShareToken(w http.ResponseWriter, r *htt
 		if password == "" {
 			return httputil.OKResult(w, map[string]interface{}{"valid": false, "password_required": true})
 		}
-		if err := bcrypt.CompareHashAndPassword([]byte(shareToken.PasswordHash.String), []byte(password)); err != nil {
+		if err := h.checkSharePassword(w, r, "project", shareToken.ID, shareToken.PasswordHash.String, password); errors.Is(err, errSharePassword) {
 			return httputil.OKResult(w, map[string]interface{}{"valid": false, "password_required": true, "error": "invalid password"})
+		} else if err != nil {
+			return err
 		}
 	}
 
@@ -233,6 +238,10 @@ func (h *SharingHandler) UpdateProjectShareToken(w http.ResponseWriter, r *http.
 		return apperr.NewInternal("failed to update token", err)
 	}
 
+	h.recordShare(r, service.AuditShareUpdate, auditProjectLink, strconv.FormatInt(tokenID, 10),
+		linkSummary(existingToken.AllowEditing, existingToken.AllowDownloads, existingToken.PasswordHash.Valid, existingToken.VisibilityType),
+		linkSummary(updatedToken.AllowEditing, updatedToken.AllowDownloads, updatedToken.PasswordHash.Valid, updatedToken.VisibilityType))
+
 	project, err := h.db.GetProject(ctx, sqlc.GetProjectParams{ID: updatedToken.ProjectID, UserID: int64(userID)})
 	if err != nil {
 		return apperr.NewInternal("failed to get project", err)
@@ -275,6 +284,7 @@ func (h *SharingHandler) DeleteProjectShareToken(w http.ResponseWriter, r *http.
 	if err != nil {
 		return apperr.NewInternal("failed to delete token", err)
 	}
+	h.recordShare(r, service.AuditShareRevoke, auditProjectLink, strconv.FormatInt(tokenID, 10), nil, nil)
 	return httputil.NoContentResult(w)
 }
 
@@ -286,12 +296,14 @@ func (h *SharingHandler) validateProjectShare(w http.ResponseWriter, r *http.Req
 				PasswordRequired: true,
 			})
 		}
-		if err := bcrypt.CompareHashAndPassword([]byte(shareToken.PasswordHash.String), []byte(password)); err != nil {
+		if err := h.checkSharePassword(w, r, "project", shareToken.ID, shareToken.PasswordHash.String, password); errors.Is(err, errSharePassword) {
 			return httputil.OKResult(w, &handlers.ValidateShareResponse{
 				Valid:            false,
 				PasswordRequired: true,
 				Error:            "invalid password",
 			})
+		} else if err != nil {
+			return err
 		}
 	}
 
@@ -322,7 +334,7 @@ func (h *SharingHandler) validateProjectShare(w http.ResponseWriter, r *http.Req
 		"token_type", "project",
 		"project_id", shareToken.ProjectID,
 		"has_password", shareToken.PasswordHash.Valid,
-		"ip", r.RemoteAddr,
+		"ip", httputil.ClientIP(r),
 	)
 
 	var coverURL *string
@@ -11,6 +11,7 @@ import (
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
 	"ramiro-uziel/vault/internal/httputil"
 	"ramiro-uziel/vault/internal/storage"
+	"ramiro-uziel/vault/internal/transcoding"
 )
 
 func (h *SharingHandler) StreamSharedTrack(w http.ResponseWriter, r *http.Request) error {
@@ -65,8 +66,10 @@ func (h *SharingHandler) StreamSharedTrack(w http.ResponseWriter, r *http.Reques
 		return apperr.NewInternal("failed to query track file", err)
 	}
 
-	http.ServeFile(w, r, trackFile.FilePath)
-	return nil
+	return httputil.ServeStoredFile(w, r, h.storage, trackFile.FilePath, httputil.ServeFileOptions{
+		ContentType:   httputil.AudioContentType(transcoding.StoredFormat(trackFile)),
+		AllowRedirect: true,
+	})
 }
 
 func (h *SharingHandler) StreamSharedProjectTrack(w http.ResponseWriter, r *http.Request) error {
@@ -127,8 +130,10 @@ func (h *SharingHandler) StreamSharedProjectTrack(w http.ResponseWriter, r *http
 		return apperr.NewInternal("failed to query track file", err)
 	}
 
-	http.ServeFile(w, r, trackFile.FilePath)
-	return nil
+	return httputil.ServeStoredFile(w, r, h.storage, trackFile.FilePath, httputil.ServeFileOptions{
+		ContentType:   httputil.AudioContentType(transcoding.StoredFormat(trackFile)),
+		AllowRedirect: true,
+	})
 }
 
 func (h *SharingHandler) GetSharedProjectCover(w http.ResponseWriter, r *http.Request) error {
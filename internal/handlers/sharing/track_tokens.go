@@ -16,9 +16,8 @@ import (
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
 	"ramiro-uziel/vault/internal/handlers"
 	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
 	"ramiro-uziel/vault/internal/sqlutil"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 func (h *SharingHandler) CreateShareToken(w http.ResponseWriter, r *http.Request) error {
@@ -110,6 +109,8 @@ func (h *SharingHandler) CreateShareToken(w http.ResponseWriter, r *http.Request
 		return apperr.NewInternal("failed to create share token", err)
 	}
 
+	h.recordShare(r, service.AuditShareCreate, auditTrackLink, strconv.FormatInt(shareToken.ID, 10), nil, linkSummary(shareToken.AllowEditing, shareToken.AllowDownloads, shareToken.PasswordHash.Valid, shareToken.VisibilityType))
+
 	shareURL := buildShareURL(r, token)
 
 	response := &handlers.ShareTokenResponse{
@@ -166,12 +167,14 @@ func (h *SharingHandler) validateTrackShare(w http.ResponseWriter, r *http.Reque
 				PasswordRequired: true,
 			})
 		}
-		if err := bcrypt.CompareHashAndPassword([]byte(shareToken.PasswordHash.String), []byte(password)); err != nil {
+		if err := h.checkSharePassword(w, r, "track", shareToken.ID, shareToken.PasswordHash.String, password); errors.Is(err, errSharePassword) {
 			return httputil.OKResult(w, &handlers.ValidateShareResponse{
 				Valid:            false,
 				PasswordRequired: true,
 				Error:            "invalid password",
 			})
+		} else if err != nil {
+			return err
 		}
 	}
 
@@ -226,7 +229,7 @@ func (h *SharingHandler) validateTrackShare(w http.ResponseWriter, r *http.Reque
 		"token_type", "track",
 		"track_id", trackDetails.ID,
 		"has_password", shareToken.PasswordHash.Valid,
-		"ip", r.RemoteAddr,
+		"ip", httputil.ClientIP(r),
 	)
 
 	var coverURL *string
@@ -425,6 +428,10 @@ func (h *SharingHandler) UpdateShareToken(w http.ResponseWriter, r *http.Request
 		return apperr.NewInternal("failed to update token", err)
 	}
 
+	h.recordShare(r, service.AuditShareUpdate, auditTrackLink, strconv.FormatInt(tokenID, 10),
+		linkSummary(existingToken.AllowEditing, existingToken.AllowDownloads, existingToken.PasswordHash.Valid, existingToken.VisibilityType),
+		linkSummary(updatedToken.AllowEditing, updatedToken.AllowDownloads, updatedToken.PasswordHash.Valid, updatedToken.VisibilityType))
+
 	track, err := h.db.GetTrackByID(ctx, updatedToken.TrackID)
 	if err != nil {
 		return apperr.NewInternal("failed to get track", err)
@@ -475,5 +482,7 @@ func (h *SharingHandler) DeleteShareToken(w http.ResponseWriter, r *http.Request
 		return apperr.NewInternal("failed to delete token", err)
 	}
 
+	h.recordShare(r, service.AuditShareRevoke, auditTrackLink, strconv.FormatInt(tokenID, 10), nil, nil)
+
 	return httputil.NoContentResult(w)
 }
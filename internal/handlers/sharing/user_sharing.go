@@ -11,6 +11,7 @@ import (
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
 	"ramiro-uziel/vault/internal/handlers/shared"
 	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
 )
 
 type ShareWithUsersRequest struct {
@@ -46,7 +47,7 @@ func (h *SharingHandler) ShareProjectWithUsers(w http.ResponseWriter, r *http.Re
 		return apperr.NewForbidden("unauthorized")
 	}
 
-	var successCount int
+	var sharedWith []int64
 	var lastErr error
 	for _, userToShareWithID := range req.UserIDs {
 		_, err := h.db.Queries.CreateUserProjectShare(ctx, sqlc.CreateUserProjectShareParams{
@@ -60,16 +61,20 @@ func (h *SharingHandler) ShareProjectWithUsers(w http.ResponseWriter, r *http.Re
 			lastErr = err
 			continue
 		}
-		successCount++
+		sharedWith = append(sharedWith, userToShareWithID)
 	}
-	if successCount == 0 {
+	if len(sharedWith) == 0 {
 		if lastErr != nil {
 			return apperr.NewInternal("failed to share with users", lastErr)
 		}
 		return apperr.NewBadRequest("no users were shared with")
 	}
+	h.recordShare(r, service.AuditShareCreate, auditProjectShare, publicID, nil, map[string]any{
+		"user_ids": req.UserIDs, "can_edit": req.CanEdit, "can_download": req.CanDownload,
+	})
+	h.notifyShared(r, "project", project.Name, project.PublicID, sharedWith)
 	return httputil.CreatedResult(w, map[string]interface{}{
-		"message": fmt.Sprintf("project shared with %d user(s)", successCount),
+		"message": fmt.Sprintf("project shared with %d user(s)", len(sharedWith)),
 		"project": project,
 	})
 }
@@ -105,7 +110,7 @@ func (h *SharingHandler) ShareTrackWithUsers(w http.ResponseWriter, r *http.Requ
 		return apperr.NewForbidden("unauthorized")
 	}
 
-	var successCount int
+	var sharedWith []int64
 	var lastErr error
 	for _, userToShareWithID := range req.UserIDs {
 		_, err := h.db.Queries.CreateUserTrackShare(ctx, sqlc.CreateUserTrackShareParams{
@@ -119,16 +124,20 @@ func (h *SharingHandler) ShareTrackWithUsers(w http.ResponseWriter, r *http.Requ
 			lastErr = err
 			continue
 		}
-		successCount++
+		sharedWith = append(sharedWith, userToShareWithID)
 	}
-	if successCount == 0 {
+	if len(sharedWith) == 0 {
 		if lastErr != nil {
 			return apperr.NewInternal("failed to share with users", lastErr)
 		}
 		return apperr.NewBadRequest("no users were shared with")
 	}
+	h.recordShare(r, service.AuditShareCreate, auditTrackShare, publicID, nil, map[string]any{
+		"user_ids": req.UserIDs, "can_edit": req.CanEdit, "can_download": req.CanDownload,
+	})
+	h.notifyShared(r, "track", track.Title, track.PublicID, sharedWith)
 	return httputil.CreatedResult(w, map[string]interface{}{
-		"message": fmt.Sprintf("track shared with %d user(s)", successCount),
+		"message": fmt.Sprintf("track shared with %d user(s)", len(sharedWith)),
 		"track":   track,
 	})
 }
@@ -286,6 +295,7 @@ func (h *SharingHandler) RevokeProjectShare(w http.ResponseWriter, r *http.Reque
 	if err != nil {
 		return apperr.NewInternal("failed to revoke share", err)
 	}
+	h.recordShare(r, service.AuditShareRevoke, auditProjectShare, strconv.FormatInt(shareID, 10), nil, nil)
 	return httputil.NoContentResult(w)
 }
 
@@ -318,6 +328,9 @@ func (h *SharingHandler) RevokeTrackShare(w http.ResponseWriter, r *http.Request
 	if err := h.db.Queries.DeleteUserTrackShareByShareID(ctx, shareID); err != nil {
 		return apperr.NewInternal("failed to revoke share", err)
 	}
+	h.recordShare(r, service.AuditShareRevoke, auditTrackShare, strconv.FormatInt(shareID, 10), map[string]any{
+		"track_id": track.PublicID, "shared_to": share.SharedTo, "can_edit": share.CanEdit, "can_download": share.CanDownload,
+	}, nil)
 	return httputil.NoContentResult(w)
 }
 
@@ -404,6 +417,9 @@ func (h *SharingHandler) UpdateProjectSharePermissions(w http.ResponseWriter, r
 	if err != nil {
 		return apperr.NewInternal("failed to update share", err)
 	}
+	h.recordShare(r, service.AuditShareUpdate, auditProjectShare, shareIDStr, nil, map[string]any{
+		"shared_to": share.SharedTo, "can_edit": share.CanEdit, "can_download": share.CanDownload,
+	})
 	return httputil.OKResult(w, share)
 }
 
@@ -450,5 +466,10 @@ func (h *SharingHandler) UpdateTrackSharePermissions(w http.ResponseWriter, r *h
 	if err != nil {
 		return apperr.NewInternal("failed to update share", err)
 	}
+	h.recordShare(r, service.AuditShareUpdate, auditTrackShare, shareIDStr, map[string]any{
+		"shared_to": existingShare.SharedTo, "can_edit": existingShare.CanEdit, "can_download": existingShare.CanDownload,
+	}, map[string]any{
+		"shared_to": share.SharedTo, "can_edit": share.CanEdit, "can_download": share.CanDownload,
+	})
 	return httputil.OKResult(w, share)
 }
@@ -11,6 +11,7 @@ import (
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
 	"ramiro-uziel/vault/internal/handlers"
 	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
 )
 
 func (h *SharingHandler) UpdateTrackVisibility(w http.ResponseWriter, r *http.Request) error {
@@ -72,6 +73,10 @@ func (h *SharingHandler) UpdateTrackVisibility(w http.ResponseWriter, r *http.Re
 		return apperr.NewInternal("failed to update track visibility", err)
 	}
 
+	h.recordShare(r, service.AuditVisibility, "track", trackID,
+		map[string]any{"visibility": track.VisibilityStatus},
+		map[string]any{"visibility": req.VisibilityStatus, "allow_editing": req.AllowEditing, "allow_downloads": req.AllowDownloads, "has_password": passwordHash.Valid})
+
 	return httputil.OKResult(w, updatedTrack)
 }
 
@@ -122,5 +127,8 @@ func (h *SharingHandler) UpdateProjectVisibility(w http.ResponseWriter, r *http.
 		return apperr.NewInternal("failed to update project visibility", err)
 	}
 
+	h.recordShare(r, service.AuditVisibility, "project", projectID, nil,
+		map[string]any{"visibility": req.VisibilityStatus, "allow_editing": req.AllowEditing, "allow_downloads": req.AllowDownloads, "has_password": passwordHash.Valid})
+
 	return httputil.OKResult(w, project)
 }
@@ -5,16 +5,19 @@ import (
 
 	"ramiro-uziel/vault/internal/apperr"
 	"ramiro-uziel/vault/internal/db"
+	"ramiro-uziel/vault/internal/handlers/shared"
 	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
 )
 
 type StatsHandler struct {
 	db        *db.DB
 	commitSHA string
+	audit     service.AuditService
 }
 
-func NewStatsHandler(database *db.DB, commitSHA string) *StatsHandler {
-	return &StatsHandler{db: database, commitSHA: commitSHA}
+func NewStatsHandler(database *db.DB, commitSHA string, audit service.AuditService) *StatsHandler {
+	return &StatsHandler{db: database, commitSHA: commitSHA, audit: audit}
 }
 
 func (h *StatsHandler) GetStorageStats(w http.ResponseWriter, r *http.Request) error {
@@ -119,11 +122,23 @@ func (h *StatsHandler) UpdateInstanceName(w http.ResponseWriter, r *http.Request
 
 	ctx := r.Context()
 
+	previous, err := h.db.GetInstanceSettings(ctx)
+	if err != nil {
+		return apperr.NewInternal("failed to get instance info", err)
+	}
+
 	settings, err := h.db.UpdateInstanceName(ctx, req.Name)
 	if err != nil {
 		return apperr.NewInternal("failed to update instance name", err)
 	}
 
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:  shared.AuditActor(r),
+		Action: service.AuditInstanceRename,
+		Before: map[string]any{"name": previous.Name},
+		After:  map[string]any{"name": settings.Name},
+	})
+
 	var createdAt *string
 	if settings.CreatedAt.Valid {
 		formatted := settings.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00")
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/db"
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
+)
+
+type StorageHandler struct {
+	db         *db.DB
+	fsck       service.FsckService
+	compaction service.CompactionService
+}
+
+func NewStorageHandler(database *db.DB, fsck service.FsckService, compaction service.CompactionService) *StorageHandler {
+	return &StorageHandler{
+		db:         database,
+		fsck:       fsck,
+		compaction: compaction,
+	}
+}
+
+func (h *StorageHandler) requireAdmin(r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	user, err := h.db.Queries.GetUserByID(r.Context(), int64(userID))
+	if err != nil || !user.IsAdmin {
+		return apperr.NewForbidden("admin access required")
+	}
+	return nil
+}
+
+// RunFsck checks storage against the database. Repairs are opt-in through the
+// request body; an empty body performs a read-only check.
+func (h *StorageHandler) RunFsck(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	ctx := r.Context()
+
+	var opts service.FsckOptions
+	if r.ContentLength != 0 {
+		var err error
+		opts, err = httputil.DecodeJSON[service.FsckOptions](r)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return apperr.NewBadRequest("invalid request body")
+		}
+	}
+
+	report, err := h.fsck.Check(ctx, opts)
+	if err != nil {
+		return apperr.NewInternal("failed to check storage", err)
+	}
+
+	return httputil.OKResult(w, report)
+}
+
+func (h *StorageHandler) GetCompaction(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	status, err := h.compaction.Status(r.Context())
+	if err != nil {
+		return apperr.NewInternal("failed to get compaction status", err)
+	}
+
+	return httputil.OKResult(w, status)
+}
+
+// UpdateCompaction turns the lossless compaction policy on or off. Sources
+// already compacted stay compacted.
+func (h *StorageHandler) UpdateCompaction(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	req, err := httputil.DecodeJSON[UpdateCompactionRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	ctx := r.Context()
+
+	if req.Enabled != nil {
+		if err := h.compaction.SetEnabled(ctx, *req.Enabled); err != nil {
+			return apperr.NewInternal("failed to update compaction policy", err)
+		}
+	}
+	if req.RetryFailed {
+		if err := h.compaction.RetryFailed(ctx); err != nil {
+			return apperr.NewInternal("failed to requeue sources", err)
+		}
+	}
+
+	status, err := h.compaction.Status(ctx)
+	if err != nil {
+		return apperr.NewInternal("failed to get compaction status", err)
+	}
+
+	return httputil.OKResult(w, status)
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
 
 	"ramiro-uziel/vault/internal/apperr"
@@ -13,14 +12,17 @@ import (
 	"ramiro-uziel/vault/internal/handlers/tracks"
 	"ramiro-uziel/vault/internal/httputil"
 	"ramiro-uziel/vault/internal/middleware"
+	"ramiro-uziel/vault/internal/storage"
+	"ramiro-uziel/vault/internal/transcoding"
 )
 
 type StreamingHandler struct {
-	db *db.DB
+	db      *db.DB
+	storage storage.Storage
 }
 
-func NewStreamingHandler(database *db.DB) *StreamingHandler {
-	return &StreamingHandler{db: database}
+func NewStreamingHandler(database *db.DB, storageAdapter storage.Storage) *StreamingHandler {
+	return &StreamingHandler{db: database, storage: storageAdapter}
 }
 
 func (h *StreamingHandler) StreamTrack(w http.ResponseWriter, r *http.Request) error {
@@ -86,8 +88,7 @@ func (h *StreamingHandler) StreamTrack(w http.ResponseWriter, r *http.Request) e
 		return apperr.NewInternal(fmt.Sprintf("failed to find track file: %v", err), err)
 	}
 
-	h.streamFile(w, r, file)
-	return nil
+	return h.streamFile(w, r, file)
 }
 
 func (h *StreamingHandler) resolveQuality(ctx context.Context, userID, trackID int64, requestedQuality string) string {
@@ -151,33 +152,9 @@ func (h *StreamingHandler) getTrackFile(ctx context.Context, versionID int64, qu
 	})
 }
 
-func (h *StreamingHandler) streamFile(w http.ResponseWriter, r *http.Request, file *sqlc.TrackFile) {
-	f, err := os.Open(file.FilePath)
-	if err != nil {
-		http.Error(w, "failed to open file", http.StatusInternalServerError)
-		return
-	}
-	defer f.Close()
-
-	stat, err := f.Stat()
-	if err != nil {
-		http.Error(w, "failed to stat file", http.StatusInternalServerError)
-		return
-	}
-
-	contentType := "audio/mpeg"
-	switch file.Format {
-	case "flac":
-		contentType = "audio/flac"
-	case "mp3":
-		contentType = "audio/mpeg"
-	case "m4a":
-		contentType = "audio/mp4"
-	case "wav":
-		contentType = "audio/wav"
-	}
-
-	w.Header().Set("Content-Type", contentType)
-
-	http.ServeContent(w, r, file.FilePath, stat.ModTime(), f)
+func (h *StreamingHandler) streamFile(w http.ResponseWriter, r *http.Request, file *sqlc.TrackFile) error {
+	return httputil.ServeStoredFile(w, r, h.storage, file.FilePath, httputil.ServeFileOptions{
+		ContentType:   httputil.AudioContentType(transcoding.StoredFormat(*file)),
+		AllowRedirect: true,
+	})
 }
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/auth"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/middleware"
+	"ramiro-uziel/vault/internal/service"
+	"ramiro-uziel/vault/internal/storage"
+)
+
+type TakeoutHandler struct {
+	takeouts service.TakeoutService
+	wsHub    *WSHub
+	config   auth.Config
+	audit    service.AuditService
+}
+
+func NewTakeoutHandler(takeouts service.TakeoutService, wsHub *WSHub, config auth.Config, audit service.AuditService) *TakeoutHandler {
+	return &TakeoutHandler{
+		takeouts: takeouts,
+		wsHub:    wsHub,
+		config:   config,
+		audit:    audit,
+	}
+}
+
+type TakeoutResponse struct {
+	ID          int64   `json:"id"`
+	Status      string  `json:"status"`
+	CreatedAt   string  `json:"created_at"`
+	FinishedAt  *string `json:"finished_at,omitempty"`
+	ExpiresAt   *string `json:"expires_at,omitempty"`
+	SizeBytes   *int64  `json:"size_bytes,omitempty"`
+	Error       *string `json:"error,omitempty"`
+	DownloadURL *string `json:"download_url,omitempty"`
+}
+
+// StartTakeout starts building an archive of the user's data. The build
+// runs in the background; a takeout_update message over the websocket
+// announces the outcome, with the download URL once it is ready.
+func (h *TakeoutHandler) StartTakeout(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	takeout, err := h.takeouts.Start(r.Context(), int64(userID))
+	if errors.Is(err, service.ErrTakeoutBuilding) {
+		return apperr.NewConflict("a takeout is already being built")
+	}
+	if err != nil {
+		return apperr.NewInternal("failed to start takeout", err)
+	}
+
+	h.audit.Record(r.Context(), service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditTakeout,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(userID),
+	})
+
+	// The build outlives the request.
+	go func(ctx context.Context) {
+		finished, _ := h.takeouts.Build(ctx, takeout)
+		h.wsHub.SendToUser(finished.UserID, WSMessage{
+			Type:    "takeout_update",
+			Payload: h.convertTakeout(finished),
+		})
+	}(context.WithoutCancel(r.Context()))
+
+	httputil.WriteJSON(w, http.StatusAccepted, h.convertTakeout(takeout))
+	return nil
+}
+
+// GetTakeout returns the user's latest takeout.
+func (h *TakeoutHandler) GetTakeout(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	takeout, err := h.takeouts.Latest(r.Context(), int64(userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperr.NewNotFound("no takeout yet")
+	}
+	if err != nil {
+		return apperr.NewInternal("failed to get takeout", err)
+	}
+	return httputil.OKResult(w, h.convertTakeout(takeout))
+}
+
+// DownloadTakeout serves a ready takeout. It is reached through the signed
+// URL takeout responses carry, so it works without a session.
+func (h *TakeoutHandler) DownloadTakeout(w http.ResponseWriter, r *http.Request) error {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		if !middleware.SignedURLValid(r.Context()) {
+			return apperr.NewUnauthorized("unauthorized")
+		}
+		signedUserID := r.URL.Query().Get("user_id")
+		if signedUserID == "" {
+			return apperr.NewUnauthorized("unauthorized")
+		}
+		parsed, err := strconv.Atoi(signedUserID)
+		if err != nil {
+			return apperr.NewBadRequest("invalid user_id")
+		}
+		userID = parsed
+	}
+
+	id, err := httputil.PathInt64(r, "id")
+	if err != nil {
+		return err
+	}
+
+	takeout, err := h.takeouts.Get(r.Context(), int64(userID), id)
+	if err != nil {
+		return httputil.HandleDBError(err, "takeout not found", "failed to get takeout")
+	}
+
+	file, err := h.takeouts.Open(takeout)
+	if err != nil {
+		return apperr.NewNotFound("takeout is not available")
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return apperr.NewInternal("failed to read takeout", err)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httputil.ServeStream(w, r, info.Name(), &storage.FileStream{
+		Reader:  file,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, httputil.ServeFileOptions{
+		ContentType: "application/zip",
+		Filename:    fmt.Sprintf("vault-takeout-%s.zip", takeout.CreatedAt.Format("2006-01-02")),
+	})
+	return nil
+}
+
+func (h *TakeoutHandler) convertTakeout(takeout sqlc.Takeout) TakeoutResponse {
+	response := TakeoutResponse{
+		ID:         takeout.ID,
+		Status:     takeout.Status,
+		CreatedAt:  httputil.FormatTime(takeout.CreatedAt),
+		FinishedAt: httputil.FormatNullTime(takeout.FinishedAt),
+		ExpiresAt:  httputil.FormatNullTime(takeout.ExpiresAt),
+		SizeBytes:  httputil.NullInt64ToPtr(takeout.SizeBytes),
+		Error:      httputil.NullStringToPtr(takeout.Error),
+	}
+
+	// The link lasts as long as the archive.
+	if takeout.Status == service.TakeoutReady && takeout.ExpiresAt.Valid {
+		if ttl := time.Until(takeout.ExpiresAt.Time); ttl > 0 {
+			query := url.Values{}
+			query.Set("user_id", strconv.FormatInt(takeout.UserID, 10))
+			path := fmt.Sprintf("/api/takeout/%d/download", takeout.ID)
+			if downloadURL, err := middleware.BuildSignedURL("", path, query, h.config.SignedURLSecret, ttl); err == nil && downloadURL != "" {
+				response.DownloadURL = &downloadURL
+			}
+		}
+	}
+	return response
+}
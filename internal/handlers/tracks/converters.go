@@ -124,40 +124,6 @@ func convertTracksWithDetails(rows []sqlc.ListTracksWithDetailsByProjectIDRow) [
 	return convertTracksWithDetailsWithPermissions(rows, 0, true, nil)
 }
 
-func convertSearchTracksRows(rows []sqlc.SearchTracksAccessibleByUserRow) []shared.TrackListResponse {
-	result := make([]shared.TrackListResponse, len(rows))
-	for i, row := range rows {
-		result[i] = shared.TrackListResponse{
-			TrackResponse: shared.TrackResponse{
-				ID:                           row.ID,
-				UserID:                       row.UserID,
-				ProjectID:                    row.ProjectID,
-				PublicID:                     row.PublicID,
-				Title:                        row.Title,
-				Artist:                       httputil.NullStringToPtr(row.Artist),
-				Album:                        httputil.NullStringToPtr(row.Album),
-				Key:                          httputil.NullStringToPtr(row.Key),
-				Bpm:                          httputil.NullInt64ToPtr(row.Bpm),
-				Notes:                        httputil.NullStringToPtr(row.Notes),
-				NotesAuthorName:              httputil.NullStringToPtr(row.NotesAuthorName),
-				NotesUpdatedAt:               httputil.FormatNullTime(row.NotesUpdatedAt),
-				ActiveVersionID:              httputil.NullInt64ToPtr(row.ActiveVersionID),
-				ActiveVersionDurationSeconds: httputil.NullFloat64ToPtr(row.ActiveVersionDurationSeconds),
-				TrackOrder:                   row.TrackOrder,
-				VisibilityStatus:             row.VisibilityStatus,
-				CreatedAt:                    httputil.FormatNullTimeString(row.CreatedAt),
-				UpdatedAt:                    httputil.FormatNullTimeString(row.UpdatedAt),
-				Waveform:                     httputil.NullStringToPtr(row.Waveform),
-				LossyTranscodingStatus:       httputil.NullStringToPtr(row.LossyTranscodingStatus),
-			},
-			ActiveVersionName: &row.ActiveVersionName,
-			ProjectName:       &row.ProjectName,
-			IsShared:          row.IsShared == 1,
-		}
-	}
-	return result
-}
-
 func convertTracksWithDetailsWithPermissions(rows []sqlc.ListTracksWithDetailsByProjectIDRow, userID int64, isProjectOwner bool, projectShare *sqlc.UserProjectShare) []shared.TrackListResponse {
 	result := make([]shared.TrackListResponse, len(rows))
 	for i, row := range rows {
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"ramiro-uziel/vault/internal/apperr"
 	"ramiro-uziel/vault/internal/db"
@@ -22,17 +23,19 @@ type TracksHandler struct {
 	db         *db.DB
 	storage    storage.Storage
 	transcoder Transcoder
+	audit      service.AuditService
 }
 
 type Transcoder interface {
 	TranscodeVersion(ctx context.Context, input transcoding.TranscodeVersionInput) error
 }
 
-func NewTracksHandler(database *db.DB, storageAdapter storage.Storage, transcoder Transcoder) *TracksHandler {
+func NewTracksHandler(database *db.DB, storageAdapter storage.Storage, transcoder Transcoder, audit service.AuditService) *TracksHandler {
 	return &TracksHandler{
 		db:         database,
 		storage:    storageAdapter,
 		transcoder: transcoder,
+		audit:      audit,
 	}
 }
 
@@ -179,36 +182,6 @@ func (h *TracksHandler) ListTracks(w http.ResponseWriter, r *http.Request) error
 	return httputil.OKResult(w, response)
 }
 
-func (h *TracksHandler) SearchTracks(w http.ResponseWriter, r *http.Request) error {
-	userID, err := httputil.RequireUserID(r)
-	if err != nil {
-		return apperr.NewUnauthorized("user not found in context")
-	}
-
-	query := r.URL.Query().Get("q")
-	limitStr := r.URL.Query().Get("limit")
-	limit := int64(100)
-	if limitStr != "" {
-		if parsedLimit, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-
-	ctx := r.Context()
-
-	dbTracks, err := h.db.Queries.SearchTracksAccessibleByUser(ctx, sqlc.SearchTracksAccessibleByUserParams{
-		UserID:      int64(userID),
-		SearchQuery: query,
-		LimitCount:  limit,
-	})
-	if err != nil {
-		return apperr.NewInternal("failed to search tracks", err)
-	}
-	response := convertSearchTracksRows(dbTracks)
-
-	return httputil.OKResult(w, response)
-}
-
 func (h *TracksHandler) GetTrack(w http.ResponseWriter, r *http.Request) error {
 	userID, err := httputil.RequireUserID(r)
 	if err != nil {
@@ -443,29 +416,26 @@ func (h *TracksHandler) DeleteTrack(w http.ResponseWriter, r *http.Request) erro
 		return apperr.NewForbidden("editing not allowed for this track")
 	}
 
-	project, err := queries.GetProjectByID(ctx, track.ProjectID)
-	if err := httputil.HandleDBError(err, "project not found", "failed to fetch project"); err != nil {
-		return err
-	}
-
-	err = queries.DeleteTrack(ctx, sqlc.DeleteTrackParams{
-		ID:     track.ID,
-		UserID: track.UserID,
+	// Files stay in storage until the track is purged from the trash.
+	err = queries.TrashTrack(ctx, sqlc.TrashTrackParams{
+		DeletedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		ID:        track.ID,
 	})
 	if err != nil {
 		return apperr.NewInternal("failed to delete track", err)
 	}
 
-	if err := h.storage.DeleteTrack(ctx, storage.DeleteTrackInput{
-		ProjectPublicID: project.PublicID,
-		TrackID:         track.ID,
-	}); err != nil {
-		return apperr.NewInternal("failed to delete track files", err)
-	}
-
 	if err := tx.Commit(); err != nil {
 		return apperr.NewInternal("failed to finalize deletion", err)
 	}
 
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditTrackDelete,
+		TargetType: "track",
+		TargetID:   publicID,
+		Before:     map[string]any{"title": track.Title, "project_id": track.ProjectID},
+	})
+
 	return httputil.NoContentResult(w)
 }
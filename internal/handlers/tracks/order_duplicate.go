@@ -4,9 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -166,11 +164,7 @@ func (h *TracksHandler) DuplicateTrack(w http.ResponseWriter, r *http.Request) e
 				fmt.Sprintf("tracks/%d/versions/%d", duplicateTrack.ID, newVersion.ID), 1)
 			newPath := filepath.Join(newDir, fileName)
 
-			if err := os.MkdirAll(newDir, 0o755); err != nil {
-				return apperr.NewInternal("failed to create version directory", err)
-			}
-
-			if err := copyFile(oldPath, newPath); err != nil {
+			if err := h.storage.CopyFile(ctx, oldPath, newPath); err != nil {
 				return apperr.NewInternal("failed to copy file", err)
 			}
 
@@ -189,6 +183,20 @@ func (h *TracksHandler) DuplicateTrack(w http.ResponseWriter, r *http.Request) e
 				return apperr.NewInternal("failed to create file record", err)
 			}
 
+			if file.CompactionStatus.Valid {
+				err = queries.CopyTrackFileCompaction(ctx, sqlc.CopyTrackFileCompactionParams{
+					StoredFormat:     file.StoredFormat,
+					OriginalCodec:    file.OriginalCodec,
+					OriginalSize:     file.OriginalSize,
+					PcmHash:          file.PcmHash,
+					CompactionStatus: file.CompactionStatus,
+					ID:               newFile.ID,
+				})
+				if err != nil {
+					return apperr.NewInternal("failed to create file record", err)
+				}
+			}
+
 			if file.Waveform.Valid {
 				err = queries.UpdateWaveform(ctx, sqlc.UpdateWaveformParams{
 					Waveform: file.Waveform,
@@ -217,24 +225,3 @@ func (h *TracksHandler) DuplicateTrack(w http.ResponseWriter, r *http.Request) e
 
 	return httputil.CreatedResult(w, convertTrack(duplicateTrack))
 }
-
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer destFile.Close()
-
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		return err
-	}
-
-	return destFile.Sync()
-}
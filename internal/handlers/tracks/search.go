@@ -0,0 +1,150 @@
+package tracks
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/db"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/httputil"
+)
+
+func (h *TracksHandler) SearchTracks(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("user not found in context")
+	}
+
+	query := r.URL.Query()
+	filters, err := parseTrackFilters(query)
+	if err != nil {
+		return err
+	}
+
+	limit := int64(100)
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.ParseInt(limitStr, 10, 64); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	dbTracks, next, err := h.db.SearchTracks(r.Context(), db.TrackSearch{
+		UserID:       int64(userID),
+		TrackFilters: filters,
+		Sort:         query.Get("sort"),
+		Order:        query.Get("order"),
+		Cursor:       query.Get("cursor"),
+		Limit:        limit,
+	})
+	if errors.Is(err, db.ErrInvalidSearch) {
+		return apperr.NewBadRequest(err.Error())
+	}
+	if err != nil {
+		return apperr.NewInternal("failed to search tracks", err)
+	}
+
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
+	return httputil.OKResult(w, shared.ConvertTrackSearchRows(dbTracks))
+}
+
+// parseTrackFilters reads search filters from query parameters. Keys may be
+// repeated or comma-separated; dates are YYYY-MM-DD or RFC 3339.
+func parseTrackFilters(query url.Values) (db.TrackFilters, error) {
+	filters := db.TrackFilters{
+		Text:          query.Get("q"),
+		CompatibleKey: query.Get("compatible_key"),
+		Owner:         query.Get("owner"),
+		Project:       query.Get("project"),
+	}
+
+	for _, value := range query["key"] {
+		for _, key := range strings.Split(value, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				filters.Keys = append(filters.Keys, key)
+			}
+		}
+	}
+
+	var err error
+	if filters.BPMMin, err = parseIntParam(query, "bpm_min"); err != nil {
+		return filters, err
+	}
+	if filters.BPMMax, err = parseIntParam(query, "bpm_max"); err != nil {
+		return filters, err
+	}
+	if filters.FolderID, err = parseIntParam(query, "folder_id"); err != nil {
+		return filters, err
+	}
+	if filters.DurationMin, err = parseFloatParam(query, "duration_min"); err != nil {
+		return filters, err
+	}
+	if filters.DurationMax, err = parseFloatParam(query, "duration_max"); err != nil {
+		return filters, err
+	}
+	if filters.CreatedAfter, err = parseTimeParam(query, "created_after"); err != nil {
+		return filters, err
+	}
+	if filters.CreatedBefore, err = parseTimeParam(query, "created_before"); err != nil {
+		return filters, err
+	}
+	if filters.UpdatedAfter, err = parseTimeParam(query, "updated_after"); err != nil {
+		return filters, err
+	}
+	if filters.UpdatedBefore, err = parseTimeParam(query, "updated_before"); err != nil {
+		return filters, err
+	}
+
+	if sharedStr := query.Get("shared"); sharedStr != "" {
+		shared, err := strconv.ParseBool(sharedStr)
+		if err != nil {
+			return filters, apperr.NewBadRequest("invalid shared")
+		}
+		filters.Shared = &shared
+	}
+
+	return filters, nil
+}
+
+func parseIntParam(query url.Values, name string) (*int64, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, apperr.NewBadRequest("invalid " + name)
+	}
+	return &n, nil
+}
+
+func parseFloatParam(query url.Values, name string) (*float64, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, apperr.NewBadRequest("invalid " + name)
+	}
+	return &f, nil
+}
+
+func parseTimeParam(query url.Values, name string) (*time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, apperr.NewBadRequest("invalid " + name)
+}
@@ -3,6 +3,7 @@ package tracks
 import (
 	"database/sql"
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
@@ -158,30 +159,37 @@ func (h *TracksHandler) UploadTrack(w http.ResponseWriter, r *http.Request) erro
 		return apperr.NewInternal("failed to set active version", err)
 	}
 
+	reader := io.Reader(file)
+	originalName := header.Filename
+	if transcoding.IsVideoExtension(ext) {
+		wavPath, err := transcoding.ExtractAudioFromVideo(file, ext)
+		if err != nil {
+			return apperr.NewInternal("failed to extract audio from video", err)
+		}
+		defer os.Remove(wavPath)
+
+		wavFile, err := os.Open(wavPath)
+		if err != nil {
+			return apperr.NewInternal("failed to open extracted audio", err)
+		}
+		defer wavFile.Close()
+
+		reader = wavFile
+		originalName = strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename)) + ".wav"
+	}
+
 	saveResult, err := h.storage.SaveTrackSource(r.Context(), storage.SaveTrackSourceInput{
 		ProjectPublicID: project.PublicID,
 		TrackID:         track.ID,
 		VersionID:       version.ID,
-		OriginalName:    header.Filename,
-		Reader:          file,
+		OriginalName:    originalName,
+		Reader:          reader,
 	})
 	if err != nil {
 		return apperr.NewInternal("failed to save file", err)
 	}
 
-	if transcoding.IsVideoExtension(ext) {
-		wavPath, err := transcoding.ExtractAudioToWAV(saveResult.Path)
-		if err != nil {
-			return apperr.NewInternal("failed to extract audio from video", err)
-		}
-		saveResult.Path = wavPath
-		saveResult.Format = "wav"
-		if fi, err := os.Stat(wavPath); err == nil {
-			saveResult.Size = fi.Size()
-		}
-	}
-
-	metadata, err := transcoding.ExtractMetadata(saveResult.Path)
+	metadata, err := transcoding.ExtractStoredMetadata(ctx, h.storage, saveResult.Path)
 	if err != nil {
 		slog.Debug("failed to extract metadata", "error", err)
 		metadata = &transcoding.AudioMetadata{}
@@ -211,7 +219,7 @@ func (h *TracksHandler) UploadTrack(w http.ResponseWriter, r *http.Request) erro
 		FileSize:          saveResult.Size,
 		Format:            format,
 		Bitrate:           bitrate,
-		ContentHash:       sql.NullString{},
+		ContentHash:       sql.NullString{String: saveResult.ContentHash, Valid: saveResult.ContentHash != ""},
 		TranscodingStatus: sql.NullString{String: "completed", Valid: true},
 		OriginalFilename:  sql.NullString{String: header.Filename, Valid: true},
 	})
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
+)
+
+type TrashHandler struct {
+	trash service.TrashService
+	audit service.AuditService
+}
+
+func NewTrashHandler(trash service.TrashService, audit service.AuditService) *TrashHandler {
+	return &TrashHandler{trash: trash, audit: audit}
+}
+
+// ListTrash returns the user's trashed items, most recently deleted first.
+func (h *TrashHandler) ListTrash(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	contents, err := h.trash.List(r.Context(), int64(userID))
+	if err != nil {
+		return apperr.NewInternal("failed to list trash", err)
+	}
+
+	items := make([]TrashItemResponse, 0, len(contents.Projects)+len(contents.Tracks)+len(contents.Versions)+len(contents.Folders))
+	for _, project := range contents.Projects {
+		items = append(items, h.trashItem(service.TrashProject, project.PublicID, project.Name, project.DeletedAt))
+	}
+	for _, track := range contents.Tracks {
+		item := h.trashItem(service.TrashTrack, track.PublicID, track.Title, track.DeletedAt)
+		item.ProjectPublicID = &track.ProjectPublicID
+		item.ProjectName = &track.ProjectName
+		items = append(items, item)
+	}
+	for _, version := range contents.Versions {
+		item := h.trashItem(service.TrashVersion, strconv.FormatInt(version.ID, 10), version.VersionName, version.DeletedAt)
+		item.ProjectPublicID = &version.ProjectPublicID
+		item.TrackPublicID = &version.TrackPublicID
+		item.TrackTitle = &version.TrackTitle
+		items = append(items, item)
+	}
+	for _, folder := range contents.Folders {
+		items = append(items, h.trashItem(service.TrashFolder, strconv.FormatInt(folder.ID, 10), folder.Name, folder.DeletedAt))
+	}
+
+	// Timestamps are formatted as RFC 3339 in UTC, so they sort as strings.
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedAt > items[j].DeletedAt
+	})
+
+	return httputil.OKResult(w, items)
+}
+
+func (h *TrashHandler) trashItem(itemType, id, name string, deletedAt sql.NullTime) TrashItemResponse {
+	item := TrashItemResponse{
+		Type:      itemType,
+		ID:        id,
+		Name:      name,
+		DeletedAt: httputil.FormatNullTimeString(deletedAt),
+	}
+	if retention := h.trash.Retention(); retention > 0 && deletedAt.Valid {
+		purgeAt := httputil.FormatTime(deletedAt.Time.Add(retention))
+		item.PurgeAt = &purgeAt
+	}
+	return item
+}
+
+func (h *TrashHandler) RestoreItem(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	err = h.trash.Restore(r.Context(), int64(userID), r.PathValue("type"), r.PathValue("id"))
+	if err := trashError(err, "failed to restore item"); err != nil {
+		return err
+	}
+
+	h.audit.Record(r.Context(), service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditTrashRestore,
+		TargetType: r.PathValue("type"),
+		TargetID:   r.PathValue("id"),
+	})
+
+	return httputil.NoContentResult(w)
+}
+
+// PurgeItem permanently deletes a single trashed item and its files.
+func (h *TrashHandler) PurgeItem(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	err = h.trash.Purge(r.Context(), int64(userID), r.PathValue("type"), r.PathValue("id"))
+	if err := trashError(err, "failed to delete item"); err != nil {
+		return err
+	}
+
+	h.audit.Record(r.Context(), service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditTrashPurge,
+		TargetType: r.PathValue("type"),
+		TargetID:   r.PathValue("id"),
+	})
+
+	return httputil.NoContentResult(w)
+}
+
+// EmptyTrash permanently deletes everything in the user's trash.
+func (h *TrashHandler) EmptyTrash(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	if err := h.trash.Empty(r.Context(), int64(userID)); err != nil {
+		return apperr.NewInternal("failed to empty trash", err)
+	}
+
+	h.audit.Record(r.Context(), service.AuditEvent{
+		Actor:  shared.AuditActor(r),
+		Action: service.AuditTrashEmpty,
+	})
+
+	return httputil.NoContentResult(w)
+}
+
+func trashError(err error, internalMsg string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, service.ErrUnknownTrashType):
+		return apperr.NewBadRequest("invalid item type")
+	case errors.Is(err, service.ErrTrashParentDeleted):
+		return apperr.NewBadRequest("the item's project or track is in the trash; restore it first")
+	}
+	return httputil.HandleDBError(err, "item not found in trash", internalMsg)
+}
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/handlers/shared"
+	"ramiro-uziel/vault/internal/httputil"
+	authsvc "ramiro-uziel/vault/internal/service"
+)
+
+type LoginTwoFactorRequest struct {
+	Challenge string `json:"challenge"`
+	Code      string `json:"code"`
+}
+
+type TwoFactorSetupRequest struct {
+	Password string `json:"password"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// LoginTwoFactor completes a login the password step answered with a
+// challenge. The code is a TOTP code or a recovery code; for a user who is
+// enrolling because the instance requires it, it is the first code from the
+// new secret, and the response carries their recovery codes.
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	req, err := httputil.DecodeJSON[LoginTwoFactorRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Challenge == "" || req.Code == "" {
+		return apperr.NewBadRequest("challenge and code are required")
+	}
+
+	ctx := r.Context()
+
+	// Wrong codes count against the account like wrong passwords, so new
+	// challenges don't give unlimited guesses, and a lockout also stops
+	// challenges started before it.
+	challengeUserID, challengeErr := h.twoFactor.ChallengeUser(ctx, req.Challenge)
+	var lockoutKey *authsvc.LockoutKey
+	var lockout *authsvc.Lockout
+	if challengeErr == nil {
+		if user, err := h.authService.Me(ctx, challengeUserID); err == nil {
+			key := authsvc.AccountLockout(user.Username)
+			if lockout, err = h.lockouts.Attempt(ctx, key); err != nil {
+				return shared.LockedOut(w, err)
+			}
+			lockoutKey = &key
+		}
+	}
+
+	completion, err := h.twoFactor.CompleteLogin(ctx, req.Challenge, req.Code)
+	if err == authsvc.ErrInvalidTwoFactorCode && challengeErr == nil {
+		actor := shared.AuditActor(r)
+		actor.UserID = challengeUserID
+		h.audit.Record(ctx, authsvc.AuditEvent{
+			Actor:  actor,
+			Action: authsvc.AuditLoginFailed,
+			After:  map[string]any{"step": "two_factor"},
+		})
+		if lockoutKey != nil {
+			shared.FailedAttempt(r, h.audit, *lockoutKey, lockout)
+		}
+	}
+	if err != nil {
+		return mapAuthError(err)
+	}
+
+	user, err := h.authService.Me(ctx, completion.UserID)
+	if err != nil {
+		return apperr.NewInternal("failed to get user", err)
+	}
+
+	method := "totp"
+	if completion.UsedRecoveryCode {
+		method = "recovery_code"
+	}
+	if err := h.startSession(w, r, user, map[string]any{"second_factor": method}); err != nil {
+		return err
+	}
+
+	response := map[string]interface{}{
+		"user": serviceUserToResponse(user),
+	}
+	if completion.RecoveryCodes != nil {
+		h.recordTwoFactorEnabled(r, user.ID)
+		response["recovery_codes"] = completion.RecoveryCodes
+	}
+	return httputil.OKResult(w, response)
+}
+
+// LoginTwoFactorSetup starts enrollment for a user whose login challenge
+// requires it. It is only open to users without a second factor.
+func (h *AuthHandler) LoginTwoFactorSetup(w http.ResponseWriter, r *http.Request) error {
+	req, err := httputil.DecodeJSON[LoginTwoFactorRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Challenge == "" {
+		return apperr.NewBadRequest("challenge is required")
+	}
+
+	ctx := r.Context()
+
+	userID, err := h.twoFactor.ChallengeUser(ctx, req.Challenge)
+	if err != nil {
+		return mapAuthError(err)
+	}
+
+	status, err := h.twoFactor.Status(ctx, userID)
+	if err != nil {
+		return apperr.NewInternal("failed to get two-factor status", err)
+	}
+	if status.Enabled || status.Passkeys > 0 {
+		return mapAuthError(authsvc.ErrTwoFactorEnabled)
+	}
+
+	enrollment, err := h.twoFactor.BeginEnrollment(ctx, userID)
+	if err != nil {
+		return apperr.NewInternal("failed to start enrollment", err)
+	}
+	return httputil.OKResult(w, enrollment)
+}
+
+func (h *AuthHandler) GetTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	status, err := h.twoFactor.Status(r.Context(), int64(userID))
+	if err != nil {
+		return apperr.NewInternal("failed to get two-factor status", err)
+	}
+	return httputil.OKResult(w, status)
+}
+
+// SetupTwoFactor generates a new TOTP secret for the user. It replaces their
+// current one only once ConfirmTwoFactor accepts a code from it.
+func (h *AuthHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	req, err := httputil.DecodeJSON[TwoFactorSetupRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Password == "" {
+		return apperr.NewBadRequest("password is required")
+	}
+
+	ctx := r.Context()
+
+	if err := h.verifyPassword(r, req.Password); err != nil {
+		return err
+	}
+
+	enrollment, err := h.twoFactor.BeginEnrollment(ctx, int64(userID))
+	if err != nil {
+		return apperr.NewInternal("failed to start enrollment", err)
+	}
+	return httputil.OKResult(w, enrollment)
+}
+
+func (h *AuthHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	req, err := httputil.DecodeJSON[TwoFactorCodeRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Code == "" {
+		return apperr.NewBadRequest("code is required")
+	}
+
+	codes, err := h.twoFactor.ConfirmEnrollment(r.Context(), int64(userID), req.Code)
+	if err != nil {
+		return mapAuthError(err)
+	}
+
+	h.recordTwoFactorEnabled(r, int64(userID))
+
+	return httputil.OKResult(w, map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes, used or not.
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	req, err := httputil.DecodeJSON[TwoFactorCodeRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Code == "" {
+		return apperr.NewBadRequest("code is required")
+	}
+
+	ctx := r.Context()
+
+	if err := h.twoFactor.Verify(ctx, int64(userID), req.Code); err != nil {
+		return mapAuthError(err)
+	}
+
+	codes, err := h.twoFactor.RegenerateRecoveryCodes(ctx, int64(userID))
+	if err != nil {
+		return mapAuthError(err)
+	}
+
+	h.audit.Record(ctx, authsvc.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     authsvc.AuditRecoveryCodes,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(userID),
+	})
+
+	return httputil.OKResult(w, map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor removes the user's TOTP second factor. It takes both their
+// password and a code, and is refused while the instance requires 2FA and the
+// user has no passkey to fall back on.
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	req, err := httputil.DecodeJSON[DisableTwoFactorRequest](r)
+	if err != nil {
+		return apperr.NewBadRequest("invalid request body")
+	}
+
+	if req.Password == "" || req.Code == "" {
+		return apperr.NewBadRequest("password and code are required")
+	}
+
+	ctx := r.Context()
+
+	status, err := h.twoFactor.Status(ctx, int64(userID))
+	if err != nil {
+		return apperr.NewInternal("failed to get two-factor status", err)
+	}
+	if status.Required && status.Passkeys == 0 {
+		return mapAuthError(authsvc.ErrTwoFactorRequired)
+	}
+
+	if err := h.verifyPassword(r, req.Password); err != nil {
+		return err
+	}
+	if err := h.twoFactor.Verify(ctx, int64(userID), req.Code); err != nil {
+		return mapAuthError(err)
+	}
+
+	if err := h.twoFactor.Disable(ctx, int64(userID)); err != nil {
+		return apperr.NewInternal("failed to disable two-factor authentication", err)
+	}
+
+	h.audit.Record(ctx, authsvc.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     authsvc.AuditTwoFactorDisable,
+		TargetType: "user",
+		TargetID:   strconv.Itoa(userID),
+	})
+
+	httputil.NoContent(w)
+	return nil
+}
+
+func (h *AuthHandler) verifyPassword(r *http.Request, password string) error {
+	userID, err := httputil.RequireUserID(r)
+	if err != nil {
+		return apperr.NewUnauthorized("unauthorized")
+	}
+
+	user, err := h.authService.Me(r.Context(), int64(userID))
+	if err != nil {
+		return apperr.NewInternal("failed to get user", err)
+	}
+
+	if _, err := h.authService.VerifyCredentials(r.Context(), user.Username, password); err != nil {
+		return apperr.NewUnauthorized("incorrect password")
+	}
+	return nil
+}
+
+func (h *AuthHandler) recordTwoFactorEnabled(r *http.Request, userID int64) {
+	actor := shared.AuditActor(r)
+	actor.UserID = userID
+	h.audit.Record(r.Context(), authsvc.AuditEvent{
+		Actor:      actor,
+		Action:     authsvc.AuditTwoFactorEnable,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(userID, 10),
+	})
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"time"
 
+	"ramiro-uziel/vault/internal/db"
 	"ramiro-uziel/vault/internal/handlers/shared"
 	"ramiro-uziel/vault/internal/models"
 	"ramiro-uziel/vault/internal/service"
@@ -36,6 +37,20 @@ type ResetPasswordRequest struct {
 	ResetToken string `json:"reset_token"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ChangeEmailRequest struct {
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+type ConfirmEmailRequest struct {
+	Token string `json:"token"`
+}
+
 type DeleteSelfRequest struct {
 	Password string `json:"password"`
 }
@@ -75,20 +90,26 @@ type ProjectResponse = shared.ProjectResponse
 type CreateFolderRequest struct {
 	Name     string `json:"name"`
 	ParentID *int64 `json:"parent_id,omitempty"`
+	// SmartQuery makes this a smart folder whose contents are the tracks
+	// matching the search.
+	SmartQuery *db.TrackFilters `json:"smart_query,omitempty"`
 }
 
 type UpdateFolderRequest struct {
-	Name     *string `json:"name,omitempty"`
-	ParentID *int64  `json:"parent_id,omitempty"`
+	Name       *string          `json:"name,omitempty"`
+	ParentID   *int64           `json:"parent_id,omitempty"`
+	SmartQuery *db.TrackFilters `json:"smart_query,omitempty"`
 }
 
 type FolderResponse struct {
-	ID          int64  `json:"id"`
-	Name        string `json:"name"`
-	ParentID    *int64 `json:"parent_id,omitempty"`
-	FolderOrder int64  `json:"folder_order"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID          int64            `json:"id"`
+	Name        string           `json:"name"`
+	ParentID    *int64           `json:"parent_id,omitempty"`
+	FolderOrder int64            `json:"folder_order"`
+	SmartQuery  *db.TrackFilters `json:"smart_query,omitempty"`
+	TrackCount  *int64           `json:"track_count,omitempty"` // Smart folders only
+	CreatedAt   string           `json:"created_at"`
+	UpdatedAt   string           `json:"updated_at"`
 }
 
 type FolderContentsResponse struct {
@@ -96,6 +117,7 @@ type FolderContentsResponse struct {
 	Folders      []FolderResponse             `json:"folders"`
 	Projects     []shared.ProjectResponse     `json:"projects"`
 	SharedTracks []shared.SharedTrackResponse `json:"shared_tracks"`
+	Tracks       []shared.TrackSearchResponse `json:"tracks,omitempty"` // Smart folders only
 }
 
 type MoveProjectRequest struct {
@@ -136,6 +158,7 @@ type VersionWithMetadata struct {
 	SourceFormat           *string  `json:"source_format,omitempty"`
 	SourceBitrate          *int64   `json:"source_bitrate,omitempty"`
 	SourceOriginalFilename *string  `json:"source_original_filename,omitempty"`
+	SourceStoredFormat     *string  `json:"source_stored_format,omitempty"`
 	LossyTranscodingStatus *string  `json:"lossy_transcoding_status,omitempty"`
 	Waveform               *string  `json:"waveform,omitempty"`
 }
@@ -220,6 +243,11 @@ type UpdateInstanceNameRequest struct {
 	Name string `json:"name"`
 }
 
+type UpdateCompactionRequest struct {
+	Enabled     *bool `json:"enabled,omitempty"`
+	RetryFailed bool  `json:"retry_failed,omitempty"`
+}
+
 type OrganizeItemRequest struct {
 	FolderID    *int64 `json:"folder_id,omitempty"`
 	CustomOrder *int64 `json:"custom_order,omitempty"`
@@ -337,3 +365,17 @@ type ProjectShareTokenResponse struct {
 	UpdatedAt          interface{} `json:"updated_at,omitempty"`
 	ShareURL           string      `json:"share_url"`
 }
+
+// TrashItemResponse is one entry of the trash listing. ID is the public ID
+// for projects and tracks, and the numeric ID for versions and folders.
+type TrashItemResponse struct {
+	Type            string  `json:"type"`
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	ProjectPublicID *string `json:"project_public_id,omitempty"`
+	ProjectName     *string `json:"project_name,omitempty"`
+	TrackPublicID   *string `json:"track_public_id,omitempty"`
+	TrackTitle      *string `json:"track_title,omitempty"`
+	DeletedAt       string  `json:"deleted_at"`
+	PurgeAt         *string `json:"purge_at,omitempty"`
+}
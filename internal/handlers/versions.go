@@ -10,12 +10,15 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"ramiro-uziel/vault/internal/apperr"
 	"ramiro-uziel/vault/internal/db"
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/handlers/shared"
 	"ramiro-uziel/vault/internal/handlers/tracks"
 	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/service"
 	"ramiro-uziel/vault/internal/storage"
 	"ramiro-uziel/vault/internal/transcoding"
 )
@@ -24,13 +27,15 @@ type VersionsHandler struct {
 	db         *db.DB
 	storage    storage.Storage
 	transcoder tracks.Transcoder
+	audit      service.AuditService
 }
 
-func NewVersionsHandler(database *db.DB, storageAdapter storage.Storage, transcoder tracks.Transcoder) *VersionsHandler {
+func NewVersionsHandler(database *db.DB, storageAdapter storage.Storage, transcoder tracks.Transcoder, audit service.AuditService) *VersionsHandler {
 	return &VersionsHandler{
 		db:         database,
 		storage:    storageAdapter,
 		transcoder: transcoder,
+		audit:      audit,
 	}
 }
 
@@ -87,6 +92,9 @@ func (h *VersionsHandler) ListVersions(w http.ResponseWriter, r *http.Request) e
 			if sourceFile.OriginalFilename.Valid && sourceFile.OriginalFilename.String != "" {
 				result[i].SourceOriginalFilename = &sourceFile.OriginalFilename.String
 			}
+			if transcoding.IsCompacted(sourceFile) {
+				result[i].SourceStoredFormat = &sourceFile.StoredFormat.String
+			}
 		}
 
 		lossyFile, err := h.db.GetTrackFile(ctx, sqlc.GetTrackFileParams{
@@ -310,27 +318,26 @@ func (h *VersionsHandler) DeleteVersion(w http.ResponseWriter, r *http.Request)
 		return apperr.NewBadRequest("Cannot delete the only version. A track must have at least one version.")
 	}
 
-	project, err := queries.GetProjectByID(ctx, track.ProjectID)
-	if err := httputil.HandleDBError(err, "project not found", "failed to load project"); err != nil {
-		return err
-	}
-
-	if err := queries.DeleteTrackVersion(ctx, versionID); err != nil {
-		return apperr.NewInternal("failed to delete version", err)
-	}
-
-	if err := h.storage.DeleteVersion(ctx, storage.DeleteVersionInput{
-		ProjectPublicID: project.PublicID,
-		TrackID:         track.ID,
-		VersionID:       versionID,
+	// Files stay in storage until the version is purged from the trash.
+	if err := queries.TrashTrackVersion(ctx, sqlc.TrashTrackVersionParams{
+		DeletedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		ID:        versionID,
 	}); err != nil {
-		return apperr.NewInternal("failed to delete version files", err)
+		return apperr.NewInternal("failed to delete version", err)
 	}
 
 	if err := tx.Commit(); err != nil {
 		return apperr.NewInternal("failed to finalize deletion", err)
 	}
 
+	h.audit.Record(ctx, service.AuditEvent{
+		Actor:      shared.AuditActor(r),
+		Action:     service.AuditVersionDelete,
+		TargetType: "version",
+		TargetID:   strconv.FormatInt(versionID, 10),
+		Before:     map[string]any{"version_name": versionWithOwnership.VersionName, "track_id": track.PublicID},
+	})
+
 	return httputil.NoContentResult(w)
 }
 
@@ -420,30 +427,37 @@ func (h *VersionsHandler) UploadVersion(w http.ResponseWriter, r *http.Request)
 		return apperr.NewInternal("failed to create version", err)
 	}
 
+	reader := io.Reader(file)
+	originalName := header.Filename
+	if transcoding.IsVideoExtension(ext) {
+		wavPath, err := transcoding.ExtractAudioFromVideo(file, ext)
+		if err != nil {
+			return apperr.NewInternal("failed to extract audio from video", err)
+		}
+		defer os.Remove(wavPath)
+
+		wavFile, err := os.Open(wavPath)
+		if err != nil {
+			return apperr.NewInternal("failed to open extracted audio", err)
+		}
+		defer wavFile.Close()
+
+		reader = wavFile
+		originalName = strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename)) + ".wav"
+	}
+
 	saveResult, err := h.storage.SaveTrackSource(r.Context(), storage.SaveTrackSourceInput{
 		ProjectPublicID: project.PublicID,
 		TrackID:         track.ID,
 		VersionID:       version.ID,
-		OriginalName:    header.Filename,
-		Reader:          file,
+		OriginalName:    originalName,
+		Reader:          reader,
 	})
 	if err != nil {
 		return apperr.NewInternal("failed to save file", err)
 	}
 
-	if transcoding.IsVideoExtension(ext) {
-		wavPath, err := transcoding.ExtractAudioToWAV(saveResult.Path)
-		if err != nil {
-			return apperr.NewInternal("failed to extract audio from video", err)
-		}
-		saveResult.Path = wavPath
-		saveResult.Format = "wav"
-		if fi, err := os.Stat(wavPath); err == nil {
-			saveResult.Size = fi.Size()
-		}
-	}
-
-	metadata, err := transcoding.ExtractMetadata(saveResult.Path)
+	metadata, err := transcoding.ExtractStoredMetadata(ctx, h.storage, saveResult.Path)
 	if err != nil {
 		slog.Debug("failed to extract metadata", "error", err)
 		metadata = &transcoding.AudioMetadata{}
@@ -473,7 +487,7 @@ func (h *VersionsHandler) UploadVersion(w http.ResponseWriter, r *http.Request)
 		FileSize:          saveResult.Size,
 		Format:            format,
 		Bitrate:           bitrate,
-		ContentHash:       sql.NullString{},
+		ContentHash:       sql.NullString{String: saveResult.ContentHash, Valid: saveResult.ContentHash != ""},
 		TranscodingStatus: sql.NullString{String: "completed", Valid: true},
 		OriginalFilename:  sql.NullString{String: header.Filename, Valid: true},
 	})
@@ -539,23 +553,5 @@ func (h *VersionsHandler) DownloadVersion(w http.ResponseWriter, r *http.Request
 		return err
 	}
 
-	file, err := os.Open(sourceFile.FilePath)
-	if err != nil {
-		return apperr.NewInternal("failed to open file", err)
-	}
-	defer file.Close()
-
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return apperr.NewInternal("failed to stat file", err)
-	}
-
-	filename := fmt.Sprintf("%s.%s", versionWithOwnership.VersionName, sourceFile.Format)
-
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))
-
-	io.Copy(w, file)
-	return nil
+	return shared.ServeSourceDownload(w, r, h.storage, sourceFile, versionWithOwnership.VersionName)
 }
@@ -12,6 +12,14 @@ func NullStringToPtr(ns sql.NullString) *string {
 	return nil
 }
 
+// StringToPtr returns nil for an empty string.
+func StringToPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
 func NullInt64ToPtr(ni sql.NullInt64) *int64 {
 	if ni.Valid {
 		return &ni.Int64
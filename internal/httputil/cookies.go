@@ -30,6 +30,30 @@ func ClearAuthCookies(w http.ResponseWriter, config auth.Config) {
 	clearCookie(auth.CSRFCookieName, false)
 }
 
+// SetCSRFCookie sets only the CSRF cookie, for browsers authenticated
+// without a session.
+func SetCSRFCookie(w http.ResponseWriter, csrfToken string, config auth.Config) {
+	http.SetCookie(w, buildCookie(auth.CSRFCookieName, csrfToken, config, config.RefreshExpiration, false))
+}
+
+// SetOIDCStateCookie binds a single sign-on login to the browser that
+// started it. It is always SameSite=Lax, since the provider redirects back
+// from another site.
+func SetOIDCStateCookie(w http.ResponseWriter, state string, config auth.Config, ttl time.Duration) {
+	cookie := buildCookie(auth.OIDCStateCookieName, state, config, ttl, true)
+	cookie.Path = "/api/auth/oidc"
+	cookie.SameSite = http.SameSiteLaxMode
+	http.SetCookie(w, cookie)
+}
+
+func ClearOIDCStateCookie(w http.ResponseWriter, config auth.Config) {
+	cookie := buildCookie(auth.OIDCStateCookieName, "", config, -1*time.Hour, true)
+	cookie.Path = "/api/auth/oidc"
+	cookie.SameSite = http.SameSiteLaxMode
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
+
 func buildCookie(name, value string, config auth.Config, ttl time.Duration, httpOnly bool) *http.Cookie {
 	return &http.Cookie{
 		Name:     name,
@@ -0,0 +1,84 @@
+package httputil
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"ramiro-uziel/vault/internal/apperr"
+	"ramiro-uziel/vault/internal/storage"
+)
+
+// ServeFileOptions controls how a stored file is delivered.
+// Filename, when set, makes the response an attachment download.
+// AllowRedirect lets backends that support it send the client to a presigned URL.
+type ServeFileOptions struct {
+	ContentType   string
+	Filename      string
+	AllowRedirect bool
+}
+
+// ServeStoredFile streams a file from storage with HTTP Range support.
+func ServeStoredFile(w http.ResponseWriter, r *http.Request, store storage.Storage, path string, opts ServeFileOptions) error {
+	ctx := r.Context()
+
+	if redirector, ok := store.(storage.Redirector); ok && opts.AllowRedirect {
+		url, ok, err := redirector.RedirectURL(ctx, path, storage.RedirectOptions{
+			ContentType: opts.ContentType,
+			Filename:    opts.Filename,
+		})
+		if err != nil {
+			return apperr.NewInternal("failed to presign file", err)
+		}
+		if ok {
+			w.Header().Set("Cache-Control", "no-store")
+			http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+			return nil
+		}
+	}
+
+	stream, err := store.OpenFile(ctx, path)
+	if errors.Is(err, storage.ErrNotExist) {
+		return apperr.NewNotFound("file not found")
+	}
+	if err != nil {
+		return apperr.NewInternal("failed to open file", err)
+	}
+	defer stream.Reader.Close()
+
+	ServeStream(w, r, path, stream, opts)
+	return nil
+}
+
+// ServeStream writes an already opened file with HTTP Range support. name is
+// only used to guess the content type when opts does not set one.
+func ServeStream(w http.ResponseWriter, r *http.Request, name string, stream *storage.FileStream, opts ServeFileOptions) {
+	if opts.ContentType != "" {
+		w.Header().Set("Content-Type", opts.ContentType)
+	}
+	if opts.Filename != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": opts.Filename}))
+	}
+
+	http.ServeContent(w, r, name, stream.ModTime, stream.Reader)
+}
+
+// AudioContentType maps a track file format to its MIME type.
+func AudioContentType(format string) string {
+	switch format {
+	case "flac":
+		return "audio/flac"
+	case "mp3":
+		return "audio/mpeg"
+	case "m4a":
+		return "audio/mp4"
+	case "wav":
+		return "audio/wav"
+	case "aiff", "aif":
+		return "audio/aiff"
+	case "ogg", "opus":
+		return "audio/ogg"
+	}
+	return fmt.Sprintf("audio/%s", format)
+}
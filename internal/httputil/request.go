@@ -47,3 +47,9 @@ func RequireUsername(r *http.Request) (string, error) {
 	}
 	return username, nil
 }
+
+// ClientIP returns the address the request came from, without the port,
+// as resolved through any trusted proxies.
+func ClientIP(r *http.Request) string {
+	return middleware.ClientIP(r)
+}
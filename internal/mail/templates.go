@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Templates, each a pair of files: name.txt defines "subject" and "text",
+// and name.html defines "content" for the shared HTML layout.
+const (
+	TemplateInvite        = "invite"
+	TemplatePasswordReset = "password_reset"
+	TemplateEmailChange   = "email_change"
+	TemplateProjectShared = "project_shared"
+	TemplateTrackShared   = "track_shared"
+)
+
+//go:embed templates/*
+var templateFiles embed.FS
+
+type template struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates = mustParseTemplates(
+	TemplateInvite,
+	TemplatePasswordReset,
+	TemplateEmailChange,
+	TemplateProjectShared,
+	TemplateTrackShared,
+)
+
+// Data fills in a template. Each template uses only some of the fields.
+type Data struct {
+	Instance       string
+	Username       string
+	Link           string
+	ExpiresInHours int
+	InvitedBy      string
+	SharedBy       string
+	// Name is the shared project or track.
+	Name string
+}
+
+func mustParseTemplates(names ...string) map[string]template {
+	layout := htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/layout.html"))
+
+	parsed := make(map[string]template, len(names))
+	for _, name := range names {
+		text := texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/"+name+".txt"))
+		html := htmltemplate.Must(htmltemplate.Must(layout.Clone()).ParseFS(templateFiles, "templates/"+name+".html"))
+		parsed[name] = template{text: text, html: html}
+	}
+	return parsed
+}
+
+// Render fills in a template, giving a message without a recipient.
+func Render(name string, data Data) (Message, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, err
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		// Names in the subject come from users; keep them on one line.
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimLeft(text.String(), "\n"),
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"ramiro-uziel/vault/internal/auth"
+)
+
+const (
+	APITokenIDKey     contextKey = "api_token_id"
+	APITokenScopesKey contextKey = "api_token_scopes"
+)
+
+// APITokenIdentity is who a personal API token acts for, and what it may do.
+type APITokenIdentity struct {
+	TokenID  int64
+	UserID   int
+	Username string
+	Scopes   []string
+}
+
+// APITokenValidator resolves a personal API token, or fails if it is unknown,
+// expired or revoked.
+type APITokenValidator func(ctx context.Context, token string, r *http.Request) (*APITokenIdentity, error)
+
+// APITokenAuth accepts personal API tokens sent as "Authorization: Bearer"
+// in front of a session middleware, which handles every other request. A
+// token request is only let through if the token has the scope the route
+// needs; see RequiredScope.
+func APITokenAuth(validate APITokenValidator, session func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		sessionNext := session(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if !auth.IsAPIToken(token) {
+				sessionNext.ServeHTTP(w, r)
+				return
+			}
+
+			identity, err := validate(r.Context(), token, r)
+			if err != nil {
+				slog.WarnContext(r.Context(), "Auth failed: invalid API token",
+					"path", r.URL.Path,
+					"method", r.Method,
+				)
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			scope, allowed := RequiredScope(r)
+			if !allowed {
+				http.Error(w, "not available to API tokens", http.StatusForbidden)
+				return
+			}
+			if !slices.Contains(identity.Scopes, scope) {
+				http.Error(w, "token lacks the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), UserIDKey, identity.UserID)
+			ctx = context.WithValue(ctx, UsernameKey, identity.Username)
+			ctx = context.WithValue(ctx, APITokenIDKey, identity.TokenID)
+			ctx = context.WithValue(ctx, APITokenScopesKey, identity.Scopes)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequiredScope returns the scope an API token needs for a request. The
+// second result is false for routes tokens cannot use at all: account,
+// credential and token management, takeouts, websockets, and any change not
+// listed in tokenRouteScopes.
+func RequiredScope(r *http.Request) (string, bool) {
+	path := r.URL.Path
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	switch {
+	case path == "/api/auth/me" && safe:
+		return auth.ScopeRead, true
+	case strings.HasPrefix(path, "/api/auth/"),
+		strings.HasPrefix(path, "/api/tokens"),
+		strings.HasPrefix(path, "/api/takeout"),
+		strings.HasPrefix(path, "/api/ws"):
+		return "", false
+	case strings.HasPrefix(path, "/api/admin/"),
+		path == "/api/stats/storage/global",
+		path == "/api/instance/name":
+		return auth.ScopeAdmin, true
+	case safe:
+		return auth.ScopeRead, true
+	}
+
+	_, pattern := tokenRoutes.Handler(r)
+	scope, ok := tokenRouteScopes[pattern]
+	return scope, ok
+}
+
+// tokenRouteScopes lists the changes API tokens may make, by route pattern.
+// upload covers the token owner's own material: adding, editing, organizing
+// and removing it. Anything that changes who else can see it needs
+// share-manage.
+var tokenRouteScopes = map[string]string{
+	"POST /api/library/upload":                    auth.ScopeUpload,
+	"POST /api/tracks/{track_id}/versions/upload": auth.ScopeUpload,
+	"POST /api/projects":                          auth.ScopeUpload,
+	"PUT /api/projects/{id}":                      auth.ScopeUpload,
+	"PUT /api/projects/{id}/folder":               auth.ScopeUpload,
+	"POST /api/projects/import":                   auth.ScopeUpload,
+	"POST /api/projects/move-to-folder":           auth.ScopeUpload,
+	"PUT /api/projects/{id}/cover":                auth.ScopeUpload,
+	"POST /api/projects/{id}/duplicate":           auth.ScopeUpload,
+	"POST /api/folders":                           auth.ScopeUpload,
+	"PUT /api/folders/{id}":                       auth.ScopeUpload,
+	"POST /api/trash/{type}/{id}/restore":         auth.ScopeUpload,
+	"POST /api/tracks/reorder":                    auth.ScopeUpload,
+	"PUT /api/tracks/{id}":                        auth.ScopeUpload,
+	"POST /api/tracks/{id}/duplicate":             auth.ScopeUpload,
+	"PUT /api/versions/{id}":                      auth.ScopeUpload,
+	"POST /api/versions/{id}/activate":            auth.ScopeUpload,
+	"POST /api/organize/bulk":                     auth.ScopeUpload,
+	"PUT /api/shared-projects/{id}/organize":      auth.ScopeUpload,
+	"PUT /api/shared-tracks/{id}/organize":        auth.ScopeUpload,
+	"PUT /api/tracks/{trackId}/notes":             auth.ScopeUpload,
+	"PUT /api/projects/{projectId}/notes":         auth.ScopeUpload,
+
+	"DELETE /api/projects/{id}":       auth.ScopeUpload,
+	"DELETE /api/projects/{id}/cover": auth.ScopeUpload,
+	"POST /api/folders/{id}/empty":    auth.ScopeUpload,
+	"DELETE /api/folders/{id}":        auth.ScopeUpload,
+	"DELETE /api/trash":               auth.ScopeUpload,
+	"DELETE /api/trash/{type}/{id}":   auth.ScopeUpload,
+	"DELETE /api/tracks/{id}":         auth.ScopeUpload,
+	"DELETE /api/versions/{id}":       auth.ScopeUpload,
+	"DELETE /api/notes/{noteId}":      auth.ScopeUpload,
+
+	"POST /api/tracks/{id}/share":              auth.ScopeShareManage,
+	"PUT /api/share/{id}":                      auth.ScopeShareManage,
+	"DELETE /api/share/{id}":                   auth.ScopeShareManage,
+	"POST /api/projects/{id}/share":            auth.ScopeShareManage,
+	"PUT /api/share/projects/{id}":             auth.ScopeShareManage,
+	"DELETE /api/share/projects/{id}":          auth.ScopeShareManage,
+	"PUT /api/tracks/{id}/visibility":          auth.ScopeShareManage,
+	"PUT /api/projects/{id}/visibility":        auth.ScopeShareManage,
+	"POST /api/share/accept/{token}":           auth.ScopeShareManage,
+	"DELETE /api/share/leave/{id}":             auth.ScopeShareManage,
+	"POST /api/projects/{id}/share-with-users": auth.ScopeShareManage,
+	"POST /api/tracks/{id}/share-with-users":   auth.ScopeShareManage,
+	"PUT /api/user-shares/projects/{shareId}":  auth.ScopeShareManage,
+	"PUT /api/user-shares/tracks/{shareId}":    auth.ScopeShareManage,
+	"DELETE /api/user-shares/projects/{id}":    auth.ScopeShareManage,
+	"DELETE /api/user-shares/tracks/{id}":      auth.ScopeShareManage,
+	"DELETE /api/projects/{id}/leave":          auth.ScopeShareManage,
+	"DELETE /api/shared-tracks/{id}/leave":     auth.ScopeShareManage,
+}
+
+// tokenRoutes matches requests to tokenRouteScopes with the same precedence
+// rules as the server's own mux.
+var tokenRoutes = func() *http.ServeMux {
+	mux := http.NewServeMux()
+	for pattern := range tokenRouteScopes {
+		mux.Handle(pattern, http.NotFoundHandler())
+	}
+	return mux
+}()
+
+// IsAPITokenRequest reports whether a request authenticates with a personal
+// API token rather than the session cookies.
+func IsAPITokenRequest(r *http.Request) bool {
+	return auth.IsAPIToken(bearerToken(r))
+}
+
+// GetAPITokenID returns the API token a request was authenticated with.
+func GetAPITokenID(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(APITokenIDKey).(int64)
+	return id, ok
+}
+
+func bearerToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return ""
+	}
+	parts := strings.Split(authHeader, " ")
+	if len(parts) == 2 && parts[0] == "Bearer" {
+		return parts[1]
+	}
+	return ""
+}
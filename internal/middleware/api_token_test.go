@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"ramiro-uziel/vault/internal/auth"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method  string
+		path    string
+		scope   string
+		allowed bool
+	}{
+		{"GET", "/api/projects/7", auth.ScopeRead, true},
+		{"GET", "/api/auth/me", auth.ScopeRead, true},
+		{"POST", "/api/library/upload", auth.ScopeUpload, true},
+		{"POST", "/api/tracks/3/versions/upload", auth.ScopeUpload, true},
+		{"POST", "/api/projects", auth.ScopeUpload, true},
+		{"PUT", "/api/projects/7", auth.ScopeUpload, true},
+		{"POST", "/api/organize/bulk", auth.ScopeUpload, true},
+		{"PUT", "/api/tracks/3", auth.ScopeUpload, true},
+		{"DELETE", "/api/projects/7", auth.ScopeUpload, true},
+		{"DELETE", "/api/trash", auth.ScopeUpload, true},
+		{"DELETE", "/api/share/leave/4", auth.ScopeShareManage, true},
+		{"DELETE", "/api/share/projects/4", auth.ScopeShareManage, true},
+		{"PUT", "/api/projects/7/visibility", auth.ScopeShareManage, true},
+		{"GET", "/api/admin/users", auth.ScopeAdmin, true},
+		{"PUT", "/api/preferences", "", false},
+		{"PUT", "/api/share/abc/track/3/update", "", false},
+		{"POST", "/api/auth/change-password", "", false},
+		{"POST", "/api/tokens", "", false},
+		{"POST", "/api/something-new", "", false},
+		{"PATCH", "/api/projects/7", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			scope, allowed := RequiredScope(httptest.NewRequest(tt.method, tt.path, nil))
+			if scope != tt.scope || allowed != tt.allowed {
+				t.Errorf("RequiredScope() = %q, %v, want %q, %v", scope, allowed, tt.scope, tt.allowed)
+			}
+		})
+	}
+}
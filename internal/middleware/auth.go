@@ -16,13 +16,15 @@ import (
 type contextKey string
 
 const (
-	UserIDKey   contextKey = "user_id"
-	UsernameKey contextKey = "username"
-	IsAdminKey  contextKey = "is_admin"
+	UserIDKey    contextKey = "user_id"
+	UsernameKey  contextKey = "username"
+	IsAdminKey   contextKey = "is_admin"
+	SessionIDKey contextKey = "session_id"
 )
 
-// SessionValidator checks if a token issued at a given time is still valid
-type SessionValidator func(userID int, issuedAt time.Time) bool
+// SessionValidator checks if a token issued at a given time is still valid.
+// sessionID is zero for tokens that are not tied to a session.
+type SessionValidator func(userID int, sessionID int64, issuedAt time.Time) bool
 
 // AuthMiddleware validates JWT tokens and adds user info to context
 func createAuthMiddleware(jwtSecret string, sessionValidator ...SessionValidator) func(http.Handler) http.Handler {
@@ -71,7 +73,7 @@ func createAuthMiddleware(jwtSecret string, sessionValidator ...SessionValidator
 
 			// Check if session is still valid (not invalidated by reset/import)
 			if validator != nil && claims.IssuedAt != nil {
-				if !validator(claims.UserID, claims.IssuedAt.Time) {
+				if !validator(claims.UserID, claims.SessionID, claims.IssuedAt.Time) {
 					slog.WarnContext(r.Context(), "Auth failed: session invalidated",
 						"path", r.URL.Path,
 						"method", r.Method,
@@ -84,6 +86,7 @@ func createAuthMiddleware(jwtSecret string, sessionValidator ...SessionValidator
 			// Add user info to context
 			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
 			ctx = context.WithValue(ctx, UsernameKey, claims.Username)
+			ctx = context.WithValue(ctx, SessionIDKey, claims.SessionID)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -130,7 +133,7 @@ func OptionalAuthMiddleware(jwtSecret string, sessionValidator ...SessionValidat
 			}
 
 			if validator != nil && claims.IssuedAt != nil {
-				if !validator(claims.UserID, claims.IssuedAt.Time) {
+				if !validator(claims.UserID, claims.SessionID, claims.IssuedAt.Time) {
 					http.Error(w, "session expired", http.StatusUnauthorized)
 					return
 				}
@@ -138,6 +141,7 @@ func OptionalAuthMiddleware(jwtSecret string, sessionValidator ...SessionValidat
 
 			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
 			ctx = context.WithValue(ctx, UsernameKey, claims.Username)
+			ctx = context.WithValue(ctx, SessionIDKey, claims.SessionID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -149,6 +153,12 @@ func GetUserID(ctx context.Context) (int, bool) {
 	return userID, ok
 }
 
+// GetSessionID extracts the session the request's access token belongs to
+func GetSessionID(ctx context.Context) (int64, bool) {
+	sessionID, ok := ctx.Value(SessionIDKey).(int64)
+	return sessionID, ok && sessionID != 0
+}
+
 // GetUsername extracts username from context
 func GetUsername(ctx context.Context) (string, bool) {
 	username, ok := ctx.Value(UsernameKey).(string)
@@ -176,7 +186,7 @@ func CORS(config CORSConfig) func(http.Handler) http.Handler {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
 					w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 					w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CSRF-Token")
-					w.Header().Set("Access-Control-Expose-Headers", "Content-Range, Content-Length, Accept-Ranges, Content-Disposition")
+					w.Header().Set("Access-Control-Expose-Headers", "Content-Range, Content-Length, Accept-Ranges, Content-Disposition, X-Next-Cursor")
 					w.Header().Set("Access-Control-Allow-Credentials", "true")
 				}
 			}
@@ -300,6 +310,7 @@ func Logging(next http.Handler) http.Handler {
 			slog.String("path", r.URL.Path),
 			slog.Int("status", wrapped.statusCode),
 			slog.String("duration", duration.Round(time.Millisecond).String()),
+			slog.String("ip", ClientIP(r)),
 		}
 
 		// Add user info if available from context
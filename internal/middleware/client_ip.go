@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const ClientIPKey contextKey = "client_ip"
+
+// Forwarding headers a trusted proxy may set.
+const (
+	ProxyHeaderForwarded     = "Forwarded"
+	ProxyHeaderXForwardedFor = "X-Forwarded-For"
+	ProxyHeaderXRealIP       = "X-Real-IP"
+)
+
+// ClientIPResolver works out which address a request came from, believing
+// forwarding headers only as far as they were written by trusted proxies.
+type ClientIPResolver struct {
+	TrustedProxies []*net.IPNet
+	// Header is the one forwarding header the proxies set. The others are
+	// passed through from the client unchanged by most proxies, so they are
+	// never read.
+	Header string
+}
+
+// ParseProxyHeader names one of the forwarding headers, in any case. Empty
+// means X-Forwarded-For.
+func ParseProxyHeader(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ProxyHeaderXForwardedFor, nil
+	}
+	for _, header := range []string{ProxyHeaderForwarded, ProxyHeaderXForwardedFor, ProxyHeaderXRealIP} {
+		if strings.EqualFold(name, header) {
+			return header, nil
+		}
+	}
+	return "", fmt.Errorf("unknown forwarding header %q", name)
+}
+
+// RealIP resolves each request's client address once and keeps it in the
+// context, where ClientIP finds it.
+func RealIP(resolver ClientIPResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), ClientIPKey, resolver.Resolve(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientIP returns the address RealIP resolved for a request, or the
+// connection's peer address without RealIP.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ClientIPKey).(string); ok {
+		return ip
+	}
+	return peerIP(r)
+}
+
+// Resolve returns the connection's peer address unless that is a trusted
+// proxy. Then it walks the forwarding chain from the nearest hop back,
+// skipping trusted proxies, and returns the first address it cannot vouch
+// for. Only the end of the chain is walked, since whoever sent the request
+// can write anything into the start of it.
+func (c ClientIPResolver) Resolve(r *http.Request) string {
+	peer := peerIP(r)
+	if !containsIP(c.TrustedProxies, net.ParseIP(peer)) {
+		return peer
+	}
+
+	var chain []string
+	switch values := r.Header.Values(c.Header); c.Header {
+	case ProxyHeaderForwarded:
+		chain = forwardedFor(values)
+	case ProxyHeaderXRealIP:
+		if len(values) > 0 {
+			// The proxy replaces X-Real-IP rather than appending to it, so
+			// only its last value is the proxy's own.
+			chain = []string{strings.TrimSpace(values[len(values)-1])}
+		}
+	default:
+		chain = splitList(values)
+	}
+
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseNode(chain[i])
+		if ip == nil {
+			// An obfuscated or garbled hop; nothing beyond it can be trusted
+			// either, so the last hop that could be is the client.
+			break
+		}
+		client = ip.String()
+		if !containsIP(c.TrustedProxies, ip) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedFor returns the for= parameter of each Forwarded element, in
+// order. Elements without one are kept as empty hops, so the chain still
+// lines up with the proxies that wrote it.
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, element := range splitList(values) {
+		node := ""
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "for") {
+				node = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+		chain = append(chain, node)
+	}
+	return chain
+}
+
+// splitList splits the comma-separated values of a repeated header into one
+// list, in the order the header lines came in.
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+	}
+	return items
+}
+
+// parseNode parses a hop as "1.2.3.4", "1.2.3.4:port", "2001:db8::1" or
+// "[2001:db8::1]:port". Obfuscated identifiers such as "unknown" or
+// "_hidden" give nil.
+func parseNode(node string) net.IP {
+	if strings.HasPrefix(node, "[") {
+		end := strings.Index(node, "]")
+		if end < 0 {
+			return nil
+		}
+		node = node[1:end]
+	} else if strings.Count(node, ":") == 1 {
+		node, _, _ = strings.Cut(node, ":")
+	}
+	return net.ParseIP(node)
+}
+
+func peerIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
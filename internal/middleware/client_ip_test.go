@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPResolverResolve(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"127.0.0.1", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "untrusted peer keeps its own address",
+			remoteAddr: "203.0.113.5:1234",
+			header:     ProxyHeaderXForwardedFor,
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "203.0.113.5",
+		},
+		{
+			name:       "rightmost untrusted hop",
+			remoteAddr: "127.0.0.1:1234",
+			header:     ProxyHeaderXForwardedFor,
+			headers:    map[string]string{"X-Forwarded-For": "6.6.6.6, 2.2.2.2, 10.1.2.3"},
+			want:       "2.2.2.2",
+		},
+		{
+			name:       "other headers are not read",
+			remoteAddr: "127.0.0.1:1234",
+			header:     ProxyHeaderXForwardedFor,
+			headers: map[string]string{
+				"Forwarded":       "for=198.51.100.99",
+				"X-Real-IP":       "198.51.100.98",
+				"X-Forwarded-For": "203.0.113.7",
+			},
+			want: "203.0.113.7",
+		},
+		{
+			name:       "configured header missing",
+			remoteAddr: "127.0.0.1:1234",
+			header:     ProxyHeaderForwarded,
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			want:       "127.0.0.1",
+		},
+		{
+			name:       "forwarded with quoted IPv6 and port",
+			remoteAddr: "127.0.0.1:1234",
+			header:     ProxyHeaderForwarded,
+			headers:    map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`},
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "obfuscated hop stops the walk",
+			remoteAddr: "127.0.0.1:1234",
+			header:     ProxyHeaderForwarded,
+			headers:    map[string]string{"Forwarded": "for=7.7.7.7, for=unknown"},
+			want:       "127.0.0.1",
+		},
+		{
+			name:       "x-real-ip",
+			remoteAddr: "127.0.0.1:1234",
+			header:     ProxyHeaderXRealIP,
+			headers:    map[string]string{"X-Real-IP": "3.3.3.3"},
+			want:       "3.3.3.3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+
+			resolver := ClientIPResolver{TrustedProxies: trusted, Header: tt.header}
+			if got := resolver.Resolve(r); got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -19,6 +19,13 @@ func CSRFMiddleware(config CSRFMiddlewareConfig) func(http.Handler) http.Handler
 				return
 			}
 
+			// API tokens are sent by scripts, not by a browser holding
+			// cookies, so there is no request for another site to forge.
+			if IsAPITokenRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			for _, path := range config.ExemptPaths {
 				if strings.HasPrefix(r.URL.Path, path) {
 					next.ServeHTTP(w, r)
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"ramiro-uziel/vault/internal/auth"
+)
+
+// ForwardAuthConfig says which proxies may vouch for a user, and in which
+// headers.
+type ForwardAuthConfig struct {
+	TrustedProxies []*net.IPNet
+	UserHeader     string
+	EmailHeader    string
+	// IssueCSRF gives a browser authenticated by the proxy a CSRF cookie, so
+	// it can make the same state-changing requests a logged-in one can.
+	IssueCSRF func(w http.ResponseWriter)
+}
+
+// ForwardAuthIdentity is the account a forwarded user maps to.
+type ForwardAuthIdentity struct {
+	UserID   int
+	Username string
+}
+
+// ForwardAuthResolver maps a forwarded username and email to an account.
+type ForwardAuthResolver func(ctx context.Context, username, email string) (*ForwardAuthIdentity, error)
+
+// ForwardAuth trusts the user header set by an authenticating reverse proxy
+// in front of a session middleware. The header is only believed on
+// connections from a trusted proxy; everywhere else, and on trusted
+// connections without it, the session middleware decides.
+func ForwardAuth(config ForwardAuthConfig, resolve ForwardAuthResolver, session func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		sessionNext := session(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username := r.Header.Get(config.UserHeader)
+			if username == "" || !config.trusted(r) {
+				sessionNext.ServeHTTP(w, r)
+				return
+			}
+
+			identity, err := resolve(r.Context(), username, r.Header.Get(config.EmailHeader))
+			if err != nil {
+				slog.WarnContext(r.Context(), "Auth failed: forwarded user has no account",
+					"path", r.URL.Path,
+					"method", r.Method,
+					"forwarded_user", username,
+					"error", err.Error(),
+				)
+				http.Error(w, "forwarded user has no account", http.StatusForbidden)
+				return
+			}
+
+			if _, err := r.Cookie(auth.CSRFCookieName); err != nil && config.IssueCSRF != nil {
+				config.IssueCSRF(w)
+			}
+
+			ctx := context.WithValue(r.Context(), UserIDKey, identity.UserID)
+			ctx = context.WithValue(ctx, UsernameKey, identity.Username)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// trusted checks the connection's own peer address. Forwarding headers are
+// not consulted, since whoever can set the user header could set those too.
+func (c ForwardAuthConfig) trusted(r *http.Request) bool {
+	return containsIP(c.TrustedProxies, net.ParseIP(peerIP(r)))
+}
+
+// ParseCIDRs parses a list of networks. A bare address is taken as a single
+// host.
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", value)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			value = fmt.Sprintf("%s/%d", value, bits)
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", value, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
@@ -74,38 +73,10 @@ func (rl *IPRateLimiter) cleanupStaleEntries() {
 	}
 }
 
-// extractIP gets the client IP from the request
-func extractIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies/load balancers)
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		// Take the first IP in the list
-		ips := strings.Split(forwarded, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// Check X-Real-IP header
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
-	}
-
-	// Fall back to RemoteAddr
-	// RemoteAddr is in the format "IP:port", so we need to split it
-	ip := r.RemoteAddr
-	if colonIdx := strings.LastIndex(ip, ":"); colonIdx != -1 {
-		ip = ip[:colonIdx]
-	}
-
-	return ip
-}
-
 // RateLimit returns a middleware that enforces rate limiting
 func (rl *IPRateLimiter) RateLimit(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ip := extractIP(r)
+		ip := ClientIP(r)
 		limiter := rl.getLimiter(ip)
 
 		if !limiter.Allow() {
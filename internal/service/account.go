@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"ramiro-uziel/vault/internal/auth"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+)
+
+// emailChangeTTL is how long the link sent to a new email address works.
+const emailChangeTTL = 24 * time.Hour
+
+var (
+	ErrIncorrectPassword = errors.New("incorrect password")
+	ErrInvalidEmail      = errors.New("invalid email address")
+	ErrEmailTaken        = errors.New("email is already registered")
+)
+
+type EmailChange struct {
+	// Token goes to the new address, which confirms it by sending it back.
+	Token     string
+	Email     string
+	ExpiresAt time.Time
+}
+
+type EmailChangeResult struct {
+	User          *User
+	PreviousEmail string
+}
+
+func (s *authService) ChangePassword(ctx context.Context, userID int64, currentPassword, newPassword string, keepSessionID int64) error {
+	if currentPassword == "" || newPassword == "" {
+		return errors.New("current and new password are required")
+	}
+
+	user, err := s.db.Queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := auth.VerifyPassword(currentPassword, user.PasswordHash); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	passwordHash, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := s.db.Queries.WithTx(tx)
+
+	if _, err := qtx.UpdateUserPassword(ctx, sqlc.UpdateUserPasswordParams{
+		PasswordHash: passwordHash,
+		ID:           userID,
+	}); err != nil {
+		return err
+	}
+
+	// Other sessions may be whoever learned the old password.
+	if _, err := qtx.RevokeOtherRefreshTokensByUser(ctx, sqlc.RevokeOtherRefreshTokensByUserParams{
+		UserID: userID,
+		KeepID: keepSessionID,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *authService) RequestEmailChange(ctx context.Context, userID int64, password, newEmail string) (*EmailChange, error) {
+	newEmail = strings.TrimSpace(newEmail)
+	if password == "" {
+		return nil, errors.New("password is required")
+	}
+	if address, err := mail.ParseAddress(newEmail); err != nil || address.Address != newEmail {
+		return nil, ErrInvalidEmail
+	}
+
+	user, err := s.db.Queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.VerifyPassword(password, user.PasswordHash); err != nil {
+		return nil, ErrIncorrectPassword
+	}
+	if strings.EqualFold(user.Email, newEmail) {
+		return nil, ErrEmailTaken
+	}
+	if _, err := s.db.Queries.GetUserByEmail(ctx, newEmail); err == nil {
+		return nil, ErrEmailTaken
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	token, err := auth.GenerateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(emailChangeTTL)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := s.db.Queries.WithTx(tx)
+
+	// Only the latest request can be confirmed.
+	userRef := sql.NullInt64{Int64: userID, Valid: true}
+	if err := qtx.DeletePendingUserTokens(ctx, sqlc.DeletePendingUserTokensParams{
+		UserID:    userRef,
+		TokenType: "email_change",
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := qtx.CreateResetToken(ctx, sqlc.CreateResetTokenParams{
+		TokenHash: auth.HashToken(token, s.authConfig.TokenPepper),
+		TokenType: "email_change",
+		UserID:    userRef,
+		CreatedBy: userID,
+		Email:     newEmail,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &EmailChange{
+		Token:     token,
+		Email:     newEmail,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (s *authService) ConfirmEmailChange(ctx context.Context, token string) (*EmailChangeResult, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	changeToken, err := s.db.Queries.GetInviteTokenByToken(ctx, auth.HashToken(token, s.authConfig.TokenPepper))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if changeToken.TokenType != "email_change" {
+		return nil, ErrInvalidTokenType
+	}
+	if changeToken.Used {
+		return nil, ErrTokenUsed
+	}
+	if time.Now().After(changeToken.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	if !changeToken.UserID.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.db.Queries.GetUserByID(ctx, changeToken.UserID.Int64)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := s.db.Queries.WithTx(tx)
+
+	if _, err := qtx.MarkTokenAsUsed(ctx, changeToken.ID); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenUsed
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The address may have been registered since the change was requested.
+	updated, err := qtx.UpdateUserEmail(ctx, sqlc.UpdateUserEmailParams{
+		Email: changeToken.Email,
+		ID:    user.ID,
+	})
+	if isUniqueConstraintError(err) {
+		return nil, ErrEmailTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &EmailChangeResult{
+		User:          sqlcUserToServiceUser(updated),
+		PreviousEmail: user.Email,
+	}, nil
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"ramiro-uziel/vault/internal/auth"
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+)
+
+const (
+	maxAPITokenNameLength = 64
+	// apiTokenPrefixLength is how much of a token is kept in the clear to
+	// identify it: the fixed prefix and four random characters.
+	apiTokenPrefixLength = len(auth.APITokenPrefix) + 4
+	// apiTokenTouchInterval limits how often use of a token is written back,
+	// so a script streaming many requests does not write on each one.
+	apiTokenTouchInterval = time.Minute
+)
+
+var (
+	ErrInvalidScope       = errors.New("unknown API token scope")
+	ErrScopeNotAllowed    = errors.New("only admins can create tokens with the admin scope")
+	ErrInvalidTokenExpiry = errors.New("token expiry must be in the future")
+)
+
+// APITokenService manages personal API tokens, which let scripts act as a
+// user within the scopes the token was created with.
+type APITokenService interface {
+	// Create returns the token in the clear. It is not stored and cannot be
+	// shown again.
+	Create(ctx context.Context, userID int64, input CreateAPITokenInput) (*NewAPIToken, error)
+	List(ctx context.Context, userID int64) ([]APIToken, error)
+	Revoke(ctx context.Context, userID, tokenID int64) error
+	// Authenticate resolves a token to its owner and records its use. It
+	// fails with ErrInvalidToken for unknown and expired tokens.
+	Authenticate(ctx context.Context, token, ip string) (*APITokenOwner, error)
+}
+
+type CreateAPITokenInput struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+type APIToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP *string    `json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type NewAPIToken struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+type APITokenOwner struct {
+	TokenID   int64
+	UserID    int64
+	Username  string
+	Scopes    []string
+	CreatedAt time.Time
+}
+
+type apiTokenService struct {
+	db         *db.DB
+	authConfig auth.Config
+}
+
+func NewAPITokenService(database *db.DB, authConfig auth.Config) APITokenService {
+	return &apiTokenService{
+		db:         database,
+		authConfig: authConfig,
+	}
+}
+
+func (s *apiTokenService) Create(ctx context.Context, userID int64, input CreateAPITokenInput) (*NewAPIToken, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, errors.New("token name is required")
+	}
+	if runes := []rune(name); len(runes) > maxAPITokenNameLength {
+		name = string(runes[:maxAPITokenNameLength])
+	}
+
+	if len(input.Scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+	scopes := make([]string, 0, len(input.Scopes))
+	for _, scope := range input.Scopes {
+		if !slices.Contains(auth.APITokenScopes, scope) {
+			return nil, ErrInvalidScope
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	user, err := s.db.Queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(scopes, auth.ScopeAdmin) && !user.IsAdmin {
+		return nil, ErrScopeNotAllowed
+	}
+
+	var expiresAt sql.NullTime
+	if input.ExpiresAt != nil {
+		if !input.ExpiresAt.After(time.Now()) {
+			return nil, ErrInvalidTokenExpiry
+		}
+		expiresAt = sql.NullTime{Time: input.ExpiresAt.UTC(), Valid: true}
+	}
+
+	token, err := auth.GenerateAPIToken()
+	if err != nil {
+		return nil, err
+	}
+
+	row, err := s.db.Queries.CreateAPIToken(ctx, sqlc.CreateAPITokenParams{
+		UserID:      userID,
+		Name:        name,
+		TokenHash:   auth.HashToken(token, s.authConfig.TokenPepper),
+		TokenPrefix: token[:apiTokenPrefixLength],
+		Scopes:      strings.Join(scopes, ","),
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &NewAPIToken{APIToken: sqlcAPITokenToAPIToken(row), Token: token}, nil
+}
+
+func (s *apiTokenService) List(ctx context.Context, userID int64) ([]APIToken, error) {
+	rows, err := s.db.Queries.ListAPITokensByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]APIToken, 0, len(rows))
+	for _, row := range rows {
+		tokens = append(tokens, sqlcAPITokenToAPIToken(row))
+	}
+	return tokens, nil
+}
+
+func (s *apiTokenService) Revoke(ctx context.Context, userID, tokenID int64) error {
+	deleted, err := s.db.Queries.DeleteAPIToken(ctx, sqlc.DeleteAPITokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *apiTokenService) Authenticate(ctx context.Context, token, ip string) (*APITokenOwner, error) {
+	if !auth.IsAPIToken(token) {
+		return nil, ErrInvalidToken
+	}
+
+	row, err := s.db.Queries.GetAPITokenByHash(ctx, auth.HashToken(token, s.authConfig.TokenPepper))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if row.ExpiresAt.Valid && now.After(row.ExpiresAt.Time) {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.db.Queries.GetUserByID(ctx, row.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_ = s.db.Queries.TouchAPIToken(ctx, sqlc.TouchAPITokenParams{
+		Now:   sql.NullTime{Time: now, Valid: true},
+		Ip:    sql.NullString{String: ip, Valid: ip != ""},
+		ID:    row.ID,
+		Since: sql.NullTime{Time: now.Add(-apiTokenTouchInterval), Valid: true},
+	})
+
+	return &APITokenOwner{
+		TokenID:   row.ID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Scopes:    strings.Split(row.Scopes, ","),
+		CreatedAt: row.CreatedAt,
+	}, nil
+}
+
+func sqlcAPITokenToAPIToken(row sqlc.ApiToken) APIToken {
+	token := APIToken{
+		ID:        row.ID,
+		Name:      row.Name,
+		Prefix:    row.TokenPrefix,
+		Scopes:    strings.Split(row.Scopes, ","),
+		CreatedAt: row.CreatedAt,
+	}
+	if row.ExpiresAt.Valid {
+		token.ExpiresAt = &row.ExpiresAt.Time
+	}
+	if row.LastUsedAt.Valid {
+		token.LastUsedAt = &row.LastUsedAt.Time
+	}
+	if row.LastUsedIp.Valid {
+		token.LastUsedIP = &row.LastUsedIp.String
+	}
+	return token
+}
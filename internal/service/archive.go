@@ -0,0 +1,315 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"ramiro-uziel/vault/internal/db"
+	"ramiro-uziel/vault/internal/storage"
+)
+
+// ArchiveVersion is the format of instance archives written by exports and
+// backups.
+const ArchiveVersion = "1.0"
+
+// Values of ExportManifest.Kind for backups. Exports leave it empty.
+const (
+	ArchiveFull        = "full"
+	ArchiveIncremental = "incremental"
+)
+
+const archiveManifestName = "manifest.json"
+
+// ExportManifest is written as manifest.json at the root of every instance
+// archive.
+type ExportManifest struct {
+	Version      string    `json:"version"`
+	AppVersion   string    `json:"app_version"`
+	InstanceName string    `json:"instance_name"`
+	CreatedAt    time.Time `json:"created_at"`
+	// EncryptionKeyID is set when stored files are encrypted at rest. Files
+	// are exported as-is, so restoring them needs the same master key.
+	EncryptionKeyID string `json:"encryption_key_id,omitempty"`
+	// Kind, Base and Files are set on backups. An incremental backup only
+	// holds the files that changed since Base; Files lists every file of
+	// the instance and where its content is kept.
+	Kind  string        `json:"kind,omitempty"`
+	Base  string        `json:"base,omitempty"`
+	Files []ArchiveFile `json:"files,omitempty"`
+}
+
+// ArchiveFile is one file of a backup, vault.db included.
+type ArchiveFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modified_at"`
+	SHA256  string    `json:"sha256"`
+	// Archive names the backup holding the content when it is not the one
+	// this manifest belongs to.
+	Archive string `json:"archive,omitempty"`
+}
+
+// WriteInstanceArchive writes a ZIP holding the manifest, a consistent copy
+// of the database taken with the online backup API, and every file in
+// storage under projects/. onFile, when set, is called after each entry. The
+// result can be restored with an instance import.
+func WriteInstanceArchive(ctx context.Context, w io.Writer, database *db.DB, store storage.Storage, manifest ExportManifest, onFile func(name string)) error {
+	if onFile == nil {
+		onFile = func(string) {}
+	}
+
+	tmp, err := os.CreateTemp("", "vault-archive-*.db")
+	if err != nil {
+		return fmt.Errorf("failed to create database copy: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	if err := database.BackupTo(ctx, tmpPath); err != nil {
+		return fmt.Errorf("failed to copy database: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+
+	if err := writeArchiveManifest(zw, manifest); err != nil {
+		return err
+	}
+	onFile(archiveManifestName)
+
+	if err := addFileToZip(zw, tmpPath, "vault.db"); err != nil {
+		return fmt.Errorf("failed to archive database: %w", err)
+	}
+	onFile("vault.db")
+
+	err = walkStoredFiles(ctx, store, func(info storage.FileInfo, name string) error {
+		stream, err := openStoredFile(ctx, store, info.Path)
+		// Files deleted while the archive is written are left out.
+		if errors.Is(err, storage.ErrNotExist) {
+			return nil
+		}
+		if err == nil {
+			err = writeStreamToZip(zw, stream, name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+		onFile(name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// walkStoredFiles calls fn for every file in storage, with its name in an
+// archive: its path from the projects/ directory on.
+func walkStoredFiles(ctx context.Context, store storage.Storage, fn func(info storage.FileInfo, name string) error) error {
+	return store.ListFiles(ctx, func(info storage.FileInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := archivePathOf(info.Path)
+		if name == "" {
+			return nil
+		}
+		return fn(info, name)
+	})
+}
+
+// openStoredFile opens a stored file as it is kept, so files encrypted at
+// rest are archived encrypted.
+func openStoredFile(ctx context.Context, store storage.Storage, path string) (*storage.FileStream, error) {
+	if raw, ok := store.(storage.RawOpener); ok {
+		return raw.OpenRaw(ctx, path)
+	}
+	return store.OpenFile(ctx, path)
+}
+
+// WriteBackupArchive writes an instance archive that lists every file with
+// its size, modification time and SHA-256 in the manifest, which goes last
+// since the hashes are taken while copying. Stored files are read through
+// store. When manifest.Base is set, base must be that backup's manifest:
+// files whose size and modification time have not changed since are not
+// copied again but listed with the archive that holds them. It returns the
+// manifest as written.
+func WriteBackupArchive(ctx context.Context, w io.Writer, database *db.DB, store storage.Storage, manifest ExportManifest, base *ExportManifest) (*ExportManifest, error) {
+	unchanged := map[string]ArchiveFile{}
+	if manifest.Base != "" {
+		manifest.Kind = ArchiveIncremental
+		for _, file := range base.Files {
+			if file.Archive == "" {
+				file.Archive = manifest.Base
+			}
+			unchanged[file.Path] = file
+		}
+	} else {
+		manifest.Kind = ArchiveFull
+	}
+	manifest.Files = nil
+
+	tmp, err := os.CreateTemp("", "vault-archive-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database copy: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	if err := database.BackupTo(ctx, tmpPath); err != nil {
+		return nil, fmt.Errorf("failed to copy database: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+
+	// The database changes with every write, so it is always copied.
+	dbStream, err := openLocalFile(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive database: %w", err)
+	}
+	dbFile, err := addHashedFileToZip(zw, dbStream, "vault.db", dbStream.ModTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive database: %w", err)
+	}
+	manifest.Files = append(manifest.Files, dbFile)
+
+	err = walkStoredFiles(ctx, store, func(info storage.FileInfo, name string) error {
+		if prev, ok := unchanged[name]; ok && prev.Size == info.Size && prev.ModTime.Equal(info.ModTime.UTC()) {
+			manifest.Files = append(manifest.Files, prev)
+			return nil
+		}
+
+		stream, err := openStoredFile(ctx, store, info.Path)
+		// Files deleted while the archive is written are left out.
+		if errors.Is(err, storage.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+		// The listing's modification time is what the next backup compares.
+		file, err := addHashedFileToZip(zw, stream, name, info.ModTime)
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+		manifest.Files = append(manifest.Files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeArchiveManifest(zw, manifest); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// ReadArchiveManifest returns the manifest of an instance archive.
+func ReadArchiveManifest(zr *zip.Reader) (*ExportManifest, error) {
+	for _, f := range zr.File {
+		if f.Name != archiveManifestName {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return readManifest(rc)
+	}
+	return nil, fmt.Errorf("archive has no manifest")
+}
+
+func readManifest(r io.Reader) (*ExportManifest, error) {
+	var manifest ExportManifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// CountArchiveFiles returns how many entries WriteInstanceArchive will write
+// and how many bytes of stored files they hold, for progress reporting.
+func CountArchiveFiles(ctx context.Context, store storage.Storage) (int, int64, error) {
+	count := 2 // manifest.json + vault.db
+	var size int64
+	err := walkStoredFiles(ctx, store, func(info storage.FileInfo, _ string) error {
+		count++
+		size += info.Size
+		return nil
+	})
+	return count, size, err
+}
+
+func writeArchiveManifest(zw *zip.Writer, manifest ExportManifest) error {
+	manifestFile, err := zw.Create(archiveManifestName)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(manifestFile).Encode(manifest)
+}
+
+// addHashedFileToZip copies a file into the archive, hashing it on the way,
+// and closes it.
+func addHashedFileToZip(zw *zip.Writer, stream *storage.FileStream, name string, modTime time.Time) (ArchiveFile, error) {
+	defer stream.Reader.Close()
+
+	zipFile, err := zw.Create(name)
+	if err != nil {
+		return ArchiveFile{}, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(zipFile, hash), stream.Reader)
+	if err != nil {
+		return ArchiveFile{}, err
+	}
+	return ArchiveFile{
+		Path:    name,
+		Size:    size,
+		ModTime: modTime.UTC(),
+		SHA256:  hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// openLocalFile opens a file on local disk, such as a database copy, as a
+// stream.
+func openLocalFile(path string) (*storage.FileStream, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &storage.FileStream{Reader: file, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func addFileToZip(zw *zip.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	zipFile, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(zipFile, file)
+	return err
+}
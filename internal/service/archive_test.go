@@ -0,0 +1,150 @@
+//go:build sqlite_fts5
+
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"ramiro-uziel/vault/internal/storage"
+)
+
+// memStorage is a storage backend without local files, like S3. Only the
+// methods archives use are implemented. Files are modified at a fixed time
+// unless modTimes says otherwise.
+type memStorage struct {
+	storage.Storage
+	files    map[string][]byte
+	modTimes map[string]time.Time
+}
+
+func (s *memStorage) modTime(path string) time.Time {
+	if modTime, ok := s.modTimes[path]; ok {
+		return modTime
+	}
+	return time.Unix(1700000000, 0)
+}
+
+func (s *memStorage) ListFiles(ctx context.Context, fn func(storage.FileInfo) error) error {
+	keys := make([]string, 0, len(s.files))
+	for key := range s.files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(storage.FileInfo{Path: key, Size: int64(len(s.files[key])), ModTime: s.modTime(key)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memStorage) OpenFile(ctx context.Context, path string) (*storage.FileStream, error) {
+	data, ok := s.files[path]
+	if !ok {
+		return nil, storage.ErrNotExist
+	}
+	return &storage.FileStream{
+		Reader:  nopSeekCloser{bytes.NewReader(data)},
+		Size:    int64(len(data)),
+		ModTime: s.modTime(path),
+	}, nil
+}
+
+type nopSeekCloser struct{ io.ReadSeeker }
+
+func (nopSeekCloser) Close() error { return nil }
+
+// readArchive returns the entries of an archive by name.
+func readArchive(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make(map[string][]byte, len(zr.File))
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[f.Name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return entries
+}
+
+func TestWriteInstanceArchiveReadsThroughStorage(t *testing.T) {
+	ctx := context.Background()
+	store := &memStorage{files: map[string][]byte{
+		"vault/projects/p1/tracks/1/versions/1/source.wav": []byte("source audio"),
+		"vault/projects/p1/covers/cover.png":               []byte("cover"),
+	}}
+
+	count, size, err := CountArchiveFiles(ctx, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 || size != int64(len("source audio")+len("cover")) {
+		t.Fatalf("CountArchiveFiles() = %d, %d", count, size)
+	}
+
+	var buf bytes.Buffer
+	var written []string
+	err = WriteInstanceArchive(ctx, &buf, newTestDB(t), store, ExportManifest{Version: ArchiveVersion}, func(name string) {
+		written = append(written, name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != count {
+		t.Fatalf("wrote %v, counted %d entries", written, count)
+	}
+
+	entries := readArchive(t, buf.Bytes())
+	if string(entries["projects/p1/tracks/1/versions/1/source.wav"]) != "source audio" ||
+		string(entries["projects/p1/covers/cover.png"]) != "cover" {
+		t.Fatalf("archive entries = %v", written)
+	}
+	if _, ok := entries["vault.db"]; !ok {
+		t.Fatal("archive has no database")
+	}
+}
+
+func TestWriteInstanceArchiveKeepsFilesEncrypted(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	keyring, err := storage.NewKeyring(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := storage.NewFilesystemStorage(dataDir)
+	store.SetKeyring(keyring)
+
+	path := filepath.Join(dataDir, "projects", "p1", "tracks", "1", "versions", "1", "source.wav")
+	if _, err := store.WriteFile(ctx, path, strings.NewReader("secret audio")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteInstanceArchive(ctx, &buf, newTestDB(t), store, ExportManifest{Version: ArchiveVersion}, nil); err != nil {
+		t.Fatal(err)
+	}
+	archived, ok := readArchive(t, buf.Bytes())["projects/p1/tracks/1/versions/1/source.wav"]
+	if !ok {
+		t.Fatal("archive does not hold the stored file")
+	}
+	if bytes.Contains(archived, []byte("secret audio")) {
+		t.Fatal("an encrypted file was archived in the clear")
+	}
+}
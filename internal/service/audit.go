@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+)
+
+// Audited actions. The part before the dot is the kind of thing acted on.
+const (
+	AuditLogin            = "auth.login"
+	AuditLoginFailed      = "auth.login_failed"
+	AuditPasswordReset    = "auth.password_reset"
+	AuditPasswordChange   = "auth.password_change"
+	AuditEmailChange      = "auth.email_change"
+	AuditAccountDelete    = "auth.account_delete"
+	AuditTwoFactorEnable  = "auth.2fa_enable"
+	AuditTwoFactorDisable = "auth.2fa_disable"
+	AuditRecoveryCodes    = "auth.recovery_codes"
+	AuditPasskeyAdd       = "auth.passkey_add"
+	AuditPasskeyRemove    = "auth.passkey_remove"
+	AuditTokenCreate      = "auth.token_create"
+	AuditTokenRevoke      = "auth.token_revoke"
+	AuditIdentityLink     = "auth.identity_link"
+	AuditIdentityUnlink   = "auth.identity_unlink"
+	AuditSessionRevoke    = "auth.session_revoke"
+	AuditLockout          = "auth.lockout"
+	AuditUnlock           = "auth.unlock"
+	AuditUserInvite       = "user.invite"
+	AuditUserRole         = "user.role_change"
+	AuditUserRename       = "user.rename"
+	AuditUserDelete       = "user.delete"
+	AuditUserResetLink    = "user.reset_link"
+	AuditTwoFactorReset   = "user.2fa_reset"
+	AuditUserSessions     = "user.session_revoke"
+	AuditTakeout          = "user.takeout"
+	AuditInstanceExport   = "instance.export"
+	AuditInstanceImport   = "instance.import"
+	AuditInstanceReset    = "instance.reset"
+	AuditInstanceRename   = "instance.rename"
+	AuditInstanceBackup   = "instance.backup"
+	AuditInstanceSecurity = "instance.security"
+	AuditProjectDelete    = "project.delete"
+	AuditProjectImport    = "project.import"
+	AuditTrackDelete      = "track.delete"
+	AuditVersionDelete    = "version.delete"
+	AuditFolderDelete     = "folder.delete"
+	AuditTrashRestore     = "trash.restore"
+	AuditTrashPurge       = "trash.purge"
+	AuditTrashEmpty       = "trash.empty"
+	AuditTrashExpire      = "trash.expire"
+	AuditShareCreate      = "share.create"
+	AuditShareUpdate      = "share.update"
+	AuditShareRevoke      = "share.revoke"
+	AuditShareLeave       = "share.leave"
+	AuditVisibility       = "share.visibility"
+	AuditAuditExport      = "audit.export"
+)
+
+// AuditActor is who performed an action. The zero value is the server
+// itself.
+type AuditActor struct {
+	UserID    int64
+	Username  string
+	IP        string
+	UserAgent string
+}
+
+// AuditEvent describes one action. Before and After summarize the target's
+// state around the action and are stored as JSON; either may be nil.
+type AuditEvent struct {
+	Actor      AuditActor
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     any
+	After      any
+}
+
+type AuditService interface {
+	// Record appends an event to the audit log. A failure to record is
+	// logged rather than returned, so it never undoes the action itself.
+	Record(ctx context.Context, event AuditEvent)
+	List(ctx context.Context, filter db.AuditFilter) ([]sqlc.AuditEvent, error)
+	Each(ctx context.Context, filter db.AuditFilter, fn func(sqlc.AuditEvent) error) error
+	// Carry reads the whole log so it can be written back with Restore after
+	// the database is replaced by an import or a reset.
+	Carry(ctx context.Context) ([]sqlc.AuditEvent, error)
+	Restore(ctx context.Context, events []sqlc.AuditEvent) error
+	PurgeExpired(ctx context.Context) (int64, error)
+	Retention() time.Duration
+}
+
+type auditService struct {
+	db        *db.DB
+	retention time.Duration
+}
+
+// NewAuditService returns an audit service that purges events older than
+// retention. A zero retention keeps them forever.
+func NewAuditService(database *db.DB, retention time.Duration) AuditService {
+	return &auditService{
+		db:        database,
+		retention: retention,
+	}
+}
+
+func (s *auditService) Retention() time.Duration {
+	return s.retention
+}
+
+func (s *auditService) Record(ctx context.Context, event AuditEvent) {
+	params := sqlc.CreateAuditEventParams{
+		CreatedAt:     time.Now().UTC(),
+		ActorUsername: sql.NullString{String: event.Actor.Username, Valid: event.Actor.Username != ""},
+		Ip:            sql.NullString{String: event.Actor.IP, Valid: event.Actor.IP != ""},
+		UserAgent:     sql.NullString{String: event.Actor.UserAgent, Valid: event.Actor.UserAgent != ""},
+		Action:        event.Action,
+		TargetType:    sql.NullString{String: event.TargetType, Valid: event.TargetType != ""},
+		TargetID:      sql.NullString{String: event.TargetID, Valid: event.TargetID != ""},
+		BeforeSummary: auditSummary(event.Before),
+		AfterSummary:  auditSummary(event.After),
+	}
+	if event.Actor.UserID != 0 {
+		params.ActorID = sql.NullInt64{Int64: event.Actor.UserID, Valid: true}
+	}
+
+	// The action has already happened, so record it even if the request
+	// that caused it has been cancelled.
+	if err := s.db.CreateAuditEvent(context.WithoutCancel(ctx), params); err != nil {
+		slog.Error("Failed to record audit event", "action", event.Action, "target_type", event.TargetType, "target_id", event.TargetID, "error", err)
+	}
+}
+
+func (s *auditService) List(ctx context.Context, filter db.AuditFilter) ([]sqlc.AuditEvent, error) {
+	return s.db.ListAuditEvents(ctx, filter)
+}
+
+func (s *auditService) Each(ctx context.Context, filter db.AuditFilter, fn func(sqlc.AuditEvent) error) error {
+	return s.db.EachAuditEvent(ctx, filter, fn)
+}
+
+func (s *auditService) Carry(ctx context.Context) ([]sqlc.AuditEvent, error) {
+	return s.db.ListAllAuditEvents(ctx)
+}
+
+// Restore appends carried events in their original order. They get new IDs
+// but keep their times, and land after any events already in the database.
+func (s *auditService) Restore(ctx context.Context, events []sqlc.AuditEvent) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := s.db.Queries.WithTx(tx)
+	for _, event := range events {
+		if err := queries.CreateAuditEvent(ctx, sqlc.CreateAuditEventParams{
+			CreatedAt:     event.CreatedAt,
+			ActorID:       event.ActorID,
+			ActorUsername: event.ActorUsername,
+			Ip:            event.Ip,
+			UserAgent:     event.UserAgent,
+			Action:        event.Action,
+			TargetType:    event.TargetType,
+			TargetID:      event.TargetID,
+			BeforeSummary: event.BeforeSummary,
+			AfterSummary:  event.AfterSummary,
+		}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *auditService) PurgeExpired(ctx context.Context) (int64, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	return s.db.DeleteAuditEventsBefore(ctx, time.Now().UTC().Add(-s.retention))
+}
+
+func auditSummary(v any) sql.NullString {
+	if v == nil {
+		return sql.NullString{}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Warn("Failed to encode audit summary", "error", err)
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+// RunAuditPurge deletes expired audit events every interval until ctx is
+// cancelled. It does nothing when retention is disabled.
+func RunAuditPurge(ctx context.Context, audit AuditService, interval time.Duration) {
+	if audit.Retention() <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := audit.PurgeExpired(ctx)
+		if err != nil {
+			slog.Warn("Audit log purge failed", "error", err)
+		} else if purged > 0 {
+			slog.Info("Purged expired audit events", "events", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"ramiro-uziel/vault/internal/auth"
 	"ramiro-uziel/vault/internal/db"
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/webauthn"
 )
 
 var (
@@ -42,9 +43,30 @@ type AuthService interface {
 
 	ValidateResetToken(ctx context.Context, token string) (bool, error)
 	ValidateInviteToken(ctx context.Context, token string) (bool, error)
-	ResetPassword(ctx context.Context, token, newPassword string) error
+	// ResetPassword sets a new password from a reset token and returns the
+	// ID of the user it belongs to.
+	ResetPassword(ctx context.Context, token, newPassword string) (int64, error)
 
 	GetInviteToken(ctx context.Context, token string) (*InviteToken, error)
+
+	// ChangePassword sets a new password for a user who knows the current
+	// one, and ends every session but keepSessionID.
+	ChangePassword(ctx context.Context, userID int64, currentPassword, newPassword string, keepSessionID int64) error
+	// RequestEmailChange checks the password and returns a token for the new
+	// address. The email only changes once the token is confirmed.
+	RequestEmailChange(ctx context.Context, userID int64, password, newEmail string) (*EmailChange, error)
+	ConfirmEmailChange(ctx context.Context, token string) (*EmailChangeResult, error)
+
+	// Passkeys. Each ceremony is a begin call that returns options for
+	// the browser and a finish call that verifies its response.
+	BeginPasskeyRegistration(ctx context.Context, userID int64) (*webauthn.CreationOptions, error)
+	FinishPasskeyRegistration(ctx context.Context, userID int64, name string, response *webauthn.RegistrationResponse) (*Passkey, error)
+	// BeginPasskeyLogin starts a passwordless login when userID is nil, and
+	// a second factor check for that user otherwise.
+	BeginPasskeyLogin(ctx context.Context, userID *int64) (*webauthn.RequestOptions, error)
+	FinishPasskeyLogin(ctx context.Context, userID *int64, response *webauthn.AuthenticationResponse) (*User, error)
+	ListPasskeys(ctx context.Context, userID int64) ([]Passkey, error)
+	DeletePasskey(ctx context.Context, userID, passkeyID int64) error
 }
 
 type authService struct {
@@ -418,11 +440,6 @@ func (s *authService) GetInviteToken(ctx context.Context, token string) (*Invite
 }
 
 func (s *authService) CreateSession(ctx context.Context, userID int, username string, meta SessionMeta) (*SessionTokens, error) {
-	accessToken, err := auth.GenerateToken(userID, username, s.authConfig)
-	if err != nil {
-		return nil, err
-	}
-
 	refreshToken, err := auth.GenerateSecureToken(32)
 	if err != nil {
 		return nil, err
@@ -431,7 +448,7 @@ func (s *authService) CreateSession(ctx context.Context, userID int, username st
 	refreshHash := auth.HashToken(refreshToken, s.authConfig.TokenPepper)
 	expiresAt := time.Now().Add(s.authConfig.RefreshExpiration)
 
-	_, err = s.db.Queries.CreateRefreshToken(ctx, sqlc.CreateRefreshTokenParams{
+	session, err := s.db.Queries.CreateRefreshToken(ctx, sqlc.CreateRefreshTokenParams{
 		UserID:    int64(userID),
 		TokenHash: refreshHash,
 		ExpiresAt: expiresAt,
@@ -442,6 +459,17 @@ func (s *authService) CreateSession(ctx context.Context, userID int, username st
 		return nil, err
 	}
 
+	return s.sessionTokens(ctx, userID, username, session.ID, refreshToken)
+}
+
+// sessionTokens issues the access and CSRF tokens for a session that holds
+// refreshToken.
+func (s *authService) sessionTokens(ctx context.Context, userID int, username string, sessionID int64, refreshToken string) (*SessionTokens, error) {
+	accessToken, err := auth.GenerateToken(userID, username, sessionID, s.authConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	csrfToken, err := auth.GenerateSecureToken(16)
 	if err != nil {
 		return nil, err
@@ -477,15 +505,33 @@ func (s *authService) RefreshSession(ctx context.Context, refreshToken string, m
 		return nil, ErrTokenExpired
 	}
 
-	_ = s.db.Queries.UpdateRefreshTokenLastUsed(ctx, stored.ID)
-	_ = s.db.Queries.RevokeRefreshToken(ctx, stored.ID)
-
 	user, err := s.db.Queries.GetUserByID(ctx, stored.UserID)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.CreateSession(ctx, int(user.ID), user.Username, meta)
+	// The refresh token is replaced in place, so the session keeps its ID
+	// for as long as it is refreshed.
+	newRefreshToken, err := auth.GenerateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+	rotated, err := s.db.Queries.RotateRefreshToken(ctx, sqlc.RotateRefreshTokenParams{
+		TokenHash:    auth.HashToken(newRefreshToken, s.authConfig.TokenPepper),
+		ExpiresAt:    time.Now().Add(s.authConfig.RefreshExpiration),
+		UserAgent:    sql.NullString{String: meta.UserAgent, Valid: meta.UserAgent != ""},
+		Ip:           sql.NullString{String: meta.IP, Valid: meta.IP != ""},
+		ID:           stored.ID,
+		OldTokenHash: refreshHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rotated == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	return s.sessionTokens(ctx, int(user.ID), user.Username, stored.ID, newRefreshToken)
 }
 
 func (s *authService) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
@@ -511,48 +557,48 @@ func (s *authService) InvalidateUserSessions(ctx context.Context, userID int64)
 	return s.db.Queries.RevokeRefreshTokensByUser(ctx, userID)
 }
 
-func (s *authService) ResetPassword(ctx context.Context, token, newPassword string) error {
+func (s *authService) ResetPassword(ctx context.Context, token, newPassword string) (int64, error) {
 	if token == "" || newPassword == "" {
-		return errors.New("token and password are required")
+		return 0, errors.New("token and password are required")
 	}
 
 	inviteToken, err := s.db.Queries.GetInviteTokenByToken(ctx, auth.HashToken(token, s.authConfig.TokenPepper))
 	if errors.Is(err, sql.ErrNoRows) {
-		return ErrInvalidToken
+		return 0, ErrInvalidToken
 	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if inviteToken.TokenType != "reset" {
-		return ErrInvalidTokenType
+		return 0, ErrInvalidTokenType
 	}
 	if inviteToken.Used {
-		return ErrTokenUsed
+		return 0, ErrTokenUsed
 	}
 	if time.Now().After(inviteToken.ExpiresAt) {
-		return ErrTokenExpired
+		return 0, ErrTokenExpired
 	}
 	if !inviteToken.UserID.Valid {
-		return ErrInvalidToken
+		return 0, ErrInvalidToken
 	}
 
 	passwordHash, err := auth.HashPassword(newPassword)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if _, err := s.db.Queries.UpdateUserPassword(ctx, sqlc.UpdateUserPasswordParams{
 		PasswordHash: passwordHash,
 		ID:           inviteToken.UserID.Int64,
 	}); err != nil {
-		return err
+		return 0, err
 	}
 
 	if _, err := s.db.Queries.MarkTokenAsUsed(ctx, inviteToken.ID); err != nil {
-		return err
+		return 0, err
 	}
 
-	return s.InvalidateUserSessions(ctx, inviteToken.UserID.Int64)
+	return inviteToken.UserID.Int64, s.InvalidateUserSessions(ctx, inviteToken.UserID.Int64)
 }
 
 func sqlcUserToServiceUser(user sqlc.User) *User {
@@ -0,0 +1,567 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/storage"
+)
+
+// Values of backup_runs.trigger and backup_runs.status.
+const (
+	BackupScheduled = "scheduled"
+	BackupManual    = "manual"
+
+	BackupRunning   = "running"
+	BackupSucceeded = "succeeded"
+	BackupFailed    = "failed"
+)
+
+// backupRunsToKeep is how many past runs are kept for the status report.
+const backupRunsToKeep = 100
+
+// Backups are named after the time they were taken, so names sort by age and
+// retention can work from the name alone. An incremental backup's name also
+// carries the time of its base: vault-backup-<time>-from-<base time>.zip.
+const (
+	backupNamePrefix = "vault-backup-"
+	backupNameSuffix = ".zip"
+	backupBaseSep    = "-from-"
+	backupTimeFormat = "20060102T150405Z"
+)
+
+// backupIndexFile, in the data directory, holds the manifest of the last
+// backup so the next one can leave out what has not changed.
+const backupIndexFile = "backup-index.json"
+
+var ErrBackupRunning = errors.New("a backup is already running")
+
+const interruptedBackup = "interrupted before it finished"
+
+// BackupPolicy sets when backups run and which ones are kept. A zero
+// Interval leaves backups to be started by hand. Each kept daily backup is
+// the newest of its day and each weekly one the newest of its ISO week, in
+// UTC; a backup counted by either is kept, along with the backups it was
+// taken against. With both zero nothing is pruned.
+//
+// Every FullEvery-th backup is a full one and those in between are
+// incremental, each holding only the files changed since the backup before
+// it. FullEvery of 1 or less makes every backup full.
+type BackupPolicy struct {
+	Interval   time.Duration
+	KeepDaily  int
+	KeepWeekly int
+	FullEvery  int
+}
+
+type BackupService interface {
+	// Run takes a backup now and prunes old ones. It returns
+	// ErrBackupRunning if another backup has not finished.
+	Run(ctx context.Context, trigger string) (*sqlc.BackupRun, error)
+	Status(ctx context.Context) (*BackupStatus, error)
+	// NextRun is when the next scheduled backup is due, or the zero time
+	// if backups are not scheduled.
+	NextRun(ctx context.Context) (time.Time, error)
+	// FailInterrupted marks runs recorded as running, but not running in
+	// this process, as failed.
+	FailInterrupted(ctx context.Context) error
+	Policy() BackupPolicy
+}
+
+type BackupStatus struct {
+	Policy      BackupPolicy
+	Destination string
+	Running     bool
+	NextRunAt   time.Time
+	LastRun     *sqlc.BackupRun
+	LastSuccess *sqlc.BackupRun
+	// Backups lists the archives at the destination, oldest first. When
+	// the destination cannot be read, BackupsError says why.
+	Backups      []StoredBackup
+	BackupsError error
+}
+
+type StoredBackup struct {
+	storage.FileInfo
+	// Kind is ArchiveFull or ArchiveIncremental, or empty for files this
+	// service did not write. Base names the backup an incremental one was
+	// taken against, if it is still there.
+	Kind string
+	Base string
+}
+
+type backupService struct {
+	db      *db.DB
+	dataDir string
+	storage storage.Storage
+	keyring *storage.Keyring
+	dest    storage.BackupDestination
+	policy  BackupPolicy
+	mu      sync.Mutex
+	running bool
+}
+
+func NewBackupService(database *db.DB, dataDir string, storageAdapter storage.Storage, keyring *storage.Keyring, dest storage.BackupDestination, policy BackupPolicy) BackupService {
+	return &backupService{
+		db:      database,
+		dataDir: dataDir,
+		storage: storageAdapter,
+		keyring: keyring,
+		dest:    dest,
+		policy:  policy,
+	}
+}
+
+func (s *backupService) Policy() BackupPolicy {
+	return s.policy
+}
+
+func (s *backupService) Run(ctx context.Context, trigger string) (*sqlc.BackupRun, error) {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return nil, ErrBackupRunning
+	}
+	s.running = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	if err := s.failInterrupted(ctx); err != nil {
+		return nil, err
+	}
+
+	startedAt := time.Now().UTC()
+	run, err := s.db.CreateBackupRun(ctx, sqlc.CreateBackupRunParams{
+		StartedAt:   startedAt,
+		Trigger:     trigger,
+		Destination: s.dest.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	name, size, runErr := s.write(ctx, startedAt)
+
+	run.FinishedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	if runErr != nil {
+		run.Status = BackupFailed
+		run.Error = sql.NullString{String: runErr.Error(), Valid: true}
+		slog.Error("Backup failed", "destination", s.dest.String(), "error", runErr)
+	} else {
+		run.Status = BackupSucceeded
+		run.Name = sql.NullString{String: name, Valid: true}
+		run.SizeBytes = sql.NullInt64{Int64: size, Valid: true}
+		slog.Info("Backup written", "destination", s.dest.String(), "name", name, "size", size)
+	}
+
+	// Record the outcome even if the run was cancelled part way.
+	finishCtx := context.WithoutCancel(ctx)
+	if err := s.db.FinishBackupRun(finishCtx, sqlc.FinishBackupRunParams{
+		FinishedAt: run.FinishedAt,
+		Status:     run.Status,
+		Name:       run.Name,
+		SizeBytes:  run.SizeBytes,
+		Error:      run.Error,
+		ID:         run.ID,
+	}); err != nil {
+		slog.Warn("Failed to record backup run", "error", err)
+	}
+	if err := s.db.DeleteOldBackupRuns(finishCtx, backupRunsToKeep); err != nil {
+		slog.Warn("Failed to prune backup history", "error", err)
+	}
+
+	if runErr == nil {
+		if err := s.prune(ctx); err != nil {
+			slog.Warn("Failed to prune old backups", "destination", s.dest.String(), "error", err)
+		}
+	}
+	return &run, runErr
+}
+
+// write builds the archive in a temporary file, since destinations need its
+// size up front, and uploads it. The backup is incremental when the last one
+// can serve as its base.
+func (s *backupService) write(ctx context.Context, createdAt time.Time) (string, int64, error) {
+	settings, err := s.db.GetInstanceSettings(ctx)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read instance settings: %w", err)
+	}
+
+	tmp, err := os.CreateTemp("", "vault-backup-*.zip")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	manifest := ExportManifest{
+		Version:         ArchiveVersion,
+		AppVersion:      "v0.0.1",
+		InstanceName:    settings.Name,
+		CreatedAt:       createdAt,
+		EncryptionKeyID: s.keyring.CurrentKeyID(),
+	}
+	name := backupName(createdAt, time.Time{})
+	chain := 0
+	base := s.incrementalBase(ctx)
+	if base != nil {
+		manifest.Base = base.Name
+		baseAt, _, _ := parseBackupName(base.Name)
+		name = backupName(createdAt, baseAt)
+		chain = base.Chain + 1
+	}
+
+	var baseManifest *ExportManifest
+	if base != nil {
+		baseManifest = &base.Manifest
+	}
+	written, err := WriteBackupArchive(ctx, tmp, s.db, s.storage, manifest, baseManifest)
+	if err != nil {
+		return "", 0, err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	if err := s.dest.PutBackup(ctx, name, tmp, size); err != nil {
+		return "", 0, fmt.Errorf("failed to upload backup: %w", err)
+	}
+
+	if err := s.saveIndex(backupIndex{Name: name, Chain: chain, Manifest: *written}); err != nil {
+		slog.Warn("Failed to save backup index, the next backup will be full", "error", err)
+		os.Remove(filepath.Join(s.dataDir, backupIndexFile))
+	}
+	return name, size, nil
+}
+
+// backupIndex is what the data directory remembers of the last backup.
+type backupIndex struct {
+	Name string `json:"name"`
+	// Chain counts the incremental backups since the last full one.
+	Chain    int            `json:"chain"`
+	Manifest ExportManifest `json:"manifest"`
+}
+
+// incrementalBase returns the last backup if the next one can be taken
+// against it: the policy allows another incremental and every archive it
+// depends on is still at the destination. Otherwise the next backup is full.
+func (s *backupService) incrementalBase(ctx context.Context) *backupIndex {
+	if s.policy.FullEvery <= 1 {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(s.dataDir, backupIndexFile))
+	if err != nil {
+		return nil
+	}
+	var index backupIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		slog.Warn("Ignoring unreadable backup index", "error", err)
+		return nil
+	}
+	if index.Chain+1 >= s.policy.FullEvery {
+		return nil
+	}
+	if _, _, ok := parseBackupName(index.Name); !ok {
+		return nil
+	}
+
+	stored, err := s.dest.ListBackups(ctx)
+	if err != nil {
+		return nil
+	}
+	present := map[string]bool{}
+	for _, backup := range stored {
+		present[backup.Path] = true
+	}
+	if !present[index.Name] {
+		slog.Info("Last backup is gone from the destination, taking a full backup", "name", index.Name)
+		return nil
+	}
+	for _, file := range index.Manifest.Files {
+		if file.Archive != "" && !present[file.Archive] {
+			slog.Info("A backup the last one depends on is gone, taking a full backup", "name", file.Archive)
+			return nil
+		}
+	}
+	return &index
+}
+
+func (s *backupService) saveIndex(index backupIndex) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dataDir, backupIndexFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// backupName names a backup taken at at, against the backup taken at base if
+// it is incremental.
+func backupName(at, base time.Time) string {
+	name := backupNamePrefix + at.UTC().Format(backupTimeFormat)
+	if !base.IsZero() {
+		name += backupBaseSep + base.UTC().Format(backupTimeFormat)
+	}
+	return name + backupNameSuffix
+}
+
+// parseBackupName reverses backupName. base is zero for full backups; ok is
+// false for names this service does not write.
+func parseBackupName(name string) (at, base time.Time, ok bool) {
+	rest, found := strings.CutPrefix(name, backupNamePrefix)
+	if !found {
+		return time.Time{}, time.Time{}, false
+	}
+	rest, found = strings.CutSuffix(rest, backupNameSuffix)
+	if !found {
+		return time.Time{}, time.Time{}, false
+	}
+	atPart, basePart, incremental := strings.Cut(rest, backupBaseSep)
+	at, err := time.Parse(backupTimeFormat, atPart)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	if incremental {
+		if base, err = time.Parse(backupTimeFormat, basePart); err != nil || !base.Before(at) {
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	return at, base, true
+}
+
+// storedBackups describes backups by their names, resolving each
+// incremental backup's base among the others.
+func storedBackups(files []storage.FileInfo) []StoredBackup {
+	byTime := map[time.Time]string{}
+	for _, file := range files {
+		if at, _, ok := parseBackupName(file.Path); ok {
+			byTime[at] = file.Path
+		}
+	}
+
+	backups := make([]StoredBackup, 0, len(files))
+	for _, file := range files {
+		backup := StoredBackup{FileInfo: file}
+		if _, base, ok := parseBackupName(file.Path); ok {
+			backup.Kind = ArchiveFull
+			if !base.IsZero() {
+				backup.Kind = ArchiveIncremental
+				backup.Base = byTime[base]
+			}
+		}
+		backups = append(backups, backup)
+	}
+	return backups
+}
+
+// prune deletes the backups the policy no longer keeps. Files whose names
+// this service did not write are left alone.
+func (s *backupService) prune(ctx context.Context) error {
+	if s.policy.KeepDaily <= 0 && s.policy.KeepWeekly <= 0 {
+		return nil
+	}
+	backups, err := s.dest.ListBackups(ctx)
+	if err != nil {
+		return err
+	}
+
+	var taken []backupTime
+	for _, backup := range backups {
+		if at, base, ok := parseBackupName(backup.Path); ok {
+			taken = append(taken, backupTime{name: backup.Path, at: at, base: base})
+		}
+	}
+
+	for _, name := range expiredBackups(taken, s.policy) {
+		if err := s.dest.DeleteBackup(ctx, name); err != nil && !errors.Is(err, storage.ErrNotExist) {
+			return err
+		}
+		slog.Info("Deleted old backup", "destination", s.dest.String(), "name", name)
+	}
+	return nil
+}
+
+type backupTime struct {
+	name string
+	at   time.Time
+	// base is when the backup this one was taken against was taken, or
+	// zero for a full backup.
+	base time.Time
+}
+
+// expiredBackups returns the backups the policy does not keep. taken must be
+// oldest first. Backups a kept incremental one depends on are kept too.
+func expiredBackups(taken []backupTime, policy BackupPolicy) []string {
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	kept := map[time.Time]bool{}
+	byTime := map[time.Time]backupTime{}
+	for _, backup := range taken {
+		byTime[backup.at] = backup
+	}
+
+	for i := len(taken) - 1; i >= 0; i-- {
+		backup := taken[i]
+		day := backup.at.Format(time.DateOnly)
+		year, week := backup.at.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+
+		keep := false
+		if !days[day] && len(days) < policy.KeepDaily {
+			days[day] = true
+			keep = true
+		}
+		if !weeks[weekKey] && len(weeks) < policy.KeepWeekly {
+			weeks[weekKey] = true
+			keep = true
+		}
+		if !keep {
+			continue
+		}
+		for at := backup.at; !at.IsZero() && !kept[at]; at = byTime[at].base {
+			kept[at] = true
+		}
+	}
+
+	var expired []string
+	for i := len(taken) - 1; i >= 0; i-- {
+		if !kept[taken[i].at] {
+			expired = append(expired, taken[i].name)
+		}
+	}
+	return expired
+}
+
+func (s *backupService) Status(ctx context.Context) (*BackupStatus, error) {
+	status := &BackupStatus{
+		Policy:      s.policy,
+		Destination: s.dest.String(),
+	}
+
+	s.mu.Lock()
+	status.Running = s.running
+	s.mu.Unlock()
+
+	lastRun, err := s.db.GetLastBackupRun(ctx)
+	if err == nil {
+		// A run recorded as running that this process is not running was
+		// cut short, by a restart or by restoring a backup taken during it.
+		if lastRun.Status == BackupRunning && !status.Running {
+			lastRun.Status = BackupFailed
+			lastRun.Error = sql.NullString{String: interruptedBackup, Valid: true}
+		}
+		status.LastRun = &lastRun
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	lastSuccess, err := s.db.GetLastSuccessfulBackupRun(ctx)
+	if err == nil {
+		status.LastSuccess = &lastSuccess
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if status.NextRunAt, err = s.NextRun(ctx); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.dest.ListBackups(ctx)
+	if err != nil {
+		status.BackupsError = err
+	}
+	status.Backups = storedBackups(stored)
+	return status, nil
+}
+
+// NextRun schedules from the start of the last run, so restarts do not cause
+// extra backups. A failed run is retried within the hour.
+func (s *backupService) NextRun(ctx context.Context) (time.Time, error) {
+	if s.policy.Interval <= 0 {
+		return time.Time{}, nil
+	}
+	lastRun, err := s.db.GetLastBackupRun(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Now().UTC(), nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	wait := s.policy.Interval
+	if lastRun.Status == BackupFailed {
+		wait = min(wait, time.Hour)
+	}
+	return lastRun.StartedAt.Add(wait), nil
+}
+
+func (s *backupService) FailInterrupted(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return nil
+	}
+	return s.failInterrupted(ctx)
+}
+
+func (s *backupService) failInterrupted(ctx context.Context) error {
+	return s.db.FailRunningBackupRuns(ctx, sqlc.FailRunningBackupRunsParams{
+		FinishedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		Error:      sql.NullString{String: interruptedBackup, Valid: true},
+	})
+}
+
+// RunBackups takes scheduled backups until ctx is cancelled. Runs left
+// unfinished by a previous process are marked failed first; with no
+// interval set it returns after that.
+func RunBackups(ctx context.Context, backups BackupService) {
+	if err := backups.FailInterrupted(ctx); err != nil {
+		slog.Warn("Failed to clean up interrupted backups", "error", err)
+	}
+	if backups.Policy().Interval <= 0 {
+		return
+	}
+
+	for {
+		next, err := backups.NextRun(ctx)
+		if err != nil {
+			slog.Warn("Failed to schedule backup", "error", err)
+			next = time.Now().Add(time.Hour)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// Failures are logged and recorded by Run, and NextRun retries
+		// them. A backup started by hand meanwhile pushes the schedule back.
+		backups.Run(ctx, BackupScheduled)
+	}
+}
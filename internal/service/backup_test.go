@@ -0,0 +1,116 @@
+//go:build sqlite_fts5
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ramiro-uziel/vault/internal/storage"
+)
+
+// runTestBackup takes a backup and returns its entries and manifest.
+func runTestBackup(t *testing.T, backups BackupService, dest storage.BackupDestination) (string, map[string][]byte, ExportManifest) {
+	t.Helper()
+	ctx := context.Background()
+	run, err := backups.Run(ctx, "manual")
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := dest.GetBackup(ctx, run.Name.String, &buf); err != nil {
+		t.Fatal(err)
+	}
+	entries := readArchive(t, buf.Bytes())
+	var manifest ExportManifest
+	if err := json.Unmarshal(entries[archiveManifestName], &manifest); err != nil {
+		t.Fatal(err)
+	}
+	return run.Name.String, entries, manifest
+}
+
+func TestBackupReadsStoredFilesThroughStorage(t *testing.T) {
+	database := newTestDB(t)
+	dest, err := storage.NewLocalBackups(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &memStorage{files: map[string][]byte{
+		"vault/projects/p1/tracks/1/versions/1/source.wav": []byte("source audio"),
+	}}
+	backups := NewBackupService(database, t.TempDir(), store, nil, dest, BackupPolicy{KeepDaily: 7})
+
+	_, entries, manifest := runTestBackup(t, backups, dest)
+	if string(entries["projects/p1/tracks/1/versions/1/source.wav"]) != "source audio" {
+		t.Fatalf("backup does not hold the stored file: %v", manifest.Files)
+	}
+	if manifest.Kind != ArchiveFull || len(manifest.Files) != 2 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+}
+
+func TestIncrementalBackupCopiesOnlyChangedStoredFiles(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	dest, err := storage.NewLocalBackups(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		kept    = "vault/projects/p1/tracks/1/versions/1/source.wav"
+		changed = "vault/projects/p1/tracks/2/versions/1/source.wav"
+		added   = "vault/projects/p1/tracks/3/versions/1/source.wav"
+	)
+	store := &memStorage{
+		files: map[string][]byte{
+			kept:    []byte("kept audio"),
+			changed: []byte("first take"),
+		},
+		modTimes: map[string]time.Time{},
+	}
+	backups := NewBackupService(database, t.TempDir(), store, nil, dest, BackupPolicy{KeepDaily: 7, FullEvery: 7})
+
+	full, _, _ := runTestBackup(t, backups, dest)
+
+	store.files[changed] = []byte("second take")
+	store.modTimes[changed] = time.Unix(1800000000, 0)
+	store.files[added] = []byte("new track")
+	incremental, entries, manifest := runTestBackup(t, backups, dest)
+
+	if manifest.Kind != ArchiveIncremental || manifest.Base != full {
+		t.Fatalf("second backup = %s based on %q, want incremental on %s", manifest.Kind, manifest.Base, full)
+	}
+	if _, ok := entries[archivePathOf(kept)]; ok {
+		t.Error("an unchanged file was copied again")
+	}
+	if string(entries[archivePathOf(changed)]) != "second take" || string(entries[archivePathOf(added)]) != "new track" {
+		t.Error("a changed or new file was not copied")
+	}
+	for _, file := range manifest.Files {
+		if file.Path == archivePathOf(kept) && file.Archive != full {
+			t.Errorf("unchanged file is listed in %q, want %s", file.Archive, full)
+		}
+	}
+
+	report, err := RestoreBackup(ctx, dest, RestoreOptions{Name: incremental, VerifyOnly: true})
+	if err != nil {
+		t.Fatalf("verifying the incremental backup: %v", err)
+	}
+	if report.Files != 4 || len(report.Archives) != 2 {
+		t.Fatalf("verified %d files from %v, want 4 files from both backups", report.Files, report.Archives)
+	}
+
+	target := t.TempDir()
+	if _, err := RestoreBackup(ctx, dest, RestoreOptions{Name: incremental, TargetDir: target}); err != nil {
+		t.Fatalf("restoring the incremental backup: %v", err)
+	}
+	restored, err := os.ReadFile(filepath.Join(target, filepath.FromSlash(archivePathOf(kept))))
+	if err != nil || string(restored) != "kept audio" {
+		t.Fatalf("file restored from the base backup = %q, %v", restored, err)
+	}
+}
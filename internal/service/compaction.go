@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/storage"
+	"ramiro-uziel/vault/internal/transcoding"
+)
+
+// Values of track_files.compaction_status.
+const (
+	CompactionCompacted = "compacted"
+	CompactionSkipped   = "skipped"
+	CompactionFailed    = "failed"
+)
+
+// compactionBatchSize is how many sources one compaction run looks at.
+const compactionBatchSize = 20
+
+type CompactionService interface {
+	Status(ctx context.Context) (*CompactionStatus, error)
+	SetEnabled(ctx context.Context, enabled bool) error
+	RetryFailed(ctx context.Context) error
+	// CompactPending works through up to limit eligible sources and returns
+	// how many it processed. It does nothing while the policy is off.
+	CompactPending(ctx context.Context, limit int) (int, error)
+}
+
+type CompactionStatus struct {
+	Enabled        bool  `json:"enabled"`
+	PendingFiles   int64 `json:"pending_files"`
+	CompactedFiles int64 `json:"compacted_files"`
+	SkippedFiles   int64 `json:"skipped_files"`
+	FailedFiles    int64 `json:"failed_files"`
+	SavedBytes     int64 `json:"saved_bytes"`
+}
+
+type compactionService struct {
+	db      *db.DB
+	storage storage.Storage
+}
+
+func NewCompactionService(database *db.DB, storageAdapter storage.Storage) CompactionService {
+	return &compactionService{
+		db:      database,
+		storage: storageAdapter,
+	}
+}
+
+func (s *compactionService) Status(ctx context.Context) (*CompactionStatus, error) {
+	enabled, err := s.db.GetSourceCompaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.db.GetCompactionStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	savedBytes, _ := stats.SavedBytes.(int64)
+
+	return &CompactionStatus{
+		Enabled:        enabled,
+		PendingFiles:   stats.PendingFiles,
+		CompactedFiles: stats.CompactedFiles,
+		SkippedFiles:   stats.SkippedFiles,
+		FailedFiles:    stats.FailedFiles,
+		SavedBytes:     savedBytes,
+	}, nil
+}
+
+func (s *compactionService) SetEnabled(ctx context.Context, enabled bool) error {
+	return s.db.SetSourceCompaction(ctx, enabled)
+}
+
+// RetryFailed puts sources whose compaction failed back in the queue.
+func (s *compactionService) RetryFailed(ctx context.Context) error {
+	return s.db.ResetFailedCompactions(ctx)
+}
+
+func (s *compactionService) CompactPending(ctx context.Context, limit int) (int, error) {
+	enabled, err := s.db.GetSourceCompaction(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !enabled {
+		return 0, nil
+	}
+
+	files, err := s.db.ListCompactionCandidates(ctx, int64(limit))
+	if err != nil {
+		return 0, err
+	}
+
+	for i, file := range files {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+
+		status, err := s.compactFile(ctx, file)
+		if ctx.Err() != nil {
+			// Interrupted, not failed: leave it queued for the next run.
+			return i, ctx.Err()
+		}
+		if err != nil {
+			slog.Warn("Source compaction failed", "track_file_id", file.ID, "path", file.FilePath, "error", err)
+		}
+		if status == CompactionCompacted {
+			continue
+		}
+		if err := s.db.SetCompactionStatus(ctx, sqlc.SetCompactionStatusParams{
+			CompactionStatus: sql.NullString{String: status, Valid: true},
+			ID:               file.ID,
+		}); err != nil {
+			return i, err
+		}
+	}
+
+	return len(files), nil
+}
+
+// compactFile converts one source to FLAC and swaps it in. The original is
+// only deleted once the record points at the verified FLAC.
+func (s *compactionService) compactFile(ctx context.Context, file sqlc.TrackFile) (string, error) {
+	localPath, release, err := s.storage.LocalFile(ctx, file.FilePath)
+	if err != nil {
+		return CompactionFailed, fmt.Errorf("failed to fetch source: %w", err)
+	}
+	defer release()
+
+	metadata, err := transcoding.ExtractMetadata(localPath)
+	if err != nil {
+		return CompactionFailed, err
+	}
+	if !transcoding.CanCompact(file.Format, metadata.Codec) {
+		return CompactionSkipped, nil
+	}
+
+	tmp, err := os.CreateTemp("", "vault-compact-*.flac")
+	if err != nil {
+		return CompactionFailed, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	pcmHash, err := transcoding.CompactToFLAC(ctx, localPath, tmp.Name(), metadata.Codec)
+	if err != nil {
+		return CompactionFailed, err
+	}
+
+	compactedFile, err := os.Open(tmp.Name())
+	if err != nil {
+		return CompactionFailed, err
+	}
+	defer compactedFile.Close()
+
+	info, err := compactedFile.Stat()
+	if err != nil {
+		return CompactionFailed, err
+	}
+	if info.Size() >= file.FileSize {
+		return CompactionSkipped, nil
+	}
+
+	newPath := filepath.Join(filepath.Dir(file.FilePath), "source."+transcoding.CompactFormat)
+	hasher := sha256.New()
+	size, err := s.storage.WriteFile(ctx, newPath, io.TeeReader(compactedFile, hasher))
+	if err != nil {
+		return CompactionFailed, fmt.Errorf("failed to store compacted source: %w", err)
+	}
+
+	updated, err := s.db.CompactTrackFile(ctx, sqlc.CompactTrackFileParams{
+		FilePath:      newPath,
+		FileSize:      size,
+		ContentHash:   sql.NullString{String: hex.EncodeToString(hasher.Sum(nil)), Valid: true},
+		StoredFormat:  sql.NullString{String: transcoding.CompactFormat, Valid: true},
+		OriginalCodec: sql.NullString{String: metadata.Codec, Valid: true},
+		OriginalSize:  sql.NullInt64{Int64: file.FileSize, Valid: true},
+		PcmHash:       sql.NullString{String: pcmHash, Valid: true},
+		ID:            file.ID,
+		FilePath_2:    file.FilePath,
+	})
+	if err != nil || updated == 0 {
+		// The version was replaced or deleted meanwhile; keep what it has now.
+		s.storage.DeleteFile(ctx, newPath)
+		if err != nil {
+			return CompactionFailed, err
+		}
+		return CompactionSkipped, nil
+	}
+
+	if err := s.storage.DeleteFile(ctx, file.FilePath); err != nil {
+		slog.Warn("Failed to delete compacted original", "path", file.FilePath, "error", err)
+	}
+
+	slog.Info("Compacted source to FLAC",
+		"track_file_id", file.ID,
+		"original_size", file.FileSize,
+		"compacted_size", size,
+	)
+	return CompactionCompacted, nil
+}
+
+// RunCompaction compacts pending sources every interval until ctx is
+// cancelled. Each run works through the whole backlog in small batches.
+func RunCompaction(ctx context.Context, compaction CompactionService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			processed, err := compaction.CompactPending(ctx, compactionBatchSize)
+			if err != nil {
+				slog.Warn("Source compaction run failed", "error", err)
+				break
+			}
+			if processed == 0 {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
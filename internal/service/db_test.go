@@ -0,0 +1,35 @@
+//go:build sqlite_fts5
+
+package service
+
+import (
+	"context"
+	"testing"
+
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+)
+
+// newTestDB opens a migrated database in a temporary directory.
+func newTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	database, err := db.New(db.Config{DataDir: t.TempDir(), DBFile: "vault.db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func createTestUser(t *testing.T, database *db.DB, username, email string) sqlc.User {
+	t.Helper()
+	user, err := database.Queries.CreateUser(context.Background(), sqlc.CreateUserParams{
+		Username:     username,
+		Email:        email,
+		PasswordHash: "unused",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/mail"
+)
+
+const (
+	emailBatchSize = 20
+	// maxEmailAttempts spreads retries over about two hours with the
+	// backoff below.
+	maxEmailAttempts = 8
+	maxEmailBackoff  = time.Hour
+	emailRetention   = 30 * 24 * time.Hour
+)
+
+var ErrEmailDisabled = errors.New("email delivery is not configured")
+
+type EmailConfig struct {
+	SMTP mail.Config
+	// PublicURL is where users reach the app, for links in messages.
+	PublicURL string
+}
+
+// EmailService queues templated messages and delivers them in the
+// background, retrying failed sends with backoff.
+type EmailService interface {
+	// Enabled reports whether outbound email is configured. The Queue
+	// methods fail with ErrEmailDisabled when it is not.
+	Enabled() bool
+	QueueInvite(ctx context.Context, to, invitedBy, token string, expiresAt time.Time) error
+	QueuePasswordReset(ctx context.Context, to, username, token string, expiresAt time.Time) error
+	QueueEmailChange(ctx context.Context, to, username, token string, expiresAt time.Time) error
+	QueueShareNotification(ctx context.Context, share ShareNotification) error
+	// SendDue sends the queued messages that are due and returns how many
+	// went out.
+	SendDue(ctx context.Context) (int, error)
+	// Queued is signalled when a message is queued, so the worker can send
+	// it without waiting for its next pass.
+	Queued() <-chan struct{}
+}
+
+// ShareNotification tells a user that a project or track was shared with
+// them.
+type ShareNotification struct {
+	RecipientID int64
+	SharedBy    string
+	// Kind is "project" or "track".
+	Kind     string
+	Name     string
+	PublicID string
+}
+
+type emailService struct {
+	db        *db.DB
+	sender    mail.Sender
+	publicURL string
+	queued    chan struct{}
+}
+
+func NewEmailService(database *db.DB, config EmailConfig) EmailService {
+	s := &emailService{
+		db:        database,
+		publicURL: strings.TrimRight(config.PublicURL, "/"),
+		queued:    make(chan struct{}, 1),
+	}
+	if config.SMTP.Enabled() {
+		s.sender = mail.NewSMTPSender(config.SMTP)
+	}
+	return s
+}
+
+func (s *emailService) Enabled() bool {
+	return s.sender != nil
+}
+
+func (s *emailService) Queued() <-chan struct{} {
+	return s.queued
+}
+
+func (s *emailService) QueueInvite(ctx context.Context, to, invitedBy, token string, expiresAt time.Time) error {
+	return s.queue(ctx, to, mail.TemplateInvite, mail.Data{
+		InvitedBy:      invitedBy,
+		Link:           s.link("/accept-invite", token),
+		ExpiresInHours: hoursUntil(expiresAt),
+	})
+}
+
+func (s *emailService) QueuePasswordReset(ctx context.Context, to, username, token string, expiresAt time.Time) error {
+	return s.queue(ctx, to, mail.TemplatePasswordReset, mail.Data{
+		Username:       username,
+		Link:           s.link("/reset-password", token),
+		ExpiresInHours: hoursUntil(expiresAt),
+	})
+}
+
+func (s *emailService) QueueEmailChange(ctx context.Context, to, username, token string, expiresAt time.Time) error {
+	return s.queue(ctx, to, mail.TemplateEmailChange, mail.Data{
+		Username:       username,
+		Link:           s.link("/confirm-email", token),
+		ExpiresInHours: hoursUntil(expiresAt),
+	})
+}
+
+func (s *emailService) QueueShareNotification(ctx context.Context, share ShareNotification) error {
+	if !s.Enabled() {
+		return ErrEmailDisabled
+	}
+
+	recipient, err := s.db.Queries.GetUserByID(ctx, share.RecipientID)
+	if err != nil {
+		return err
+	}
+
+	template, path := mail.TemplateProjectShared, "/project/"
+	if share.Kind == "track" {
+		template, path = mail.TemplateTrackShared, "/shared-track/"
+	}
+	return s.queue(ctx, recipient.Email, template, mail.Data{
+		Username: recipient.Username,
+		SharedBy: share.SharedBy,
+		Name:     share.Name,
+		Link:     s.publicURL + path + url.PathEscape(share.PublicID),
+	})
+}
+
+// link is an app page that reads a token from its query.
+func (s *emailService) link(path, token string) string {
+	return s.publicURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func (s *emailService) queue(ctx context.Context, to, template string, data mail.Data) error {
+	if !s.Enabled() {
+		return ErrEmailDisabled
+	}
+
+	data.Instance = s.instanceName(ctx)
+	msg, err := mail.Render(template, data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.db.Queries.EnqueueEmail(ctx, sqlc.EnqueueEmailParams{
+		Recipient:     to,
+		Template:      template,
+		Subject:       msg.Subject,
+		TextBody:      msg.Text,
+		HtmlBody:      msg.HTML,
+		NextAttemptAt: time.Now().UTC(),
+	}); err != nil {
+		return err
+	}
+
+	select {
+	case s.queued <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *emailService) instanceName(ctx context.Context) string {
+	settings, err := s.db.Queries.GetInstanceSettings(ctx)
+	if err != nil || settings.Name == "" {
+		return "Vault"
+	}
+	return settings.Name
+}
+
+func (s *emailService) SendDue(ctx context.Context) (int, error) {
+	if !s.Enabled() {
+		return 0, nil
+	}
+
+	sent := 0
+	for {
+		due, err := s.db.Queries.ListDueEmails(ctx, sqlc.ListDueEmailsParams{
+			NextAttemptAt: time.Now().UTC(),
+			Limit:         emailBatchSize,
+		})
+		if err != nil {
+			return sent, err
+		}
+
+		for _, email := range due {
+			delivered, err := s.send(ctx, email)
+			if err != nil {
+				return sent, err
+			}
+			if delivered {
+				sent++
+			}
+			if ctx.Err() != nil {
+				return sent, ctx.Err()
+			}
+		}
+
+		if len(due) < emailBatchSize {
+			break
+		}
+	}
+
+	if err := s.db.Queries.DeleteOldEmails(ctx, time.Now().UTC().Add(-emailRetention)); err != nil {
+		return sent, err
+	}
+	return sent, nil
+}
+
+// send attempts one delivery, records the outcome and reports whether the
+// message went out. Only failures to record the outcome are errors.
+func (s *emailService) send(ctx context.Context, email sqlc.EmailQueue) (bool, error) {
+	sendErr := s.sender.Send(ctx, mail.Message{
+		To:      email.Recipient,
+		Subject: email.Subject,
+		Text:    email.TextBody,
+		HTML:    email.HtmlBody,
+	})
+	if sendErr == nil {
+		return true, s.db.Queries.MarkEmailSent(ctx, sqlc.MarkEmailSentParams{
+			SentAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+			ID:     email.ID,
+		})
+	}
+
+	lastError := sql.NullString{String: sendErr.Error(), Valid: true}
+	attempts := email.Attempts + 1
+	if mail.IsPermanent(sendErr) || attempts >= maxEmailAttempts {
+		slog.Warn("Giving up on email",
+			"id", email.ID,
+			"template", email.Template,
+			"attempts", attempts,
+			"error", sendErr,
+		)
+		return false, s.db.Queries.FailEmail(ctx, sqlc.FailEmailParams{
+			LastError: lastError,
+			ID:        email.ID,
+		})
+	}
+
+	backoff := min(time.Minute<<email.Attempts, maxEmailBackoff)
+	slog.Info("Email send failed, will retry",
+		"id", email.ID,
+		"template", email.Template,
+		"attempts", attempts,
+		"retry_in", backoff,
+		"error", sendErr,
+	)
+	return false, s.db.Queries.RetryEmail(ctx, sqlc.RetryEmailParams{
+		LastError:     lastError,
+		NextAttemptAt: time.Now().UTC().Add(backoff),
+		ID:            email.ID,
+	})
+}
+
+func hoursUntil(t time.Time) int {
+	return max(int(time.Until(t).Round(time.Hour)/time.Hour), 1)
+}
+
+// RunEmailQueue sends queued email as it is queued, and retries failed
+// sends every interval, until ctx is cancelled.
+func RunEmailQueue(ctx context.Context, emails EmailService, interval time.Duration) {
+	if !emails.Enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sent, err := emails.SendDue(ctx)
+		if err != nil {
+			slog.Warn("Email queue failed", "error", err)
+		} else if sent > 0 {
+			slog.Debug("Processed queued email", "messages", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-emails.Queued():
+		}
+	}
+}
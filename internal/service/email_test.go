@@ -0,0 +1,297 @@
+//go:build sqlite_fts5
+
+package service
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ramiro-uziel/vault/internal/db"
+	mailer "ramiro-uziel/vault/internal/mail"
+)
+
+// smtpSink is an SMTP server that keeps what it is sent. Recipients in
+// replies get that RCPT reply instead of being accepted.
+type smtpSink struct {
+	listener net.Listener
+	replies  map[string]string
+
+	mu       sync.Mutex
+	messages []string
+	rcpts    map[string]int
+}
+
+func newSMTPSink(t *testing.T, replies map[string]string) *smtpSink {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &smtpSink{listener: listener, replies: replies, rcpts: map[string]int{}}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go sink.serve(conn)
+		}
+	}()
+	return sink
+}
+
+func (s *smtpSink) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 sink ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "RCPT":
+			to := strings.Trim(strings.TrimPrefix(strings.ToUpper(arg), "TO:"), "<>")
+			to = strings.ToLower(to)
+			s.mu.Lock()
+			s.rcpts[to]++
+			s.mu.Unlock()
+			if reply, ok := s.replies[to]; ok {
+				text.PrintfLine("%s", reply)
+			} else {
+				text.PrintfLine("250 ok")
+			}
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, string(data))
+			s.mu.Unlock()
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func (s *smtpSink) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func (s *smtpSink) attempts(to string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rcpts[to]
+}
+
+func newTestEmailService(database *db.DB, sink *smtpSink) EmailService {
+	return NewEmailService(database, EmailConfig{
+		SMTP: mailer.Config{
+			Host:     "127.0.0.1",
+			Port:     sink.port(),
+			From:     "Vault <vault@vault.test>",
+			Security: mailer.SecurityNone,
+		},
+		PublicURL: "https://vault.test/",
+	})
+}
+
+// receivedEmail is a delivered message with its parts decoded.
+type receivedEmail struct {
+	header mail.Header
+	text   string
+	html   string
+}
+
+func parseReceivedEmail(t *testing.T, raw string) receivedEmail {
+	t.Helper()
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	email := receivedEmail{header: msg.Header}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); mediaType {
+		case "text/plain":
+			email.text = string(body)
+		case "text/html":
+			email.html = string(body)
+		}
+	}
+	return email
+}
+
+type queuedEmail struct {
+	status        string
+	attempts      int
+	lastError     *string
+	nextAttemptAt time.Time
+}
+
+func getQueuedEmail(t *testing.T, database *db.DB, recipient string) queuedEmail {
+	t.Helper()
+	var email queuedEmail
+	if err := database.DB.QueryRow(
+		`SELECT status, attempts, last_error, next_attempt_at FROM email_queue WHERE recipient = ?`,
+		recipient,
+	).Scan(&email.status, &email.attempts, &email.lastError, &email.nextAttemptAt); err != nil {
+		t.Fatal(err)
+	}
+	return email
+}
+
+func TestEmailSendDueDeliversRenderedMessages(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	sink := newSMTPSink(t, nil)
+	emails := newTestEmailService(database, sink)
+	alice := createTestUser(t, database, "alice", "alice@example.com")
+
+	if err := emails.QueuePasswordReset(ctx, "alice@example.com", "alice", "tok/en+1", time.Now().Add(24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := emails.QueueShareNotification(ctx, ShareNotification{
+		RecipientID: alice.ID,
+		SharedBy:    "<bob>",
+		Kind:        "track",
+		Name:        "Demo",
+		PublicID:    "trk123",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := emails.SendDue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := sink.received()
+	if sent != 2 || len(received) != 2 {
+		t.Fatalf("SendDue() sent %d, sink received %d, want 2", sent, len(received))
+	}
+
+	reset := parseReceivedEmail(t, received[0])
+	resetLink := "https://vault.test/reset-password?token=tok%2Fen%2B1"
+	if reset.header.Get("To") != "<alice@example.com>" || reset.header.Get("From") != `"Vault" <vault@vault.test>` {
+		t.Errorf("reset headers = %v", reset.header)
+	}
+	if reset.header.Get("Subject") != "Reset your Vault password" {
+		t.Errorf("reset subject = %q", reset.header.Get("Subject"))
+	}
+	if !strings.Contains(reset.text, "Hi alice,") || !strings.Contains(reset.text, "\n"+resetLink+"\n") ||
+		!strings.Contains(reset.text, "expires in 24 hours") {
+		t.Errorf("reset text part = %q", reset.text)
+	}
+	if !strings.Contains(reset.html, `href="`+resetLink+`"`) {
+		t.Errorf("reset HTML part has no link to %s: %q", resetLink, reset.html)
+	}
+
+	share := parseReceivedEmail(t, received[1])
+	shareSubject, err := new(mime.WordDecoder).DecodeHeader(share.header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shareSubject != "<bob> shared a track with you" {
+		t.Errorf("share subject = %q", shareSubject)
+	}
+	if !strings.Contains(share.text, "https://vault.test/shared-track/trk123") {
+		t.Errorf("share text part = %q", share.text)
+	}
+	if !strings.Contains(share.html, `href="https://vault.test/shared-track/trk123"`) ||
+		!strings.Contains(share.html, "&lt;bob&gt;") || strings.Contains(share.html, "<bob>") {
+		t.Errorf("share HTML part = %q", share.html)
+	}
+
+	if email := getQueuedEmail(t, database, "alice@example.com"); email.status != "sent" || email.attempts != 1 {
+		t.Errorf("queued email after sending = %+v", email)
+	}
+}
+
+func TestEmailSendDueRetriesOnlyTemporaryFailures(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	sink := newSMTPSink(t, map[string]string{
+		"bounce@example.com": "550 no such user",
+		"busy@example.com":   "451 try again later",
+	})
+	emails := newTestEmailService(database, sink)
+	expiresAt := time.Now().Add(24 * time.Hour)
+
+	for _, to := range []string{"bounce@example.com", "busy@example.com"} {
+		if err := emails.QueueInvite(ctx, to, "admin", "token", expiresAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before := time.Now().UTC()
+	sent, err := emails.SendDue(ctx)
+	if err != nil || sent != 0 {
+		t.Fatalf("SendDue() = %d, %v, want 0, nil", sent, err)
+	}
+
+	bounced := getQueuedEmail(t, database, "bounce@example.com")
+	if bounced.status != "failed" || bounced.attempts != 1 ||
+		bounced.lastError == nil || !strings.Contains(*bounced.lastError, "550") {
+		t.Errorf("after a 5xx, email = %+v, want failed for good", bounced)
+	}
+	busy := getQueuedEmail(t, database, "busy@example.com")
+	if busy.status != "pending" || busy.attempts != 1 ||
+		busy.lastError == nil || !strings.Contains(*busy.lastError, "451") {
+		t.Errorf("after a 4xx, email = %+v, want pending", busy)
+	}
+	if busy.nextAttemptAt.Before(before.Add(time.Minute - time.Second)) {
+		t.Errorf("after a 4xx, next attempt at %v, want a minute after %v", busy.nextAttemptAt, before)
+	}
+
+	// Neither is due again yet.
+	if _, err := emails.SendDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.attempts("bounce@example.com"); got != 1 {
+		t.Errorf("the bounced email was tried %d times, want 1", got)
+	}
+	if got := sink.attempts("busy@example.com"); got != 1 {
+		t.Errorf("the deferred email was tried %d times before its backoff, want 1", got)
+	}
+}
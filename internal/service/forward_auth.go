@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"ramiro-uziel/vault/internal/auth"
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+)
+
+const maxForwardedUsernameLength = 64
+
+var ErrUnknownForwardedUser = errors.New("no account matches the forwarded user")
+
+// ForwardAuthService maps users authenticated by a reverse proxy, such as
+// Authelia or oauth2-proxy, to local accounts.
+type ForwardAuthService interface {
+	// Resolve finds the account for a forwarded username and email. Without
+	// one, it creates it if auto-creation is on and fails with
+	// ErrUnknownForwardedUser otherwise.
+	Resolve(ctx context.Context, username, email string) (*User, error)
+}
+
+type forwardAuthService struct {
+	db         *db.DB
+	autoCreate bool
+}
+
+func NewForwardAuthService(database *db.DB, autoCreate bool) ForwardAuthService {
+	return &forwardAuthService{
+		db:         database,
+		autoCreate: autoCreate,
+	}
+}
+
+func (s *forwardAuthService) Resolve(ctx context.Context, username, email string) (*User, error) {
+	username = strings.TrimSpace(username)
+	email = strings.TrimSpace(email)
+	if username == "" || len(username) > maxForwardedUsernameLength {
+		return nil, ErrUnknownForwardedUser
+	}
+
+	user, err := s.db.Queries.GetUserByUsername(ctx, username)
+	if err == nil {
+		return sqlcUserToServiceUser(user), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// The proxy's username may differ from the one picked at registration.
+	if email != "" {
+		user, err := s.db.Queries.GetUserByEmail(ctx, email)
+		if err == nil {
+			return sqlcUserToServiceUser(user), nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	// Emails are unique, so accounts are only created when the proxy sends
+	// one.
+	if !s.autoCreate || email == "" {
+		return nil, ErrUnknownForwardedUser
+	}
+	return s.create(ctx, username, email)
+}
+
+// create makes an account for a forwarded user. The first account on an
+// instance becomes its owner, as with registration.
+func (s *forwardAuthService) create(ctx context.Context, username, email string) (*User, error) {
+	passwordHash, err := unusablePasswordHash()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := s.db.Queries.WithTx(tx)
+
+	userCount, err := qtx.CountUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isFirstUser := userCount == 0
+
+	user, err := qtx.CreateUser(ctx, sqlc.CreateUserParams{
+		Username:     username,
+		Email:        email,
+		PasswordHash: passwordHash,
+		IsAdmin:      isFirstUser,
+		IsOwner:      isFirstUser,
+	})
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return nil, ErrUserExists
+		}
+		return nil, err
+	}
+	if err := qtx.CreateUserPreferences(ctx, sqlc.CreateUserPreferencesParams{
+		UserID:         user.ID,
+		DefaultQuality: "lossy",
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "User created from forwarded authentication",
+		"user_id", user.ID,
+		"username", user.Username,
+		"is_first_user", isFirstUser,
+	)
+	return sqlcUserToServiceUser(user), nil
+}
+
+// unusablePasswordHash is the password hash for accounts created by an
+// external login. It matches no password anyone knows until the user sets one
+// with a reset link.
+func unusablePasswordHash() (string, error) {
+	randomPassword, err := auth.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	return auth.HashPassword(randomPassword)
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/storage"
+)
+
+// Issue kinds reported by the storage integrity check.
+const (
+	FsckMissing      = "missing"
+	FsckOrphaned     = "orphaned"
+	FsckSizeMismatch = "size_mismatch"
+	FsckHashMismatch = "hash_mismatch"
+)
+
+// TranscodingStatusMissing marks a track file whose bytes are gone from storage.
+// Streaming only serves completed files, so flagged versions stop being played.
+const TranscodingStatusMissing = "missing"
+
+type FsckService interface {
+	Check(ctx context.Context, opts FsckOptions) (*FsckReport, error)
+}
+
+// Retranscoder re-queues derived files whose output is missing or damaged.
+type Retranscoder interface {
+	Retranscode(ctx context.Context, file sqlc.TrackFile) error
+}
+
+type FsckOptions struct {
+	VerifyHashes bool `json:"verify_hashes"`
+	// Repairs, all off by default so a plain check never changes anything.
+	Retranscode   bool `json:"retranscode"`
+	DeleteOrphans bool `json:"delete_orphans"`
+	MarkBroken    bool `json:"mark_broken"`
+}
+
+type FsckIssue struct {
+	Kind         string `json:"kind"`
+	Path         string `json:"path"`
+	TrackFileID  int64  `json:"track_file_id,omitempty"`
+	VersionID    int64  `json:"version_id,omitempty"`
+	Quality      string `json:"quality,omitempty"`
+	ExpectedSize int64  `json:"expected_size,omitempty"`
+	ActualSize   int64  `json:"actual_size,omitempty"`
+	Repair       string `json:"repair,omitempty"`
+	RepairError  string `json:"repair_error,omitempty"`
+}
+
+type FsckReport struct {
+	CheckedFiles   int         `json:"checked_files"`
+	CheckedObjects int         `json:"checked_objects"`
+	Issues         []FsckIssue `json:"issues"`
+	// OrphanedProjects lists project directories with no matching project row.
+	OrphanedProjects []string `json:"orphaned_projects"`
+}
+
+func (r *FsckReport) Count(kind string) int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+type fsckService struct {
+	db           *db.DB
+	storage      storage.Storage
+	retranscoder Retranscoder
+}
+
+func NewFsckService(database *db.DB, storageAdapter storage.Storage, retranscoder Retranscoder) FsckService {
+	return &fsckService{
+		db:           database,
+		storage:      storageAdapter,
+		retranscoder: retranscoder,
+	}
+}
+
+func (s *fsckService) Check(ctx context.Context, opts FsckOptions) (*FsckReport, error) {
+	report := &FsckReport{
+		Issues:           []FsckIssue{},
+		OrphanedProjects: []string{},
+	}
+
+	files, err := s.db.Queries.ListAllTrackFiles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list track files: %w", err)
+	}
+	projects, err := s.db.Queries.ListProjectStorageRefs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+
+	referenced := make(map[string]bool, len(files))
+	for _, file := range files {
+		referenced[fsckPathKey(file.FilePath)] = true
+	}
+	knownProjects := make(map[string]bool, len(projects))
+	coverDirs := make(map[string]bool)
+	for _, project := range projects {
+		knownProjects[project.PublicID] = true
+		if project.CoverArtPath.Valid {
+			referenced[fsckPathKey(project.CoverArtPath.String)] = true
+			// Resized variants live next to the source and are regenerated on demand.
+			coverDirs[fsckPathKey(filepath.Dir(project.CoverArtPath.String))] = true
+		}
+	}
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Pending and failed transcodes have no output yet.
+		if file.TranscodingStatus.Valid && file.TranscodingStatus.String != "completed" {
+			continue
+		}
+		report.CheckedFiles++
+
+		issue := s.checkFile(ctx, file, opts.VerifyHashes)
+		if issue == nil {
+			continue
+		}
+		s.repairFile(ctx, file, issue, opts)
+		report.Issues = append(report.Issues, *issue)
+	}
+
+	orphanedProjects := make(map[string]bool)
+	err = s.storage.ListFiles(ctx, func(info storage.FileInfo) error {
+		report.CheckedObjects++
+		key := fsckPathKey(info.Path)
+		if referenced[key] || coverDirs[filepath.Dir(key)] {
+			return nil
+		}
+
+		issue := FsckIssue{
+			Kind:       FsckOrphaned,
+			Path:       info.Path,
+			ActualSize: info.Size,
+		}
+		if publicID := projectIDFromPath(info.Path); publicID != "" && !knownProjects[publicID] {
+			orphanedProjects[publicID] = true
+		}
+		if opts.DeleteOrphans {
+			issue.Repair = "deleted"
+			if err := s.storage.DeleteFile(ctx, info.Path); err != nil {
+				issue.RepairError = err.Error()
+			}
+		}
+		report.Issues = append(report.Issues, issue)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk storage: %w", err)
+	}
+
+	for publicID := range orphanedProjects {
+		report.OrphanedProjects = append(report.OrphanedProjects, publicID)
+	}
+
+	return report, nil
+}
+
+func (s *fsckService) checkFile(ctx context.Context, file sqlc.TrackFile, verifyHash bool) *FsckIssue {
+	issue := &FsckIssue{
+		Path:         file.FilePath,
+		TrackFileID:  file.ID,
+		VersionID:    file.VersionID,
+		Quality:      file.Quality,
+		ExpectedSize: file.FileSize,
+	}
+
+	info, err := s.storage.StatFile(ctx, file.FilePath)
+	if errors.Is(err, storage.ErrNotExist) {
+		issue.Kind = FsckMissing
+		return issue
+	}
+	if err != nil {
+		slog.Warn("fsck: failed to stat file", "path", file.FilePath, "error", err)
+		issue.Kind = FsckMissing
+		return issue
+	}
+	issue.ActualSize = info.Size
+
+	if file.FileSize > 0 && info.Size != file.FileSize {
+		issue.Kind = FsckSizeMismatch
+		return issue
+	}
+
+	if verifyHash && file.ContentHash.Valid && file.ContentHash.String != "" {
+		sum, err := s.hashFile(ctx, file.FilePath)
+		if err != nil {
+			slog.Warn("fsck: failed to hash file", "path", file.FilePath, "error", err)
+			return nil
+		}
+		if !strings.EqualFold(sum, strings.TrimPrefix(file.ContentHash.String, "sha256:")) {
+			issue.Kind = FsckHashMismatch
+			return issue
+		}
+	}
+
+	return nil
+}
+
+func (s *fsckService) hashFile(ctx context.Context, path string) (string, error) {
+	stream, err := s.storage.OpenFile(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Reader.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, stream.Reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// repairFile fixes what it can: derived files are rebuilt from their source,
+// anything else is flagged so it is no longer served.
+func (s *fsckService) repairFile(ctx context.Context, file sqlc.TrackFile, issue *FsckIssue, opts FsckOptions) {
+	if file.Quality != "source" && opts.Retranscode && s.retranscoder != nil {
+		issue.Repair = "retranscode"
+		if err := s.retranscoder.Retranscode(ctx, file); err != nil {
+			issue.RepairError = err.Error()
+		}
+		return
+	}
+
+	if opts.MarkBroken {
+		issue.Repair = "marked_broken"
+		if err := s.db.Queries.UpdateTranscodingStatus(ctx, sqlc.UpdateTranscodingStatusParams{
+			TranscodingStatus: sql.NullString{String: TranscodingStatusMissing, Valid: true},
+			ID:                file.ID,
+		}); err != nil {
+			issue.RepairError = err.Error()
+		}
+	}
+}
+
+func fsckPathKey(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+// projectIDFromPath returns the project public ID segment following
+// "projects/" in a stored path, or "" if there is none.
+func projectIDFromPath(path string) string {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "projects" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
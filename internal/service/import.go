@@ -0,0 +1,299 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ramiro-uziel/vault/internal/db"
+)
+
+// Limits on what an instance import extracts, so an archive cannot expand
+// to fill the disk. Audio barely compresses; an entry far smaller than what
+// it claims to expand to is not a real backup.
+const (
+	MaxImportSize    = 1 << 40
+	maxImportEntries = 1_000_000
+	maxImportRatio   = 1000
+)
+
+// missingFilesToList caps the missing files an import plan names.
+const missingFilesToList = 50
+
+// ErrInvalidArchive is wrapped by the errors for archives that cannot be
+// imported as they are, as opposed to failures on this side.
+var ErrInvalidArchive = errors.New("invalid backup")
+
+// ImportPlan describes what importing an archive would change.
+type ImportPlan struct {
+	Manifest ExportManifest  `json:"manifest"`
+	Current  InstanceSummary `json:"current"`
+	Incoming InstanceSummary `json:"incoming"`
+	// PendingMigrations are applied to the imported database to bring it up
+	// to this version's schema.
+	PendingMigrations []string `json:"pending_migrations"`
+	// MissingFiles are files the imported database refers to that the
+	// archive does not hold, up to missingFilesToList of them.
+	MissingFiles     []string `json:"missing_files"`
+	MissingFileCount int      `json:"missing_file_count"`
+}
+
+type InstanceSummary struct {
+	Name        string `json:"name"`
+	Users       int64  `json:"users"`
+	Projects    int64  `json:"projects"`
+	Tracks      int64  `json:"tracks"`
+	Versions    int64  `json:"versions"`
+	TrackFiles  int64  `json:"track_files"`
+	StoredFiles int64  `json:"stored_files"`
+	StoredBytes int64  `json:"stored_bytes"`
+}
+
+// ExtractInstanceArchive extracts an instance archive into dir. Only the
+// manifest, the database and files under projects/ are accepted, and the
+// whole archive is checked against the import limits before anything is
+// written.
+func ExtractInstanceArchive(zr *zip.Reader, dir string, onFile func(name string)) error {
+	if len(zr.File) > maxImportEntries {
+		return fmt.Errorf("%w: more than %d entries", ErrInvalidArchive, maxImportEntries)
+	}
+
+	var total uint64
+	seen := make(map[string]bool, len(zr.File))
+	for _, f := range zr.File {
+		name := strings.TrimSuffix(f.Name, "/")
+		if f.FileInfo().IsDir() {
+			if name != "projects" && !restorablePath(name) {
+				return fmt.Errorf("%w: unexpected entry %q", ErrInvalidArchive, f.Name)
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() || (name != archiveManifestName && !restorablePath(name)) {
+			return fmt.Errorf("%w: unexpected entry %q", ErrInvalidArchive, f.Name)
+		}
+		if seen[name] {
+			return fmt.Errorf("%w: %q appears twice", ErrInvalidArchive, f.Name)
+		}
+		seen[name] = true
+
+		if f.UncompressedSize64 > 1<<20 && f.UncompressedSize64 > maxImportRatio*max(f.CompressedSize64, 1) {
+			return fmt.Errorf("%w: %q is compressed too well to be genuine", ErrInvalidArchive, f.Name)
+		}
+		total += f.UncompressedSize64
+		if total > MaxImportSize {
+			return fmt.Errorf("%w: expands to more than %d bytes", ErrInvalidArchive, uint64(MaxImportSize))
+		}
+	}
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := extractArchiveFile(f, dir); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+		}
+		if onFile != nil {
+			onFile(f.Name)
+		}
+	}
+	return nil
+}
+
+// extractArchiveFile writes one entry whose name has been checked. The ZIP
+// reader fails if the entry holds more than its header claims.
+func extractArchiveFile(f *zip.File, dir string) error {
+	path := filepath.Join(dir, filepath.FromSlash(f.Name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		if errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrFormat) {
+			return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		return err
+	}
+	return out.Close()
+}
+
+// PrepareImport checks an archive extracted into dir and brings its database
+// up to this version's schema, leaving dir ready to replace the live data
+// directory. It fails with ErrInvalidArchive if the manifest is from an
+// unknown format, the database is damaged or from a newer version, or a
+// migration does not apply. current and dataDir describe the live instance,
+// for the plan.
+func PrepareImport(ctx context.Context, dir string, current *db.DB, dataDir string) (*ImportPlan, error) {
+	file, err := os.Open(filepath.Join(dir, archiveManifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: missing manifest", ErrInvalidArchive)
+	} else if err != nil {
+		return nil, err
+	}
+	manifest, err := readManifest(file)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if major, _, _ := strings.Cut(manifest.Version, "."); major != strings.Split(ArchiveVersion, ".")[0] {
+		return nil, fmt.Errorf("%w: archive format %q is not supported, this version reads %s", ErrInvalidArchive, manifest.Version, ArchiveVersion)
+	}
+	// An incremental backup lacks the files it shares with earlier ones.
+	if manifest.Kind == ArchiveIncremental {
+		return nil, fmt.Errorf("%w: this is an incremental backup, restore it with vault-server restore", ErrInvalidArchive)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "vault.db")); err != nil {
+		return nil, fmt.Errorf("%w: missing database", ErrInvalidArchive)
+	}
+
+	plan := &ImportPlan{
+		Manifest:          *manifest,
+		PendingMigrations: []string{},
+		MissingFiles:      []string{},
+	}
+	plan.Manifest.Files = nil
+	if plan.Current, err = summarizeInstance(ctx, current, dataDir); err != nil {
+		return nil, err
+	}
+
+	incoming, err := db.New(db.Config{DataDir: dir, DBFile: "vault.db", SkipMigrations: true})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer incoming.Close()
+
+	var integrity string
+	if err := incoming.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return nil, fmt.Errorf("%w: database cannot be read: %v", ErrInvalidArchive, err)
+	}
+	if integrity != "ok" {
+		return nil, fmt.Errorf("%w: database is damaged: %s", ErrInvalidArchive, integrity)
+	}
+
+	states, err := incoming.MigrationStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	for _, state := range states {
+		switch {
+		case state.Unknown:
+			return nil, fmt.Errorf("%w: database has migration %s from a newer version", ErrInvalidArchive, state.Version)
+		case state.Modified:
+			return nil, fmt.Errorf("%w: database has a different migration %s", ErrInvalidArchive, state.Version)
+		case !state.Applied:
+			plan.PendingMigrations = append(plan.PendingMigrations, state.Version)
+		}
+	}
+	if _, err := incoming.MigrateUp(ctx, ""); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	// Migrating took a snapshot that is of no use once imported.
+	os.RemoveAll(incoming.SnapshotDir())
+
+	if plan.Incoming, err = summarizeInstance(ctx, incoming, dir); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if err := findMissingFiles(ctx, incoming, dir, plan); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	return plan, nil
+}
+
+func summarizeInstance(ctx context.Context, database *db.DB, dataDir string) (InstanceSummary, error) {
+	var summary InstanceSummary
+	settings, err := database.Queries.GetInstanceSettings(ctx)
+	if err != nil {
+		return summary, fmt.Errorf("failed to read instance settings: %w", err)
+	}
+	counts, err := database.Queries.GetInstanceSummary(ctx)
+	if err != nil {
+		return summary, fmt.Errorf("failed to count instance data: %w", err)
+	}
+	summary.Name = settings.Name
+	summary.Users, _ = counts.UserCount.(int64)
+	summary.Projects, _ = counts.ProjectCount.(int64)
+	summary.Tracks, _ = counts.TrackCount.(int64)
+	summary.Versions, _ = counts.VersionCount.(int64)
+	summary.TrackFiles, _ = counts.FileCount.(int64)
+
+	filepath.WalkDir(filepath.Join(dataDir, "projects"), func(_ string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			summary.StoredFiles++
+			summary.StoredBytes += info.Size()
+		}
+		return nil
+	})
+	return summary, nil
+}
+
+// findMissingFiles checks that every finished track file and cover the
+// database refers to is in dir. Stored paths carry the data directory of
+// the instance that wrote them, so they are matched from projects/ on.
+func findMissingFiles(ctx context.Context, database *db.DB, dir string, plan *ImportPlan) error {
+	files, err := database.Queries.ListAllTrackFiles(ctx)
+	if err != nil {
+		return err
+	}
+	projects, err := database.Queries.ListProjectStorageRefs(ctx)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	for _, file := range files {
+		// Pending and failed transcodes have no output yet.
+		if file.TranscodingStatus.Valid && file.TranscodingStatus.String != "completed" {
+			continue
+		}
+		paths = append(paths, file.FilePath)
+	}
+	for _, project := range projects {
+		if project.CoverArtPath.Valid {
+			paths = append(paths, project.CoverArtPath.String)
+		}
+	}
+
+	for _, stored := range paths {
+		rel := archivePathOf(stored)
+		if rel != "" {
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel))); err == nil {
+				continue
+			}
+		}
+		plan.MissingFileCount++
+		if len(plan.MissingFiles) < missingFilesToList {
+			plan.MissingFiles = append(plan.MissingFiles, stored)
+		}
+	}
+	return nil
+}
+
+// archivePathOf returns the part of a stored path from its projects/
+// directory on, or "" if it has none.
+func archivePathOf(stored string) string {
+	parts := strings.Split(filepath.ToSlash(stored), "/")
+	for i := len(parts) - 2; i >= 0; i-- {
+		if parts[i] == "projects" {
+			return strings.Join(parts[i:], "/")
+		}
+	}
+	return ""
+}
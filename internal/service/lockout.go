@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+)
+
+// Lockout policy. The first few failures are free; each one after that
+// blocks the next attempt for twice as long as the last, starting at a
+// second, until lockoutThreshold locks the subject out. Once a lockout
+// ends, every further failure locks it again, until lockoutForgetAfter
+// passes without one.
+const (
+	lockoutFreeAttempts = 3
+	lockoutThreshold    = 10
+	lockoutDuration     = 15 * time.Minute
+	lockoutForgetAfter  = time.Hour
+	// lockoutRaceRetries bounds how often Attempt rereads a subject that
+	// other attempts keep changing under it.
+	lockoutRaceRetries = 5
+)
+
+// Lockout scopes.
+const (
+	LockoutAccount = "account"
+	LockoutShare   = "share"
+)
+
+var ErrLockedOut = errors.New("too many failed attempts")
+
+// LockedOutError says when a blocked subject may try again. It wraps
+// ErrLockedOut.
+type LockedOutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedOutError) Error() string {
+	return fmt.Sprintf("%v, retry in %s", ErrLockedOut, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedOutError) Unwrap() error {
+	return ErrLockedOut
+}
+
+// LockoutKey names what failed attempts are counted against.
+type LockoutKey struct {
+	Scope   string
+	Subject string
+}
+
+// AccountLockout is the key for a username's password attempts, counted
+// whether or not the account exists.
+func AccountLockout(username string) LockoutKey {
+	return LockoutKey{Scope: LockoutAccount, Subject: strings.ToLower(strings.TrimSpace(username))}
+}
+
+// ShareLockout is the key for a share link's password attempts. kind is
+// "track" or "project".
+func ShareLockout(kind string, shareTokenID int64) LockoutKey {
+	return LockoutKey{Scope: LockoutShare, Subject: kind + ":" + strconv.FormatInt(shareTokenID, 10)}
+}
+
+type Lockout struct {
+	ID            int64      `json:"id"`
+	Scope         string     `json:"scope"`
+	Subject       string     `json:"subject"`
+	Failures      int64      `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	BlockedUntil  *time.Time `json:"blocked_until,omitempty"`
+	// Locked is set during a lockout, as opposed to the short delays
+	// before one.
+	Locked bool `json:"locked"`
+}
+
+// LockoutService counts failed password attempts per account and per
+// share link, so guessing one is slowed and then stopped however many
+// addresses it comes from.
+type LockoutService interface {
+	// Attempt counts an attempt against key before its credential is
+	// checked, and returns the key's state as if it fails. It fails with a
+	// *LockedOutError while key may not try again. Counting first means
+	// parallel guesses cannot all slip in before any is recorded.
+	Attempt(ctx context.Context, key LockoutKey) (*Lockout, error)
+	// Succeed forgets the key's failures, including the attempt that
+	// succeeded.
+	Succeed(ctx context.Context, key LockoutKey) error
+	// List returns the keys with recent failures, most recent first.
+	List(ctx context.Context) ([]Lockout, error)
+	// Unlock forgets the failures of a listed key and returns it.
+	Unlock(ctx context.Context, id int64) (*Lockout, error)
+	PurgeStale(ctx context.Context) error
+}
+
+type lockoutService struct {
+	db *db.DB
+}
+
+func NewLockoutService(database *db.DB) LockoutService {
+	return &lockoutService{db: database}
+}
+
+func (s *lockoutService) Attempt(ctx context.Context, key LockoutKey) (*Lockout, error) {
+	for range lockoutRaceRetries {
+		now := time.Now().UTC()
+		row, err := s.db.Queries.GetAuthLockout(ctx, sqlc.GetAuthLockoutParams{
+			Scope:   key.Scope,
+			Subject: key.Subject,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			row, err = s.db.Queries.InsertAuthAttempt(ctx, sqlc.InsertAuthAttemptParams{
+				Scope:   key.Scope,
+				Subject: key.Subject,
+				Now:     now,
+			})
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return lockoutFromRow(row), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if row.BlockedUntil.Valid {
+			if wait := row.BlockedUntil.Time.Sub(now); wait > 0 {
+				return nil, &LockedOutError{RetryAfter: wait}
+			}
+		}
+
+		// A failure after a quiet period starts the count over.
+		failures := row.Failures + 1
+		if row.LastFailureAt.Before(now.Add(-lockoutForgetAfter)) {
+			failures = 1
+		}
+		var blockedUntil sql.NullTime
+		if block := lockoutDelay(failures); block > 0 {
+			blockedUntil = sql.NullTime{Time: now.Add(block), Valid: true}
+		}
+
+		// The update only applies if no other attempt got in since the read,
+		// so each attempt sees the block set by the one before it.
+		row, err = s.db.Queries.UpdateAuthAttempt(ctx, sqlc.UpdateAuthAttemptParams{
+			Failures:     failures,
+			Now:          now,
+			BlockedUntil: blockedUntil,
+			ID:           row.ID,
+			ReadFailures: row.Failures,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return lockoutFromRow(row), nil
+	}
+
+	// Attempts on this key are arriving faster than they can be counted.
+	return nil, &LockedOutError{RetryAfter: time.Second}
+}
+
+// lockoutDelay is how long the failures-th failure blocks the next attempt.
+func lockoutDelay(failures int64) time.Duration {
+	switch {
+	case failures >= lockoutThreshold:
+		return lockoutDuration
+	case failures > lockoutFreeAttempts:
+		return time.Second << (failures - lockoutFreeAttempts - 1)
+	default:
+		return 0
+	}
+}
+
+func (s *lockoutService) Succeed(ctx context.Context, key LockoutKey) error {
+	return s.db.Queries.DeleteAuthLockout(ctx, sqlc.DeleteAuthLockoutParams{
+		Scope:   key.Scope,
+		Subject: key.Subject,
+	})
+}
+
+func (s *lockoutService) List(ctx context.Context) ([]Lockout, error) {
+	rows, err := s.db.Queries.ListAuthLockouts(ctx, time.Now().UTC().Add(-lockoutForgetAfter))
+	if err != nil {
+		return nil, err
+	}
+
+	lockouts := make([]Lockout, 0, len(rows))
+	for _, row := range rows {
+		lockouts = append(lockouts, *lockoutFromRow(row))
+	}
+	return lockouts, nil
+}
+
+func (s *lockoutService) Unlock(ctx context.Context, id int64) (*Lockout, error) {
+	row, err := s.db.Queries.GetAuthLockoutByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.db.Queries.DeleteAuthLockoutByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if deleted == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return lockoutFromRow(row), nil
+}
+
+func (s *lockoutService) PurgeStale(ctx context.Context) error {
+	now := time.Now().UTC()
+	return s.db.Queries.DeleteStaleAuthLockouts(ctx, sqlc.DeleteStaleAuthLockoutsParams{
+		ForgetBefore: now.Add(-lockoutForgetAfter),
+		Now:          sql.NullTime{Time: now, Valid: true},
+	})
+}
+
+func lockoutFromRow(row sqlc.AuthLockout) *Lockout {
+	lockout := &Lockout{
+		ID:            row.ID,
+		Scope:         row.Scope,
+		Subject:       row.Subject,
+		Failures:      row.Failures,
+		LastFailureAt: row.LastFailureAt,
+	}
+	if row.BlockedUntil.Valid {
+		blockedUntil := row.BlockedUntil.Time
+		lockout.BlockedUntil = &blockedUntil
+		lockout.Locked = row.Failures >= lockoutThreshold && blockedUntil.After(time.Now())
+	}
+	return lockout
+}
+
+// RunLockoutPurge forgets stale failed attempts every interval until ctx is
+// cancelled.
+func RunLockoutPurge(ctx context.Context, lockouts LockoutService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := lockouts.PurgeStale(ctx); err != nil {
+			slog.Warn("Lockout purge failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
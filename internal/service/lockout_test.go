@@ -0,0 +1,82 @@
+//go:build sqlite_fts5
+
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestLockoutAttemptDelaysAfterFreeAttempts(t *testing.T) {
+	ctx := context.Background()
+	lockouts := NewLockoutService(newTestDB(t))
+	key := AccountLockout("Alice")
+
+	for i := 1; i <= lockoutFreeAttempts; i++ {
+		lockout, err := lockouts.Attempt(ctx, key)
+		if err != nil {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+		if lockout.BlockedUntil != nil {
+			t.Fatalf("attempt %d blocked the next one", i)
+		}
+	}
+
+	lockout, err := lockouts.Attempt(ctx, key)
+	if err != nil {
+		t.Fatalf("first attempt past the free ones: %v", err)
+	}
+	if lockout.BlockedUntil == nil || lockout.Locked {
+		t.Fatalf("expected a short delay, got %+v", lockout)
+	}
+
+	_, err = lockouts.Attempt(ctx, AccountLockout("alice"))
+	var locked *LockedOutError
+	if !errors.As(err, &locked) || locked.RetryAfter <= 0 {
+		t.Fatalf("expected a LockedOutError during the delay, got %v", err)
+	}
+
+	if err := lockouts.Succeed(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockouts.Attempt(ctx, key); err != nil {
+		t.Fatalf("attempt after success: %v", err)
+	}
+}
+
+func TestLockoutAttemptCountsParallelGuesses(t *testing.T) {
+	ctx := context.Background()
+	lockouts := NewLockoutService(newTestDB(t))
+	key := ShareLockout("track", 1)
+
+	const guesses = 40
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for range guesses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := lockouts.Attempt(ctx, key)
+			if err != nil && !errors.Is(err, ErrLockedOut) {
+				t.Error(err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The free attempts, then the one that sets the first delay.
+	if allowed > lockoutFreeAttempts+1 {
+		t.Fatalf("%d of %d parallel guesses were let through, want at most %d", allowed, guesses, lockoutFreeAttempts+1)
+	}
+}
@@ -0,0 +1,471 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"ramiro-uziel/vault/internal/auth"
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/oidc"
+)
+
+const (
+	// oidcLoginTTL is how long a user has to log in at the provider.
+	oidcLoginTTL          = 10 * time.Minute
+	maxProvisionedNameLen = 32
+)
+
+var (
+	ErrOIDCDisabled    = errors.New("single sign-on is not configured")
+	ErrNoLinkedAccount = errors.New("no account is linked to this identity")
+	ErrIdentityLinked  = errors.New("identity is linked to another account")
+	ErrOIDCProvider    = errors.New("identity provider login failed")
+)
+
+var usernameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+type OIDCConfig struct {
+	oidc.Config
+	// ProviderName labels the login button.
+	ProviderName string
+	// AllowedDomains lets anyone with a verified email at these domains get
+	// an account on first login.
+	AllowedDomains []string
+	// AdminClaim and AdminValues make a user an admin when the claim holds
+	// one of the values, and not an admin when it does not. Unset, roles are
+	// managed in Vault.
+	AdminClaim  string
+	AdminValues []string
+}
+
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != "" && c.ClientID != ""
+}
+
+// OIDCService logs users in through an OpenID provider with the
+// authorization code flow and PKCE, and manages the identities linked to
+// local users.
+type OIDCService interface {
+	Enabled() bool
+	ProviderName() string
+	// Begin starts a login at the provider and returns where to send the
+	// browser. The state in it has to come back with the callback.
+	Begin(ctx context.Context, input OIDCBeginInput) (*OIDCRedirect, error)
+	// Complete finishes a login from the provider's callback and returns the
+	// user it resolved to, creating or linking one if allowed.
+	Complete(ctx context.Context, state, code string) (*OIDCResult, error)
+	ListIdentities(ctx context.Context, userID int64) ([]Identity, error)
+	Unlink(ctx context.Context, userID, identityID int64) error
+}
+
+type OIDCBeginInput struct {
+	// InviteToken lets the login create an account from an invite.
+	InviteToken string
+	// LinkUserID links the identity to this logged-in user instead.
+	LinkUserID *int64
+}
+
+type OIDCRedirect struct {
+	URL       string
+	State     string
+	ExpiresAt time.Time
+}
+
+type OIDCResult struct {
+	User        *User
+	Provisioned bool
+	Linked      bool
+	// LinkOnly is set when the flow linked an identity for a user who was
+	// already logged in.
+	LinkOnly bool
+}
+
+type Identity struct {
+	ID          int64      `json:"id"`
+	Issuer      string     `json:"issuer"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+type oidcService struct {
+	db         *db.DB
+	authConfig auth.Config
+	config     OIDCConfig
+	provider   *oidc.Provider
+}
+
+func NewOIDCService(database *db.DB, authConfig auth.Config, config OIDCConfig) OIDCService {
+	s := &oidcService{
+		db:         database,
+		authConfig: authConfig,
+		config:     config,
+	}
+	if config.Enabled() {
+		s.provider = oidc.NewProvider(config.Config)
+	}
+	return s
+}
+
+func (s *oidcService) Enabled() bool {
+	return s.provider != nil
+}
+
+func (s *oidcService) ProviderName() string {
+	if s.config.ProviderName != "" {
+		return s.config.ProviderName
+	}
+	return "SSO"
+}
+
+func (s *oidcService) Begin(ctx context.Context, input OIDCBeginInput) (*OIDCRedirect, error) {
+	if !s.Enabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	now := time.Now()
+	_ = s.db.Queries.DeleteExpiredOIDCLogins(ctx, now)
+
+	state, err := oidc.NewState()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	redirectURL, err := s.provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		slog.ErrorContext(ctx, "OIDC discovery failed", "issuer", s.config.Issuer, "error", err)
+		return nil, ErrOIDCProvider
+	}
+
+	params := sqlc.CreateOIDCLoginParams{
+		StateHash:    auth.HashToken(state, s.authConfig.TokenPepper),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(oidcLoginTTL),
+	}
+	if input.InviteToken != "" {
+		params.InviteTokenHash = sql.NullString{String: auth.HashToken(input.InviteToken, s.authConfig.TokenPepper), Valid: true}
+	}
+	if input.LinkUserID != nil {
+		params.LinkUserID = sql.NullInt64{Int64: *input.LinkUserID, Valid: true}
+	}
+	if err := s.db.Queries.CreateOIDCLogin(ctx, params); err != nil {
+		return nil, err
+	}
+
+	return &OIDCRedirect{URL: redirectURL, State: state, ExpiresAt: params.ExpiresAt}, nil
+}
+
+func (s *oidcService) Complete(ctx context.Context, state, code string) (*OIDCResult, error) {
+	if !s.Enabled() {
+		return nil, ErrOIDCDisabled
+	}
+	if state == "" || code == "" {
+		return nil, ErrInvalidToken
+	}
+
+	login, err := s.db.Queries.TakeOIDCLogin(ctx, auth.HashToken(state, s.authConfig.TokenPepper))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(login.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	claims, err := s.provider.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		slog.WarnContext(ctx, "OIDC login failed", "issuer", s.config.Issuer, "error", err)
+		return nil, ErrOIDCProvider
+	}
+
+	result, err := s.resolve(ctx, login, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.syncAdmin(ctx, result.User, claims); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// resolve finds or creates the user an identity logs in as.
+func (s *oidcService) resolve(ctx context.Context, login sqlc.OidcLogin, claims *oidc.Claims) (*OIDCResult, error) {
+	now := sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	identity, err := s.db.Queries.GetUserIdentity(ctx, sqlc.GetUserIdentityParams{
+		Issuer:  s.config.Issuer,
+		Subject: claims.Subject,
+	})
+	if err == nil {
+		if login.LinkUserID.Valid && login.LinkUserID.Int64 != identity.UserID {
+			return nil, ErrIdentityLinked
+		}
+		if err := s.db.Queries.UpdateUserIdentityLogin(ctx, sqlc.UpdateUserIdentityLoginParams{
+			Email:       claims.Email,
+			LastLoginAt: now,
+			ID:          identity.ID,
+		}); err != nil {
+			return nil, err
+		}
+		user, err := s.db.Queries.GetUserByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return &OIDCResult{User: sqlcUserToServiceUser(user), LinkOnly: login.LinkUserID.Valid}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if login.LinkUserID.Valid {
+		user, err := s.link(ctx, login.LinkUserID.Int64, claims)
+		if err != nil {
+			return nil, err
+		}
+		return &OIDCResult{User: user, Linked: true, LinkOnly: true}, nil
+	}
+
+	// An account with the same verified email is the same person, as far
+	// as a provider trusted for login can tell.
+	if claims.Email != "" && claims.EmailVerified {
+		existing, err := s.db.Queries.GetUserByEmail(ctx, claims.Email)
+		if err == nil {
+			user, err := s.link(ctx, existing.ID, claims)
+			if err != nil {
+				return nil, err
+			}
+			return &OIDCResult{User: user, Linked: true}, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	user, err := s.provision(ctx, login, claims)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCResult{User: user, Provisioned: true, Linked: true}, nil
+}
+
+func (s *oidcService) link(ctx context.Context, userID int64, claims *oidc.Claims) (*User, error) {
+	if _, err := s.db.Queries.CreateUserIdentity(ctx, sqlc.CreateUserIdentityParams{
+		UserID:      userID,
+		Issuer:      s.config.Issuer,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		LastLoginAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	}); err != nil {
+		if strings.HasPrefix(err.Error(), "UNIQUE constraint failed: user_identities") {
+			return nil, ErrIdentityLinked
+		}
+		return nil, err
+	}
+	user, err := s.db.Queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return sqlcUserToServiceUser(user), nil
+}
+
+// provision creates an account for a new identity, if an invite or the
+// domain allowlist lets it in.
+func (s *oidcService) provision(ctx context.Context, login sqlc.OidcLogin, claims *oidc.Claims) (*User, error) {
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrNoLinkedAccount
+	}
+
+	var invite *sqlc.InviteToken
+	if login.InviteTokenHash.Valid {
+		token, err := s.db.Queries.GetInviteTokenByToken(ctx, login.InviteTokenHash.String)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		// An invite addressed to someone only admits that address.
+		if err == nil && token.TokenType == "invite" && !token.Used && time.Now().Before(token.ExpiresAt) &&
+			(token.Email == "" || strings.EqualFold(token.Email, claims.Email)) {
+			invite = &token
+		}
+	}
+	if invite == nil && !s.domainAllowed(claims.Email) {
+		return nil, ErrNoLinkedAccount
+	}
+
+	passwordHash, err := unusablePasswordHash()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := s.db.Queries.WithTx(tx)
+
+	username, err := s.availableUsername(ctx, qtx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := qtx.CreateUser(ctx, sqlc.CreateUserParams{
+		Username:     username,
+		Email:        claims.Email,
+		PasswordHash: passwordHash,
+	})
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return nil, ErrUserExists
+		}
+		return nil, err
+	}
+	if err := qtx.CreateUserPreferences(ctx, sqlc.CreateUserPreferencesParams{
+		UserID:         user.ID,
+		DefaultQuality: "lossy",
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := qtx.CreateUserIdentity(ctx, sqlc.CreateUserIdentityParams{
+		UserID:      user.ID,
+		Issuer:      s.config.Issuer,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		LastLoginAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	}); err != nil {
+		return nil, err
+	}
+	if invite != nil {
+		if _, err := qtx.MarkTokenAsUsed(ctx, invite.ID); errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenUsed
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return sqlcUserToServiceUser(user), nil
+}
+
+func (s *oidcService) domainAllowed(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	return slices.ContainsFunc(s.config.AllowedDomains, func(allowed string) bool {
+		return strings.EqualFold(allowed, domain)
+	})
+}
+
+// availableUsername derives a username from the identity's preferred
+// username or email, with a number appended if it is taken.
+func (s *oidcService) availableUsername(ctx context.Context, q *sqlc.Queries, claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = strings.Trim(usernameUnsafe.ReplaceAllString(base, "-"), "-.")
+	if base == "" {
+		base = "user"
+	}
+	if runes := []rune(base); len(runes) > maxProvisionedNameLen {
+		base = string(runes[:maxProvisionedNameLen])
+	}
+
+	candidate := base
+	for i := 2; i < 1000; i++ {
+		_, err := q.GetUserByUsername(ctx, candidate)
+		if errors.Is(err, sql.ErrNoRows) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+	return "", ErrUserExists
+}
+
+// syncAdmin applies the admin claim mapping. The owner always stays an
+// admin.
+func (s *oidcService) syncAdmin(ctx context.Context, user *User, claims *oidc.Claims) error {
+	if s.config.AdminClaim == "" || user.IsOwner {
+		return nil
+	}
+	isAdmin := slices.ContainsFunc(s.config.AdminValues, func(value string) bool {
+		return claims.ClaimContains(s.config.AdminClaim, value)
+	})
+	if isAdmin == user.IsAdmin {
+		return nil
+	}
+	if _, err := s.db.Queries.UpdateUserRole(ctx, sqlc.UpdateUserRoleParams{
+		IsAdmin: isAdmin,
+		ID:      user.ID,
+	}); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Admin role updated from identity provider claims",
+		"user_id", user.ID,
+		"is_admin", isAdmin,
+	)
+	user.IsAdmin = isAdmin
+	return nil
+}
+
+func (s *oidcService) ListIdentities(ctx context.Context, userID int64) ([]Identity, error) {
+	rows, err := s.db.Queries.ListUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	identities := make([]Identity, 0, len(rows))
+	for _, row := range rows {
+		identity := Identity{
+			ID:        row.ID,
+			Issuer:    row.Issuer,
+			Email:     row.Email,
+			CreatedAt: row.CreatedAt,
+		}
+		if row.LastLoginAt.Valid {
+			identity.LastLoginAt = &row.LastLoginAt.Time
+		}
+		identities = append(identities, identity)
+	}
+	return identities, nil
+}
+
+func (s *oidcService) Unlink(ctx context.Context, userID, identityID int64) error {
+	deleted, err := s.db.Queries.DeleteUserIdentity(ctx, sqlc.DeleteUserIdentityParams{
+		ID:     identityID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
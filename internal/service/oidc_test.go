@@ -0,0 +1,285 @@
+//go:build sqlite_fts5
+
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"ramiro-uziel/vault/internal/auth"
+	"ramiro-uziel/vault/internal/db"
+	"ramiro-uziel/vault/internal/oidc"
+)
+
+const testClientID = "vault"
+
+// fakeIdP is an OpenID provider that logs in whoever the test says, and
+// checks PKCE at its token endpoint like a real one.
+type fakeIdP struct {
+	*httptest.Server
+	t   *testing.T
+	key *ecdsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]idpGrant
+}
+
+type idpGrant struct {
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{t: t, key: key, codes: map[string]idpGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		x := make([]byte, 32)
+		y := make([]byte, 32)
+		key.X.FillBytes(x)
+		key.Y.FillBytes(y)
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "EC",
+			"kid": "test",
+			"use": "sig",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(x),
+			"y":   base64.RawURLEncoding.EncodeToString(y),
+		}}})
+	})
+	mux.HandleFunc("POST /token", idp.token)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// authorize logs a user in at the provider for the login redirect, and
+// returns the code the provider would send back with the callback.
+func (idp *fakeIdP) authorize(redirect *OIDCRedirect, claims jwt.MapClaims) string {
+	idp.t.Helper()
+	location, err := url.Parse(redirect.URL)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	query := location.Query()
+	if query.Get("state") != redirect.State || query.Get("code_challenge_method") != "S256" {
+		idp.t.Fatalf("authorization request = %v", query)
+	}
+
+	code := rand.Text()
+	idp.mu.Lock()
+	idp.codes[code] = idpGrant{
+		challenge: query.Get("code_challenge"),
+		nonce:     query.Get("nonce"),
+		claims:    claims,
+	}
+	idp.mu.Unlock()
+	return code
+}
+
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	grant, ok := idp.codes[r.PostFormValue("code")]
+	delete(idp.codes, r.PostFormValue("code"))
+	idp.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   idp.URL,
+		"aud":   testClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+		"nonce": grant.nonce,
+	}
+	for name, value := range grant.claims {
+		claims[name] = value
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		idp.t.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+}
+
+func newTestOIDCService(t *testing.T, database *db.DB, idp *fakeIdP) *oidcService {
+	t.Helper()
+	return NewOIDCService(database, auth.Config{TokenPepper: "pepper"}, OIDCConfig{
+		Config: oidc.Config{
+			Issuer:      idp.URL,
+			ClientID:    testClientID,
+			RedirectURL: "https://vault.test/api/auth/oidc/callback",
+			Scopes:      []string{"openid", "email"},
+		},
+	}).(*oidcService)
+}
+
+// oidcLogin runs a login at the provider as the given identity.
+func oidcLogin(t *testing.T, service OIDCService, idp *fakeIdP, claims jwt.MapClaims) (*OIDCResult, error) {
+	t.Helper()
+	ctx := context.Background()
+	redirect, err := service.Begin(ctx, OIDCBeginInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service.Complete(ctx, redirect.State, idp.authorize(redirect, claims))
+}
+
+func TestOIDCCompleteRejectsStateAndPKCEMismatch(t *testing.T) {
+	ctx := context.Background()
+	idp := newFakeIdP(t)
+	service := newTestOIDCService(t, newTestDB(t), idp)
+	claims := jwt.MapClaims{"sub": "alice"}
+
+	redirect, err := service.Begin(ctx, OIDCBeginInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := idp.authorize(redirect, claims)
+	if _, err := service.Complete(ctx, "forged-state", code); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Complete() with an unknown state = %v, want ErrInvalidToken", err)
+	}
+
+	// A code issued for one login, injected into another, fails PKCE at
+	// the provider.
+	other, err := service.Begin(ctx, OIDCBeginInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Complete(ctx, other.State, code); !errors.Is(err, ErrOIDCProvider) {
+		t.Fatalf("Complete() with another login's code = %v, want ErrOIDCProvider", err)
+	}
+
+	// Each state is good for one callback.
+	if _, err := service.Complete(ctx, other.State, idp.authorize(other, claims)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Complete() with a used state = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestOIDCCompleteLinksVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	idp := newFakeIdP(t)
+	service := newTestOIDCService(t, database, idp)
+	alice := createTestUser(t, database, "alice", "alice@example.com")
+	claims := jwt.MapClaims{"sub": "alice-at-idp", "email": "alice@example.com", "email_verified": true}
+
+	result, err := oidcLogin(t, service, idp, claims)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if result.User.ID != alice.ID || !result.Linked || result.Provisioned {
+		t.Fatalf("first login = %+v, want a link to user %d", result, alice.ID)
+	}
+
+	// From then on the identity itself finds the account.
+	result, err = oidcLogin(t, service, idp, claims)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if result.User.ID != alice.ID || result.Linked {
+		t.Fatalf("second login = %+v, want user %d through the linked identity", result, alice.ID)
+	}
+
+	identities, err := service.ListIdentities(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 1 || identities[0].Issuer != idp.URL {
+		t.Fatalf("identities = %+v", identities)
+	}
+}
+
+func TestOIDCCompleteRefusesUnverifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	idp := newFakeIdP(t)
+	service := newTestOIDCService(t, database, idp)
+	service.config.AllowedDomains = []string{"example.com"}
+	alice := createTestUser(t, database, "alice", "alice@example.com")
+
+	for _, verified := range []any{false, "false", nil} {
+		claims := jwt.MapClaims{"sub": "mallory", "email": "alice@example.com"}
+		if verified != nil {
+			claims["email_verified"] = verified
+		}
+		if _, err := oidcLogin(t, service, idp, claims); !errors.Is(err, ErrNoLinkedAccount) {
+			t.Fatalf("login with email_verified %v = %v, want ErrNoLinkedAccount", verified, err)
+		}
+	}
+
+	identities, err := service.ListIdentities(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 0 {
+		t.Fatalf("an unverified email was linked: %+v", identities)
+	}
+}
+
+func TestOIDCLinkReportsIdentityLinkedElsewhere(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	idp := newFakeIdP(t)
+	service := newTestOIDCService(t, database, idp)
+	alice := createTestUser(t, database, "alice", "alice@example.com")
+	bob := createTestUser(t, database, "bob", "bob@example.com")
+	claims := &oidc.Claims{Subject: "shared", Email: "shared@example.com", EmailVerified: true}
+
+	// Two first logins for one identity can both miss it and race to link
+	// it; the loser hits the unique constraint.
+	if _, err := service.link(ctx, alice.ID, claims); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.link(ctx, bob.ID, claims); !errors.Is(err, ErrIdentityLinked) {
+		t.Fatalf("link() of an identity already linked = %v, want ErrIdentityLinked", err)
+	}
+
+	// Without the race, a user linking it finds it linked to someone else.
+	redirect, err := service.Begin(ctx, OIDCBeginInput{LinkUserID: &bob.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := idp.authorize(redirect, jwt.MapClaims{"sub": "shared"})
+	if _, err := service.Complete(ctx, redirect.State, code); !errors.Is(err, ErrIdentityLinked) {
+		t.Fatalf("linking an identity of another user = %v, want ErrIdentityLinked", err)
+	}
+}
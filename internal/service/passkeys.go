@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/webauthn"
+)
+
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+	defaultPasskeyName   = "Passkey"
+	maxPasskeyNameLength = 64
+)
+
+var (
+	ErrPasskeysDisabled = errors.New("passkeys are not configured on this instance")
+	ErrInvalidPasskey   = errors.New("passkey could not be verified")
+	ErrPasskeyExists    = errors.New("passkey is already registered")
+	ErrNoPasskeys       = errors.New("no passkeys registered")
+)
+
+type Passkey struct {
+	ID             int64      `json:"id"`
+	Name           string     `json:"name"`
+	Transports     []string   `json:"transports"`
+	BackupEligible bool       `json:"backup_eligible"`
+	BackedUp       bool       `json:"backed_up"`
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+}
+
+// webauthn returns the relying party settings, named after the instance.
+func (s *authService) webauthn(ctx context.Context) (webauthn.Config, error) {
+	if s.authConfig.WebAuthnRPID == "" {
+		return webauthn.Config{}, ErrPasskeysDisabled
+	}
+	name := defaultTOTPIssuer
+	if settings, err := s.db.Queries.GetInstanceSettings(ctx); err == nil && settings.Name != "" {
+		name = settings.Name
+	}
+	return webauthn.Config{
+		RPID:    s.authConfig.WebAuthnRPID,
+		RPName:  name,
+		Origins: s.authConfig.WebAuthnOrigins,
+	}, nil
+}
+
+// passkeyUserHandle is the opaque user ID credentials are created for.
+func passkeyUserHandle(userID int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
+
+func (s *authService) BeginPasskeyRegistration(ctx context.Context, userID int64) (*webauthn.CreationOptions, error) {
+	config, err := s.webauthn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.db.Queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.db.Queries.ListPasskeysByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.createPasskeyChallenge(ctx, &userID, ceremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	return config.Registration(webauthn.UserEntity{
+		ID:          passkeyUserHandle(userID),
+		Name:        user.Username,
+		DisplayName: user.Username,
+	}, challenge, credentialDescriptors(existing)), nil
+}
+
+func (s *authService) FinishPasskeyRegistration(ctx context.Context, userID int64, name string, response *webauthn.RegistrationResponse) (*Passkey, error) {
+	config, err := s.webauthn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.takePasskeyChallenge(ctx, response.Response.ClientDataJSON, ceremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.UserID.Valid || challenge.UserID.Int64 != userID {
+		return nil, ErrInvalidPasskey
+	}
+
+	credential, err := config.VerifyRegistration(response, decodeStoredChallenge(challenge.Challenge))
+	if err != nil {
+		return nil, passkeyError(ctx, err, userID)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+	if runes := []rune(name); len(runes) > maxPasskeyNameLength {
+		name = string(runes[:maxPasskeyNameLength])
+	}
+
+	passkey, err := s.db.Queries.CreatePasskey(ctx, sqlc.CreatePasskeyParams{
+		UserID:         userID,
+		CredentialID:   base64.RawURLEncoding.EncodeToString(credential.ID),
+		PublicKey:      credential.PublicKey,
+		SignCount:      int64(credential.SignCount),
+		Name:           name,
+		Aaguid:         hex.EncodeToString(credential.AAGUID),
+		Transports:     strings.Join(credential.Transports, ","),
+		BackupEligible: credential.BackupEligible,
+		BackupState:    credential.BackupState,
+	})
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "UNIQUE constraint failed: passkeys") {
+			return nil, ErrPasskeyExists
+		}
+		return nil, err
+	}
+	return sqlcPasskeyToPasskey(passkey), nil
+}
+
+func (s *authService) BeginPasskeyLogin(ctx context.Context, userID *int64) (*webauthn.RequestOptions, error) {
+	config, err := s.webauthn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Passwordless logins let the browser offer any discoverable credential
+	// and must verify the user, since the passkey is the only factor.
+	var allow []webauthn.CredentialDescriptor
+	userVerification := "required"
+	if userID != nil {
+		passkeys, err := s.db.Queries.ListPasskeysByUser(ctx, *userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(passkeys) == 0 {
+			return nil, ErrNoPasskeys
+		}
+		allow = credentialDescriptors(passkeys)
+		userVerification = "preferred"
+	}
+
+	challenge, err := s.createPasskeyChallenge(ctx, userID, ceremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+	return config.Authentication(challenge, allow, userVerification), nil
+}
+
+func (s *authService) FinishPasskeyLogin(ctx context.Context, userID *int64, response *webauthn.AuthenticationResponse) (*User, error) {
+	config, err := s.webauthn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.takePasskeyChallenge(ctx, response.Response.ClientDataJSON, ceremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+	if userID == nil && challenge.UserID.Valid {
+		return nil, ErrInvalidPasskey
+	}
+	if userID != nil && (!challenge.UserID.Valid || challenge.UserID.Int64 != *userID) {
+		return nil, ErrInvalidPasskey
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(response.RawID)
+	passkey, err := s.db.Queries.GetPasskeyByCredentialID(ctx, credentialID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidPasskey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userID != nil && passkey.UserID != *userID {
+		return nil, ErrInvalidPasskey
+	}
+	// A discoverable credential names its user; it has to be the owner of
+	// the credential on file.
+	handle := response.Response.UserHandle
+	if (userID == nil && len(handle) == 0) || (len(handle) > 0 && string(handle) != string(passkeyUserHandle(passkey.UserID))) {
+		return nil, ErrInvalidPasskey
+	}
+
+	assertion, err := config.VerifyAuthentication(
+		response,
+		decodeStoredChallenge(challenge.Challenge),
+		passkey.PublicKey,
+		uint32(passkey.SignCount),
+		userID == nil,
+	)
+	if err != nil {
+		return nil, passkeyError(ctx, err, passkey.UserID)
+	}
+
+	if err := s.db.Queries.UpdatePasskeyUse(ctx, sqlc.UpdatePasskeyUseParams{
+		SignCount:   int64(assertion.SignCount),
+		BackupState: assertion.BackupState,
+		ID:          passkey.ID,
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.Me(ctx, passkey.UserID)
+}
+
+func (s *authService) ListPasskeys(ctx context.Context, userID int64) ([]Passkey, error) {
+	rows, err := s.db.Queries.ListPasskeysByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys := make([]Passkey, 0, len(rows))
+	for _, row := range rows {
+		passkeys = append(passkeys, *sqlcPasskeyToPasskey(row))
+	}
+	return passkeys, nil
+}
+
+func (s *authService) DeletePasskey(ctx context.Context, userID, passkeyID int64) error {
+	deleted, err := s.db.Queries.DeletePasskey(ctx, sqlc.DeletePasskeyParams{
+		ID:     passkeyID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *authService) createPasskeyChallenge(ctx context.Context, userID *int64, ceremony string) (webauthn.Bytes, error) {
+	now := time.Now()
+	_ = s.db.Queries.DeleteExpiredPasskeyChallenges(ctx, now)
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	params := sqlc.CreatePasskeyChallengeParams{
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Ceremony:  ceremony,
+		ExpiresAt: now.Add(webauthn.Timeout),
+	}
+	if userID != nil {
+		params.UserID = sql.NullInt64{Int64: *userID, Valid: true}
+	}
+	if err := s.db.Queries.CreatePasskeyChallenge(ctx, params); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// takePasskeyChallenge finds the challenge a response answers and uses it
+// up, so a response cannot be replayed.
+func (s *authService) takePasskeyChallenge(ctx context.Context, clientDataJSON []byte, ceremony string) (sqlc.PasskeyChallenge, error) {
+	raw, err := webauthn.Challenge(clientDataJSON)
+	if err != nil {
+		return sqlc.PasskeyChallenge{}, ErrInvalidPasskey
+	}
+	challenge, err := s.db.Queries.TakePasskeyChallenge(ctx, sqlc.TakePasskeyChallengeParams{
+		Challenge: base64.RawURLEncoding.EncodeToString(raw),
+		Ceremony:  ceremony,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return challenge, ErrInvalidPasskey
+	}
+	if err != nil {
+		return challenge, err
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return challenge, ErrInvalidPasskey
+	}
+	return challenge, nil
+}
+
+// decodeStoredChallenge decodes a challenge createPasskeyChallenge encoded.
+func decodeStoredChallenge(challenge string) []byte {
+	decoded, _ := base64.RawURLEncoding.DecodeString(challenge)
+	return decoded
+}
+
+// passkeyError logs why a response failed verification and hides it from
+// the client.
+func passkeyError(ctx context.Context, err error, userID int64) error {
+	if !errors.Is(err, webauthn.ErrVerification) {
+		return err
+	}
+	slog.WarnContext(ctx, "Passkey verification failed", "user_id", userID, "error", err)
+	return ErrInvalidPasskey
+}
+
+func credentialDescriptors(passkeys []sqlc.Passkey) []webauthn.CredentialDescriptor {
+	descriptors := make([]webauthn.CredentialDescriptor, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		id, err := base64.RawURLEncoding.DecodeString(passkey.CredentialID)
+		if err != nil {
+			continue
+		}
+		descriptors = append(descriptors, webauthn.CredentialDescriptor{
+			Type:       "public-key",
+			ID:         id,
+			Transports: splitTransports(passkey.Transports),
+		})
+	}
+	return descriptors
+}
+
+func splitTransports(transports string) []string {
+	if transports == "" {
+		return []string{}
+	}
+	return strings.Split(transports, ",")
+}
+
+func sqlcPasskeyToPasskey(passkey sqlc.Passkey) *Passkey {
+	result := &Passkey{
+		ID:             passkey.ID,
+		Name:           passkey.Name,
+		Transports:     splitTransports(passkey.Transports),
+		BackupEligible: passkey.BackupEligible,
+		BackedUp:       passkey.BackupState,
+	}
+	if passkey.CreatedAt.Valid {
+		result.CreatedAt = &passkey.CreatedAt.Time
+	}
+	if passkey.LastUsedAt.Valid {
+		result.LastUsedAt = &passkey.LastUsedAt.Time
+	}
+	return result
+}
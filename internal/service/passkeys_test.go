@@ -0,0 +1,139 @@
+//go:build sqlite_fts5
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ramiro-uziel/vault/internal/auth"
+	"ramiro-uziel/vault/internal/webauthn/webauthntest"
+)
+
+const testRPID = "vault.test"
+
+// registerTestPasskey registers a software authenticator for a new user.
+func registerTestPasskey(t *testing.T) (AuthService, int64, *webauthntest.Authenticator) {
+	t.Helper()
+	ctx := context.Background()
+	database := newTestDB(t)
+	authService := NewAuthService(database, auth.Config{WebAuthnRPID: testRPID})
+	user := createTestUser(t, database, "alice", "alice@example.com")
+
+	authenticator, err := webauthntest.New(testRPID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, err := authService.BeginPasskeyRegistration(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := authenticator.Create(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passkey, err := authService.FinishPasskeyRegistration(ctx, user.ID, "Laptop", response)
+	if err != nil {
+		t.Fatalf("FinishPasskeyRegistration() = %v", err)
+	}
+	if passkey.Name != "Laptop" || len(passkey.Transports) != 1 || passkey.Transports[0] != "internal" {
+		t.Fatalf("registered passkey = %+v", passkey)
+	}
+	return authService, user.ID, authenticator
+}
+
+// passkeyLogin runs a login ceremony, passwordless when userID is nil.
+func passkeyLogin(authService AuthService, authenticator *webauthntest.Authenticator, userID *int64) (*User, error) {
+	ctx := context.Background()
+	options, err := authService.BeginPasskeyLogin(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	response, err := authenticator.Get(options)
+	if err != nil {
+		return nil, err
+	}
+	return authService.FinishPasskeyLogin(ctx, userID, response)
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	authService, userID, authenticator := registerTestPasskey(t)
+
+	user, err := passkeyLogin(authService, authenticator, nil)
+	if err != nil {
+		t.Fatalf("passwordless login: %v", err)
+	}
+	if user.ID != userID {
+		t.Fatalf("logged in as user %d, want %d", user.ID, userID)
+	}
+
+	// As a second factor the account is already known.
+	if _, err := passkeyLogin(authService, authenticator, &userID); err != nil {
+		t.Fatalf("second factor login: %v", err)
+	}
+}
+
+func TestPasskeyRegistrationRejectsReplayAndDuplicates(t *testing.T) {
+	ctx := context.Background()
+	authService, userID, authenticator := registerTestPasskey(t)
+
+	options, err := authService.BeginPasskeyRegistration(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := authenticator.Create(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authService.FinishPasskeyRegistration(ctx, userID, "", response); !errors.Is(err, ErrPasskeyExists) {
+		t.Fatalf("registering the same credential again = %v, want ErrPasskeyExists", err)
+	}
+	// The challenge was used up by the attempt above.
+	if _, err := authService.FinishPasskeyRegistration(ctx, userID, "", response); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("replayed registration = %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestPasskeyLoginRejectsCounterRegression(t *testing.T) {
+	authService, _, authenticator := registerTestPasskey(t)
+
+	if _, err := passkeyLogin(authService, authenticator, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A clone of the authenticator would sign with a counter that has
+	// already been seen.
+	authenticator.SignCount--
+	if _, err := passkeyLogin(authService, authenticator, nil); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("login with a repeated counter = %v, want ErrInvalidPasskey", err)
+	}
+
+	authenticator.SignCount += 5
+	if _, err := passkeyLogin(authService, authenticator, nil); err != nil {
+		t.Fatalf("login after the counter moved on: %v", err)
+	}
+}
+
+func TestPasskeyLoginUserVerification(t *testing.T) {
+	authService, userID, authenticator := registerTestPasskey(t)
+	authenticator.UserVerified = false
+
+	// Without a password, the passkey must verify the user.
+	if _, err := passkeyLogin(authService, authenticator, nil); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("passwordless login without UV = %v, want ErrInvalidPasskey", err)
+	}
+	// After a password, presence is enough.
+	if _, err := passkeyLogin(authService, authenticator, &userID); err != nil {
+		t.Fatalf("second factor login without UV: %v", err)
+	}
+}
+
+func TestPasskeyLoginRejectsOtherOrigins(t *testing.T) {
+	authService, _, authenticator := registerTestPasskey(t)
+	authenticator.Origin = "https://evil.test"
+
+	if _, err := passkeyLogin(authService, authenticator, nil); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("login from another origin = %v, want ErrInvalidPasskey", err)
+	}
+}
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/httputil"
+	"ramiro-uziel/vault/internal/storage"
+	"ramiro-uziel/vault/internal/transcoding"
+)
+
+// ProjectArchiveVersion is the format of project archives. Imports accept
+// any archive with the same major version.
+const ProjectArchiveVersion = "1.0"
+
+// ProjectManifestName is the manifest at the root of a project archive.
+// Archives without one hold audio only and cannot be imported.
+const ProjectManifestName = "project.json"
+
+// ErrInvalidProjectArchive is wrapped by the errors for project archives
+// that cannot be imported.
+var ErrInvalidProjectArchive = errors.New("invalid project archive")
+
+// ProjectManifest describes a project independently of the instance it was
+// exported from: no IDs, owners or sharing, and files by their entry name in
+// the archive.
+type ProjectManifest struct {
+	Version    string        `json:"version"`
+	ExportedAt time.Time     `json:"exported_at"`
+	Project    ProjectExport `json:"project"`
+	Tracks     []TrackExport `json:"tracks"`
+}
+
+type ProjectExport struct {
+	Name            string  `json:"name"`
+	Description     *string `json:"description,omitempty"`
+	QualityOverride *string `json:"quality_override,omitempty"`
+	AuthorOverride  *string `json:"author_override,omitempty"`
+	Notes           *string `json:"notes,omitempty"`
+	NotesAuthorName *string `json:"notes_author_name,omitempty"`
+	// UserNotes are the notes each user with access left on the project.
+	UserNotes []NoteExport `json:"user_notes,omitempty"`
+	Cover     string       `json:"cover,omitempty"`
+}
+
+type TrackExport struct {
+	Title           string          `json:"title"`
+	Artist          *string         `json:"artist,omitempty"`
+	Album           *string         `json:"album,omitempty"`
+	Key             *string         `json:"key,omitempty"`
+	BPM             *int64          `json:"bpm,omitempty"`
+	Order           int64           `json:"order"`
+	Notes           *string         `json:"notes,omitempty"`
+	NotesAuthorName *string         `json:"notes_author_name,omitempty"`
+	UserNotes       []NoteExport    `json:"user_notes,omitempty"`
+	Versions        []VersionExport `json:"versions"`
+}
+
+type VersionExport struct {
+	Name             string   `json:"name"`
+	Notes            *string  `json:"notes,omitempty"`
+	Order            int64    `json:"order"`
+	DurationSeconds  *float64 `json:"duration_seconds,omitempty"`
+	Active           bool     `json:"active"`
+	OriginalFilename *string  `json:"original_filename,omitempty"`
+	// File is the entry holding the version's source audio.
+	File string `json:"file"`
+}
+
+type NoteExport struct {
+	AuthorName string `json:"author_name"`
+	Content    string `json:"content"`
+}
+
+// ProjectArchiveName is the file name a project archive is downloaded as.
+func ProjectArchiveName(project sqlc.Project) string {
+	return sanitizeEntryName(project.Name) + ".zip"
+}
+
+// WriteProjectArchive writes a project archive holding the cover, the source
+// of every version of tracks, and project.json. The active version of each
+// track sits at the root under the track title; the other versions go under
+// versions/. Files that cannot be read are left out of the archive and the
+// manifest.
+func WriteProjectArchive(ctx context.Context, w io.Writer, queries *sqlc.Queries, store storage.Storage, project sqlc.Project, tracks []sqlc.Track) error {
+	notes, err := queries.GetNotesByProject(ctx, sql.NullInt64{Int64: project.ID, Valid: true})
+	if err != nil {
+		return fmt.Errorf("failed to list project notes: %w", err)
+	}
+	manifest := &ProjectManifest{
+		ExportedAt: time.Now().UTC(),
+		Project: ProjectExport{
+			Name:            project.Name,
+			Description:     httputil.NullStringToPtr(project.Description),
+			QualityOverride: httputil.NullStringToPtr(project.QualityOverride),
+			AuthorOverride:  httputil.NullStringToPtr(project.AuthorOverride),
+			Notes:           httputil.NullStringToPtr(project.Notes),
+			NotesAuthorName: httputil.NullStringToPtr(project.NotesAuthorName),
+			UserNotes:       exportNotes(notes),
+		},
+		Tracks: []TrackExport{},
+	}
+
+	zw := zip.NewWriter(w)
+
+	if project.CoverArtPath.Valid {
+		ext := filepath.Ext(project.CoverArtPath.String)
+		stream, err := store.OpenFile(ctx, project.CoverArtPath.String)
+		if err == nil {
+			err = writeStreamToZip(zw, stream, "cover"+ext)
+		}
+		if err != nil {
+			slog.Debug("failed to add cover to zip", "error", err)
+		} else {
+			manifest.Project.Cover = "cover" + ext
+		}
+	}
+
+	usedNames := make(map[string]int)
+	uniqueName := func(dir, baseName, ext string) string {
+		zipName := dir + baseName + ext
+		if count, exists := usedNames[zipName]; exists {
+			usedNames[zipName] = count + 1
+			return fmt.Sprintf("%s%s (%d)%s", dir, baseName, count+1, ext)
+		}
+		usedNames[zipName] = 1
+		return zipName
+	}
+
+	for _, track := range tracks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		notes, err := queries.GetNotesByTrack(ctx, sql.NullInt64{Int64: track.ID, Valid: true})
+		if err != nil {
+			return fmt.Errorf("failed to list notes of %q: %w", track.Title, err)
+		}
+		versions, err := queries.ListTrackVersions(ctx, track.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list versions of %q: %w", track.Title, err)
+		}
+
+		trackExport := TrackExport{
+			Title:           track.Title,
+			Artist:          httputil.NullStringToPtr(track.Artist),
+			Album:           httputil.NullStringToPtr(track.Album),
+			Key:             httputil.NullStringToPtr(track.Key),
+			BPM:             httputil.NullInt64ToPtr(track.Bpm),
+			Order:           track.TrackOrder,
+			Notes:           httputil.NullStringToPtr(track.Notes),
+			NotesAuthorName: httputil.NullStringToPtr(track.NotesAuthorName),
+			UserNotes:       exportNotes(notes),
+			Versions:        []VersionExport{},
+		}
+
+		for _, version := range versions {
+			sourceFile, err := queries.GetTrackFile(ctx, sqlc.GetTrackFileParams{
+				VersionID: version.ID,
+				Quality:   "source",
+			})
+			if err != nil {
+				continue
+			}
+			if _, err := store.StatFile(ctx, sourceFile.FilePath); err != nil {
+				continue
+			}
+
+			active := track.ActiveVersionID.Valid && track.ActiveVersionID.Int64 == version.ID
+			ext := filepath.Ext(sourceFile.FilePath)
+			if ext == "" || transcoding.IsCompacted(sourceFile) {
+				ext = "." + sourceFile.Format
+			}
+			var zipName string
+			if active {
+				zipName = uniqueName("", sanitizeEntryName(track.Title), ext)
+			} else {
+				zipName = uniqueName("versions/", sanitizeEntryName(track.Title+" - "+version.VersionName), ext)
+			}
+
+			// Sources are written in the format they were uploaded in.
+			stream, err := transcoding.OpenSource(ctx, store, sourceFile)
+			if err == nil {
+				err = writeStreamToZip(zw, stream, zipName)
+			}
+			if err != nil {
+				slog.Debug("failed to add track to zip", "track_title", track.Title, "error", err)
+				continue
+			}
+
+			trackExport.Versions = append(trackExport.Versions, VersionExport{
+				Name:             version.VersionName,
+				Notes:            httputil.NullStringToPtr(version.Notes),
+				Order:            version.VersionOrder,
+				DurationSeconds:  httputil.NullFloat64ToPtr(version.DurationSeconds),
+				Active:           active,
+				OriginalFilename: httputil.NullStringToPtr(sourceFile.OriginalFilename),
+				File:             zipName,
+			})
+		}
+		manifest.Tracks = append(manifest.Tracks, trackExport)
+	}
+
+	if err := writeProjectManifest(zw, manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func exportNotes(notes []sqlc.Note) []NoteExport {
+	exported := make([]NoteExport, 0, len(notes))
+	for _, note := range notes {
+		exported = append(exported, NoteExport{
+			AuthorName: note.AuthorName,
+			Content:    note.Content,
+		})
+	}
+	return exported
+}
+
+func writeStreamToZip(zw *zip.Writer, stream *storage.FileStream, name string) error {
+	defer stream.Reader.Close()
+
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: stream.ModTime,
+	}
+	header.SetMode(0o644)
+
+	writer, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, stream.Reader)
+	return err
+}
+
+var entryNameReplacer = strings.NewReplacer(
+	"/", "-",
+	"\\", "-",
+	":", "-",
+	"*", "-",
+	"?", "",
+	"\"", "",
+	"<", "",
+	">", "",
+	"|", "-",
+)
+
+// sanitizeEntryName makes a title usable as a file name on any system the
+// archive is extracted on.
+func sanitizeEntryName(name string) string {
+	return entryNameReplacer.Replace(name)
+}
+
+// writeProjectManifest adds the manifest to a project archive. It goes last,
+// so it only names the files that made it into the archive.
+func writeProjectManifest(zw *zip.Writer, manifest *ProjectManifest) error {
+	manifest.Version = ProjectArchiveVersion
+	writer, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     ProjectManifestName,
+		Method:   zip.Deflate,
+		Modified: manifest.ExportedAt,
+	})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
+
+// ProjectArchive is a checked project archive, ready to import.
+type ProjectArchive struct {
+	Manifest *ProjectManifest
+	entries  map[string]*zip.File
+}
+
+// OpenProjectArchive reads and checks the manifest of a project archive. The
+// archive is held to the same limits as instance imports, and every file the
+// manifest names must be in it. It fails with ErrInvalidProjectArchive if the
+// archive cannot be imported.
+func OpenProjectArchive(zr *zip.Reader) (*ProjectArchive, error) {
+	if len(zr.File) > maxImportEntries {
+		return nil, fmt.Errorf("%w: more than %d entries", ErrInvalidProjectArchive, maxImportEntries)
+	}
+
+	archive := &ProjectArchive{entries: make(map[string]*zip.File, len(zr.File))}
+	var total uint64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if _, ok := archive.entries[f.Name]; ok {
+			return nil, fmt.Errorf("%w: %q appears twice", ErrInvalidProjectArchive, f.Name)
+		}
+		if f.UncompressedSize64 > 1<<20 && f.UncompressedSize64 > maxImportRatio*max(f.CompressedSize64, 1) {
+			return nil, fmt.Errorf("%w: %q is compressed too well to be genuine", ErrInvalidProjectArchive, f.Name)
+		}
+		total += f.UncompressedSize64
+		if total > MaxImportSize {
+			return nil, fmt.Errorf("%w: expands to more than %d bytes", ErrInvalidProjectArchive, uint64(MaxImportSize))
+		}
+		archive.entries[f.Name] = f
+	}
+
+	entry, ok := archive.entries[ProjectManifestName]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %s, the archive may be from an older version", ErrInvalidProjectArchive, ProjectManifestName)
+	}
+	if entry.UncompressedSize64 > 64<<20 {
+		return nil, fmt.Errorf("%w: %s is too large", ErrInvalidProjectArchive, ProjectManifestName)
+	}
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProjectArchive, err)
+	}
+	defer rc.Close()
+	var manifest ProjectManifest
+	if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("%w: invalid manifest: %v", ErrInvalidProjectArchive, err)
+	}
+	archive.Manifest = &manifest
+
+	if major, _, _ := strings.Cut(manifest.Version, "."); major != strings.Split(ProjectArchiveVersion, ".")[0] {
+		return nil, fmt.Errorf("%w: format %q is not supported, this version reads %s", ErrInvalidProjectArchive, manifest.Version, ProjectArchiveVersion)
+	}
+	if strings.TrimSpace(manifest.Project.Name) == "" {
+		return nil, fmt.Errorf("%w: project has no name", ErrInvalidProjectArchive)
+	}
+
+	if manifest.Project.Cover != "" {
+		if err := archive.checkFile(manifest.Project.Cover); err != nil {
+			return nil, err
+		}
+	}
+	for _, track := range manifest.Tracks {
+		if strings.TrimSpace(track.Title) == "" {
+			return nil, fmt.Errorf("%w: a track has no title", ErrInvalidProjectArchive)
+		}
+		for _, version := range track.Versions {
+			if err := archive.checkFile(version.File); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return archive, nil
+}
+
+func (a *ProjectArchive) checkFile(name string) error {
+	if name == "" || path.Clean(name) != name || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return fmt.Errorf("%w: unexpected file name %q", ErrInvalidProjectArchive, name)
+	}
+	if _, ok := a.entries[name]; !ok {
+		return fmt.Errorf("%w: %q is missing", ErrInvalidProjectArchive, name)
+	}
+	return nil
+}
+
+// Open opens a file the manifest names. The reader fails if the entry holds
+// more than its header claims or does not match its checksum.
+func (a *ProjectArchive) Open(name string) (io.ReadCloser, error) {
+	entry, ok := a.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%q is missing", name)
+	}
+	return entry.Open()
+}
@@ -463,6 +463,8 @@ func (s *projectService) MoveProjectsToFolder(ctx context.Context, publicIDs []s
 	return s.MoveProjectsToFolderWithOrder(ctx, projects, userID, folderID)
 }
 
+// DeleteProject moves a project and its tracks to the trash. Files stay in
+// storage until the trash is purged.
 func (s *projectService) DeleteProject(ctx context.Context, publicID string, userID int64) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -480,17 +482,20 @@ func (s *projectService) DeleteProject(ctx context.Context, publicID string, use
 		return err
 	}
 
-	err = queries.DeleteProject(ctx, sqlc.DeleteProjectParams{
-		ID:     project.ID,
-		UserID: userID,
-	})
-	if err != nil {
+	deletedAt := trashTime()
+	if err := queries.TrashProjectTracks(ctx, sqlc.TrashProjectTracksParams{
+		DeletedAt: deletedAt,
+		ProjectID: project.ID,
+	}); err != nil {
 		return err
 	}
 
-	if err := s.storage.DeleteProject(ctx, storage.DeleteProjectInput{
-		ProjectPublicID: project.PublicID,
-	}); err != nil {
+	err = queries.TrashProject(ctx, sqlc.TrashProjectParams{
+		DeletedAt: deletedAt,
+		ID:        project.ID,
+		UserID:    userID,
+	})
+	if err != nil {
 		return err
 	}
 
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"ramiro-uziel/vault/internal/storage"
+)
+
+type RestoreOptions struct {
+	// Name is the backup to restore. Empty means the newest one.
+	Name string
+	// TargetDir is the data directory to restore into.
+	TargetDir string
+	// VerifyOnly checks every file of the backup without restoring it.
+	VerifyOnly bool
+	// Force replaces the database and files already in TargetDir.
+	Force bool
+}
+
+type RestoreReport struct {
+	Name string `json:"name"`
+	Kind string `json:"kind,omitempty"`
+	// Archives lists every backup read, the restored one first.
+	Archives []string `json:"archives"`
+	Files    int      `json:"files"`
+	Bytes    int64    `json:"bytes"`
+	// EncryptionKeyID is the key the stored files were encrypted with, if
+	// any. The restored instance needs it.
+	EncryptionKeyID string `json:"encryption_key_id,omitempty"`
+}
+
+// RestoreBackup rebuilds a data directory from a backup. For an incremental
+// backup, the files it did not copy are read from the backups its manifest
+// points to. Every file is checked against the size and SHA-256 in the
+// manifest before anything in TargetDir is touched; archives from before
+// manifests listed files are checked against their ZIP checksums only.
+//
+// The server must not be running against TargetDir.
+func RestoreBackup(ctx context.Context, dest storage.BackupDestination, opts RestoreOptions) (*RestoreReport, error) {
+	if !opts.VerifyOnly && !opts.Force {
+		if _, err := os.Stat(filepath.Join(opts.TargetDir, "vault.db")); err == nil {
+			return nil, fmt.Errorf("%s already has a database, use force to replace it", opts.TargetDir)
+		}
+	}
+
+	name := opts.Name
+	if name == "" {
+		latest, err := latestBackup(ctx, dest)
+		if err != nil {
+			return nil, err
+		}
+		name = latest
+	}
+
+	workDir, err := os.MkdirTemp("", "vault-restore-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	archives := newRestoreArchives(dest, workDir)
+	defer archives.Close()
+
+	archive, err := archives.open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := ReadArchiveManifest(&archive.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	files := manifest.Files
+	if len(files) == 0 {
+		if manifest.Kind == ArchiveIncremental {
+			return nil, fmt.Errorf("%s: incremental backup lists no files", name)
+		}
+		files = archivedFiles(archive)
+	}
+
+	report := &RestoreReport{
+		Name:            name,
+		Kind:            manifest.Kind,
+		EncryptionKeyID: manifest.EncryptionKeyID,
+	}
+
+	var stageDir string
+	if !opts.VerifyOnly {
+		if err := os.MkdirAll(opts.TargetDir, 0o755); err != nil {
+			return nil, err
+		}
+		// Staged next to the target so moving it in is a rename.
+		if stageDir, err = os.MkdirTemp(opts.TargetDir, ".restore-*"); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(stageDir)
+	}
+
+	hasDB := false
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !restorablePath(file.Path) {
+			return nil, fmt.Errorf("%s: unexpected file %q", name, file.Path)
+		}
+		hasDB = hasDB || file.Path == "vault.db"
+
+		source := name
+		if file.Archive != "" {
+			source = file.Archive
+		}
+		from, err := archives.open(ctx, source)
+		if err != nil {
+			return nil, fmt.Errorf("%s needs %s: %w", name, source, err)
+		}
+		if err := restoreFile(from, file, stageDir); err != nil {
+			return nil, fmt.Errorf("%s in %s: %w", file.Path, source, err)
+		}
+		report.Files++
+		report.Bytes += file.Size
+	}
+	if !hasDB {
+		return nil, fmt.Errorf("%s: backup has no database", name)
+	}
+	report.Archives = archives.names
+
+	if opts.VerifyOnly {
+		return report, nil
+	}
+	swap, err := SwapDataDir(opts.TargetDir, stageDir)
+	if err != nil {
+		return nil, err
+	}
+	swap.Commit()
+	return report, nil
+}
+
+func latestBackup(ctx context.Context, dest storage.BackupDestination) (string, error) {
+	backups, err := dest.ListBackups(ctx)
+	if err != nil {
+		return "", err
+	}
+	for i := len(backups) - 1; i >= 0; i-- {
+		if _, _, ok := parseBackupName(backups[i].Path); ok {
+			return backups[i].Path, nil
+		}
+	}
+	return "", fmt.Errorf("no backups at %s", dest)
+}
+
+// restoreArchives downloads each backup a restore reads once.
+type restoreArchives struct {
+	dest    storage.BackupDestination
+	workDir string
+	opened  map[string]*restoreArchive
+	names   []string
+}
+
+type restoreArchive struct {
+	*zip.ReadCloser
+	entries map[string]*zip.File
+}
+
+func newRestoreArchives(dest storage.BackupDestination, workDir string) *restoreArchives {
+	return &restoreArchives{dest: dest, workDir: workDir, opened: map[string]*restoreArchive{}}
+}
+
+func (a *restoreArchives) open(ctx context.Context, name string) (*restoreArchive, error) {
+	if archive, ok := a.opened[name]; ok {
+		return archive, nil
+	}
+
+	local := filepath.Join(a.workDir, fmt.Sprintf("%d.zip", len(a.names)))
+	file, err := os.Create(local)
+	if err != nil {
+		return nil, err
+	}
+	err = a.dest.GetBackup(ctx, name, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if errors.Is(err, storage.ErrNotExist) {
+		return nil, fmt.Errorf("backup %s not found", name)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+
+	zr, err := zip.OpenReader(local)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid archive: %w", name, err)
+	}
+	archive := &restoreArchive{ReadCloser: zr, entries: map[string]*zip.File{}}
+	for _, f := range zr.File {
+		archive.entries[f.Name] = f
+	}
+	a.opened[name] = archive
+	a.names = append(a.names, name)
+	return archive, nil
+}
+
+func (a *restoreArchives) Close() {
+	for _, archive := range a.opened {
+		archive.Close()
+	}
+}
+
+// archivedFiles lists the files of an archive whose manifest does not.
+func archivedFiles(archive *restoreArchive) []ArchiveFile {
+	var files []ArchiveFile
+	for _, f := range archive.File {
+		if f.Name == archiveManifestName || f.FileInfo().IsDir() {
+			continue
+		}
+		files = append(files, ArchiveFile{Path: f.Name, Size: int64(f.UncompressedSize64)})
+	}
+	return files
+}
+
+// restorablePath accepts the database, the WAL older exports carried, and
+// relative paths under projects/.
+func restorablePath(name string) bool {
+	if name == "vault.db" || name == "vault.db-wal" {
+		return true
+	}
+	return strings.HasPrefix(name, "projects/") && path.Clean(name) == name && filepath.IsLocal(filepath.FromSlash(name))
+}
+
+// restoreFile copies one file out of an archive into stageDir, or only reads
+// it when stageDir is empty, and checks it against the manifest.
+func restoreFile(archive *restoreArchive, file ArchiveFile, stageDir string) error {
+	entry, ok := archive.entries[file.Path]
+	if !ok {
+		return fmt.Errorf("missing from archive")
+	}
+
+	rc, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out := io.Discard
+	var target string
+	if stageDir != "" {
+		target = filepath.Join(stageDir, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		f, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	// Reading to the end also checks the entry's ZIP checksum.
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), rc)
+	if err != nil {
+		return err
+	}
+	if size != file.Size {
+		return fmt.Errorf("size is %d, manifest says %d", size, file.Size)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); file.SHA256 != "" && sum != file.SHA256 {
+		return fmt.Errorf("checksum mismatch")
+	}
+
+	if target != "" && !file.ModTime.IsZero() {
+		// Keeping the times lets size and time spot unchanged files again.
+		return os.Chtimes(target, file.ModTime, file.ModTime)
+	}
+	return nil
+}
+
+// swappedNames are the entries of a data directory that a restore or import
+// replaces. The backup index goes too, so the next backup is full.
+var swappedNames = []string{"vault.db", "vault.db-wal", "vault.db-shm", "projects", backupIndexFile}
+
+// DataSwap is a data directory whose database and stored files were
+// replaced, with the previous ones kept aside until Commit or Rollback.
+type DataSwap struct {
+	dataDir  string
+	previous string
+	moved    []string
+}
+
+// SwapDataDir moves the database and stored files staged in stageDir into
+// dataDir, moving what was there aside first. If that fails part way, the
+// previous state is put back before returning. The database must be closed.
+func SwapDataDir(dataDir, stageDir string) (*DataSwap, error) {
+	previous, err := os.MkdirTemp(dataDir, ".previous-*")
+	if err != nil {
+		return nil, err
+	}
+	swap := &DataSwap{dataDir: dataDir, previous: previous}
+
+	for _, name := range swappedNames {
+		err := os.Rename(filepath.Join(dataDir, name), filepath.Join(previous, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			swap.Rollback()
+			return nil, fmt.Errorf("failed to move %s aside: %w", name, err)
+		}
+		swap.moved = append(swap.moved, name)
+	}
+
+	for _, name := range swappedNames {
+		err := os.Rename(filepath.Join(stageDir, name), filepath.Join(dataDir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			swap.Rollback()
+			return nil, fmt.Errorf("failed to move %s into place: %w", name, err)
+		}
+	}
+	return swap, nil
+}
+
+// Rollback puts the previous database and stored files back. The database
+// must be closed.
+func (s *DataSwap) Rollback() error {
+	var firstErr error
+	for _, name := range swappedNames {
+		if err := os.RemoveAll(filepath.Join(s.dataDir, name)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, name := range s.moved {
+		if err := os.Rename(filepath.Join(s.previous, name), filepath.Join(s.dataDir, name)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		os.RemoveAll(s.previous)
+	}
+	return firstErr
+}
+
+// Commit deletes the previous database and stored files.
+func (s *DataSwap) Commit() error {
+	return os.RemoveAll(s.previous)
+}
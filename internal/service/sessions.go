@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+)
+
+// SessionService lists and revokes a user's login sessions. A session is a
+// refresh token; revoking it also ends the access tokens issued with it.
+type SessionService interface {
+	// List returns the user's active sessions, most recently used first.
+	// currentID marks the session making the request, or is zero.
+	List(ctx context.Context, userID, currentID int64) ([]Session, error)
+	Revoke(ctx context.Context, userID, sessionID int64) error
+	// RevokeOthers ends every session of the user but keepID, and returns
+	// how many it ended.
+	RevokeOthers(ctx context.Context, userID, keepID int64) (int64, error)
+}
+
+type Session struct {
+	ID         int64     `json:"id"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+type sessionService struct {
+	db *db.DB
+}
+
+func NewSessionService(database *db.DB) SessionService {
+	return &sessionService{db: database}
+}
+
+func (s *sessionService) List(ctx context.Context, userID, currentID int64) ([]Session, error) {
+	rows, err := s.db.Queries.ListActiveRefreshTokensByUser(ctx, sqlc.ListActiveRefreshTokensByUserParams{
+		UserID: userID,
+		Now:    time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(rows))
+	for _, row := range rows {
+		session := Session{
+			ID:        row.ID,
+			Device:    describeUserAgent(row.UserAgent.String),
+			UserAgent: row.UserAgent.String,
+			IP:        row.Ip.String,
+			CreatedAt: row.CreatedAt.Time,
+			ExpiresAt: row.ExpiresAt,
+			Current:   row.ID == currentID,
+		}
+		session.LastUsedAt = session.CreatedAt
+		if row.LastUsedAt.Valid {
+			session.LastUsedAt = row.LastUsedAt.Time
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (s *sessionService) Revoke(ctx context.Context, userID, sessionID int64) error {
+	revoked, err := s.db.Queries.RevokeUserRefreshToken(ctx, sqlc.RevokeUserRefreshTokenParams{
+		ID:     sessionID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *sessionService) RevokeOthers(ctx context.Context, userID, keepID int64) (int64, error) {
+	return s.db.Queries.RevokeOtherRefreshTokensByUser(ctx, sqlc.RevokeOtherRefreshTokensByUserParams{
+		UserID: userID,
+		KeepID: keepID,
+	})
+}
+
+// describeUserAgent names the browser and system in a User-Agent header, like
+// "Firefox on Linux", for telling sessions apart.
+func describeUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := ""
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"), strings.Contains(userAgent, "FxiOS/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"), strings.Contains(userAgent, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	default:
+		// Scripts and apps, like "curl/8.5.0".
+		name, _, _ := strings.Cut(userAgent, "/")
+		name, _, _ = strings.Cut(name, " ")
+		browser = name
+	}
+
+	system := ""
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		system = "iOS"
+	case strings.Contains(userAgent, "Android"):
+		system = "Android"
+	case strings.Contains(userAgent, "CrOS"):
+		system = "ChromeOS"
+	case strings.Contains(userAgent, "Windows"):
+		system = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"), strings.Contains(userAgent, "Macintosh"):
+		system = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		system = "Linux"
+	}
+
+	if system == "" {
+		return browser
+	}
+	return browser + " on " + system
+}
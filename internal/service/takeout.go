@@ -0,0 +1,692 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/storage"
+)
+
+// Values of takeouts.status.
+const (
+	TakeoutBuilding = "building"
+	TakeoutReady    = "ready"
+	TakeoutFailed   = "failed"
+	TakeoutExpired  = "expired"
+)
+
+// TakeoutVersion is the format of takeout.json.
+const TakeoutVersion = "1.0"
+
+const takeoutManifestName = "takeout.json"
+
+// takeoutHistory is how long finished takeouts stay listed.
+const takeoutHistory = 30 * 24 * time.Hour
+
+var ErrTakeoutBuilding = errors.New("a takeout is already being built")
+
+const interruptedTakeout = "interrupted before it finished"
+
+// TakeoutService builds personal data archives: everything a user owns or
+// wrote, packaged so it can leave the instance with them. Archives are kept
+// in the data directory until they expire.
+type TakeoutService interface {
+	// Start records a new takeout for a user. It returns ErrTakeoutBuilding
+	// if one of theirs has not finished.
+	Start(ctx context.Context, userID int64) (sqlc.Takeout, error)
+	// Build writes the archive of a takeout Start returned and records the
+	// outcome. Builds run one at a time.
+	Build(ctx context.Context, takeout sqlc.Takeout) (sqlc.Takeout, error)
+	Get(ctx context.Context, userID, id int64) (sqlc.Takeout, error)
+	Latest(ctx context.Context, userID int64) (sqlc.Takeout, error)
+	// Open opens the archive of a ready takeout.
+	Open(takeout sqlc.Takeout) (*os.File, error)
+	// FailInterrupted marks takeouts recorded as building, but not building
+	// in this process, as failed.
+	FailInterrupted(ctx context.Context) error
+	// PurgeExpired marks takeouts past their expiry as expired and deletes
+	// archives no ready takeout refers to. It returns how many archives it
+	// deleted.
+	PurgeExpired(ctx context.Context) (int, error)
+	TTL() time.Duration
+}
+
+// TakeoutManifest is written as takeout.json at the root of a takeout. Each
+// project is a project archive of its own, which POST /api/projects/import
+// accepts as is.
+type TakeoutManifest struct {
+	Version     string              `json:"version"`
+	CreatedAt   time.Time           `json:"created_at"`
+	User        TakeoutUser         `json:"user"`
+	Preferences *TakeoutPreferences `json:"preferences,omitempty"`
+	Folders     []TakeoutFolder     `json:"folders"`
+	// Projects are the projects the user owns, with every track in them.
+	Projects []TakeoutProject `json:"projects"`
+	// Contributions hold the tracks the user owns in other people's
+	// projects, one archive per project.
+	Contributions []TakeoutProject `json:"contributions"`
+	// Notes are the notes the user left on other people's projects and
+	// tracks. Notes on their own are in the project archives.
+	Notes  []TakeoutNote `json:"notes"`
+	Shares TakeoutShares `json:"shares"`
+}
+
+type TakeoutUser struct {
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TakeoutPreferences struct {
+	DefaultQuality     string   `json:"default_quality"`
+	DiscColors         []string `json:"disc_colors,omitempty"`
+	ColorSpread        *int64   `json:"color_spread,omitempty"`
+	GradientSpread     *int64   `json:"gradient_spread,omitempty"`
+	ColorShiftRotation *int64   `json:"color_shift_rotation,omitempty"`
+}
+
+type TakeoutFolder struct {
+	// Path is the folder's name under its parents, joined with "/".
+	Path       string  `json:"path"`
+	SmartQuery *string `json:"smart_query,omitempty"`
+}
+
+type TakeoutProject struct {
+	Name string `json:"name"`
+	// Folder is the path of the folder the project is in, if any.
+	Folder string `json:"folder,omitempty"`
+	// Owner is set for contributions.
+	Owner   string `json:"owner,omitempty"`
+	Tracks  int    `json:"tracks"`
+	Archive string `json:"archive"`
+}
+
+type TakeoutNote struct {
+	// Type is "project" or "track".
+	Type       string     `json:"type"`
+	Project    string     `json:"project"`
+	Track      string     `json:"track,omitempty"`
+	Owner      string     `json:"owner"`
+	AuthorName string     `json:"author_name"`
+	Content    string     `json:"content"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+type TakeoutShares struct {
+	// Links are the share links the user created. Tokens and passwords are
+	// not included.
+	Links []TakeoutShareLink `json:"links"`
+	// Users are the projects and tracks the user shared with other users
+	// of the instance.
+	Users []TakeoutUserShare `json:"users"`
+}
+
+type TakeoutShareLink struct {
+	Type              string     `json:"type"`
+	PublicID          string     `json:"public_id"`
+	Title             string     `json:"title"`
+	Visibility        string     `json:"visibility"`
+	AllowEditing      bool       `json:"allow_editing"`
+	AllowDownloads    bool       `json:"allow_downloads"`
+	PasswordProtected bool       `json:"password_protected"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	MaxAccessCount    *int64     `json:"max_access_count,omitempty"`
+	AccessCount       int64      `json:"access_count"`
+	CreatedAt         *time.Time `json:"created_at,omitempty"`
+}
+
+type TakeoutUserShare struct {
+	Type        string     `json:"type"`
+	PublicID    string     `json:"public_id"`
+	Title       string     `json:"title"`
+	SharedWith  string     `json:"shared_with"`
+	CanEdit     bool       `json:"can_edit"`
+	CanDownload bool       `json:"can_download"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+type takeoutService struct {
+	db      *db.DB
+	storage storage.Storage
+	dir     string
+	ttl     time.Duration
+	// build is held while an archive is written, so builds queue.
+	build    sync.Mutex
+	mu       sync.Mutex
+	building map[int64]bool
+}
+
+// NewTakeoutService keeps archives under dataDir/takeouts for ttl after they
+// are built.
+func NewTakeoutService(database *db.DB, storageAdapter storage.Storage, dataDir string, ttl time.Duration) TakeoutService {
+	return &takeoutService{
+		db:       database,
+		storage:  storageAdapter,
+		dir:      filepath.Join(dataDir, "takeouts"),
+		ttl:      ttl,
+		building: make(map[int64]bool),
+	}
+}
+
+func (s *takeoutService) TTL() time.Duration {
+	return s.ttl
+}
+
+func (s *takeoutService) Start(ctx context.Context, userID int64) (sqlc.Takeout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.building[userID] {
+		return sqlc.Takeout{}, ErrTakeoutBuilding
+	}
+
+	takeout, err := s.db.CreateTakeout(ctx, sqlc.CreateTakeoutParams{
+		UserID:    userID,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return sqlc.Takeout{}, err
+	}
+	s.building[userID] = true
+	return takeout, nil
+}
+
+func (s *takeoutService) Build(ctx context.Context, takeout sqlc.Takeout) (sqlc.Takeout, error) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.building, takeout.UserID)
+		s.mu.Unlock()
+	}()
+
+	s.build.Lock()
+	fileName, size, buildErr := s.write(ctx, takeout)
+	s.build.Unlock()
+
+	params := sqlc.FinishTakeoutParams{
+		FinishedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		Status:     TakeoutReady,
+		ID:         takeout.ID,
+	}
+	if buildErr != nil {
+		params.Status = TakeoutFailed
+		params.Error = sql.NullString{String: buildErr.Error(), Valid: true}
+		slog.Error("Takeout failed", "user_id", takeout.UserID, "error", buildErr)
+	} else {
+		params.ExpiresAt = sql.NullTime{Time: params.FinishedAt.Time.Add(s.ttl), Valid: true}
+		params.FileName = sql.NullString{String: fileName, Valid: true}
+		params.SizeBytes = sql.NullInt64{Int64: size, Valid: true}
+		slog.Info("Takeout ready", "user_id", takeout.UserID, "size", size)
+	}
+
+	// Record the outcome even if the build was cancelled part way.
+	finished, err := s.db.FinishTakeout(context.WithoutCancel(ctx), params)
+	if err != nil {
+		if buildErr == nil {
+			os.Remove(filepath.Join(s.dir, fileName))
+		}
+		return takeout, fmt.Errorf("failed to record takeout: %w", err)
+	}
+	return finished, buildErr
+}
+
+// write builds the archive in a temporary file and moves it into place once
+// it is complete.
+func (s *takeoutService) write(ctx context.Context, takeout sqlc.Takeout) (string, int64, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", 0, fmt.Errorf("failed to create takeout directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".partial-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := s.writeArchive(ctx, tmp, takeout.UserID); err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+
+	fileName := fmt.Sprintf("takeout-%d.zip", takeout.ID)
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, fileName)); err != nil {
+		return "", 0, err
+	}
+	return fileName, info.Size(), nil
+}
+
+func (s *takeoutService) writeArchive(ctx context.Context, w io.Writer, userID int64) error {
+	queries := s.db.Queries
+
+	user, err := queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to read user: %w", err)
+	}
+	manifest := TakeoutManifest{
+		Version:   TakeoutVersion,
+		CreatedAt: time.Now().UTC(),
+		User: TakeoutUser{
+			Username:  user.Username,
+			Email:     user.Email,
+			CreatedAt: user.CreatedAt.Time,
+		},
+		Folders:       []TakeoutFolder{},
+		Projects:      []TakeoutProject{},
+		Contributions: []TakeoutProject{},
+		Notes:         []TakeoutNote{},
+		Shares: TakeoutShares{
+			Links: []TakeoutShareLink{},
+			Users: []TakeoutUserShare{},
+		},
+	}
+
+	if manifest.Preferences, err = s.preferences(ctx, userID); err != nil {
+		return err
+	}
+
+	folders, err := queries.ListAllFoldersByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list folders: %w", err)
+	}
+	folderPaths := takeoutFolderPaths(folders)
+	for _, folder := range folders {
+		var smartQuery *string
+		if folder.SmartQuery.Valid {
+			smartQuery = &folder.SmartQuery.String
+		}
+		manifest.Folders = append(manifest.Folders, TakeoutFolder{
+			Path:       folderPaths[folder.ID],
+			SmartQuery: smartQuery,
+		})
+	}
+
+	zw := zip.NewWriter(w)
+	usedNames := make(map[string]bool)
+
+	projects, err := queries.ListProjectsByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list projects: %w", err)
+	}
+	for _, row := range projects {
+		project, err := s.db.GetProjectByID(ctx, row.ID)
+		if err != nil {
+			return fmt.Errorf("failed to read project %q: %w", row.Name, err)
+		}
+		tracks, err := queries.ListTracksByProjectID(ctx, project.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list tracks of %q: %w", project.Name, err)
+		}
+		archive := uniqueTakeoutName(usedNames, "projects/", project.Name)
+		if err := s.writeProject(ctx, zw, archive, project, tracks); err != nil {
+			return err
+		}
+		entry := TakeoutProject{Name: project.Name, Tracks: len(tracks), Archive: archive}
+		if project.FolderID.Valid {
+			entry.Folder = folderPaths[project.FolderID.Int64]
+		}
+		manifest.Projects = append(manifest.Projects, entry)
+	}
+
+	contributed, err := queries.ListTakeoutContributedTracks(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list tracks in other projects: %w", err)
+	}
+	for start := 0; start < len(contributed); {
+		end := start
+		for end < len(contributed) && contributed[end].ProjectID == contributed[start].ProjectID {
+			end++
+		}
+		tracks := contributed[start:end]
+		start = end
+
+		project, err := s.db.GetProjectByID(ctx, tracks[0].ProjectID)
+		if err != nil {
+			return fmt.Errorf("failed to read project of %q: %w", tracks[0].Title, err)
+		}
+		owner, err := queries.GetUserByID(ctx, project.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to read owner of %q: %w", project.Name, err)
+		}
+		archive := uniqueTakeoutName(usedNames, "contributions/", project.Name)
+		if err := s.writeProject(ctx, zw, archive, project, tracks); err != nil {
+			return err
+		}
+		manifest.Contributions = append(manifest.Contributions, TakeoutProject{
+			Name:    project.Name,
+			Owner:   owner.Username,
+			Tracks:  len(tracks),
+			Archive: archive,
+		})
+	}
+
+	if err := s.addNotes(ctx, userID, &manifest); err != nil {
+		return err
+	}
+	if err := s.addShares(ctx, userID, &manifest); err != nil {
+		return err
+	}
+
+	writer, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     takeoutManifestName,
+		Method:   zip.Deflate,
+		Modified: manifest.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeProject adds a project archive as an entry of the takeout. Audio
+// barely compresses, so the entry is stored.
+func (s *takeoutService) writeProject(ctx context.Context, zw *zip.Writer, name string, project sqlc.Project, tracks []sqlc.Track) error {
+	writer, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := WriteProjectArchive(ctx, writer, s.db.Queries, s.storage, project, tracks); err != nil {
+		return fmt.Errorf("failed to write %q: %w", project.Name, err)
+	}
+	return nil
+}
+
+func (s *takeoutService) preferences(ctx context.Context, userID int64) (*TakeoutPreferences, error) {
+	prefs, err := s.db.Queries.GetUserPreferences(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preferences: %w", err)
+	}
+
+	result := &TakeoutPreferences{
+		DefaultQuality:     prefs.DefaultQuality,
+		ColorSpread:        nullInt64Ptr(prefs.ColorSpread),
+		GradientSpread:     nullInt64Ptr(prefs.GradientSpread),
+		ColorShiftRotation: nullInt64Ptr(prefs.ColorShiftRotation),
+	}
+	// Stored as a JSON array.
+	if prefs.DiscColors.Valid && prefs.DiscColors.String != "" {
+		_ = json.Unmarshal([]byte(prefs.DiscColors.String), &result.DiscColors)
+	}
+	return result, nil
+}
+
+func (s *takeoutService) addNotes(ctx context.Context, userID int64, manifest *TakeoutManifest) error {
+	projectNotes, err := s.db.Queries.ListTakeoutProjectNotes(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list project notes: %w", err)
+	}
+	for _, note := range projectNotes {
+		manifest.Notes = append(manifest.Notes, TakeoutNote{
+			Type:       "project",
+			Project:    note.ProjectName,
+			Owner:      note.OwnerUsername,
+			AuthorName: note.AuthorName,
+			Content:    note.Content,
+			UpdatedAt:  nullTimePtr(note.UpdatedAt),
+		})
+	}
+
+	trackNotes, err := s.db.Queries.ListTakeoutTrackNotes(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list track notes: %w", err)
+	}
+	for _, note := range trackNotes {
+		manifest.Notes = append(manifest.Notes, TakeoutNote{
+			Type:       "track",
+			Project:    note.ProjectName,
+			Track:      note.TrackTitle,
+			Owner:      note.OwnerUsername,
+			AuthorName: note.AuthorName,
+			Content:    note.Content,
+			UpdatedAt:  nullTimePtr(note.UpdatedAt),
+		})
+	}
+	return nil
+}
+
+func (s *takeoutService) addShares(ctx context.Context, userID int64, manifest *TakeoutManifest) error {
+	queries := s.db.Queries
+
+	projectLinks, err := queries.ListTakeoutProjectShareLinks(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list project share links: %w", err)
+	}
+	for _, link := range projectLinks {
+		passwordProtected, _ := link.PasswordProtected.(int64)
+		manifest.Shares.Links = append(manifest.Shares.Links, TakeoutShareLink{
+			Type:              "project",
+			PublicID:          link.ProjectPublicID,
+			Title:             link.ProjectName,
+			Visibility:        link.VisibilityType,
+			AllowEditing:      link.AllowEditing,
+			AllowDownloads:    link.AllowDownloads,
+			PasswordProtected: passwordProtected != 0,
+			ExpiresAt:         nullTimePtr(link.ExpiresAt),
+			MaxAccessCount:    nullInt64Ptr(link.MaxAccessCount),
+			AccessCount:       link.CurrentAccessCount.Int64,
+			CreatedAt:         nullTimePtr(link.CreatedAt),
+		})
+	}
+
+	trackLinks, err := queries.ListTakeoutTrackShareLinks(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list track share links: %w", err)
+	}
+	for _, link := range trackLinks {
+		passwordProtected, _ := link.PasswordProtected.(int64)
+		manifest.Shares.Links = append(manifest.Shares.Links, TakeoutShareLink{
+			Type:              "track",
+			PublicID:          link.TrackPublicID,
+			Title:             link.TrackTitle,
+			Visibility:        link.VisibilityType,
+			AllowEditing:      link.AllowEditing,
+			AllowDownloads:    link.AllowDownloads,
+			PasswordProtected: passwordProtected != 0,
+			ExpiresAt:         nullTimePtr(link.ExpiresAt),
+			MaxAccessCount:    nullInt64Ptr(link.MaxAccessCount),
+			AccessCount:       link.CurrentAccessCount.Int64,
+			CreatedAt:         nullTimePtr(link.CreatedAt),
+		})
+	}
+
+	projectShares, err := queries.ListTakeoutProjectShares(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list project shares: %w", err)
+	}
+	for _, share := range projectShares {
+		manifest.Shares.Users = append(manifest.Shares.Users, TakeoutUserShare{
+			Type:        "project",
+			PublicID:    share.ProjectPublicID,
+			Title:       share.ProjectName,
+			SharedWith:  share.SharedToUsername,
+			CanEdit:     share.CanEdit,
+			CanDownload: share.CanDownload,
+			CreatedAt:   nullTimePtr(share.CreatedAt),
+		})
+	}
+
+	trackShares, err := queries.ListTakeoutTrackShares(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list track shares: %w", err)
+	}
+	for _, share := range trackShares {
+		manifest.Shares.Users = append(manifest.Shares.Users, TakeoutUserShare{
+			Type:        "track",
+			PublicID:    share.TrackPublicID,
+			Title:       share.TrackTitle,
+			SharedWith:  share.SharedToUsername,
+			CanEdit:     share.CanEdit,
+			CanDownload: share.CanDownload,
+			CreatedAt:   nullTimePtr(share.CreatedAt),
+		})
+	}
+	return nil
+}
+
+// takeoutFolderPaths maps each folder to its path from the root.
+func takeoutFolderPaths(folders []sqlc.Folder) map[int64]string {
+	byID := make(map[int64]sqlc.Folder, len(folders))
+	for _, folder := range folders {
+		byID[folder.ID] = folder
+	}
+
+	paths := make(map[int64]string, len(folders))
+	for _, folder := range folders {
+		names := []string{folder.Name}
+		seen := map[int64]bool{folder.ID: true}
+		for parent := folder.ParentID; parent.Valid && !seen[parent.Int64]; {
+			next, ok := byID[parent.Int64]
+			if !ok {
+				break
+			}
+			seen[next.ID] = true
+			names = append([]string{next.Name}, names...)
+			parent = next.ParentID
+		}
+		paths[folder.ID] = strings.Join(names, "/")
+	}
+	return paths
+}
+
+// uniqueTakeoutName names a project archive entry after the project, with a
+// number added when two projects share a name.
+func uniqueTakeoutName(used map[string]bool, dir, projectName string) string {
+	base := dir + sanitizeEntryName(projectName)
+	name := base + ".zip"
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s (%d).zip", base, i)
+	}
+	used[name] = true
+	return name
+}
+
+func (s *takeoutService) Get(ctx context.Context, userID, id int64) (sqlc.Takeout, error) {
+	return s.db.GetTakeout(ctx, sqlc.GetTakeoutParams{ID: id, UserID: userID})
+}
+
+func (s *takeoutService) Latest(ctx context.Context, userID int64) (sqlc.Takeout, error) {
+	return s.db.GetLatestTakeout(ctx, userID)
+}
+
+func (s *takeoutService) Open(takeout sqlc.Takeout) (*os.File, error) {
+	if takeout.Status != TakeoutReady || !takeout.FileName.Valid || !takeout.ExpiresAt.Valid || !time.Now().Before(takeout.ExpiresAt.Time) {
+		return nil, os.ErrNotExist
+	}
+	return os.Open(filepath.Join(s.dir, filepath.Base(takeout.FileName.String)))
+}
+
+func (s *takeoutService) FailInterrupted(ctx context.Context) error {
+	return s.db.FailBuildingTakeouts(ctx, sqlc.FailBuildingTakeoutsParams{
+		FinishedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		Error:      sql.NullString{String: interruptedTakeout, Valid: true},
+	})
+}
+
+func (s *takeoutService) PurgeExpired(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	if err := s.db.ExpireTakeouts(ctx, sql.NullTime{Time: now, Valid: true}); err != nil {
+		return 0, err
+	}
+	if err := s.db.DeleteOldTakeouts(ctx, now.Add(-takeoutHistory)); err != nil {
+		return 0, err
+	}
+
+	kept, err := s.db.ListKeptTakeoutFiles(ctx, sql.NullTime{Time: now, Valid: true})
+	if err != nil {
+		return 0, err
+	}
+	keep := make(map[string]bool, len(kept))
+	for _, name := range kept {
+		keep[name.String] = true
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, entry := range entries {
+		// Partial files belong to builds in progress.
+		if entry.IsDir() || keep[entry.Name()] || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil {
+			slog.Warn("Failed to delete expired takeout", "file", entry.Name(), "error", err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// RunTakeoutPurge marks interrupted takeouts as failed, then deletes expired
+// takeouts every interval until ctx is cancelled.
+func RunTakeoutPurge(ctx context.Context, takeouts TakeoutService, interval time.Duration) {
+	if err := takeouts.FailInterrupted(ctx); err != nil {
+		slog.Warn("Failed to mark interrupted takeouts", "error", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := takeouts.PurgeExpired(ctx)
+		if err != nil {
+			slog.Warn("Takeout purge failed", "error", err)
+		} else if purged > 0 {
+			slog.Info("Purged expired takeouts", "takeouts", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullInt64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
@@ -0,0 +1,687 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/storage"
+)
+
+// Kinds of item that can sit in the trash.
+const (
+	TrashProject = "project"
+	TrashTrack   = "track"
+	TrashVersion = "version"
+	TrashFolder  = "folder"
+)
+
+// Entries of trashed_folder_contents, recording what was filed in a folder
+// when it was trashed.
+const (
+	trashedOwnProject    = "project"
+	trashedSharedProject = "shared_project"
+	trashedSharedTrack   = "shared_track"
+)
+
+var (
+	ErrUnknownTrashType = errors.New("unknown trash item type")
+	// ErrTrashParentDeleted is returned when restoring an item whose project or
+	// track is itself in the trash.
+	ErrTrashParentDeleted = errors.New("parent item is in the trash")
+)
+
+type TrashService interface {
+	List(ctx context.Context, userID int64) (*TrashContents, error)
+	TrashFolder(ctx context.Context, folderID, userID int64) error
+	Restore(ctx context.Context, userID int64, itemType, id string) error
+	Purge(ctx context.Context, userID int64, itemType, id string) error
+	Empty(ctx context.Context, userID int64) error
+	PurgeExpired(ctx context.Context) (int, error)
+	Retention() time.Duration
+}
+
+// TrashContents lists a user's trash. Items trashed together with their
+// project, track or folder are not listed separately; they come back with it.
+type TrashContents struct {
+	Projects []sqlc.Project
+	Tracks   []sqlc.ListTrashedTracksRow
+	Versions []sqlc.ListTrashedTrackVersionsRow
+	Folders  []sqlc.Folder
+}
+
+type trashService struct {
+	db        *db.DB
+	storage   storage.Storage
+	retention time.Duration
+	audit     AuditService
+}
+
+// NewTrashService returns a trash service that purges items once they have
+// been trashed for longer than retention. A zero retention keeps them until
+// they are purged by hand. Expired purges are recorded in the audit log.
+func NewTrashService(database *db.DB, storageAdapter storage.Storage, retention time.Duration, audit AuditService) TrashService {
+	return &trashService{
+		db:        database,
+		storage:   storageAdapter,
+		retention: retention,
+		audit:     audit,
+	}
+}
+
+// trashTime is the deleted_at value for items trashed now. Items trashed in
+// one operation share it, which is how a restore finds them again.
+func trashTime() sql.NullTime {
+	return sql.NullTime{Time: time.Now().UTC(), Valid: true}
+}
+
+func (s *trashService) Retention() time.Duration {
+	return s.retention
+}
+
+func (s *trashService) List(ctx context.Context, userID int64) (*TrashContents, error) {
+	projects, err := s.db.ListTrashedProjects(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tracks, err := s.db.ListTrashedTracks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.db.ListTrashedTrackVersions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := s.db.ListTrashedFolders(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TrashContents{
+		Projects: projects,
+		Tracks:   tracks,
+		Versions: versions,
+		Folders:  folders,
+	}, nil
+}
+
+// TrashFolder moves a folder and its subfolders to the trash. Projects and
+// shared items filed inside go back to the root, and their placement is
+// remembered so a restore can file them again.
+func (s *trashService) TrashFolder(ctx context.Context, folderID, userID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := sqlc.New(tx)
+
+	if _, err := queries.GetFolder(ctx, sqlc.GetFolderParams{ID: folderID, UserID: userID}); err != nil {
+		return err
+	}
+
+	if err := s.trashFolderTree(ctx, queries, folderID, userID, trashTime()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *trashService) trashFolderTree(ctx context.Context, queries *sqlc.Queries, folderID, userID int64, deletedAt sql.NullTime) error {
+	folder := sql.NullInt64{Int64: folderID, Valid: true}
+
+	projects, err := queries.ListProjectsInFolder(ctx, sqlc.ListProjectsInFolderParams{
+		FolderID: folder,
+		UserID:   userID,
+	})
+	if err != nil {
+		return err
+	}
+	for _, project := range projects {
+		if err := queries.CreateTrashedFolderContent(ctx, sqlc.CreateTrashedFolderContentParams{
+			FolderID:    folderID,
+			UserID:      userID,
+			ItemType:    trashedOwnProject,
+			ItemID:      project.ID,
+			CustomOrder: project.CustomOrder,
+		}); err != nil {
+			return err
+		}
+		if _, err := queries.UpdateProjectFolder(ctx, sqlc.UpdateProjectFolderParams{
+			FolderID: sql.NullInt64{Valid: false},
+			Column2:  nil,
+			ID:       project.ID,
+			UserID:   userID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	sharedProjects, err := queries.ListSharedProjectOrganizationsInFolder(ctx, sqlc.ListSharedProjectOrganizationsInFolderParams{
+		UserID:   userID,
+		FolderID: folder,
+	})
+	if err != nil {
+		return err
+	}
+	for _, org := range sharedProjects {
+		if err := queries.CreateTrashedFolderContent(ctx, sqlc.CreateTrashedFolderContentParams{
+			FolderID:    folderID,
+			UserID:      userID,
+			ItemType:    trashedSharedProject,
+			ItemID:      org.ProjectID,
+			CustomOrder: org.CustomOrder,
+		}); err != nil {
+			return err
+		}
+		if _, err := queries.UpsertSharedProjectOrganization(ctx, sqlc.UpsertSharedProjectOrganizationParams{
+			UserID:      userID,
+			ProjectID:   org.ProjectID,
+			FolderID:    sql.NullInt64{Valid: false},
+			CustomOrder: org.CustomOrder,
+		}); err != nil {
+			return err
+		}
+	}
+
+	sharedTracks, err := queries.ListSharedTrackOrganizationsInFolder(ctx, sqlc.ListSharedTrackOrganizationsInFolderParams{
+		UserID:   userID,
+		FolderID: folder,
+	})
+	if err != nil {
+		return err
+	}
+	for _, org := range sharedTracks {
+		if err := queries.CreateTrashedFolderContent(ctx, sqlc.CreateTrashedFolderContentParams{
+			FolderID:    folderID,
+			UserID:      userID,
+			ItemType:    trashedSharedTrack,
+			ItemID:      org.TrackID,
+			CustomOrder: org.CustomOrder,
+		}); err != nil {
+			return err
+		}
+		if _, err := queries.UpsertSharedTrackOrganization(ctx, sqlc.UpsertSharedTrackOrganizationParams{
+			UserID:      userID,
+			TrackID:     org.TrackID,
+			FolderID:    sql.NullInt64{Valid: false},
+			CustomOrder: org.CustomOrder,
+		}); err != nil {
+			return err
+		}
+	}
+
+	subfolders, err := queries.ListFoldersByParent(ctx, sqlc.ListFoldersByParentParams{
+		UserID:   userID,
+		ParentID: folder,
+	})
+	if err != nil {
+		return err
+	}
+	for _, subfolder := range subfolders {
+		if err := s.trashFolderTree(ctx, queries, subfolder.ID, userID, deletedAt); err != nil {
+			return err
+		}
+	}
+
+	return queries.TrashFolder(ctx, sqlc.TrashFolderParams{
+		DeletedAt: deletedAt,
+		ID:        folderID,
+		UserID:    userID,
+	})
+}
+
+// Restore takes an item out of the trash. Projects are identified by public
+// ID, tracks by public ID, and versions and folders by numeric ID.
+func (s *trashService) Restore(ctx context.Context, userID int64, itemType, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := sqlc.New(tx)
+
+	switch itemType {
+	case TrashProject:
+		project, err := queries.GetTrashedProject(ctx, sqlc.GetTrashedProjectParams{PublicID: id, UserID: userID})
+		if err != nil {
+			return err
+		}
+		if err := queries.RestoreProjectTracks(ctx, project.ID); err != nil {
+			return err
+		}
+		if err := queries.RestoreProject(ctx, project.ID); err != nil {
+			return err
+		}
+		// The folder may have been trashed in the meantime.
+		if project.FolderID.Valid {
+			count, err := queries.CheckFolderExists(ctx, sqlc.CheckFolderExistsParams{ID: project.FolderID.Int64, UserID: userID})
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				if _, err := queries.UpdateProjectFolder(ctx, sqlc.UpdateProjectFolderParams{
+					FolderID: sql.NullInt64{Valid: false},
+					Column2:  nil,
+					ID:       project.ID,
+					UserID:   userID,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+	case TrashTrack:
+		track, err := queries.GetTrashedTrack(ctx, sqlc.GetTrashedTrackParams{PublicID: id, UserID: userID})
+		if err != nil {
+			return err
+		}
+		if track.ProjectDeletedAt.Valid {
+			return ErrTrashParentDeleted
+		}
+		if err := queries.RestoreTrack(ctx, track.ID); err != nil {
+			return err
+		}
+
+	case TrashVersion:
+		versionID, err := parseTrashID(id)
+		if err != nil {
+			return err
+		}
+		version, err := queries.GetTrashedTrackVersion(ctx, sqlc.GetTrashedTrackVersionParams{ID: versionID, UserID: userID})
+		if err != nil {
+			return err
+		}
+		if version.TrackDeletedAt.Valid || version.ProjectDeletedAt.Valid {
+			return ErrTrashParentDeleted
+		}
+		if err := queries.RestoreTrackVersion(ctx, version.ID); err != nil {
+			return err
+		}
+
+	case TrashFolder:
+		folderID, err := parseTrashID(id)
+		if err != nil {
+			return err
+		}
+		folder, err := queries.GetTrashedFolder(ctx, sqlc.GetTrashedFolderParams{ID: folderID, UserID: userID})
+		if err != nil {
+			return err
+		}
+		parentID := folder.ParentID
+		if parentID.Valid {
+			count, err := queries.CheckFolderExists(ctx, sqlc.CheckFolderExistsParams{ID: parentID.Int64, UserID: userID})
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				parentID = sql.NullInt64{Valid: false}
+			}
+		}
+		if err := restoreFolderTree(ctx, queries, folder.ID, parentID, userID); err != nil {
+			return err
+		}
+
+	default:
+		return ErrUnknownTrashType
+	}
+
+	return tx.Commit()
+}
+
+func restoreFolderTree(ctx context.Context, queries *sqlc.Queries, folderID int64, parentID sql.NullInt64, userID int64) error {
+	// Subfolders trashed along with this one share its deleted_at, so they
+	// have to be looked up before it is cleared.
+	subfolders, err := queries.ListFoldersTrashedWithParent(ctx, sql.NullInt64{Int64: folderID, Valid: true})
+	if err != nil {
+		return err
+	}
+
+	if err := queries.RestoreFolder(ctx, sqlc.RestoreFolderParams{ParentID: parentID, ID: folderID}); err != nil {
+		return err
+	}
+
+	for _, subfolder := range subfolders {
+		if err := restoreFolderTree(ctx, queries, subfolder.ID, subfolder.ParentID, userID); err != nil {
+			return err
+		}
+	}
+
+	contents, err := queries.ListTrashedFolderContents(ctx, folderID)
+	if err != nil {
+		return err
+	}
+	folder := sql.NullInt64{Int64: folderID, Valid: true}
+	for _, item := range contents {
+		// Items the user has filed elsewhere since are left where they are.
+		switch item.ItemType {
+		case trashedOwnProject:
+			project, err := queries.GetProject(ctx, sqlc.GetProjectParams{ID: item.ItemID, UserID: userID})
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && project.FolderID.Valid) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := queries.UpdateProjectFolder(ctx, sqlc.UpdateProjectFolderParams{
+				FolderID: folder,
+				Column2:  folder,
+				ID:       project.ID,
+				UserID:   userID,
+			}); err != nil {
+				return err
+			}
+			if _, err := queries.UpdateProjectCustomOrder(ctx, sqlc.UpdateProjectCustomOrderParams{
+				CustomOrder: item.CustomOrder,
+				ID:          project.ID,
+				UserID:      userID,
+			}); err != nil {
+				return err
+			}
+
+		case trashedSharedProject:
+			org, err := queries.GetUserSharedProjectOrganization(ctx, sqlc.GetUserSharedProjectOrganizationParams{
+				UserID:    userID,
+				ProjectID: item.ItemID,
+			})
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && org.FolderID.Valid) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := queries.UpsertSharedProjectOrganization(ctx, sqlc.UpsertSharedProjectOrganizationParams{
+				UserID:      userID,
+				ProjectID:   item.ItemID,
+				FolderID:    folder,
+				CustomOrder: item.CustomOrder,
+			}); err != nil {
+				return err
+			}
+
+		case trashedSharedTrack:
+			org, err := queries.GetUserSharedTrackOrganization(ctx, sqlc.GetUserSharedTrackOrganizationParams{
+				UserID:  userID,
+				TrackID: item.ItemID,
+			})
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && org.FolderID.Valid) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := queries.UpsertSharedTrackOrganization(ctx, sqlc.UpsertSharedTrackOrganizationParams{
+				UserID:      userID,
+				TrackID:     item.ItemID,
+				FolderID:    folder,
+				CustomOrder: item.CustomOrder,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return queries.DeleteTrashedFolderContents(ctx, folderID)
+}
+
+// Purge permanently deletes a trashed item and its stored files.
+func (s *trashService) Purge(ctx context.Context, userID int64, itemType, id string) error {
+	switch itemType {
+	case TrashProject:
+		project, err := s.db.GetTrashedProject(ctx, sqlc.GetTrashedProjectParams{PublicID: id, UserID: userID})
+		if err != nil {
+			return err
+		}
+		return s.purgeProject(ctx, project.ID, project.UserID, project.PublicID)
+
+	case TrashTrack:
+		track, err := s.db.GetTrashedTrack(ctx, sqlc.GetTrashedTrackParams{PublicID: id, UserID: userID})
+		if err != nil {
+			return err
+		}
+		return s.purgeTrack(ctx, track.ID, track.UserID, track.ProjectPublicID)
+
+	case TrashVersion:
+		versionID, err := parseTrashID(id)
+		if err != nil {
+			return err
+		}
+		version, err := s.db.GetTrashedTrackVersion(ctx, sqlc.GetTrashedTrackVersionParams{ID: versionID, UserID: userID})
+		if err != nil {
+			return err
+		}
+		return s.purgeVersion(ctx, version.ID, version.TrackID, version.ProjectPublicID)
+
+	case TrashFolder:
+		folderID, err := parseTrashID(id)
+		if err != nil {
+			return err
+		}
+		folder, err := s.db.GetTrashedFolder(ctx, sqlc.GetTrashedFolderParams{ID: folderID, UserID: userID})
+		if err != nil {
+			return err
+		}
+		return s.purgeFolder(ctx, folder.ID, folder.UserID)
+	}
+
+	return ErrUnknownTrashType
+}
+
+// Empty purges everything in a user's trash.
+func (s *trashService) Empty(ctx context.Context, userID int64) error {
+	contents, err := s.List(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, project := range contents.Projects {
+		if err := s.purgeProject(ctx, project.ID, project.UserID, project.PublicID); err != nil {
+			return err
+		}
+	}
+	for _, track := range contents.Tracks {
+		if err := s.purgeTrack(ctx, track.ID, track.UserID, track.ProjectPublicID); err != nil {
+			return err
+		}
+	}
+	for _, version := range contents.Versions {
+		if err := s.purgeVersion(ctx, version.ID, version.TrackID, version.ProjectPublicID); err != nil {
+			return err
+		}
+	}
+	for _, folder := range contents.Folders {
+		if err := s.purgeFolder(ctx, folder.ID, folder.UserID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PurgeExpired purges every item that has been in the trash for longer than
+// the retention period, across all users. It returns how many were purged.
+func (s *trashService) PurgeExpired(ctx context.Context) (int, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+
+	cutoff := sql.NullTime{Time: time.Now().UTC().Add(-s.retention), Valid: true}
+	purged := 0
+
+	// Projects go first: purging one also removes the tracks and versions
+	// trashed with it.
+	projects, err := s.db.ListExpiredTrashedProjects(ctx, cutoff)
+	if err != nil {
+		return purged, err
+	}
+	for _, project := range projects {
+		if err := s.purgeProject(ctx, project.ID, project.UserID, project.PublicID); err != nil {
+			return purged, err
+		}
+		s.recordExpired(ctx, TrashProject, project.PublicID, project.UserID, project.Name)
+		purged++
+	}
+
+	tracks, err := s.db.ListExpiredTrashedTracks(ctx, cutoff)
+	if err != nil {
+		return purged, err
+	}
+	for _, track := range tracks {
+		if err := s.purgeTrack(ctx, track.ID, track.UserID, track.ProjectPublicID); err != nil {
+			return purged, err
+		}
+		s.recordExpired(ctx, TrashTrack, track.PublicID, track.UserID, track.Title)
+		purged++
+	}
+
+	versions, err := s.db.ListExpiredTrashedTrackVersions(ctx, cutoff)
+	if err != nil {
+		return purged, err
+	}
+	for _, version := range versions {
+		if err := s.purgeVersion(ctx, version.ID, version.TrackID, version.ProjectPublicID); err != nil {
+			return purged, err
+		}
+		s.recordExpired(ctx, TrashVersion, strconv.FormatInt(version.ID, 10), 0, version.VersionName)
+		purged++
+	}
+
+	folders, err := s.db.ListExpiredTrashedFolders(ctx, cutoff)
+	if err != nil {
+		return purged, err
+	}
+	for _, folder := range folders {
+		if err := s.purgeFolder(ctx, folder.ID, folder.UserID); err != nil {
+			return purged, err
+		}
+		s.recordExpired(ctx, TrashFolder, strconv.FormatInt(folder.ID, 10), folder.UserID, folder.Name)
+		purged++
+	}
+
+	return purged, nil
+}
+
+// recordExpired notes an item purged for outliving the retention period.
+// ownerID is 0 when the item has no owner of its own.
+func (s *trashService) recordExpired(ctx context.Context, itemType, id string, ownerID int64, name string) {
+	before := map[string]any{"name": name}
+	if ownerID != 0 {
+		before["owner_id"] = ownerID
+	}
+	s.audit.Record(ctx, AuditEvent{
+		Action:     AuditTrashExpire,
+		TargetType: itemType,
+		TargetID:   id,
+		Before:     before,
+	})
+}
+
+func (s *trashService) purgeProject(ctx context.Context, projectID, ownerID int64, publicID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := sqlc.New(tx).DeleteProject(ctx, sqlc.DeleteProjectParams{ID: projectID, UserID: ownerID}); err != nil {
+		return err
+	}
+
+	if err := s.storage.DeleteProject(ctx, storage.DeleteProjectInput{
+		ProjectPublicID: publicID,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *trashService) purgeTrack(ctx context.Context, trackID, ownerID int64, projectPublicID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := sqlc.New(tx).DeleteTrack(ctx, sqlc.DeleteTrackParams{ID: trackID, UserID: ownerID}); err != nil {
+		return err
+	}
+
+	if err := s.storage.DeleteTrack(ctx, storage.DeleteTrackInput{
+		ProjectPublicID: projectPublicID,
+		TrackID:         trackID,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *trashService) purgeVersion(ctx context.Context, versionID, trackID int64, projectPublicID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := sqlc.New(tx).DeleteTrackVersion(ctx, versionID); err != nil {
+		return err
+	}
+
+	if err := s.storage.DeleteVersion(ctx, storage.DeleteVersionInput{
+		ProjectPublicID: projectPublicID,
+		TrackID:         trackID,
+		VersionID:       versionID,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// purgeFolder deletes a trashed folder. Its subfolders go with it through the
+// parent_id cascade; projects that were inside were unfiled when it was
+// trashed, so nothing else is lost.
+func (s *trashService) purgeFolder(ctx context.Context, folderID, ownerID int64) error {
+	return s.db.DeleteFolder(ctx, sqlc.DeleteFolderParams{ID: folderID, UserID: ownerID})
+}
+
+func parseTrashID(id string) (int64, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, sql.ErrNoRows
+	}
+	return n, nil
+}
+
+// RunTrashPurge purges expired trash every interval until ctx is cancelled.
+func RunTrashPurge(ctx context.Context, trash TrashService, interval time.Duration) {
+	if trash.Retention() <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := trash.PurgeExpired(ctx)
+		if err != nil {
+			slog.Warn("Trash purge failed", "error", err)
+		} else if purged > 0 {
+			slog.Info("Purged expired trash", "items", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
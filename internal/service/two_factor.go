@@ -0,0 +1,508 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"ramiro-uziel/vault/internal/auth"
+	"ramiro-uziel/vault/internal/db"
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+)
+
+const (
+	recoveryCodeCount = 10
+	// loginChallengeTTL is how long a user has to enter their second factor
+	// after their password.
+	loginChallengeTTL    = 5 * time.Minute
+	maxChallengeAttempts = 5
+	defaultTOTPIssuer    = "Vault"
+)
+
+var (
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrNoPendingEnrollment  = errors.New("no two-factor enrollment in progress")
+	ErrTwoFactorRequired    = errors.New("two-factor authentication is required on this instance")
+)
+
+// TwoFactorService manages TOTP second factors, their recovery codes, and
+// the challenges that stand between the password step of a login and the
+// session. Passkeys, registered through AuthService, count as a second factor
+// too.
+type TwoFactorService interface {
+	Status(ctx context.Context, userID int64) (*TwoFactorStatus, error)
+	// EnabledUserIDs returns the users who have a second factor.
+	EnabledUserIDs(ctx context.Context) (map[int64]bool, error)
+	Required(ctx context.Context) (bool, error)
+	SetRequired(ctx context.Context, required bool) error
+
+	// BeginEnrollment generates a new secret for a user. It takes effect
+	// once ConfirmEnrollment accepts a code from it; until then a second
+	// factor the user already has keeps working.
+	BeginEnrollment(ctx context.Context, userID int64) (*TOTPEnrollment, error)
+	// ConfirmEnrollment enables the secret BeginEnrollment generated and
+	// returns a fresh set of recovery codes.
+	ConfirmEnrollment(ctx context.Context, userID int64, code string) ([]string, error)
+	// Verify accepts a TOTP code or an unused recovery code.
+	Verify(ctx context.Context, userID int64, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID int64) ([]string, error)
+	// Disable removes a user's TOTP secret and recovery codes.
+	Disable(ctx context.Context, userID int64) error
+	// Reset removes every second factor a user has, passkeys included.
+	Reset(ctx context.Context, userID int64) error
+
+	// StartLogin returns the challenge a login must complete before it gets
+	// a session, or nil if the user needs no second factor.
+	StartLogin(ctx context.Context, userID int64) (*LoginChallenge, error)
+	// ChallengeUser returns the user a live challenge belongs to.
+	ChallengeUser(ctx context.Context, token string) (int64, error)
+	// CompleteLogin checks a code against a challenge and uses it up. For a
+	// user enrolling during login, the code confirms the enrollment.
+	CompleteLogin(ctx context.Context, token, code string) (*LoginCompletion, error)
+	// CompletePasskeyLogin uses up a challenge once verify accepts a passkey
+	// assertion from its user.
+	CompletePasskeyLogin(ctx context.Context, token string, verify func(userID int64) error) (*LoginCompletion, error)
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabled_at,omitempty"`
+	Pending           bool       `json:"pending"`
+	Required          bool       `json:"required"`
+	RecoveryCodesLeft int64      `json:"recovery_codes_left"`
+	Passkeys          int64      `json:"passkeys"`
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// URI to show as a QR code.
+	URI string `json:"otpauth_uri"`
+}
+
+type LoginChallenge struct {
+	Token     string
+	ExpiresAt time.Time
+	// Methods are the second factors the user can answer with: "totp",
+	// "recovery_code" and "passkey".
+	Methods []string
+	// EnrollmentRequired is set when the instance requires a second factor
+	// and the user has none yet.
+	EnrollmentRequired bool
+}
+
+type LoginCompletion struct {
+	UserID int64
+	// RecoveryCodes is set when the login confirmed a new enrollment.
+	RecoveryCodes    []string
+	UsedRecoveryCode bool
+}
+
+type twoFactorService struct {
+	db         *db.DB
+	authConfig auth.Config
+}
+
+func NewTwoFactorService(database *db.DB, authConfig auth.Config) TwoFactorService {
+	return &twoFactorService{
+		db:         database,
+		authConfig: authConfig,
+	}
+}
+
+func (s *twoFactorService) Status(ctx context.Context, userID int64) (*TwoFactorStatus, error) {
+	required, err := s.Required(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := &TwoFactorStatus{Required: required}
+
+	if status.Passkeys, err = s.db.Queries.CountPasskeysByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	totp, err := s.db.Queries.GetUserTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status.Enabled = totp.Secret.Valid
+	status.Pending = totp.PendingSecret.Valid
+	if totp.EnabledAt.Valid {
+		status.EnabledAt = &totp.EnabledAt.Time
+	}
+
+	if status.Enabled {
+		if status.RecoveryCodesLeft, err = s.db.Queries.CountUnusedRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+func (s *twoFactorService) EnabledUserIDs(ctx context.Context) (map[int64]bool, error) {
+	ids, err := s.db.Queries.ListTwoFactorUserIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	passkeyIDs, err := s.db.Queries.ListPasskeyUserIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	enabled := make(map[int64]bool, len(ids)+len(passkeyIDs))
+	for _, id := range append(ids, passkeyIDs...) {
+		enabled[id] = true
+	}
+	return enabled, nil
+}
+
+func (s *twoFactorService) Required(ctx context.Context) (bool, error) {
+	required, err := s.db.Queries.GetRequireTwoFactor(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return required, err
+}
+
+func (s *twoFactorService) SetRequired(ctx context.Context, required bool) error {
+	return s.db.Queries.SetRequireTwoFactor(ctx, required)
+}
+
+func (s *twoFactorService) BeginEnrollment(ctx context.Context, userID int64) (*TOTPEnrollment, error) {
+	user, err := s.db.Queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Queries.SetPendingTOTPSecret(ctx, sqlc.SetPendingTOTPSecretParams{
+		UserID:        userID,
+		PendingSecret: sql.NullString{String: secret, Valid: true},
+	}); err != nil {
+		return nil, err
+	}
+
+	issuer := defaultTOTPIssuer
+	if settings, err := s.db.Queries.GetInstanceSettings(ctx); err == nil && settings.Name != "" {
+		issuer = settings.Name
+	}
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    auth.TOTPURI(issuer, user.Username, secret),
+	}, nil
+}
+
+func (s *twoFactorService) ConfirmEnrollment(ctx context.Context, userID int64, code string) ([]string, error) {
+	totp, err := s.db.Queries.GetUserTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !totp.PendingSecret.Valid) {
+		return nil, ErrNoPendingEnrollment
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := auth.ValidateTOTP(totp.PendingSecret.String, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := s.db.Queries.WithTx(tx)
+	if err := qtx.ConfirmTOTPSecret(ctx, sqlc.ConfirmTOTPSecretParams{
+		EnabledAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		LastStep:  step,
+		UserID:    userID,
+	}); err != nil {
+		return nil, err
+	}
+	codes, err := s.replaceRecoveryCodes(ctx, qtx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *twoFactorService) Verify(ctx context.Context, userID int64, code string) error {
+	_, err := s.verify(ctx, userID, code)
+	return err
+}
+
+// verify reports whether the code accepted was a recovery code.
+func (s *twoFactorService) verify(ctx context.Context, userID int64, code string) (bool, error) {
+	totp, err := s.db.Queries.GetUserTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !totp.Secret.Valid) {
+		return false, ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return false, err
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == auth.TOTPDigits {
+		step, ok := auth.ValidateTOTP(totp.Secret.String, code, time.Now())
+		if !ok {
+			return false, ErrInvalidTwoFactorCode
+		}
+		// A code seen before, or one older than it, is a replay.
+		updated, err := s.db.Queries.UpdateTOTPLastStep(ctx, sqlc.UpdateTOTPLastStepParams{
+			Step:   step,
+			UserID: userID,
+		})
+		if err != nil {
+			return false, err
+		}
+		if updated == 0 {
+			return false, ErrInvalidTwoFactorCode
+		}
+		return false, nil
+	}
+
+	used, err := s.db.Queries.UseRecoveryCode(ctx, sqlc.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code), s.authConfig.TokenPepper),
+	})
+	if err != nil {
+		return false, err
+	}
+	if used == 0 {
+		return false, ErrInvalidTwoFactorCode
+	}
+	return true, nil
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	totp, err := s.db.Queries.GetUserTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !totp.Secret.Valid) {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, err := s.replaceRecoveryCodes(ctx, s.db.Queries.WithTx(tx), userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// replaceRecoveryCodes drops a user's recovery codes and stores new ones. The
+// codes are returned in the clear only here.
+func (s *twoFactorService) replaceRecoveryCodes(ctx context.Context, q *sqlc.Queries, userID int64) ([]string, error) {
+	if err := q.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := auth.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if err := q.CreateRecoveryCode(ctx, sqlc.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code), s.authConfig.TokenPepper),
+		}); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func (s *twoFactorService) Disable(ctx context.Context, userID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := s.db.Queries.WithTx(tx)
+	if err := qtx.DeleteUserTOTP(ctx, userID); err != nil {
+		return err
+	}
+	if err := qtx.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *twoFactorService) Reset(ctx context.Context, userID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := s.db.Queries.WithTx(tx)
+	if err := qtx.DeleteUserTOTP(ctx, userID); err != nil {
+		return err
+	}
+	if err := qtx.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+	if err := qtx.DeletePasskeysByUser(ctx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *twoFactorService) StartLogin(ctx context.Context, userID int64) (*LoginChallenge, error) {
+	status, err := s.Status(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	hasPasskeys := status.Passkeys > 0
+	if !status.Enabled && !hasPasskeys && !status.Required {
+		return nil, nil
+	}
+
+	methods := []string{}
+	if status.Enabled {
+		methods = append(methods, "totp", "recovery_code")
+	}
+	if hasPasskeys {
+		methods = append(methods, "passkey")
+	}
+
+	now := time.Now()
+	_ = s.db.Queries.DeleteExpiredLoginChallenges(ctx, now)
+
+	token, err := auth.GenerateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+	challenge := &LoginChallenge{
+		Token:              token,
+		ExpiresAt:          now.Add(loginChallengeTTL),
+		Methods:            methods,
+		EnrollmentRequired: !status.Enabled && !hasPasskeys,
+	}
+	if err := s.db.Queries.CreateLoginChallenge(ctx, sqlc.CreateLoginChallengeParams{
+		UserID:    userID,
+		TokenHash: auth.HashToken(token, s.authConfig.TokenPepper),
+		ExpiresAt: challenge.ExpiresAt,
+	}); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func (s *twoFactorService) ChallengeUser(ctx context.Context, token string) (int64, error) {
+	challenge, err := s.challenge(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	return challenge.UserID, nil
+}
+
+func (s *twoFactorService) challenge(ctx context.Context, token string) (sqlc.LoginChallenge, error) {
+	if token == "" {
+		return sqlc.LoginChallenge{}, ErrInvalidToken
+	}
+	challenge, err := s.db.Queries.GetLoginChallenge(ctx, auth.HashToken(token, s.authConfig.TokenPepper))
+	if errors.Is(err, sql.ErrNoRows) {
+		return challenge, ErrInvalidToken
+	}
+	if err != nil {
+		return challenge, err
+	}
+	if time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= maxChallengeAttempts {
+		_ = s.db.Queries.DeleteLoginChallenge(ctx, challenge.ID)
+		return challenge, ErrInvalidToken
+	}
+	return challenge, nil
+}
+
+// countAttempt uses up one of a challenge's attempts before its code is
+// checked, so parallel submissions cannot get more guesses than the limit
+// between reading the count and raising it.
+func (s *twoFactorService) countAttempt(ctx context.Context, challenge sqlc.LoginChallenge) error {
+	_, err := s.db.Queries.CountLoginChallengeAttempt(ctx, sqlc.CountLoginChallengeAttemptParams{
+		ID:          challenge.ID,
+		MaxAttempts: maxChallengeAttempts,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = s.db.Queries.DeleteLoginChallenge(ctx, challenge.ID)
+		return ErrInvalidToken
+	}
+	return err
+}
+
+func (s *twoFactorService) CompleteLogin(ctx context.Context, token, code string) (*LoginCompletion, error) {
+	challenge, err := s.challenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.countAttempt(ctx, challenge); err != nil {
+		return nil, err
+	}
+	completion := &LoginCompletion{UserID: challenge.UserID}
+
+	status, err := s.Status(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case status.Enabled:
+		completion.UsedRecoveryCode, err = s.verify(ctx, challenge.UserID, code)
+	case status.Pending:
+		completion.RecoveryCodes, err = s.ConfirmEnrollment(ctx, challenge.UserID, code)
+	case status.Passkeys > 0:
+		err = ErrInvalidTwoFactorCode
+	default:
+		return nil, ErrNoPendingEnrollment
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Queries.DeleteLoginChallenge(ctx, challenge.ID); err != nil {
+		return nil, err
+	}
+	return completion, nil
+}
+
+func (s *twoFactorService) CompletePasskeyLogin(ctx context.Context, token string, verify func(userID int64) error) (*LoginCompletion, error) {
+	challenge, err := s.challenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.countAttempt(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	if err := verify(challenge.UserID); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Queries.DeleteLoginChallenge(ctx, challenge.ID); err != nil {
+		return nil, err
+	}
+	return &LoginCompletion{UserID: challenge.UserID}, nil
+}
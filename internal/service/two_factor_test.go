@@ -0,0 +1,63 @@
+//go:build sqlite_fts5
+
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"ramiro-uziel/vault/internal/auth"
+)
+
+func TestCompleteLoginLimitsParallelGuesses(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	twoFactor := NewTwoFactorService(database, auth.Config{TokenPepper: "pepper"})
+	user := createTestUser(t, database, "alice", "alice@example.com")
+
+	// A required but unenrolled second factor gives a challenge that takes
+	// the code confirming a new enrollment.
+	if err := twoFactor.SetRequired(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := twoFactor.StartLogin(ctx, user.ID)
+	if err != nil || challenge == nil {
+		t.Fatalf("StartLogin() = %v, %v", challenge, err)
+	}
+	if _, err := twoFactor.BeginEnrollment(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	const guesses = 30
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		checked int
+	)
+	for range guesses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := twoFactor.CompleteLogin(ctx, challenge.Token, "000000")
+			switch {
+			case errors.Is(err, ErrInvalidTwoFactorCode):
+				mu.Lock()
+				checked++
+				mu.Unlock()
+			case errors.Is(err, ErrInvalidToken):
+			default:
+				t.Errorf("CompleteLogin() = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if checked == 0 || checked > maxChallengeAttempts {
+		t.Fatalf("%d codes were checked on one challenge, want at most %d", checked, maxChallengeAttempts)
+	}
+	if _, err := twoFactor.CompleteLogin(ctx, challenge.Token, "000000"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("challenge still usable after its attempts: %v", err)
+	}
+}
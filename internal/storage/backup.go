@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BackupDestination stores backup archives under flat names. Listings only
+// include names the destination itself wrote with PutBackup.
+type BackupDestination interface {
+	PutBackup(ctx context.Context, name string, reader io.Reader, size int64) error
+	// GetBackup copies a backup into w. It returns ErrNotExist if there is
+	// no backup by that name.
+	GetBackup(ctx context.Context, name string, w io.Writer) error
+	ListBackups(ctx context.Context) ([]FileInfo, error)
+	DeleteBackup(ctx context.Context, name string) error
+	// String describes where backups go, for status reports. It never
+	// includes credentials.
+	String() string
+}
+
+// LocalBackups keeps backups in a directory on this machine.
+type LocalBackups struct {
+	dir string
+}
+
+func NewLocalBackups(dir string) (*LocalBackups, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	return &LocalBackups{dir: dir}, nil
+}
+
+func (l *LocalBackups) String() string {
+	return l.dir
+}
+
+// PutBackup writes to a temporary file first so a partial backup never
+// shows up in listings.
+func (l *LocalBackups) PutBackup(ctx context.Context, name string, reader io.Reader, size int64) error {
+	if err := validBackupName(name); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(l.dir, ".partial-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(l.dir, name))
+}
+
+func (l *LocalBackups) GetBackup(ctx context.Context, name string, w io.Writer) error {
+	if err := validBackupName(name); err != nil {
+		return err
+	}
+	file, err := os.Open(filepath.Join(l.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotExist
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func (l *LocalBackups) ListBackups(ctx context.Context) ([]FileInfo, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, FileInfo{Path: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sortBackups(backups)
+	return backups, nil
+}
+
+func (l *LocalBackups) DeleteBackup(ctx context.Context, name string) error {
+	if err := validBackupName(name); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(l.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotExist
+	}
+	return err
+}
+
+// S3Backups keeps backups in an S3-compatible bucket, optionally under a
+// prefix.
+type S3Backups struct {
+	client *s3Client
+	prefix string
+}
+
+func NewS3Backups(cfg S3Config) (*S3Backups, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3 credentials are required")
+	}
+	client, err := newS3Client(cfg)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3Backups{client: client, prefix: prefix}, nil
+}
+
+func (s *S3Backups) String() string {
+	return fmt.Sprintf("s3://%s/%s", s.client.bucket, s.prefix)
+}
+
+func (s *S3Backups) PutBackup(ctx context.Context, name string, reader io.Reader, size int64) error {
+	if err := validBackupName(name); err != nil {
+		return err
+	}
+	return s.client.putObject(ctx, s.prefix+name, reader, size, "application/zip")
+}
+
+func (s *S3Backups) GetBackup(ctx context.Context, name string, w io.Writer) error {
+	if err := validBackupName(name); err != nil {
+		return err
+	}
+	body, err := s.client.getObject(ctx, s.prefix+name, 0)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	_, err = io.Copy(w, body)
+	return err
+}
+
+func (s *S3Backups) ListBackups(ctx context.Context) ([]FileInfo, error) {
+	var backups []FileInfo
+	err := s.client.listObjects(ctx, s.prefix, func(info FileInfo) error {
+		name := strings.TrimPrefix(info.Path, s.prefix)
+		if name != "" && !strings.Contains(name, "/") {
+			info.Path = name
+			backups = append(backups, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortBackups(backups)
+	return backups, nil
+}
+
+func (s *S3Backups) DeleteBackup(ctx context.Context, name string) error {
+	if err := validBackupName(name); err != nil {
+		return err
+	}
+	return s.client.deleteObject(ctx, s.prefix+name)
+}
+
+// validBackupName rejects names that would escape the destination.
+func validBackupName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+	return nil
+}
+
+// sortBackups orders backups by name, which for timestamped names is oldest
+// first.
+func sortBackups(backups []FileInfo) {
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Path < backups[j].Path
+	})
+}
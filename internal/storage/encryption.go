@@ -0,0 +1,447 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Encrypted files use envelope encryption: every file gets a random data key,
+// stored in the file header wrapped (AES-GCM) by an instance master key. The
+// body is split into fixed-size chunks sealed independently, so any byte range
+// can be decrypted without reading the whole file.
+//
+// Layout (version 1):
+//
+//	magic "VLTENC" | version | key ID length | key ID | wrapped key length | wrapped key | chunks...
+//
+// Each chunk is encChunkSize bytes of plaintext plus a GCM tag; only the last
+// one may be shorter. Chunk nonces encode the chunk index and a final-chunk
+// flag, which rules out reordering and truncation.
+const (
+	encMagic     = "VLTENC"
+	encVersion   = 1
+	encChunkSize = 64 << 10
+	encTagSize   = 16
+	encKeySize   = 32
+)
+
+var (
+	ErrNoEncryptionKey = errors.New("file is encrypted with a key that is not configured")
+	errCorruptHeader   = errors.New("corrupt encryption header")
+)
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring holds the master key new files are encrypted with, plus earlier
+// keys that are still accepted for reading until rotation rewraps them.
+type Keyring struct {
+	current *masterKey
+	keys    map[string]*masterKey
+}
+
+// ParseMasterKey decodes a 32-byte key given as base64 or hex.
+func ParseMasterKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if key, err := base64.StdEncoding.DecodeString(value); err == nil && len(key) == encKeySize {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(value); err == nil && len(key) == encKeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("encryption key must be %d bytes, base64 or hex encoded", encKeySize)
+}
+
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]*masterKey)}
+	for i, raw := range append([][]byte{current}, previous...) {
+		mk, err := newMasterKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			k.current = mk
+		}
+		k.keys[mk.id] = mk
+	}
+	return k, nil
+}
+
+func newMasterKey(raw []byte) (*masterKey, error) {
+	if len(raw) != encKeySize {
+		return nil, fmt.Errorf("master key must be %d bytes", encKeySize)
+	}
+	aead, err := newGCM(raw)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(append([]byte("vault-master-key:"), raw...))
+	return &masterKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// CurrentKeyID identifies the key new files are encrypted with.
+func (k *Keyring) CurrentKeyID() string {
+	if k == nil {
+		return ""
+	}
+	return k.current.id
+}
+
+// Has reports whether files wrapped with the given key ID can be read.
+func (k *Keyring) Has(keyID string) bool {
+	if k == nil {
+		return false
+	}
+	_, ok := k.keys[keyID]
+	return ok
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type encHeader struct {
+	keyID      string
+	wrappedKey []byte
+	size       int64 // encoded header length
+}
+
+func (h *encHeader) marshal() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(encMagic)
+	buf.WriteByte(encVersion)
+	buf.WriteByte(byte(len(h.keyID)))
+	buf.WriteString(h.keyID)
+	buf.WriteByte(byte(len(h.wrappedKey)))
+	buf.Write(h.wrappedKey)
+	return buf.Bytes()
+}
+
+// readEncHeader parses the header at the start of r. It returns nil without an
+// error if the data is not an encrypted file, e.g. one written before
+// encryption was enabled.
+func readEncHeader(r io.Reader) (*encHeader, error) {
+	prefix := make([]byte, len(encMagic)+2)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if string(prefix[:len(encMagic)]) != encMagic {
+		return nil, nil
+	}
+	if prefix[len(encMagic)] != encVersion {
+		return nil, fmt.Errorf("unsupported encryption version %d", prefix[len(encMagic)])
+	}
+
+	keyID := make([]byte, int(prefix[len(encMagic)+1])+1)
+	if _, err := io.ReadFull(r, keyID); err != nil {
+		return nil, errCorruptHeader
+	}
+	wrapped := make([]byte, int(keyID[len(keyID)-1]))
+	if _, err := io.ReadFull(r, wrapped); err != nil {
+		return nil, errCorruptHeader
+	}
+
+	return &encHeader{
+		keyID:      string(keyID[:len(keyID)-1]),
+		wrappedKey: wrapped,
+		size:       int64(len(prefix) + len(keyID) + len(wrapped)),
+	}, nil
+}
+
+// ReadKeyID returns the master key ID a stored file is encrypted with, or ""
+// for plaintext files.
+func ReadKeyID(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	header, err := readEncHeader(file)
+	if err != nil || header == nil {
+		return "", err
+	}
+	return header.keyID, nil
+}
+
+func (k *Keyring) wrap(dataKey []byte) (*encHeader, error) {
+	nonce := make([]byte, k.current.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header := &encHeader{
+		keyID:      k.current.id,
+		wrappedKey: k.current.aead.Seal(nonce, nonce, dataKey, []byte(k.current.id)),
+	}
+	header.size = int64(len(header.marshal()))
+	return header, nil
+}
+
+func (k *Keyring) unwrap(header *encHeader) ([]byte, error) {
+	if !k.Has(header.keyID) {
+		return nil, fmt.Errorf("%w (key ID %s)", ErrNoEncryptionKey, header.keyID)
+	}
+	mk := k.keys[header.keyID]
+	nonceSize := mk.aead.NonceSize()
+	if len(header.wrappedKey) < nonceSize {
+		return nil, errCorruptHeader
+	}
+	dataKey, err := mk.aead.Open(nil, header.wrappedKey[:nonceSize], header.wrappedKey[nonceSize:], []byte(header.keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+func chunkNonce(index int64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[:8], uint64(index))
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptingWriter seals plaintext into chunks as it is written. Close must be
+// called to emit the final chunk; it does not close the underlying writer.
+type encryptingWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	index int64
+}
+
+func (k *Keyring) newEncryptingWriter(w io.Writer) (*encryptingWriter, error) {
+	dataKey := make([]byte, encKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	header, err := k.wrap(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header.marshal()); err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, encChunkSize),
+	}, nil
+}
+
+func (e *encryptingWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full buffer is only flushed once more data arrives, so the
+		// last chunk is always the one sealed as final in Close.
+		if len(e.buf) == encChunkSize {
+			if err := e.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := min(encChunkSize-len(e.buf), len(p))
+		e.buf = append(e.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptingWriter) Close() error {
+	return e.flush(true)
+}
+
+func (e *encryptingWriter) flush(final bool) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.index, final), e.buf, nil)
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+	e.index++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// decryptingReader serves plaintext from an encrypted file, decrypting only
+// the chunks that are actually read.
+type decryptingReader struct {
+	file      *os.File
+	aead      cipher.AEAD
+	bodyStart int64
+	chunks    int64
+	size      int64
+	offset    int64
+
+	chunkIndex int64
+	chunk      []byte
+}
+
+// encryptedPlainSize derives the plaintext length from the ciphertext length.
+func encryptedPlainSize(fileSize, headerSize int64) (size, chunks int64, err error) {
+	body := fileSize - headerSize
+	sealedChunk := int64(encChunkSize + encTagSize)
+	chunks = body / sealedChunk
+	if rem := body % sealedChunk; rem != 0 {
+		if rem < encTagSize {
+			return 0, 0, errCorruptHeader
+		}
+		chunks++
+	}
+	if chunks == 0 {
+		return 0, 0, errCorruptHeader
+	}
+	return body - chunks*encTagSize, chunks, nil
+}
+
+func (k *Keyring) newDecryptingReader(file *os.File, header *encHeader, fileSize int64) (*decryptingReader, error) {
+	dataKey, err := k.unwrap(header)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	size, chunks, err := encryptedPlainSize(fileSize, header.size)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		file:       file,
+		aead:       aead,
+		bodyStart:  header.size,
+		chunks:     chunks,
+		size:       size,
+		chunkIndex: -1,
+	}, nil
+}
+
+func (d *decryptingReader) loadChunk(index int64) error {
+	if index == d.chunkIndex {
+		return nil
+	}
+	sealed := make([]byte, encChunkSize+encTagSize)
+	n, err := d.file.ReadAt(sealed, d.bodyStart+index*int64(len(sealed)))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	plain, err := d.aead.Open(sealed[:0], chunkNonce(index, index == d.chunks-1), sealed[:n], nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt chunk %d: %w", index, err)
+	}
+	d.chunk = plain
+	d.chunkIndex = index
+	return nil
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	if d.offset >= d.size {
+		return 0, io.EOF
+	}
+	if err := d.loadChunk(d.offset / encChunkSize); err != nil {
+		return 0, err
+	}
+	n := copy(p, d.chunk[d.offset%encChunkSize:])
+	d.offset += int64(n)
+	return n, nil
+}
+
+func (d *decryptingReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = d.offset + offset
+	case io.SeekEnd:
+		abs = d.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	d.offset = abs
+	return abs, nil
+}
+
+func (d *decryptingReader) Close() error {
+	return d.file.Close()
+}
+
+// rewrap replaces the header of an encrypted file so its data key is wrapped
+// by the current master key. The body is copied as-is.
+func (k *Keyring) rewrap(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	header, err := readEncHeader(src)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return errors.New("file is not encrypted")
+	}
+	dataKey, err := k.unwrap(header)
+	if err != nil {
+		return err
+	}
+	newHeader, err := k.wrap(dataKey)
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(path, func(dst io.Writer) error {
+		if _, err := dst.Write(newHeader.marshal()); err != nil {
+			return err
+		}
+		_, err := io.Copy(dst, src)
+		return err
+	})
+}
+
+// replaceFile atomically replaces path with the output of write.
+func replaceFile(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
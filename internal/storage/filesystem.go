@@ -3,6 +3,8 @@ package storage
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"image"
 	_ "image/gif"
@@ -21,12 +23,81 @@ import (
 
 type FilesystemStorage struct {
 	baseDir string
+	keyring *Keyring
 }
 
 func NewFilesystemStorage(baseDir string) *FilesystemStorage {
 	return &FilesystemStorage{baseDir: baseDir}
 }
 
+// SetKeyring enables encryption at rest for files written from now on.
+// Existing plaintext files stay readable.
+func (s *FilesystemStorage) SetKeyring(k *Keyring) {
+	s.keyring = k
+}
+
+// encode copies r into w, encrypting it when a keyring is configured, and
+// returns the number of plaintext bytes written.
+func (s *FilesystemStorage) encode(w io.Writer, r io.Reader) (int64, error) {
+	if s.keyring == nil {
+		return io.Copy(w, r)
+	}
+	enc, err := s.keyring.newEncryptingWriter(w)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start encryption: %w", err)
+	}
+	size, err := io.Copy(enc, r)
+	if err != nil {
+		return size, err
+	}
+	return size, enc.Close()
+}
+
+func (s *FilesystemStorage) writeBytes(path string, data []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := s.encode(file, bytes.NewReader(data)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// open returns a plaintext view of a stored file, decrypting transparently if
+// the file was written encrypted.
+func (s *FilesystemStorage) open(path string) (*FileStream, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	header, err := readEncHeader(file)
+	if err == nil && header == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if header == nil {
+		return &FileStream{Reader: file, Size: info.Size(), ModTime: info.ModTime()}, nil
+	}
+
+	reader, err := s.keyring.newDecryptingReader(file, header, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &FileStream{Reader: reader, Size: reader.size, ModTime: info.ModTime()}, nil
+}
+
 func (s *FilesystemStorage) SaveTrackSource(ctx context.Context, input SaveTrackSourceInput) (*SaveTrackSourceResult, error) {
 	select {
 	case <-ctx.Done():
@@ -51,7 +122,8 @@ func (s *FilesystemStorage) SaveTrackSource(ctx context.Context, input SaveTrack
 	}
 	defer file.Close()
 
-	size, err := io.Copy(file, input.Reader)
+	hasher := sha256.New()
+	size, err := s.encode(file, io.TeeReader(input.Reader, hasher))
 	if err != nil {
 		return nil, fmt.Errorf("failed to write source file: %w", err)
 	}
@@ -62,9 +134,10 @@ func (s *FilesystemStorage) SaveTrackSource(ctx context.Context, input SaveTrack
 	}
 
 	return &SaveTrackSourceResult{
-		Path:   filePath,
-		Size:   size,
-		Format: format,
+		Path:        filePath,
+		Size:        size,
+		Format:      format,
+		ContentHash: hex.EncodeToString(hasher.Sum(nil)),
 	}, nil
 }
 
@@ -147,7 +220,7 @@ func (s *FilesystemStorage) SaveProjectCover(ctx context.Context, input SaveProj
 	}
 	defer file.Close()
 
-	size, err := io.Copy(file, input.Reader)
+	size, err := s.encode(file, input.Reader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to write cover file: %w", err)
 	}
@@ -184,22 +257,22 @@ func (s *FilesystemStorage) SaveProcessedCover(ctx context.Context, input SavePr
 		ext = ".img"
 	}
 	sourcePath := filepath.Join(dir, "source"+ext)
-	if err := os.WriteFile(sourcePath, input.Source, 0o644); err != nil {
+	if err := s.writeBytes(sourcePath, input.Source); err != nil {
 		return nil, fmt.Errorf("failed to write source cover: %w", err)
 	}
 
 	smallPath := filepath.Join(dir, "small.webp")
-	if err := os.WriteFile(smallPath, input.Small, 0o644); err != nil {
+	if err := s.writeBytes(smallPath, input.Small); err != nil {
 		return nil, fmt.Errorf("failed to write small cover: %w", err)
 	}
 
 	mediumPath := filepath.Join(dir, "medium.webp")
-	if err := os.WriteFile(mediumPath, input.Medium, 0o644); err != nil {
+	if err := s.writeBytes(mediumPath, input.Medium); err != nil {
 		return nil, fmt.Errorf("failed to write medium cover: %w", err)
 	}
 
 	largePath := filepath.Join(dir, "large.webp")
-	if err := os.WriteFile(largePath, input.Large, 0o644); err != nil {
+	if err := s.writeBytes(largePath, input.Large); err != nil {
 		return nil, fmt.Errorf("failed to write large cover: %w", err)
 	}
 
@@ -311,29 +384,41 @@ func (s *FilesystemStorage) resolveSourcePath(input OpenProjectCoverInput) strin
 }
 
 func (s *FilesystemStorage) openFile(path string) (*ProjectCoverStream, error) {
-	file, err := os.Open(path)
+	stream, err := s.open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open cover file: %w", err)
 	}
-	info, err := file.Stat()
-	if err != nil {
-		file.Close()
-		return nil, fmt.Errorf("failed to stat cover file: %w", err)
-	}
 	return &ProjectCoverStream{
-		Reader: file,
-		Size:   info.Size(),
+		Reader: stream.Reader,
+		Size:   stream.Size,
 	}, nil
 }
 
 func (s *FilesystemStorage) resizeAndCache(sourcePath, projectPublicID, sizeName string, dim int) ([]byte, error) {
-	sourceFile, err := os.Open(sourcePath)
+	source, err := s.open(sourcePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open source: %w", err)
 	}
-	defer sourceFile.Close()
+	defer source.Reader.Close()
+
+	resized, err := resizeCover(source.Reader, sizeName, dim)
+	if err != nil {
+		return nil, err
+	}
+
+	coverDir := s.coverDir(projectPublicID)
+	if err := os.MkdirAll(coverDir, 0o755); err == nil {
+		cachedPath := filepath.Join(coverDir, sizeName+".webp")
+		if writeErr := s.writeBytes(cachedPath, resized); writeErr != nil {
+			slog.Warn("failed to cache resized cover", "path", cachedPath, "error", writeErr)
+		}
+	}
+
+	return resized, nil
+}
 
-	img, _, err := image.Decode(sourceFile)
+func resizeCover(source io.Reader, sizeName string, dim int) ([]byte, error) {
+	img, _, err := image.Decode(source)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
@@ -350,14 +435,6 @@ func (s *FilesystemStorage) resizeAndCache(sourcePath, projectPublicID, sizeName
 		return nil, fmt.Errorf("failed to encode webp: %w", err)
 	}
 
-	coverDir := s.coverDir(projectPublicID)
-	if err := os.MkdirAll(coverDir, 0o755); err == nil {
-		cachedPath := filepath.Join(coverDir, sizeName+".webp")
-		if writeErr := os.WriteFile(cachedPath, buf.Bytes(), 0o644); writeErr != nil {
-			slog.Warn("failed to cache resized cover", "path", cachedPath, "error", writeErr)
-		}
-	}
-
 	return buf.Bytes(), nil
 }
 
@@ -374,3 +451,264 @@ func (s *FilesystemStorage) DeleteProject(ctx context.Context, input DeleteProje
 	}
 	return nil
 }
+
+func (s *FilesystemStorage) OpenFile(ctx context.Context, path string) (*FileStream, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	stream, err := s.open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return stream, nil
+}
+
+// OpenRaw opens a stored file without decrypting it.
+func (s *FilesystemStorage) OpenRaw(ctx context.Context, path string) (*FileStream, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return &FileStream{Reader: file, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *FilesystemStorage) StatFile(ctx context.Context, path string) (*FileInfo, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	// Report the plaintext size so callers can compare it with what was uploaded.
+	size := info.Size()
+	if file, err := os.Open(path); err == nil {
+		header, _ := readEncHeader(file)
+		file.Close()
+		if header != nil {
+			if plainSize, _, err := encryptedPlainSize(size, header.size); err == nil {
+				size = plainSize
+			}
+		}
+	}
+
+	return &FileInfo{
+		Path:    path,
+		Size:    size,
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+func (s *FilesystemStorage) WriteFile(ctx context.Context, path string, reader io.Reader) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Write to a sibling temp file first so readers never see a partial file.
+	var size int64
+	err := replaceFile(path, func(w io.Writer) error {
+		var err error
+		size, err = s.encode(w, reader)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to write file: %w", err)
+	}
+	return size, nil
+}
+
+func (s *FilesystemStorage) CopyFile(ctx context.Context, srcPath, dstPath string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	// Copies are re-encrypted so every file keeps its own data key.
+	src, err := s.OpenFile(ctx, srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Reader.Close()
+
+	if _, err := s.WriteFile(ctx, dstPath, src.Reader); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *FilesystemStorage) DeleteFile(ctx context.Context, path string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+func (s *FilesystemStorage) LocalFile(ctx context.Context, path string) (string, func(), error) {
+	if _, err := s.StatFile(ctx, path); err != nil {
+		return "", nil, err
+	}
+	keyID, err := ReadKeyID(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if keyID == "" {
+		return path, func() {}, nil
+	}
+
+	// External tools such as ffmpeg need the plaintext on disk.
+	stream, err := s.OpenFile(ctx, path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer stream.Reader.Close()
+
+	tmp, err := os.CreateTemp("", "vault-decrypted-*"+filepath.Ext(path))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	release := func() { os.Remove(tmp.Name()) }
+	if _, err := io.Copy(tmp, stream.Reader); err != nil {
+		tmp.Close()
+		release()
+		return "", nil, fmt.Errorf("failed to decrypt file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		release()
+		return "", nil, fmt.Errorf("failed to decrypt file: %w", err)
+	}
+	return tmp.Name(), release, nil
+}
+
+func (s *FilesystemStorage) ListFiles(ctx context.Context, fn func(FileInfo) error) error {
+	root := filepath.Join(s.baseDir, "projects")
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		return fn(FileInfo{
+			Path:    path,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+	return nil
+}
+
+type RotateKeysResult struct {
+	Rewrapped int      `json:"rewrapped"`
+	Encrypted int      `json:"encrypted"`
+	Unchanged int      `json:"unchanged"`
+	Failed    []string `json:"failed"`
+}
+
+// RotateKeys rewraps the data key of every file that is not yet on the
+// current master key and encrypts files stored before encryption was enabled.
+// Only file headers change for rewrapped files; their audio is not re-encrypted.
+func (s *FilesystemStorage) RotateKeys(ctx context.Context) (*RotateKeysResult, error) {
+	if s.keyring == nil {
+		return nil, fmt.Errorf("no encryption key configured")
+	}
+
+	result := &RotateKeysResult{Failed: []string{}}
+	err := s.ListFiles(ctx, func(info FileInfo) error {
+		keyID, err := ReadKeyID(info.Path)
+		if err != nil {
+			slog.Warn("failed to read file for key rotation", "path", info.Path, "error", err)
+			result.Failed = append(result.Failed, info.Path)
+			return nil
+		}
+
+		switch keyID {
+		case s.keyring.CurrentKeyID():
+			result.Unchanged++
+		case "":
+			err = s.encryptInPlace(info.Path)
+			if err == nil {
+				result.Encrypted++
+			}
+		default:
+			err = s.keyring.rewrap(info.Path)
+			if err == nil {
+				result.Rewrapped++
+			}
+		}
+		if err != nil {
+			slog.Warn("failed to rotate file key", "path", info.Path, "error", err)
+			result.Failed = append(result.Failed, info.Path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *FilesystemStorage) encryptInPlace(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return replaceFile(path, func(w io.Writer) error {
+		_, err := s.encode(w, src)
+		return err
+	})
+}
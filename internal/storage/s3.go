@@ -0,0 +1,475 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Prefix is prepended to every object key, allowing several instances
+	// to share one bucket.
+	Prefix string
+	// PathStyle addresses the bucket as endpoint/bucket instead of
+	// bucket.endpoint, which MinIO and most self-hosted services require.
+	PathStyle bool
+	// PresignRedirects lets streaming endpoints redirect clients to a
+	// presigned URL instead of proxying audio through the server.
+	PresignRedirects bool
+	PresignTTL       time.Duration
+}
+
+type S3Storage struct {
+	client     *s3Client
+	prefix     string
+	presign    bool
+	presignTTL time.Duration
+}
+
+func NewS3Storage(cfg S3Config) (*S3Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3 credentials are required")
+	}
+	client, err := newS3Client(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	ttl := cfg.PresignTTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+
+	return &S3Storage{
+		client:     client,
+		prefix:     prefix,
+		presign:    cfg.PresignRedirects,
+		presignTTL: ttl,
+	}, nil
+}
+
+func (s *S3Storage) projectKey(projectPublicID string) string {
+	return s.prefix + "projects/" + projectPublicID
+}
+
+func (s *S3Storage) trackKey(projectPublicID string, trackID int64) string {
+	return fmt.Sprintf("%s/tracks/%d", s.projectKey(projectPublicID), trackID)
+}
+
+func (s *S3Storage) versionKey(projectPublicID string, trackID, versionID int64) string {
+	return fmt.Sprintf("%s/versions/%d", s.trackKey(projectPublicID, trackID), versionID)
+}
+
+func (s *S3Storage) coverKey(projectPublicID string) string {
+	return s.projectKey(projectPublicID) + "/cover"
+}
+
+func (s *S3Storage) SaveTrackSource(ctx context.Context, input SaveTrackSourceInput) (*SaveTrackSourceResult, error) {
+	ext := strings.ToLower(path.Ext(input.OriginalName))
+	if ext == "" {
+		ext = ".bin"
+	}
+
+	key := s.versionKey(input.ProjectPublicID, input.TrackID, input.VersionID) + "/source" + ext
+	hasher := sha256.New()
+	size, err := s.WriteFile(ctx, key, io.TeeReader(input.Reader, hasher))
+	if err != nil {
+		return nil, fmt.Errorf("failed to write source file: %w", err)
+	}
+
+	format := strings.TrimPrefix(ext, ".")
+	if format == "" {
+		format = "bin"
+	}
+
+	return &SaveTrackSourceResult{
+		Path:        key,
+		Size:        size,
+		Format:      format,
+		ContentHash: hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+func (s *S3Storage) DeleteTrack(ctx context.Context, input DeleteTrackInput) error {
+	if err := s.deletePrefix(ctx, s.trackKey(input.ProjectPublicID, input.TrackID)+"/"); err != nil {
+		return fmt.Errorf("failed to delete track objects: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) DeleteVersion(ctx context.Context, input DeleteVersionInput) error {
+	if err := s.deletePrefix(ctx, s.versionKey(input.ProjectPublicID, input.TrackID, input.VersionID)+"/"); err != nil {
+		return fmt.Errorf("failed to delete version objects: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) DeleteProject(ctx context.Context, input DeleteProjectInput) error {
+	if err := s.deletePrefix(ctx, s.projectKey(input.ProjectPublicID)+"/"); err != nil {
+		return fmt.Errorf("failed to delete project objects: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) SaveProjectCover(ctx context.Context, input SaveProjectCoverInput) (*SaveProjectCoverResult, error) {
+	if err := s.deletePrefix(ctx, s.coverKey(input.ProjectPublicID)+"/"); err != nil {
+		return nil, fmt.Errorf("failed to clean existing cover: %w", err)
+	}
+
+	ext := strings.ToLower(path.Ext(input.OriginalName))
+	if ext == "" {
+		ext = ".img"
+	}
+
+	key := s.coverKey(input.ProjectPublicID) + "/cover" + ext
+	size, err := s.WriteFile(ctx, key, input.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write cover file: %w", err)
+	}
+
+	mimeType := mime.TypeByExtension(ext)
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	return &SaveProjectCoverResult{
+		Path: key,
+		Mime: mimeType,
+		Size: size,
+	}, nil
+}
+
+func (s *S3Storage) SaveProcessedCover(ctx context.Context, input SaveProcessedCoverInput) (*SaveProcessedCoverResult, error) {
+	dir := s.coverKey(input.ProjectPublicID)
+	if err := s.deletePrefix(ctx, dir+"/"); err != nil {
+		return nil, fmt.Errorf("failed to clean existing cover: %w", err)
+	}
+
+	ext := strings.ToLower(input.SourceExt)
+	if ext == "" {
+		ext = ".img"
+	}
+	sourceKey := dir + "/source" + ext
+
+	objects := []struct {
+		key  string
+		data []byte
+		name string
+	}{
+		{sourceKey, input.Source, "source"},
+		{dir + "/small.webp", input.Small, "small"},
+		{dir + "/medium.webp", input.Medium, "medium"},
+		{dir + "/large.webp", input.Large, "large"},
+	}
+	for _, obj := range objects {
+		if _, err := s.WriteFile(ctx, obj.key, bytes.NewReader(obj.data)); err != nil {
+			return nil, fmt.Errorf("failed to write %s cover: %w", obj.name, err)
+		}
+	}
+
+	mimeType := mime.TypeByExtension(ext)
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	return &SaveProcessedCoverResult{
+		SourcePath: sourceKey,
+		SourceMime: mimeType,
+	}, nil
+}
+
+func (s *S3Storage) DeleteProjectCover(ctx context.Context, input DeleteProjectCoverInput) error {
+	if err := s.deletePrefix(ctx, s.coverKey(input.ProjectPublicID)+"/"); err != nil {
+		return fmt.Errorf("failed to delete cover objects: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) OpenProjectCover(ctx context.Context, input OpenProjectCoverInput) (*ProjectCoverStream, error) {
+	wantSized := input.Size != "" && input.Size != "source"
+
+	if wantSized {
+		if stream, err := s.openCover(ctx, s.coverKey(input.ProjectPublicID)+"/"+input.Size+".webp"); err == nil {
+			return stream, nil
+		}
+	}
+
+	sourceKey := s.resolveSourceKey(ctx, input)
+	if sourceKey == "" {
+		return nil, fmt.Errorf("no cover file found for project %s", input.ProjectPublicID)
+	}
+
+	if !wantSized {
+		return s.openCover(ctx, sourceKey)
+	}
+
+	dim, ok := coverSizeDimensions[input.Size]
+	if !ok {
+		return s.openCover(ctx, sourceKey)
+	}
+
+	source, err := s.client.getObject(ctx, sourceKey, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cover source: %w", err)
+	}
+	resized, err := resizeCover(source, input.Size, dim)
+	source.Close()
+	if err != nil {
+		slog.Warn("on-the-fly cover resize failed, serving source", "project", input.ProjectPublicID, "size", input.Size, "error", err)
+		return s.openCover(ctx, sourceKey)
+	}
+
+	cachedKey := s.coverKey(input.ProjectPublicID) + "/" + input.Size + ".webp"
+	if _, err := s.WriteFile(ctx, cachedKey, bytes.NewReader(resized)); err != nil {
+		slog.Warn("failed to cache resized cover", "key", cachedKey, "error", err)
+	}
+
+	return &ProjectCoverStream{
+		Reader: io.NopCloser(bytes.NewReader(resized)),
+		Size:   int64(len(resized)),
+	}, nil
+}
+
+func (s *S3Storage) resolveSourceKey(ctx context.Context, input OpenProjectCoverInput) string {
+	var found string
+	errStop := errors.New("stop")
+	_ = s.client.listObjects(ctx, s.coverKey(input.ProjectPublicID)+"/source.", func(info FileInfo) error {
+		found = info.Path
+		return errStop
+	})
+	if found != "" {
+		return found
+	}
+
+	if input.Path == "" {
+		return ""
+	}
+	if _, err := s.client.headObject(ctx, input.Path); err != nil {
+		return ""
+	}
+	return input.Path
+}
+
+func (s *S3Storage) openCover(ctx context.Context, key string) (*ProjectCoverStream, error) {
+	stream, err := s.OpenFile(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cover file: %w", err)
+	}
+	return &ProjectCoverStream{
+		Reader: stream.Reader,
+		Size:   stream.Size,
+	}, nil
+}
+
+func (s *S3Storage) OpenFile(ctx context.Context, key string) (*FileStream, error) {
+	info, err := s.client.headObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStream{
+		Reader:  &s3ObjectReader{ctx: ctx, client: s.client, key: key, size: info.Size},
+		Size:    info.Size,
+		ModTime: info.ModTime,
+	}, nil
+}
+
+func (s *S3Storage) StatFile(ctx context.Context, key string) (*FileInfo, error) {
+	return s.client.headObject(ctx, key)
+}
+
+func (s *S3Storage) WriteFile(ctx context.Context, key string, reader io.Reader) (int64, error) {
+	contentType := mime.TypeByExtension(path.Ext(key))
+
+	// PutObject needs the length up front, so spool unknown-length readers to disk.
+	var size int64
+	switch r := reader.(type) {
+	case *bytes.Reader:
+		size = int64(r.Len())
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil {
+			return 0, err
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+		size = info.Size() - offset
+	default:
+		tmp, err := os.CreateTemp("", "vault-s3-upload-*")
+		if err != nil {
+			return 0, fmt.Errorf("failed to create spool file: %w", err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		size, err = io.Copy(tmp, reader)
+		if err != nil {
+			return 0, fmt.Errorf("failed to spool upload: %w", err)
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		reader = tmp
+	}
+
+	if err := s.client.putObject(ctx, key, reader, size, contentType); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func (s *S3Storage) CopyFile(ctx context.Context, srcKey, dstKey string) error {
+	return s.client.copyObject(ctx, srcKey, dstKey)
+}
+
+func (s *S3Storage) DeleteFile(ctx context.Context, key string) error {
+	if err := s.client.deleteObject(ctx, key); err != nil && !errors.Is(err, ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *S3Storage) LocalFile(ctx context.Context, key string) (string, func(), error) {
+	body, err := s.client.getObject(ctx, key, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp("", "vault-s3-*"+path.Ext(key))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	release := func() { os.Remove(tmp.Name()) }
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		release()
+		return "", nil, fmt.Errorf("failed to download object: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		release()
+		return "", nil, err
+	}
+	return tmp.Name(), release, nil
+}
+
+func (s *S3Storage) ListFiles(ctx context.Context, fn func(FileInfo) error) error {
+	return s.client.listObjects(ctx, s.prefix+"projects/", fn)
+}
+
+func (s *S3Storage) RedirectURL(ctx context.Context, key string, opts RedirectOptions) (string, bool, error) {
+	if !s.presign {
+		return "", false, nil
+	}
+	query := url.Values{}
+	if opts.ContentType != "" {
+		query.Set("response-content-type", opts.ContentType)
+	}
+	if opts.Filename != "" {
+		query.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": opts.Filename}))
+	}
+	return s.client.presign(key, query, s.presignTTL), true, nil
+}
+
+func (s *S3Storage) deletePrefix(ctx context.Context, prefix string) error {
+	var keys []string
+	if err := s.client.listObjects(ctx, prefix, func(info FileInfo) error {
+		keys = append(keys, info.Path)
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.DeleteFile(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// s3ObjectReader lazily issues ranged GETs so http.ServeContent can seek
+// within an object without downloading it first.
+type s3ObjectReader struct {
+	ctx    context.Context
+	client *s3Client
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (r *s3ObjectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.client.getObject(r.ctx, r.key, r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *s3ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = r.offset + offset
+	case io.SeekEnd:
+		next = r.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if next < 0 {
+		return 0, fmt.Errorf("negative seek position")
+	}
+	if next != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = next
+	return next, nil
+}
+
+func (r *s3ObjectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,710 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type SFTPConfig struct {
+	// Addr is host:port; the port defaults to 22.
+	Addr     string
+	User     string
+	Password string
+	// KeyFile is an unencrypted private key used instead of, or along
+	// with, the password.
+	KeyFile string
+	// KnownHostsFile verifies the server's host key. It defaults to
+	// ~/.ssh/known_hosts; unknown hosts are always rejected.
+	KnownHostsFile string
+	// Dir is the remote directory backups are written to. It is created
+	// if missing.
+	Dir string
+}
+
+// SFTPBackups keeps backups in a directory on an SFTP server. Each call
+// opens its own connection, since backups are written rarely.
+type SFTPBackups struct {
+	addr      string
+	dir       string
+	sshConfig *ssh.ClientConfig
+}
+
+func NewSFTPBackups(cfg SFTPConfig) (*SFTPBackups, error) {
+	if cfg.Addr == "" || cfg.User == "" {
+		return nil, fmt.Errorf("SFTP address and user are required")
+	}
+	addr := cfg.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	var methods []ssh.AuthMethod
+	if cfg.KeyFile != "" {
+		keyData, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SFTP key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(keyData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SFTP key: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		methods = append(methods, ssh.Password(cfg.Password))
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("SFTP needs a password or a key file")
+	}
+
+	knownHostsFile := cfg.KnownHostsFile
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("no known_hosts file for SFTP: %w", err)
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SFTP known_hosts: %w", err)
+	}
+
+	dir := cfg.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	return &SFTPBackups{
+		addr: addr,
+		dir:  dir,
+		sshConfig: &ssh.ClientConfig{
+			User:            cfg.User,
+			Auth:            methods,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         30 * time.Second,
+		},
+	}, nil
+}
+
+func (s *SFTPBackups) String() string {
+	return fmt.Sprintf("sftp://%s@%s/%s", s.sshConfig.User, s.addr, strings.TrimPrefix(s.dir, "/"))
+}
+
+// PutBackup uploads under a temporary name and renames it into place, so a
+// partial backup never shows up in listings.
+func (s *SFTPBackups) PutBackup(ctx context.Context, name string, reader io.Reader, size int64) error {
+	if err := validBackupName(name); err != nil {
+		return err
+	}
+	return s.withClient(ctx, func(c *sftpClient) error {
+		if err := c.mkdirAll(s.dir); err != nil {
+			return err
+		}
+		partial := path.Join(s.dir, ".partial-"+name)
+		handle, err := c.open(partial, sftpFlagWrite|sftpFlagCreate|sftpFlagTruncate)
+		if err != nil {
+			return err
+		}
+		if err := c.writeFrom(handle, reader); err != nil {
+			c.close(handle)
+			c.remove(partial)
+			return err
+		}
+		if err := c.close(handle); err != nil {
+			c.remove(partial)
+			return err
+		}
+		return c.rename(partial, path.Join(s.dir, name))
+	})
+}
+
+func (s *SFTPBackups) GetBackup(ctx context.Context, name string, w io.Writer) error {
+	if err := validBackupName(name); err != nil {
+		return err
+	}
+	return s.withClient(ctx, func(c *sftpClient) error {
+		handle, err := c.open(path.Join(s.dir, name), sftpFlagRead)
+		if err != nil {
+			return err
+		}
+		defer c.close(handle)
+		return c.readTo(handle, w)
+	})
+}
+
+func (s *SFTPBackups) ListBackups(ctx context.Context) ([]FileInfo, error) {
+	var backups []FileInfo
+	err := s.withClient(ctx, func(c *sftpClient) error {
+		entries, err := c.readDir(s.dir)
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Path, ".") {
+				backups = append(backups, entry)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortBackups(backups)
+	return backups, nil
+}
+
+func (s *SFTPBackups) DeleteBackup(ctx context.Context, name string) error {
+	if err := validBackupName(name); err != nil {
+		return err
+	}
+	return s.withClient(ctx, func(c *sftpClient) error {
+		return c.remove(path.Join(s.dir, name))
+	})
+}
+
+func (s *SFTPBackups) withClient(ctx context.Context, fn func(*sftpClient) error) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SFTP server: %w", err)
+	}
+	// Closing the connection unblocks any call in progress when ctx ends.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, s.addr, s.sshConfig)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SFTP handshake failed: %w", err)
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+	defer sshClient.Close()
+
+	client, err := newSFTPClient(sshClient)
+	if err != nil {
+		return err
+	}
+	defer client.session.Close()
+
+	if err := fn(client); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// SFTP version 3 packet types and flags, from draft-ietf-secsh-filexfer-02.
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpStat     = 17
+	sftpRename   = 18
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrsPkt = 105
+
+	sftpFlagRead     = 0x01
+	sftpFlagWrite    = 0x02
+	sftpFlagCreate   = 0x08
+	sftpFlagTruncate = 0x10
+
+	sftpAttrSize        = 0x01
+	sftpAttrUIDGID      = 0x02
+	sftpAttrPermissions = 0x04
+	sftpAttrACModTime   = 0x08
+	sftpAttrExtended    = 0x80000000
+
+	sftpOK         = 0
+	sftpEOF        = 1
+	sftpNoSuchFile = 2
+
+	// sftpChunkSize is the largest read or write every server accepts;
+	// sftpWindow of them are kept in flight to hide the round trips.
+	sftpChunkSize  = 32 << 10
+	sftpWindow     = 32
+	sftpMaxPacket  = 256 << 10
+	sftpProtoVer   = 3
+	sftpPermissive = 0755
+)
+
+type sftpError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *sftpError) Error() string {
+	return fmt.Sprintf("sftp: status %d: %s", e.Code, e.Message)
+}
+
+// sftpClient is a minimal SFTP client implementing only the requests backups
+// need. Requests are sent one at a time except for reads and writes, which
+// are pipelined.
+type sftpClient struct {
+	session *ssh.Session
+	w       io.Writer
+	r       io.Reader
+	nextID  uint32
+}
+
+func newSFTPClient(conn *ssh.Client) (*sftpClient, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SFTP session: %w", err)
+	}
+	w, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("SFTP subsystem unavailable: %w", err)
+	}
+
+	c := &sftpClient{session: session, w: w, r: r}
+	if err := c.send(sftpInit, binary.BigEndian.AppendUint32(nil, sftpProtoVer)); err != nil {
+		session.Close()
+		return nil, err
+	}
+	typ, _, err := c.recv()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if typ != sftpVersion {
+		session.Close()
+		return nil, fmt.Errorf("sftp: unexpected packet %d during init", typ)
+	}
+	return c, nil
+}
+
+func (c *sftpClient) send(typ byte, payload []byte) error {
+	packet := make([]byte, 0, 5+len(payload))
+	packet = binary.BigEndian.AppendUint32(packet, uint32(1+len(payload)))
+	packet = append(packet, typ)
+	packet = append(packet, payload...)
+	_, err := c.w.Write(packet)
+	return err
+}
+
+func (c *sftpClient) recv() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, fmt.Errorf("sftp: failed to read response: %w", err)
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 1 || length > sftpMaxPacket {
+		return 0, nil, fmt.Errorf("sftp: invalid packet length %d", length)
+	}
+	body := make([]byte, length-1)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, fmt.Errorf("sftp: failed to read response: %w", err)
+	}
+	return header[4], body, nil
+}
+
+// request sends one request and returns the response type and the body
+// after its request ID.
+func (c *sftpClient) request(typ byte, fields ...[]byte) (byte, []byte, error) {
+	c.nextID++
+	id := c.nextID
+	payload := binary.BigEndian.AppendUint32(nil, id)
+	for _, field := range fields {
+		payload = append(payload, field...)
+	}
+	if err := c.send(typ, payload); err != nil {
+		return 0, nil, err
+	}
+	respType, body, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	respID, body, ok := sftpUint32(body)
+	if !ok || respID != id {
+		return 0, nil, fmt.Errorf("sftp: response for unexpected request %d", respID)
+	}
+	return respType, body, nil
+}
+
+// requestStatus sends a request answered by a status alone.
+func (c *sftpClient) requestStatus(typ byte, fields ...[]byte) error {
+	respType, body, err := c.request(typ, fields...)
+	if err != nil {
+		return err
+	}
+	return expectStatus(respType, body)
+}
+
+// expectStatus turns a status response into an error, nil for success.
+func expectStatus(typ byte, body []byte) error {
+	if typ != sftpStatus {
+		return fmt.Errorf("sftp: unexpected packet %d", typ)
+	}
+	return sftpStatusError(body)
+}
+
+func sftpStatusError(body []byte) error {
+	code, rest, ok := sftpUint32(body)
+	if !ok {
+		return fmt.Errorf("sftp: malformed status")
+	}
+	if code == sftpOK {
+		return nil
+	}
+	message, _, _ := sftpString(rest)
+	err := &sftpError{Code: code, Message: string(message)}
+	if code == sftpNoSuchFile {
+		return fmt.Errorf("%w: %v", ErrNotExist, err)
+	}
+	return err
+}
+
+func (c *sftpClient) open(name string, flags uint32) (string, error) {
+	typ, body, err := c.request(sftpOpen, sftpStr(name), sftpU32(flags), sftpU32(0))
+	if err != nil {
+		return "", err
+	}
+	if typ != sftpHandle {
+		return "", expectStatus(typ, body)
+	}
+	handle, _, ok := sftpString(body)
+	if !ok {
+		return "", fmt.Errorf("sftp: malformed handle")
+	}
+	return string(handle), nil
+}
+
+func (c *sftpClient) close(handle string) error {
+	return c.requestStatus(sftpClose, sftpStr(handle))
+}
+
+// writeFrom copies reader into an open file, keeping up to sftpWindow writes
+// in flight.
+func (c *sftpClient) writeFrom(handle string, reader io.Reader) error {
+	buf := make([]byte, sftpChunkSize)
+	var offset uint64
+	inFlight := 0
+	done := false
+
+	for !done || inFlight > 0 {
+		for !done && inFlight < sftpWindow {
+			n, err := io.ReadFull(reader, buf)
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				done = true
+			} else if err != nil {
+				return err
+			}
+			if n == 0 {
+				break
+			}
+
+			c.nextID++
+			payload := binary.BigEndian.AppendUint32(nil, c.nextID)
+			payload = append(payload, sftpStr(handle)...)
+			payload = binary.BigEndian.AppendUint64(payload, offset)
+			payload = append(payload, sftpStr(string(buf[:n]))...)
+			if err := c.send(sftpWrite, payload); err != nil {
+				return err
+			}
+			offset += uint64(n)
+			inFlight++
+		}
+		if inFlight == 0 {
+			break
+		}
+
+		typ, body, err := c.recv()
+		if err != nil {
+			return err
+		}
+		inFlight--
+		if _, body, ok := sftpUint32(body); !ok {
+			return fmt.Errorf("sftp: malformed write response")
+		} else if err := expectStatus(typ, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTo copies an open file into w, keeping up to sftpWindow reads in
+// flight. Responses may arrive out of order and reads may come back short,
+// so data is held until everything before it has been written.
+func (c *sftpClient) readTo(handle string, w io.Writer) error {
+	type request struct {
+		offset uint64
+		length uint32
+	}
+	pending := map[uint32]request{}
+	received := map[uint64][]byte{}
+	var nextOffset, written uint64
+	eof := false
+
+	send := func(offset uint64, length uint32) error {
+		c.nextID++
+		payload := binary.BigEndian.AppendUint32(nil, c.nextID)
+		payload = append(payload, sftpStr(handle)...)
+		payload = binary.BigEndian.AppendUint64(payload, offset)
+		payload = binary.BigEndian.AppendUint32(payload, length)
+		pending[c.nextID] = request{offset: offset, length: length}
+		return c.send(sftpRead, payload)
+	}
+
+	for {
+		for !eof && len(pending) < sftpWindow {
+			if err := send(nextOffset, sftpChunkSize); err != nil {
+				return err
+			}
+			nextOffset += sftpChunkSize
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		typ, body, err := c.recv()
+		if err != nil {
+			return err
+		}
+		id, body, ok := sftpUint32(body)
+		req, known := pending[id]
+		if !ok || !known {
+			return fmt.Errorf("sftp: response for unexpected request %d", id)
+		}
+		delete(pending, id)
+
+		switch typ {
+		case sftpData:
+			data, _, ok := sftpString(body)
+			if !ok {
+				return fmt.Errorf("sftp: malformed data")
+			}
+			if len(data) > 0 {
+				received[req.offset] = data
+			}
+			if remaining := req.length - uint32(len(data)); len(data) > 0 && remaining > 0 {
+				if err := send(req.offset+uint64(len(data)), remaining); err != nil {
+					return err
+				}
+			}
+		case sftpStatus:
+			if code, _, ok := sftpUint32(body); ok && code == sftpEOF {
+				eof = true
+				continue
+			}
+			return sftpStatusError(body)
+		default:
+			return fmt.Errorf("sftp: unexpected packet %d", typ)
+		}
+
+		for data, ok := received[written]; ok; data, ok = received[written] {
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			delete(received, written)
+			written += uint64(len(data))
+		}
+	}
+
+	if len(received) > 0 {
+		return fmt.Errorf("sftp: file ended with %d reads unaccounted for", len(received))
+	}
+	return nil
+}
+
+func (c *sftpClient) remove(name string) error {
+	return c.requestStatus(sftpRemove, sftpStr(name))
+}
+
+func (c *sftpClient) rename(oldName, newName string) error {
+	return c.requestStatus(sftpRename, sftpStr(oldName), sftpStr(newName))
+}
+
+// mkdirAll creates dir and any missing parents.
+func (c *sftpClient) mkdirAll(dir string) error {
+	if dir == "." || dir == "/" || dir == "" {
+		return nil
+	}
+	typ, body, err := c.request(sftpStat, sftpStr(dir))
+	if err != nil {
+		return err
+	}
+	if typ == sftpAttrsPkt {
+		return nil
+	}
+	if err := expectStatus(typ, body); !errors.Is(err, ErrNotExist) {
+		return err
+	}
+	if err := c.mkdirAll(path.Dir(dir)); err != nil {
+		return err
+	}
+	attrs := append(sftpU32(sftpAttrPermissions), sftpU32(sftpPermissive)...)
+	return c.requestStatus(sftpMkdir, sftpStr(dir), attrs)
+}
+
+// readDir lists the regular files in dir.
+func (c *sftpClient) readDir(dir string) ([]FileInfo, error) {
+	typ, body, err := c.request(sftpOpendir, sftpStr(dir))
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpHandle {
+		return nil, expectStatus(typ, body)
+	}
+	rawHandle, _, ok := sftpString(body)
+	if !ok {
+		return nil, fmt.Errorf("sftp: malformed handle")
+	}
+	handle := string(rawHandle)
+	defer c.close(handle)
+
+	var files []FileInfo
+	for {
+		typ, body, err := c.request(sftpReaddir, sftpStr(handle))
+		if err != nil {
+			return nil, err
+		}
+		if typ == sftpStatus {
+			if code, _, ok := sftpUint32(body); ok && code == sftpEOF {
+				return files, nil
+			}
+			return nil, sftpStatusError(body)
+		}
+		if typ != sftpName {
+			return nil, fmt.Errorf("sftp: unexpected packet %d", typ)
+		}
+
+		count, rest, ok := sftpUint32(body)
+		if !ok {
+			return nil, fmt.Errorf("sftp: malformed name list")
+		}
+		for i := uint32(0); i < count; i++ {
+			var name []byte
+			var attrs sftpAttrs
+			if name, rest, ok = sftpString(rest); !ok {
+				return nil, fmt.Errorf("sftp: malformed name list")
+			}
+			if _, rest, ok = sftpString(rest); !ok { // long name
+				return nil, fmt.Errorf("sftp: malformed name list")
+			}
+			if attrs, rest, ok = parseSFTPAttrs(rest); !ok {
+				return nil, fmt.Errorf("sftp: malformed attributes")
+			}
+			if attrs.regular() {
+				files = append(files, FileInfo{
+					Path:    string(name),
+					Size:    int64(attrs.size),
+					ModTime: time.Unix(int64(attrs.mtime), 0),
+				})
+			}
+		}
+	}
+}
+
+type sftpAttrs struct {
+	flags       uint32
+	size        uint64
+	permissions uint32
+	mtime       uint32
+}
+
+// regular reports whether the attributes describe a regular file. Servers
+// that omit permissions are trusted to list only what they can serve.
+func (a sftpAttrs) regular() bool {
+	return a.flags&sftpAttrPermissions == 0 || a.permissions&0o170000 == 0o100000
+}
+
+func parseSFTPAttrs(b []byte) (sftpAttrs, []byte, bool) {
+	var attrs sftpAttrs
+	var ok bool
+	if attrs.flags, b, ok = sftpUint32(b); !ok {
+		return attrs, nil, false
+	}
+	if attrs.flags&sftpAttrSize != 0 {
+		if len(b) < 8 {
+			return attrs, nil, false
+		}
+		attrs.size, b = binary.BigEndian.Uint64(b), b[8:]
+	}
+	if attrs.flags&sftpAttrUIDGID != 0 {
+		if len(b) < 8 {
+			return attrs, nil, false
+		}
+		b = b[8:]
+	}
+	if attrs.flags&sftpAttrPermissions != 0 {
+		if attrs.permissions, b, ok = sftpUint32(b); !ok {
+			return attrs, nil, false
+		}
+	}
+	if attrs.flags&sftpAttrACModTime != 0 {
+		if len(b) < 8 {
+			return attrs, nil, false
+		}
+		attrs.mtime, b = binary.BigEndian.Uint32(b[4:]), b[8:]
+	}
+	if attrs.flags&sftpAttrExtended != 0 {
+		var count uint32
+		if count, b, ok = sftpUint32(b); !ok {
+			return attrs, nil, false
+		}
+		for i := uint32(0); i < 2*count; i++ {
+			if _, b, ok = sftpString(b); !ok {
+				return attrs, nil, false
+			}
+		}
+	}
+	return attrs, b, true
+}
+
+func sftpU32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func sftpStr(s string) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(s))), s...)
+}
+
+func sftpUint32(b []byte) (uint32, []byte, bool) {
+	if len(b) < 4 {
+		return 0, nil, false
+	}
+	return binary.BigEndian.Uint32(b), b[4:], true
+}
+
+func sftpString(b []byte) ([]byte, []byte, bool) {
+	n, b, ok := sftpUint32(b)
+	if !ok || uint32(len(b)) < n {
+		return nil, nil, false
+	}
+	return b[:n], b[n:], true
+}
@@ -2,9 +2,14 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"io"
+	"time"
 )
 
+// ErrNotExist is returned when a stored file cannot be found.
+var ErrNotExist = errors.New("stored file does not exist")
+
 type Storage interface {
 	SaveTrackSource(ctx context.Context, input SaveTrackSourceInput) (*SaveTrackSourceResult, error)
 	DeleteTrack(ctx context.Context, input DeleteTrackInput) error
@@ -15,6 +20,49 @@ type Storage interface {
 	SaveProcessedCover(ctx context.Context, input SaveProcessedCoverInput) (*SaveProcessedCoverResult, error)
 	DeleteProjectCover(ctx context.Context, input DeleteProjectCoverInput) error
 	OpenProjectCover(ctx context.Context, input OpenProjectCoverInput) (*ProjectCoverStream, error)
+
+	// OpenFile opens a stored file for reading. The returned reader supports
+	// seeking so it can serve HTTP Range requests.
+	OpenFile(ctx context.Context, path string) (*FileStream, error)
+	StatFile(ctx context.Context, path string) (*FileInfo, error)
+	WriteFile(ctx context.Context, path string, reader io.Reader) (int64, error)
+	CopyFile(ctx context.Context, srcPath, dstPath string) error
+	DeleteFile(ctx context.Context, path string) error
+	// LocalFile makes a stored file available on local disk for tools such as
+	// ffmpeg that need a real path. release must be called once done.
+	LocalFile(ctx context.Context, path string) (localPath string, release func(), err error)
+	// ListFiles calls fn for every file stored under the projects tree.
+	ListFiles(ctx context.Context, fn func(FileInfo) error) error
+}
+
+// Redirector is implemented by backends that can hand clients a short-lived
+// direct URL instead of proxying the bytes through the server.
+type Redirector interface {
+	RedirectURL(ctx context.Context, path string, opts RedirectOptions) (string, bool, error)
+}
+
+type RedirectOptions struct {
+	ContentType string
+	Filename    string // Sets an attachment Content-Disposition when non-empty
+}
+
+// RawOpener is implemented by backends that transform files at rest, to read
+// a stored file as it is kept rather than as it was written. Instance
+// archives use it so encrypted files stay encrypted.
+type RawOpener interface {
+	OpenRaw(ctx context.Context, path string) (*FileStream, error)
+}
+
+type FileStream struct {
+	Reader  io.ReadSeekCloser
+	Size    int64
+	ModTime time.Time
+}
+
+type FileInfo struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
 }
 
 type SaveTrackSourceInput struct {
@@ -26,9 +74,10 @@ type SaveTrackSourceInput struct {
 }
 
 type SaveTrackSourceResult struct {
-	Path   string
-	Size   int64
-	Format string
+	Path        string
+	Size        int64
+	Format      string
+	ContentHash string // Hex-encoded SHA-256 of the stored bytes
 }
 
 type DeleteTrackInput struct {
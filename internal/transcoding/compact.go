@@ -0,0 +1,194 @@
+package transcoding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/storage"
+)
+
+// CompactFormat is the container uncompressed sources are compacted into.
+const CompactFormat = "flac"
+
+// compactableCodecs maps the PCM codecs FLAC holds without loss to the raw
+// sample format their decoded audio is compared in.
+var compactableCodecs = map[string]string{
+	"pcm_s16le": "s16le",
+	"pcm_s16be": "s16le",
+	"pcm_s24le": "s24le",
+	"pcm_s24be": "s24le",
+}
+
+// originalMuxers maps source formats to the ffmpeg muxer that rebuilds them.
+var originalMuxers = map[string]string{
+	"wav":  "wav",
+	"aiff": "aiff",
+	"aif":  "aiff",
+}
+
+// CanCompact reports whether a source in the given format and codec can be
+// stored as FLAC and decoded back to identical samples.
+func CanCompact(format, codec string) bool {
+	_, ok := compactableCodecs[codec]
+	return ok && originalMuxers[format] != ""
+}
+
+// IsCompacted reports whether a track file's bytes are stored in a different
+// format from the one it was uploaded in.
+func IsCompacted(file sqlc.TrackFile) bool {
+	return file.StoredFormat.Valid && file.StoredFormat.String != file.Format
+}
+
+// StoredFormat is the format of the bytes kept in storage for a track file.
+func StoredFormat(file sqlc.TrackFile) string {
+	if file.StoredFormat.Valid && file.StoredFormat.String != "" {
+		return file.StoredFormat.String
+	}
+	return file.Format
+}
+
+// CompactToFLAC encodes inputPath to FLAC at outputPath and checks that both
+// decode to the same samples. It returns the hash of the decoded audio.
+func CompactToFLAC(ctx context.Context, inputPath, outputPath, codec string) (string, error) {
+	sampleFormat, ok := compactableCodecs[codec]
+	if !ok {
+		return "", fmt.Errorf("codec %s cannot be compacted", codec)
+	}
+
+	cmd := exec.CommandContext(ctx,
+		"ffmpeg",
+		"-v", "error",
+		"-i", inputPath,
+		"-map", "0:a:0",
+		"-map_metadata", "0",
+		"-c:a", "flac",
+		"-compression_level", "8",
+		"-y",
+		outputPath,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("ffmpeg failed: %w, output: %s", err, string(output))
+	}
+
+	want, err := PCMHash(ctx, inputPath, sampleFormat)
+	if err != nil {
+		return "", err
+	}
+	got, err := PCMHash(ctx, outputPath, sampleFormat)
+	if err != nil {
+		return "", err
+	}
+	if got != want {
+		return "", fmt.Errorf("decoded audio differs after compaction")
+	}
+
+	return want, nil
+}
+
+// PCMHash decodes the first audio stream of path to raw samples in
+// sampleFormat and returns their hex-encoded SHA-256.
+func PCMHash(ctx context.Context, path, sampleFormat string) (string, error) {
+	cmd := exec.CommandContext(ctx,
+		"ffmpeg",
+		"-v", "error",
+		"-i", path,
+		"-map", "0:a:0",
+		"-f", sampleFormat,
+		"-",
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, stdout); err != nil {
+		cmd.Wait()
+		return "", fmt.Errorf("failed to read decoded audio: %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return "", fmt.Errorf("ffmpeg failed to decode %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// OpenSource opens a source track file in the format it was uploaded in.
+// Compacted sources are decoded back to their original container and sample
+// format; the samples match the upload, though container metadata may not.
+func OpenSource(ctx context.Context, store storage.Storage, file sqlc.TrackFile) (*storage.FileStream, error) {
+	if !IsCompacted(file) {
+		return store.OpenFile(ctx, file.FilePath)
+	}
+
+	muxer := originalMuxers[file.Format]
+	if muxer == "" || !file.OriginalCodec.Valid {
+		return nil, fmt.Errorf("cannot restore %s source", file.Format)
+	}
+
+	localPath, release, err := store.LocalFile(ctx, file.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	tmp, err := os.CreateTemp("", "vault-source-*."+file.Format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmp.Close()
+
+	cmd := exec.CommandContext(ctx,
+		"ffmpeg",
+		"-v", "error",
+		"-i", localPath,
+		"-map", "0:a:0",
+		"-map_metadata", "0",
+		"-c:a", file.OriginalCodec.String,
+		"-f", muxer,
+		"-y",
+		tmp.Name(),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("ffmpeg failed: %w, output: %s", err, string(output))
+	}
+
+	restored, err := os.Open(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	info, err := restored.Stat()
+	if err != nil {
+		restored.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return &storage.FileStream{
+		Reader:  &tempFile{File: restored},
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+// tempFile removes itself once closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.File.Name())
+	return err
+}
@@ -1,11 +1,14 @@
 package transcoding
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os/exec"
 	"strconv"
 	"strings"
+
+	"ramiro-uziel/vault/internal/storage"
 )
 
 type AudioMetadata struct {
@@ -35,6 +38,17 @@ type ffprobeOutput struct {
 	} `json:"streams"`
 }
 
+// ExtractStoredMetadata probes a file held in storage, fetching a local copy
+// first when the backend is not on disk.
+func ExtractStoredMetadata(ctx context.Context, store storage.Storage, path string) (*AudioMetadata, error) {
+	localPath, release, err := store.LocalFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ExtractMetadata(localPath)
+}
+
 func ExtractMetadata(filePath string) (*AudioMetadata, error) {
 	cmd := exec.Command(
 		"ffprobe",
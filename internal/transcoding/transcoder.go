@@ -12,6 +12,7 @@ import (
 
 	"ramiro-uziel/vault/internal/db"
 	sqlc "ramiro-uziel/vault/internal/db/sqlc"
+	"ramiro-uziel/vault/internal/storage"
 )
 
 type Job struct {
@@ -29,6 +30,7 @@ type TranscodingNotifier interface {
 
 type Transcoder struct {
 	db       *db.DB
+	storage  storage.Storage
 	queue    chan Job
 	workers  int
 	wg       sync.WaitGroup
@@ -37,10 +39,11 @@ type Transcoder struct {
 	notifier TranscodingNotifier
 }
 
-func NewTranscoder(database *db.DB, workers int) *Transcoder {
+func NewTranscoder(database *db.DB, storageAdapter storage.Storage, workers int) *Transcoder {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Transcoder{
 		db:      database,
+		storage: storageAdapter,
 		queue:   make(chan Job, 100),
 		workers: workers,
 		ctx:     ctx,
@@ -68,6 +71,13 @@ func (t *Transcoder) Stop() {
 	log.Println("All transcoding workers stopped")
 }
 
+// Drain stops accepting jobs and waits for the queued ones to finish. Unlike
+// Stop it does not abandon pending work, which suits one-shot commands.
+func (t *Transcoder) Drain() {
+	close(t.queue)
+	t.wg.Wait()
+}
+
 func (t *Transcoder) QueueJob(job Job) {
 	select {
 	case t.queue <- job:
@@ -111,28 +121,30 @@ func (t *Transcoder) processJob(job Job) {
 
 	t.notify(job, "processing")
 
-	err = t.transcodeToMP3(job.SourcePath, job.OutputPath)
+	sourcePath, release, err := t.storage.LocalFile(ctx, job.SourcePath)
+	if err != nil {
+		log.Printf("Failed to fetch source for version %d: %v", job.VersionID, err)
+		t.markFailed(ctx, job)
+		return
+	}
+	defer release()
+
+	size, err := t.transcodeToStorage(ctx, sourcePath, job.OutputPath)
 	if err != nil {
 		log.Printf("Transcoding failed for version %d: %v", job.VersionID, err)
-		t.db.UpdateTranscodingStatus(ctx, sqlc.UpdateTranscodingStatusParams{
-			TranscodingStatus: sql.NullString{String: "failed", Valid: true},
-			ID:                job.TrackFileID,
-		})
-		t.notify(job, "failed")
+		t.markFailed(ctx, job)
 		return
 	}
 
-	if stat, err := os.Stat(job.OutputPath); err == nil {
-		if err := t.db.UpdateTrackFileSize(ctx, sqlc.UpdateTrackFileSizeParams{
-			FileSize: stat.Size(),
-			ID:       job.TrackFileID,
-		}); err != nil {
-			log.Printf("Failed to update file size for version %d: %v", job.VersionID, err)
-		}
+	if err := t.db.UpdateTrackFileSize(ctx, sqlc.UpdateTrackFileSizeParams{
+		FileSize: size,
+		ID:       job.TrackFileID,
+	}); err != nil {
+		log.Printf("Failed to update file size for version %d: %v", job.VersionID, err)
 	}
 
 	log.Printf("Generating waveform for version %d", job.VersionID)
-	waveformJSON, err := GenerateWaveformJSON(job.SourcePath, 200)
+	waveformJSON, err := GenerateWaveformJSON(sourcePath, 200)
 	if err != nil {
 		log.Printf("Failed to generate waveform for version %d: %v", job.VersionID, err)
 		waveformJSON = ""
@@ -162,12 +174,47 @@ func (t *Transcoder) processJob(job Job) {
 	log.Printf("Successfully transcoded version %d to MP3", job.VersionID)
 }
 
+func (t *Transcoder) markFailed(ctx context.Context, job Job) {
+	t.db.UpdateTranscodingStatus(ctx, sqlc.UpdateTranscodingStatusParams{
+		TranscodingStatus: sql.NullString{String: "failed", Valid: true},
+		ID:                job.TrackFileID,
+	})
+	t.notify(job, "failed")
+}
+
 func (t *Transcoder) notify(job Job, status string) {
 	if t.notifier != nil {
 		t.notifier.NotifyTranscodingUpdate(job.UserID, job.TrackPublicID, job.VersionID, status)
 	}
 }
 
+// transcodeToStorage encodes inputPath into a temporary MP3 and stores it at
+// outputPath, returning the stored size.
+func (t *Transcoder) transcodeToStorage(ctx context.Context, inputPath, outputPath string) (int64, error) {
+	tmp, err := os.CreateTemp("", "vault-transcode-*.mp3")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := t.transcodeToMP3(inputPath, tmp.Name()); err != nil {
+		return 0, err
+	}
+
+	output, err := os.Open(tmp.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed to open transcoded file: %w", err)
+	}
+	defer output.Close()
+
+	size, err := t.storage.WriteFile(ctx, outputPath, output)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store transcoded file: %w", err)
+	}
+	return size, nil
+}
+
 func (t *Transcoder) transcodeToMP3(inputPath, outputPath string) error {
 	outputDir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
@@ -231,3 +278,30 @@ func (t *Transcoder) TranscodeVersion(ctx context.Context, input TranscodeVersio
 
 	return nil
 }
+
+// Retranscode re-queues an existing derived track file, for instance after its
+// output went missing from storage.
+func (t *Transcoder) Retranscode(ctx context.Context, file sqlc.TrackFile) error {
+	source, err := t.db.GetTrackFile(ctx, sqlc.GetTrackFileParams{
+		VersionID: file.VersionID,
+		Quality:   "source",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find source file: %w", err)
+	}
+
+	if err := t.db.UpdateTranscodingStatus(ctx, sqlc.UpdateTranscodingStatusParams{
+		TranscodingStatus: sql.NullString{String: "pending", Valid: true},
+		ID:                file.ID,
+	}); err != nil {
+		return fmt.Errorf("failed to reset transcoding status: %w", err)
+	}
+
+	t.QueueJob(Job{
+		TrackFileID: file.ID,
+		VersionID:   file.VersionID,
+		SourcePath:  source.FilePath,
+		OutputPath:  file.FilePath,
+	})
+	return nil
+}
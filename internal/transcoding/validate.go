@@ -2,6 +2,7 @@ package transcoding
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -24,10 +25,10 @@ var AllowedAudioExtensions = map[string]bool{
 }
 
 var VideoExtensions = map[string]bool{
-	".mp4": true,
-	".mov": true,
-	".mkv": true,
-	".avi": true,
+	".mp4":  true,
+	".mov":  true,
+	".mkv":  true,
+	".avi":  true,
 	".webm": true,
 }
 
@@ -66,3 +67,28 @@ func ExtractAudioToWAV(inputPath string) (string, error) {
 
 	return outputPath, nil
 }
+
+// ExtractAudioFromVideo spools a video upload to a temporary file and extracts
+// its audio to WAV. The caller is responsible for removing the returned file.
+func ExtractAudioFromVideo(r io.Reader, ext string) (string, error) {
+	tmp, err := os.CreateTemp("", "vault-video-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to spool video: %w", err)
+	}
+	tmp.Close()
+
+	wavPath, err := ExtractAudioToWAV(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		if wavPath != "" {
+			return wavPath, nil
+		}
+		return "", err
+	}
+	return wavPath, nil
+}
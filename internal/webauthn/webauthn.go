@@ -0,0 +1,474 @@
+// Package webauthn implements the relying party side of WebAuthn
+// registration and authentication ceremonies. It verifies what the browser
+// returns; storing credentials and challenges is up to the caller.
+//
+// Options and responses use the JSON forms of WebAuthn Level 3, with binary
+// fields as base64url, so browsers can pass them through
+// PublicKeyCredential.parseCreationOptionsFromJSON and toJSON.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Timeout is how long the browser is asked to wait for the user.
+const Timeout = 5 * time.Minute
+
+// Authenticator data flags.
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackupState    = 0x10
+	flagAttestedData   = 0x40
+	flagExtensionData  = 0x80
+)
+
+// ErrVerification is wrapped by every error about a response that does not
+// verify, as opposed to malformed input from this side.
+var ErrVerification = errors.New("webauthn verification failed")
+
+// Config identifies the relying party.
+type Config struct {
+	// RPID is the domain credentials are scoped to, such as vault.example.com.
+	RPID   string
+	RPName string
+	// Origins are the origins ceremonies may run on. Empty means
+	// https://RPID.
+	Origins []string
+}
+
+func (c Config) origins() []string {
+	if len(c.Origins) > 0 {
+		return c.Origins
+	}
+	return []string{"https://" + c.RPID}
+}
+
+// Bytes is binary data that travels as base64url in JSON.
+type Bytes []byte
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := DecodeBase64URL(s)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// DecodeBase64URL accepts base64url with or without padding.
+func DecodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// NewChallenge returns 32 random bytes for a ceremony.
+func NewChallenge() (Bytes, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+type RelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          Bytes  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         Bytes    `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// CreationOptions are PublicKeyCredentialCreationOptions.
+type CreationOptions struct {
+	RP                     RelyingParty           `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              Bytes                  `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are PublicKeyCredentialRequestOptions.
+type RequestOptions struct {
+	Challenge        Bytes                  `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// Registration asks for a discoverable credential, so it can be used without
+// a username, and excludes the credentials the user already has.
+func (c Config) Registration(user UserEntity, challenge Bytes, exclude []CredentialDescriptor) *CreationOptions {
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+	return &CreationOptions{
+		RP:        RelyingParty{ID: c.RPID, Name: c.RPName},
+		User:      user,
+		Challenge: challenge,
+		PubKeyCredParams: []CredentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            Timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "preferred",
+		},
+		Attestation: "none",
+	}
+}
+
+// Authentication asks for an assertion. With no allowed credentials the
+// browser offers the user's discoverable ones. userVerification is
+// "required", "preferred" or "discouraged".
+func (c Config) Authentication(challenge Bytes, allow []CredentialDescriptor, userVerification string) *RequestOptions {
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          Timeout.Milliseconds(),
+		RPID:             c.RPID,
+		AllowCredentials: allow,
+		UserVerification: userVerification,
+	}
+}
+
+// RegistrationResponse is the JSON form of the credential
+// navigator.credentials.create returns.
+type RegistrationResponse struct {
+	ID       string `json:"id"`
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes    `json:"clientDataJSON"`
+		AttestationObject Bytes    `json:"attestationObject"`
+		Transports        []string `json:"transports,omitempty"`
+	} `json:"response"`
+}
+
+// AuthenticationResponse is the JSON form of the credential
+// navigator.credentials.get returns.
+type AuthenticationResponse struct {
+	ID       string `json:"id"`
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes `json:"clientDataJSON"`
+		AuthenticatorData Bytes `json:"authenticatorData"`
+		Signature         Bytes `json:"signature"`
+		UserHandle        Bytes `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// Challenge returns the challenge the client data of a response carries, so
+// the caller can look up the ceremony it answers.
+func Challenge(clientDataJSON []byte) (Bytes, error) {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return nil, fmt.Errorf("%w: invalid client data", ErrVerification)
+	}
+	challenge, err := DecodeBase64URL(data.Challenge)
+	if err != nil || len(challenge) == 0 {
+		return nil, fmt.Errorf("%w: invalid challenge", ErrVerification)
+	}
+	return challenge, nil
+}
+
+func (c Config) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("%w: invalid client data", ErrVerification)
+	}
+	if data.Type != ceremony {
+		return fmt.Errorf("%w: client data is for %q", ErrVerification, data.Type)
+	}
+	got, err := DecodeBase64URL(data.Challenge)
+	if err != nil || !bytes.Equal(got, challenge) {
+		return fmt.Errorf("%w: challenge does not match", ErrVerification)
+	}
+	if !slices.Contains(c.origins(), data.Origin) {
+		return fmt.Errorf("%w: origin %q is not allowed", ErrVerification, data.Origin)
+	}
+	if data.CrossOrigin {
+		return fmt.Errorf("%w: cross-origin ceremonies are not allowed", ErrVerification)
+	}
+	return nil
+}
+
+// authenticatorData is the parsed form of the bytes an authenticator signs.
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data is too short", ErrVerification)
+	}
+	parsed := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if parsed.flags&flagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data is too short", ErrVerification)
+		}
+		parsed.aaguid = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+			return nil, fmt.Errorf("%w: invalid credential ID", ErrVerification)
+		}
+		parsed.credentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid credential public key: %v", ErrVerification, err)
+		}
+		parsed.publicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+	if parsed.flags&flagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid extension data: %v", ErrVerification, err)
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrVerification)
+	}
+	return parsed, nil
+}
+
+func (c Config) checkAuthenticatorData(data *authenticatorData, requireUV bool) error {
+	rpIDHash := sha256.Sum256([]byte(c.RPID))
+	if !bytes.Equal(data.rpIDHash, rpIDHash[:]) {
+		return fmt.Errorf("%w: credential is for another relying party", ErrVerification)
+	}
+	if data.flags&flagUserPresent == 0 {
+		return fmt.Errorf("%w: user was not present", ErrVerification)
+	}
+	if requireUV && data.flags&flagUserVerified == 0 {
+		return fmt.Errorf("%w: user was not verified", ErrVerification)
+	}
+	return nil
+}
+
+// Credential is a verified new credential, ready to store.
+type Credential struct {
+	ID []byte
+	// PublicKey is the COSE encoded public key.
+	PublicKey      []byte
+	SignCount      uint32
+	AAGUID         []byte
+	Transports     []string
+	UserVerified   bool
+	BackupEligible bool
+	BackupState    bool
+}
+
+// VerifyRegistration checks a registration response against the challenge
+// it was created with. Attestation is not required: "none" is accepted, and
+// "packed" statements are checked for a valid signature but their
+// certificate chain is not.
+func (c Config) VerifyRegistration(response *RegistrationResponse, challenge []byte) (*Credential, error) {
+	if response.Type != "public-key" {
+		return nil, fmt.Errorf("%w: unexpected credential type %q", ErrVerification, response.Type)
+	}
+	if err := c.verifyClientData(response.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	attestation, err := cborMap(response.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid attestation object: %v", ErrVerification, err)
+	}
+	format, _ := attestation["fmt"].(string)
+	rawAuthData, _ := attestation["authData"].([]byte)
+	statement, _ := attestation["attStmt"].(map[any]any)
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkAuthenticatorData(authData, false); err != nil {
+		return nil, err
+	}
+	if authData.credentialID == nil {
+		return nil, fmt.Errorf("%w: no attested credential", ErrVerification)
+	}
+	if len(response.RawID) > 0 && !bytes.Equal(response.RawID, authData.credentialID) {
+		return nil, fmt.Errorf("%w: credential ID does not match", ErrVerification)
+	}
+
+	key, err := parseCOSEKey(authData.publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+	switch format {
+	case "none":
+		if len(statement) != 0 {
+			return nil, fmt.Errorf("%w: none attestation with a statement", ErrVerification)
+		}
+	case "packed":
+		if err := verifyPacked(statement, key, signed); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported attestation format %q", ErrVerification, format)
+	}
+
+	return &Credential{
+		ID:             authData.credentialID,
+		PublicKey:      authData.publicKey,
+		SignCount:      authData.signCount,
+		AAGUID:         authData.aaguid,
+		Transports:     response.Response.Transports,
+		UserVerified:   authData.flags&flagUserVerified != 0,
+		BackupEligible: authData.flags&flagBackupEligible != 0,
+		BackupState:    authData.flags&flagBackupState != 0,
+	}, nil
+}
+
+func verifyPacked(statement map[any]any, key *publicKey, signed []byte) error {
+	alg, _ := statement["alg"].(int64)
+	sig, _ := statement["sig"].([]byte)
+	if len(sig) == 0 {
+		return fmt.Errorf("%w: packed attestation without a signature", ErrVerification)
+	}
+
+	if chain, ok := statement["x5c"].([]any); ok {
+		if len(chain) == 0 {
+			return fmt.Errorf("%w: empty attestation certificate chain", ErrVerification)
+		}
+		der, _ := chain[0].([]byte)
+		if err := verifyCertificate(alg, der, signed, sig); err != nil {
+			return fmt.Errorf("%w: %v", ErrVerification, err)
+		}
+		return nil
+	}
+
+	// Self attestation is signed with the credential key itself.
+	if alg != key.alg {
+		return fmt.Errorf("%w: self attestation algorithm does not match the key", ErrVerification)
+	}
+	if err := key.verify(signed, sig); err != nil {
+		return fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+	return nil
+}
+
+// Assertion is a verified authentication.
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+	BackupState  bool
+}
+
+// VerifyAuthentication checks an authentication response against the
+// challenge it was requested with and the stored public key and signature
+// counter of the credential it names. A counter that does not move forward
+// from a non-zero stored value suggests a cloned authenticator and fails.
+func (c Config) VerifyAuthentication(response *AuthenticationResponse, challenge, storedKey []byte, storedCount uint32, requireUV bool) (*Assertion, error) {
+	if response.Type != "public-key" {
+		return nil, fmt.Errorf("%w: unexpected credential type %q", ErrVerification, response.Type)
+	}
+	if err := c.verifyClientData(response.Response.ClientDataJSON, "webauthn.get", challenge); err != nil {
+		return nil, err
+	}
+
+	rawAuthData := response.Response.AuthenticatorData
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkAuthenticatorData(authData, requireUV); err != nil {
+		return nil, err
+	}
+
+	key, err := parseCOSEKey(storedKey)
+	if err != nil {
+		return nil, fmt.Errorf("stored credential key: %w", err)
+	}
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+	if err := key.verify(signed, response.Response.Signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+
+	if (authData.signCount != 0 || storedCount != 0) && authData.signCount <= storedCount {
+		return nil, fmt.Errorf("%w: signature counter went backwards", ErrVerification)
+	}
+
+	return &Assertion{
+		SignCount:    authData.signCount,
+		UserVerified: authData.flags&flagUserVerified != 0,
+		BackupState:  authData.flags&flagBackupState != 0,
+	}, nil
+}
@@ -0,0 +1,205 @@
+// Package webauthntest provides a software authenticator, so WebAuthn
+// ceremonies can be run end to end without a browser or security key.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"ramiro-uziel/vault/internal/webauthn"
+)
+
+// Authenticator flags, as set in authenticator data.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// Authenticator holds one P-256 credential and answers ceremonies the way a
+// platform authenticator would, with "none" attestation.
+type Authenticator struct {
+	// Origin is what the client data claims the ceremony ran on.
+	Origin string
+	// UserVerified sets the UV flag, as if a PIN or biometric was checked.
+	UserVerified bool
+	// SignCount is the counter, raised before each assertion is signed.
+	// Setting it back makes the authenticator look cloned.
+	SignCount uint32
+
+	rpID         string
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+}
+
+// New returns an authenticator for a relying party, running ceremonies on
+// https://rpID with user verification.
+func New(rpID string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		return nil, err
+	}
+	return &Authenticator{
+		Origin:       "https://" + rpID,
+		UserVerified: true,
+		rpID:         rpID,
+		key:          key,
+		credentialID: credentialID,
+	}, nil
+}
+
+// CredentialID is the ID of the authenticator's credential.
+func (a *Authenticator) CredentialID() []byte {
+	return a.credentialID
+}
+
+// Create answers navigator.credentials.create.
+func (a *Authenticator) Create(options *webauthn.CreationOptions) (*webauthn.RegistrationResponse, error) {
+	if options.RP.ID != a.rpID {
+		return nil, errors.New("options are for another relying party")
+	}
+	a.userHandle = options.User.ID
+
+	clientData, err := a.clientData("webauthn.create", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authenticatorData(flagAttestedData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, a.coseKey()...)
+
+	response := &webauthn.RegistrationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: a.credentialID,
+		Type:  "public-key",
+	}
+	response.Response.ClientDataJSON = clientData
+	response.Response.AttestationObject = cborMap(
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(authData),
+	)
+	response.Response.Transports = []string{"internal"}
+	return response, nil
+}
+
+// Get answers navigator.credentials.get.
+func (a *Authenticator) Get(options *webauthn.RequestOptions) (*webauthn.AuthenticationResponse, error) {
+	if options.RPID != a.rpID {
+		return nil, errors.New("options are for another relying party")
+	}
+
+	clientData, err := a.clientData("webauthn.get", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	a.SignCount++
+	authData := a.authenticatorData(0)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	response := &webauthn.AuthenticationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: a.credentialID,
+		Type:  "public-key",
+	}
+	response.Response.ClientDataJSON = clientData
+	response.Response.AuthenticatorData = authData
+	response.Response.Signature = signature
+	response.Response.UserHandle = a.userHandle
+	return response, nil
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge []byte) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+// authenticatorData starts authenticator data: the RP ID hash, flags and
+// counter.
+func (a *Authenticator) authenticatorData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	flags |= flagUserPresent
+	if a.UserVerified {
+		flags |= flagUserVerified
+	}
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
+
+// coseKey encodes the credential's public key as an EC2 COSE key.
+func (a *Authenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	return cborMap(
+		cborInt(1), cborInt(2), // kty: EC2
+		cborInt(3), cborInt(webauthn.AlgES256),
+		cborInt(-1), cborInt(1), // crv: P-256
+		cborInt(-2), cborBytes(x),
+		cborInt(-3), cborBytes(y),
+	)
+}
+
+// The CBOR encoders cover what the authenticator emits: small integers,
+// byte and text strings, and maps of already encoded keys and values.
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	}
+}
+
+func cborInt(n int64) []byte {
+	if n < 0 {
+		return cborHead(1, uint64(-1-n))
+	}
+	return cborHead(0, uint64(n))
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, uint64(len(b))), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, uint64(len(s))), s...)
+}
+
+func cborMap(pairs ...[]byte) []byte {
+	out := cborHead(5, uint64(len(pairs)/2))
+	for _, item := range pairs {
+		out = append(out, item...)
+	}
+	return out
+}
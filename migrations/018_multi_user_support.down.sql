@@ -0,0 +1,8 @@
+ALTER TABLE tracks DROP COLUMN shared_with_instance_users;
+ALTER TABLE projects DROP COLUMN shared_with_instance_users;
+
+DROP TABLE user_track_shares;
+DROP TABLE user_project_shares;
+DROP TABLE invite_tokens;
+
+ALTER TABLE users DROP COLUMN is_admin;
@@ -0,0 +1,5 @@
+DROP INDEX idx_projects_custom_order;
+ALTER TABLE projects DROP COLUMN custom_order;
+
+DROP TABLE user_shared_track_organization;
+DROP TABLE user_shared_project_organization;
@@ -0,0 +1 @@
+ALTER TABLE instance_settings DROP COLUMN session_invalidated_at;
@@ -0,0 +1,3 @@
+ALTER TABLE user_preferences DROP COLUMN gradient_spread;
+ALTER TABLE user_preferences DROP COLUMN color_spread;
+ALTER TABLE user_preferences DROP COLUMN disc_colors;
@@ -0,0 +1,3 @@
+-- Only the can_download default changed, and stored rows were kept as they
+-- were. Going back to the insecure default is not worth a table rebuild.
+SELECT 1;
@@ -0,0 +1,5 @@
+-- Token hashes cannot be turned back into tokens, so outstanding invite and
+-- reset links stop working. Their rows are kept under the old column name.
+DROP INDEX IF EXISTS idx_invite_tokens_token_hash;
+ALTER TABLE invite_tokens RENAME COLUMN token_hash TO token;
+CREATE INDEX IF NOT EXISTS idx_invite_tokens_token ON invite_tokens(token);
@@ -0,0 +1,12 @@
+-- Items still in the trash become visible again.
+DROP TABLE trashed_folder_contents;
+
+DROP INDEX idx_folders_deleted_at;
+DROP INDEX idx_track_versions_deleted_at;
+DROP INDEX idx_tracks_deleted_at;
+DROP INDEX idx_projects_deleted_at;
+
+ALTER TABLE folders DROP COLUMN deleted_at;
+ALTER TABLE track_versions DROP COLUMN deleted_at;
+ALTER TABLE tracks DROP COLUMN deleted_at;
+ALTER TABLE projects DROP COLUMN deleted_at;
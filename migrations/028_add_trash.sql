@@ -0,0 +1,24 @@
+-- Soft-delete support: trashed rows keep their data and files until purged
+ALTER TABLE projects ADD COLUMN deleted_at DATETIME;
+ALTER TABLE tracks ADD COLUMN deleted_at DATETIME;
+ALTER TABLE track_versions ADD COLUMN deleted_at DATETIME;
+ALTER TABLE folders ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_projects_deleted_at ON projects(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_tracks_deleted_at ON tracks(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_track_versions_deleted_at ON track_versions(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_folders_deleted_at ON folders(deleted_at);
+
+-- Items that were filed in a folder when it was trashed, so a restore can put
+-- them back. item_type is 'project' for owned projects, and 'shared_project' or
+-- 'shared_track' for entries of the user's shared item organization.
+CREATE TABLE trashed_folder_contents (
+    folder_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    item_type TEXT NOT NULL CHECK(item_type IN ('project', 'shared_project', 'shared_track')),
+    item_id INTEGER NOT NULL,
+    custom_order INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (folder_id, item_type, item_id),
+    FOREIGN KEY (folder_id) REFERENCES folders(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+-- Compacted sources stay FLAC in storage; describe them as such so they are
+-- still served correctly.
+UPDATE track_files
+SET format = stored_format
+WHERE compaction_status = 'compacted' AND stored_format IS NOT NULL;
+
+ALTER TABLE instance_settings DROP COLUMN source_compaction;
+
+ALTER TABLE track_files DROP COLUMN compaction_status;
+ALTER TABLE track_files DROP COLUMN pcm_hash;
+ALTER TABLE track_files DROP COLUMN original_size;
+ALTER TABLE track_files DROP COLUMN original_codec;
+ALTER TABLE track_files DROP COLUMN stored_format;
@@ -0,0 +1,11 @@
+-- Lossless compaction of uncompressed sources to FLAC. format and
+-- original_filename keep describing the upload; stored_format is the
+-- container the bytes are actually kept in once compacted.
+ALTER TABLE track_files ADD COLUMN stored_format TEXT;
+ALTER TABLE track_files ADD COLUMN original_codec TEXT;
+ALTER TABLE track_files ADD COLUMN original_size INTEGER;
+ALTER TABLE track_files ADD COLUMN pcm_hash TEXT;
+ALTER TABLE track_files ADD COLUMN compaction_status TEXT CHECK(compaction_status IN ('compacted', 'skipped', 'failed'));
+
+-- Opt-in, off by default
+ALTER TABLE instance_settings ADD COLUMN source_compaction BOOLEAN NOT NULL DEFAULT 0;
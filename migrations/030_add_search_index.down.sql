@@ -0,0 +1,17 @@
+DROP TRIGGER notes_fts_update;
+DROP TRIGGER notes_fts_delete;
+DROP TRIGGER notes_fts_insert;
+DROP TRIGGER track_versions_fts_update;
+DROP TRIGGER track_versions_fts_delete;
+DROP TRIGGER track_versions_fts_insert;
+DROP TRIGGER projects_fts_update;
+DROP TRIGGER projects_fts_delete;
+DROP TRIGGER projects_fts_insert;
+DROP TRIGGER tracks_fts_update;
+DROP TRIGGER tracks_fts_delete;
+DROP TRIGGER tracks_fts_insert;
+
+DROP TABLE notes_fts;
+DROP TABLE track_versions_fts;
+DROP TABLE projects_fts;
+DROP TABLE tracks_fts;
@@ -0,0 +1,77 @@
+-- Full-text search over tracks, projects, version names and notes. Each index
+-- reads its text from the base table (external content) and is kept in sync by
+-- the triggers below. Requires SQLite built with FTS5 (-tags sqlite_fts5).
+
+CREATE VIRTUAL TABLE tracks_fts USING fts5(
+    title, artist, album,
+    content='tracks', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2', prefix='2 3'
+);
+
+CREATE VIRTUAL TABLE projects_fts USING fts5(
+    name, description,
+    content='projects', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2', prefix='2 3'
+);
+
+CREATE VIRTUAL TABLE track_versions_fts USING fts5(
+    version_name, notes,
+    content='track_versions', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2', prefix='2 3'
+);
+
+CREATE VIRTUAL TABLE notes_fts USING fts5(
+    content, author_name,
+    content='notes', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2', prefix='2 3'
+);
+
+CREATE TRIGGER tracks_fts_insert AFTER INSERT ON tracks BEGIN
+    INSERT INTO tracks_fts(rowid, title, artist, album) VALUES (new.id, new.title, new.artist, new.album);
+END;
+CREATE TRIGGER tracks_fts_delete AFTER DELETE ON tracks BEGIN
+    INSERT INTO tracks_fts(tracks_fts, rowid, title, artist, album) VALUES ('delete', old.id, old.title, old.artist, old.album);
+END;
+CREATE TRIGGER tracks_fts_update AFTER UPDATE OF title, artist, album ON tracks BEGIN
+    INSERT INTO tracks_fts(tracks_fts, rowid, title, artist, album) VALUES ('delete', old.id, old.title, old.artist, old.album);
+    INSERT INTO tracks_fts(rowid, title, artist, album) VALUES (new.id, new.title, new.artist, new.album);
+END;
+
+CREATE TRIGGER projects_fts_insert AFTER INSERT ON projects BEGIN
+    INSERT INTO projects_fts(rowid, name, description) VALUES (new.id, new.name, new.description);
+END;
+CREATE TRIGGER projects_fts_delete AFTER DELETE ON projects BEGIN
+    INSERT INTO projects_fts(projects_fts, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+END;
+CREATE TRIGGER projects_fts_update AFTER UPDATE OF name, description ON projects BEGIN
+    INSERT INTO projects_fts(projects_fts, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+    INSERT INTO projects_fts(rowid, name, description) VALUES (new.id, new.name, new.description);
+END;
+
+CREATE TRIGGER track_versions_fts_insert AFTER INSERT ON track_versions BEGIN
+    INSERT INTO track_versions_fts(rowid, version_name, notes) VALUES (new.id, new.version_name, new.notes);
+END;
+CREATE TRIGGER track_versions_fts_delete AFTER DELETE ON track_versions BEGIN
+    INSERT INTO track_versions_fts(track_versions_fts, rowid, version_name, notes) VALUES ('delete', old.id, old.version_name, old.notes);
+END;
+CREATE TRIGGER track_versions_fts_update AFTER UPDATE OF version_name, notes ON track_versions BEGIN
+    INSERT INTO track_versions_fts(track_versions_fts, rowid, version_name, notes) VALUES ('delete', old.id, old.version_name, old.notes);
+    INSERT INTO track_versions_fts(rowid, version_name, notes) VALUES (new.id, new.version_name, new.notes);
+END;
+
+CREATE TRIGGER notes_fts_insert AFTER INSERT ON notes BEGIN
+    INSERT INTO notes_fts(rowid, content, author_name) VALUES (new.id, new.content, new.author_name);
+END;
+CREATE TRIGGER notes_fts_delete AFTER DELETE ON notes BEGIN
+    INSERT INTO notes_fts(notes_fts, rowid, content, author_name) VALUES ('delete', old.id, old.content, old.author_name);
+END;
+CREATE TRIGGER notes_fts_update AFTER UPDATE OF content, author_name ON notes BEGIN
+    INSERT INTO notes_fts(notes_fts, rowid, content, author_name) VALUES ('delete', old.id, old.content, old.author_name);
+    INSERT INTO notes_fts(rowid, content, author_name) VALUES (new.id, new.content, new.author_name);
+END;
+
+-- Index what is already there.
+INSERT INTO tracks_fts(tracks_fts) VALUES ('rebuild');
+INSERT INTO projects_fts(projects_fts) VALUES ('rebuild');
+INSERT INTO track_versions_fts(track_versions_fts) VALUES ('rebuild');
+INSERT INTO notes_fts(notes_fts) VALUES ('rebuild');
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_track_versions_duration;
+DROP INDEX IF EXISTS idx_tracks_updated_at;
+DROP INDEX IF EXISTS idx_tracks_created_at;
+DROP INDEX IF EXISTS idx_tracks_key;
+DROP INDEX IF EXISTS idx_tracks_bpm;
@@ -0,0 +1,6 @@
+-- Indexes for the structured filters and sort orders of track search
+CREATE INDEX IF NOT EXISTS idx_tracks_bpm ON tracks(bpm);
+CREATE INDEX IF NOT EXISTS idx_tracks_key ON tracks(key COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS idx_tracks_created_at ON tracks(created_at);
+CREATE INDEX IF NOT EXISTS idx_tracks_updated_at ON tracks(updated_at);
+CREATE INDEX IF NOT EXISTS idx_track_versions_duration ON track_versions(duration_seconds);
@@ -0,0 +1,2 @@
+-- Smart folders become empty regular folders.
+ALTER TABLE folders DROP COLUMN smart_query;
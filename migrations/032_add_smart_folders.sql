@@ -0,0 +1,3 @@
+-- Smart folders store a track search instead of holding projects. smart_query
+-- is the JSON form of the search filters; regular folders leave it NULL.
+ALTER TABLE folders ADD COLUMN smart_query TEXT;
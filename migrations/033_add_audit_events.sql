@@ -0,0 +1,27 @@
+-- Append-only record of security-relevant and destructive actions. Actors are
+-- not foreign keys so events outlive the users they name; actor_id is NULL for
+-- actions the server takes on its own, such as retention purges. before_summary
+-- and after_summary are JSON.
+CREATE TABLE audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor_id INTEGER,
+    actor_username TEXT,
+    ip TEXT,
+    user_agent TEXT,
+    action TEXT NOT NULL,
+    target_type TEXT,
+    target_id TEXT,
+    before_summary TEXT,
+    after_summary TEXT
+);
+
+CREATE INDEX idx_audit_events_created_at ON audit_events(created_at);
+CREATE INDEX idx_audit_events_actor ON audit_events(actor_id, id);
+CREATE INDEX idx_audit_events_action ON audit_events(action, id);
+CREATE INDEX idx_audit_events_target ON audit_events(target_type, target_id, id);
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
@@ -0,0 +1,15 @@
+-- One row per backup attempt, so the admin API can report the last run and
+-- the last success across restarts. status is running, succeeded or failed.
+CREATE TABLE backup_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME,
+    trigger TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'running',
+    destination TEXT NOT NULL,
+    name TEXT,
+    size_bytes INTEGER,
+    error TEXT
+);
+
+CREATE INDEX idx_backup_runs_status ON backup_runs(status, id);
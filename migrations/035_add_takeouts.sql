@@ -0,0 +1,18 @@
+-- One row per personal data takeout a user asked for. status is building,
+-- ready or failed; a ready takeout's archive is kept under DATA_DIR/takeouts
+-- until expires_at.
+CREATE TABLE takeouts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'building',
+    created_at DATETIME NOT NULL,
+    finished_at DATETIME,
+    expires_at DATETIME,
+    file_name TEXT,
+    size_bytes INTEGER,
+    error TEXT,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_takeouts_user ON takeouts(user_id, id);
+CREATE INDEX idx_takeouts_expires_at ON takeouts(expires_at);
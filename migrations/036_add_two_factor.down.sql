@@ -0,0 +1,5 @@
+ALTER TABLE instance_settings DROP COLUMN require_two_factor;
+
+DROP TABLE IF EXISTS login_challenges;
+DROP TABLE IF EXISTS user_recovery_codes;
+DROP TABLE IF EXISTS user_totp;
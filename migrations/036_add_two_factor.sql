@@ -0,0 +1,41 @@
+-- TOTP second factor. secret is set once enrollment is confirmed;
+-- pending_secret holds a secret being enrolled until its first code is
+-- confirmed, so re-enrolling does not disable the current one. last_step is
+-- the last time step a code was accepted for, so a code works only once.
+CREATE TABLE user_totp (
+    user_id INTEGER PRIMARY KEY,
+    secret TEXT,
+    pending_secret TEXT,
+    enabled_at DATETIME,
+    last_step INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- One-time recovery codes, stored hashed like other tokens.
+CREATE TABLE user_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash TEXT NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_recovery_codes_user ON user_recovery_codes(user_id);
+
+-- Short-lived challenges between the password step of a login and its
+-- second factor.
+CREATE TABLE login_challenges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_login_challenges_expires_at ON login_challenges(expires_at);
+
+ALTER TABLE instance_settings ADD COLUMN require_two_factor BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,35 @@
+-- WebAuthn credentials. credential_id is the base64url credential ID the
+-- authenticator reports; public_key is its COSE encoding. sign_count is the
+-- last signature counter seen, to notice cloned authenticators.
+CREATE TABLE passkeys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    credential_id TEXT NOT NULL UNIQUE,
+    public_key BLOB NOT NULL,
+    sign_count INTEGER NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    aaguid TEXT NOT NULL DEFAULT '',
+    transports TEXT NOT NULL DEFAULT '',
+    backup_eligible BOOLEAN NOT NULL DEFAULT 0,
+    backup_state BOOLEAN NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_passkeys_user ON passkeys(user_id);
+
+-- Outstanding WebAuthn challenges, each good for one ceremony. user_id is
+-- NULL for a passwordless login, where the user is not known until the
+-- authenticator answers.
+CREATE TABLE passkey_challenges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    challenge TEXT NOT NULL UNIQUE,
+    ceremony TEXT NOT NULL CHECK (ceremony IN ('registration', 'login')),
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_passkey_challenges_expires_at ON passkey_challenges(expires_at);
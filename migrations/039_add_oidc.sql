@@ -0,0 +1,32 @@
+-- Identities at an OpenID provider, linked to local users. A user can have
+-- several; an identity belongs to one user.
+CREATE TABLE user_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_login_at DATETIME,
+    UNIQUE (issuer, subject),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_identities_user ON user_identities(user_id);
+
+-- Logins in flight at the provider, keyed by the hash of their state
+-- parameter. link_user_id is set when a logged-in user is linking an
+-- identity rather than logging in.
+CREATE TABLE oidc_logins (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    state_hash TEXT NOT NULL UNIQUE,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    invite_token_hash TEXT,
+    link_user_id INTEGER,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (link_user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_oidc_logins_expires_at ON oidc_logins(expires_at);
@@ -0,0 +1,12 @@
+-- Failed password attempts per account (subject is the lowercased username)
+-- and per password-protected share link (subject is "track:<id>" or
+-- "project:<id>"). No attempt is allowed before blocked_until.
+CREATE TABLE auth_lockouts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    scope TEXT NOT NULL CHECK (scope IN ('account', 'share')),
+    subject TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    last_failure_at DATETIME NOT NULL,
+    blocked_until DATETIME,
+    UNIQUE (scope, subject)
+);
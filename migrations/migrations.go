@@ -0,0 +1,9 @@
+// Package migrations holds the SQLite schema. Each NNN_name.sql script is
+// applied once, in order; a matching NNN_name.down.sql reverts it. Migrations
+// without a down script can only be undone by restoring a snapshot.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS